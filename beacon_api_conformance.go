@@ -0,0 +1,301 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"encoding/json"
+	"eth-testnet-tool/consensus_client"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ConformanceEndpoints the beacon api endpoints compared by default, {state} and {block} are replaced with the state id
+var ConformanceEndpoints = []string{
+	"/eth/v1/config/spec",
+	"/eth/v1/config/fork_schedule",
+	"/eth/v1/config/deposit_contract",
+	"/eth/v1/beacon/genesis",
+	"/eth/v1/beacon/states/{state}/fork",
+	"/eth/v1/beacon/states/{state}/finality_checkpoints",
+	"/eth/v1/beacon/states/{state}/validators",
+	"/eth/v1/beacon/states/{state}/validator_balances",
+	"/eth/v1/beacon/headers/{block}",
+}
+
+// missingField is the value reported for a client whose response doesn't have the field
+const missingField = "<missing>"
+
+// ConformanceOpts configures a conformance check
+type ConformanceOpts struct {
+	// StateID the state (and block) id every client is queried at, defaults to finalized so every client has the same view
+	StateID string
+	// Endpoints the endpoints to compare, defaults to ConformanceEndpoints
+	Endpoints []string
+	// IgnoreMissing don't report fields only some clients have, e.g. client specific spec values
+	IgnoreMissing bool
+}
+
+// FieldDifference a field the clients don't agree on
+type FieldDifference struct {
+	Endpoint string `json:"endpoint"`
+	// Path the path of the field in the data of the response, e.g. /12/validator/slashed
+	Path string `json:"path"`
+	// Values the normalised value each client returned, keyed by client name
+	Values map[string]string `json:"values"`
+}
+
+func (d *FieldDifference) String() string {
+	var values []string
+	for _, client := range sortedKeys(d.Values) {
+		values = append(values, fmt.Sprintf("%s=%s", client, d.Values[client]))
+	}
+	return fmt.Sprintf("%s %s: %s", d.Endpoint, d.Path, strings.Join(values, ", "))
+}
+
+// EndpointError a client failed to answer an endpoint, its response is left out of the comparison
+type EndpointError struct {
+	Endpoint string `json:"endpoint"`
+	Client   string `json:"client"`
+	Err      string `json:"error"`
+}
+
+// ConformanceReport the result of comparing the beacon api responses of every client
+type ConformanceReport struct {
+	StateID     string             `json:"state_id"`
+	Clients     []string           `json:"clients"`
+	Differences []*FieldDifference `json:"differences"`
+	Errors      []*EndpointError   `json:"errors"`
+}
+
+// Conformant returns true if every client answered every endpoint the same way
+func (r *ConformanceReport) Conformant() bool {
+	return len(r.Differences) == 0 && len(r.Errors) == 0
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *ConformanceReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// CheckBeaconAPIConformance calls the same beacon api endpoints on every consensus client and reports the fields they disagree on.
+// Responses are normalised first so only differences in content are reported, not in formatting.
+func (c *ClientManager) CheckBeaconAPIConformance(opts ConformanceOpts) *ConformanceReport {
+	return c.CheckBeaconAPIConformanceContext(context.Background(), opts)
+}
+
+// CheckBeaconAPIConformanceContext is CheckBeaconAPIConformance with the requests cancelled with ctx
+func (c *ClientManager) CheckBeaconAPIConformanceContext(ctx context.Context, opts ConformanceOpts) *ConformanceReport {
+	if opts.StateID == "" {
+		opts.StateID = "finalized"
+	}
+	if len(opts.Endpoints) == 0 {
+		opts.Endpoints = ConformanceEndpoints
+	}
+	report := &ConformanceReport{
+		StateID:     opts.StateID,
+		Differences: []*FieldDifference{},
+		Errors:      []*EndpointError{},
+	}
+	for name := range c.ConsensusClients {
+		report.Clients = append(report.Clients, name)
+	}
+	sort.Strings(report.Clients)
+
+	for _, endpointTemplate := range opts.Endpoints {
+		endpoint := strings.NewReplacer("{state}", opts.StateID, "{block}", opts.StateID).Replace(endpointTemplate)
+		responses := c.fetchFromAllClients(ctx, endpoint)
+		fields := make(map[string]map[string]string)
+		for _, client := range report.Clients {
+			response := responses[client]
+			if response.err != nil {
+				report.Errors = append(report.Errors, &EndpointError{Endpoint: endpoint, Client: client, Err: response.err.Error()})
+				continue
+			}
+			normalised, err := normaliseJSON(response.data)
+			if err != nil {
+				report.Errors = append(report.Errors, &EndpointError{Endpoint: endpoint, Client: client, Err: err.Error()})
+				continue
+			}
+			fields[client] = flattenJSON(normalised)
+		}
+		report.Differences = append(report.Differences, diffFields(endpoint, fields, opts.IgnoreMissing)...)
+	}
+	return report
+}
+
+type rawResponse struct {
+	data interface{}
+	err  error
+}
+
+func (c *ClientManager) fetchFromAllClients(ctx context.Context, endpoint string) map[string]rawResponse {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	responses := make(map[string]rawResponse)
+	for name, client := range c.ConsensusClients {
+		wg.Add(1)
+		go func(name string, client *consensus_client.ConsensusClient) {
+			defer wg.Done()
+			data, err := client.GetRawDataContext(ctx, endpoint)
+			lock.Lock()
+			defer lock.Unlock()
+			responses[name] = rawResponse{data: data, err: err}
+		}(name, client)
+	}
+	wg.Wait()
+	return responses
+}
+
+// diffFields compares the flattened responses of the clients field by field
+func diffFields(endpoint string, fields map[string]map[string]string, ignoreMissing bool) []*FieldDifference {
+	paths := make(map[string]bool)
+	for _, clientFields := range fields {
+		for path := range clientFields {
+			paths[path] = true
+		}
+	}
+	var differences []*FieldDifference
+	for _, path := range sortedKeys(paths) {
+		values := make(map[string]string)
+		distinct := make(map[string]bool)
+		for client, clientFields := range fields {
+			value, ok := clientFields[path]
+			if !ok {
+				if ignoreMissing {
+					continue
+				}
+				value = missingField
+			}
+			values[client] = value
+			distinct[value] = true
+		}
+		if len(distinct) > 1 {
+			differences = append(differences, &FieldDifference{Endpoint: endpoint, Path: path, Values: values})
+		}
+	}
+	return differences
+}
+
+// normaliseJSON removes the formatting differences between clients:
+// numbers become decimal strings, hex is lower cased and lists of objects with a unique index are keyed by it.
+// JSON numbers that aren't plain decimal integers are an error, the beacon api never returns them.
+func normaliseJSON(data interface{}) (interface{}, error) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		normalised := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			n, err := normaliseJSON(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "field %s", key)
+			}
+			normalised[key] = n
+		}
+		return normalised, nil
+	case []interface{}:
+		normalised := make([]interface{}, len(v))
+		for i, value := range v {
+			n, err := normaliseJSON(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "item %d", i)
+			}
+			normalised[i] = n
+		}
+		if keyed, ok := keyByIndex(normalised); ok {
+			return keyed, nil
+		}
+		return normalised, nil
+	case json.Number:
+		return normaliseNumber(string(v))
+	case float64:
+		return normaliseNumber(strconv.FormatFloat(v, 'f', -1, 64))
+	case string:
+		if strings.HasPrefix(v, "0x") || strings.HasPrefix(v, "0X") {
+			return strings.ToLower(v), nil
+		}
+		// quoted integers are normalised, any other string is compared as is
+		if n, err := normaliseNumber(v); err == nil {
+			return n, nil
+		}
+		return v, nil
+	case bool:
+		return fmt.Sprintf("%t", v), nil
+	case nil:
+		return "null", nil
+	}
+	return fmt.Sprintf("%v", data), nil
+}
+
+// normaliseNumber returns a plain decimal integer without leading zeros, anything else is an error
+func normaliseNumber(value string) (string, error) {
+	digits := strings.TrimPrefix(value, "-")
+	if digits == "" || strings.TrimLeft(digits, "0123456789") != "" {
+		return "", errors.Errorf("%q is not a decimal integer", value)
+	}
+	n, _ := new(big.Int).SetString(value, 10)
+	return n.String(), nil
+}
+
+// keyByIndex turns a list of objects that each have a unique index field into a map keyed by the index
+func keyByIndex(list []interface{}) (map[string]interface{}, bool) {
+	if len(list) == 0 {
+		return nil, false
+	}
+	keyed := make(map[string]interface{}, len(list))
+	for _, item := range list {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		index, ok := object["index"].(string)
+		if !ok {
+			return nil, false
+		}
+		if _, duplicate := keyed[index]; duplicate {
+			return nil, false
+		}
+		keyed[index] = object
+	}
+	return keyed, true
+}
+
+// flattenJSON returns every leaf of the normalised JSON by its path
+func flattenJSON(data interface{}) map[string]string {
+	fields := make(map[string]string)
+	var flatten func(path string, data interface{})
+	flatten = func(path string, data interface{}) {
+		switch v := data.(type) {
+		case map[string]interface{}:
+			for key, value := range v {
+				flatten(path+"/"+key, value)
+			}
+		case []interface{}:
+			fields[path+"/length"] = fmt.Sprintf("%d", len(v))
+			for i, value := range v {
+				flatten(fmt.Sprintf("%s/%d", path, i), value)
+			}
+		default:
+			if path == "" {
+				path = "/"
+			}
+			fields[path] = fmt.Sprintf("%v", v)
+		}
+	}
+	flatten("", data)
+	return fields
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,91 @@
+package eth_testnet_tool
+
+import (
+	"bytes"
+	"encoding/json"
+	"eth-testnet-tool/consensus_client"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestCheckBeaconAPIConformance_Mock(t *testing.T) {
+	validators, err := getTestValidators(GenesisValidatorCount)
+	require.NoError(t, err)
+	manager := &ClientManager{ConsensusClients: make(map[string]*consensus_client.ConsensusClient)}
+	for _, name := range []string{"lighthouse", "prysm", "teku"} {
+		state := consensus_client.NewMockBeaconState(validators)
+		switch name {
+		case "prysm":
+			state.Validators[3].Balance = 31_000_000_000
+			state.Spec["PRYSM_ONLY_VALUE"] = "1"
+		case "teku":
+			state.Spec["SECONDS_PER_SLOT"] = "12"
+		}
+		node := consensus_client.NewMockBeaconNode(state)
+		t.Cleanup(node.Close)
+		client, err := node.ConsensusClient(name)
+		require.NoError(t, err)
+		manager.ConsensusClients[name] = client
+	}
+
+	report := manager.CheckBeaconAPIConformance(ConformanceOpts{})
+	require.False(t, report.Conformant())
+	require.Equal(t, []string{"lighthouse", "prysm", "teku"}, report.Clients)
+	require.Empty(t, report.Errors)
+
+	differences := make(map[string]*FieldDifference)
+	for _, difference := range report.Differences {
+		differences[difference.Endpoint+" "+difference.Path] = difference
+	}
+	require.Len(t, differences, 4, report.Differences)
+	require.Equal(t, map[string]string{"lighthouse": "6", "prysm": "6", "teku": "12"}, differences["/eth/v1/config/spec /SECONDS_PER_SLOT"].Values)
+	require.Equal(t, missingField, differences["/eth/v1/config/spec /PRYSM_ONLY_VALUE"].Values["teku"])
+	require.Equal(t, "31000000000", differences["/eth/v1/beacon/states/finalized/validators /3/balance"].Values["prysm"])
+	require.Equal(t, "32000000000", differences["/eth/v1/beacon/states/finalized/validator_balances /3/balance"].Values["teku"])
+
+	report = manager.CheckBeaconAPIConformance(ConformanceOpts{Endpoints: []string{"/eth/v1/config/spec", "/eth/v1/not/an/endpoint"}, IgnoreMissing: true})
+	require.Len(t, report.Differences, 1)
+	require.Len(t, report.Errors, 3)
+
+	var output bytes.Buffer
+	require.NoError(t, report.WriteJSON(&output))
+	require.True(t, json.Valid(output.Bytes()))
+}
+
+func TestNormaliseJSON(t *testing.T) {
+	decode := func(data string) interface{} {
+		decoder := json.NewDecoder(strings.NewReader(data))
+		decoder.UseNumber()
+		var generic interface{}
+		require.NoError(t, decoder.Decode(&generic))
+		return generic
+	}
+	normalise := func(data string) map[string]string {
+		normalised, err := normaliseJSON(decode(data))
+		require.NoError(t, err)
+		return flattenJSON(normalised)
+	}
+	// the same validators, ordered differently with numbers instead of strings and upper case hex
+	quoted := normalise(`[{"index":"1","balance":"32000000000","validator":{"pubkey":"0xabcd","slashed":false}},{"index":"0","balance":"0","validator":{"pubkey":"0x1234","slashed":true}}]`)
+	unquoted := normalise(`[{"index":0,"balance":0,"validator":{"pubkey":"0x1234","slashed":true}},{"index":1,"balance":32000000000,"validator":{"pubkey":"0xABCD","slashed":false}}]`)
+	require.Equal(t, quoted, unquoted)
+	require.Equal(t, "0xabcd", quoted["/1/validator/pubkey"])
+
+	// lists without a unique index keep their order
+	require.Equal(t, map[string]string{"/length": "2", "/0/epoch": "1", "/1/epoch": "0"}, normalise(`[{"epoch":"1"},{"epoch":0}]`))
+
+	// strings that only look like numbers are compared as is
+	require.Equal(t, map[string]string{"/a": "1_000", "/b": "inf", "/c": "0x1p-2", "/d": "1.5", "/e": "7"}, normalise(`{"a":"1_000","b":"inf","c":"0x1P-2","d":"1.5","e":"007"}`))
+
+	// numbers that aren't plain integers are reported
+	for _, data := range []string{`{"a":1.5}`, `{"a":1e3}`, `[{"a":-0.1}]`} {
+		_, err := normaliseJSON(decode(data))
+		require.Error(t, err, data)
+	}
+	_, err := normaliseNumber("1_000")
+	require.Error(t, err)
+	n, err := normaliseNumber("-0042")
+	require.NoError(t, err)
+	require.Equal(t, "-42", n)
+}
@@ -0,0 +1,268 @@
+package eth_testnet_tool
+
+import (
+	"bytes"
+	"context"
+	"eth-testnet-tool/consensus_client"
+	"eth-testnet-tool/validator"
+	"fmt"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"runtime"
+	"sync"
+)
+
+// DefaultBulkChunkSize the number of operations submitted per request to endpoints that accept a list
+const DefaultBulkChunkSize = 256
+
+// ErrBulkOperationFailed is returned by BulkReport.Err when the operation failed for some of the validators
+var ErrBulkOperationFailed = errors.New("bulk operation failed for some validators")
+
+// BulkOpts configures a bulk exit or bls to execution change
+type BulkOpts struct {
+	// StateID the state the validators are resolved at, defaults to head
+	StateID string
+	// Workers the number of validators signed in parallel, defaults to the number of CPUs
+	Workers int
+	// ChunkSize the number of operations per submission where the endpoint accepts a list, defaults to DefaultBulkChunkSize
+	ChunkSize int
+	// DryRun only builds and signs the operations without submitting them
+	DryRun bool
+}
+
+// ValidatorSelector picks the validators of a bulk operation, clientView is nil for validators that aren't in the beacon state
+type ValidatorSelector func(validator *validator.Validator, clientView *v1.Validator) bool
+
+// ValidatorIndexRange selects the validators with a beacon state index in [from, to)
+func ValidatorIndexRange(from phase0.ValidatorIndex, to phase0.ValidatorIndex) ValidatorSelector {
+	return func(_ *validator.Validator, clientView *v1.Validator) bool {
+		return clientView != nil && clientView.Index >= from && clientView.Index < to
+	}
+}
+
+// MnemonicAccountRange selects the validators derived from the mnemonic accounts [from, to)
+func MnemonicAccountRange(from uint64, to uint64) ValidatorSelector {
+	return func(v *validator.Validator, _ *v1.Validator) bool {
+		return v.ValidatorIndex >= from && v.ValidatorIndex < to
+	}
+}
+
+// BulkOutcome the result of a bulk operation for a single validator
+type BulkOutcome struct {
+	Validator *validator.Validator
+	// ClientView the validator as seen by the client, nil if it isn't in the beacon state
+	ClientView *v1.Validator
+	// Exit or Change is the signed operation, depending on the kind of bulk operation
+	Exit      *phase0.SignedVoluntaryExit
+	Change    *capella.SignedBLSToExecutionChange
+	Submitted bool
+	Err       error
+}
+
+// BulkReport the outcome of a bulk operation for every selected validator, in the order the validators were given
+type BulkReport struct {
+	Outcomes []*BulkOutcome
+}
+
+// Succeeded returns the outcomes without an error
+func (r *BulkReport) Succeeded() []*BulkOutcome {
+	var succeeded []*BulkOutcome
+	for _, outcome := range r.Outcomes {
+		if outcome.Err == nil {
+			succeeded = append(succeeded, outcome)
+		}
+	}
+	return succeeded
+}
+
+// Failed returns the outcomes with an error
+func (r *BulkReport) Failed() []*BulkOutcome {
+	var failed []*BulkOutcome
+	for _, outcome := range r.Outcomes {
+		if outcome.Err != nil {
+			failed = append(failed, outcome)
+		}
+	}
+	return failed
+}
+
+// Err returns ErrBulkOperationFailed with the first failure, or nil if every validator succeeded
+func (r *BulkReport) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	return errors.Wrapf(ErrBulkOperationFailed, "%d of %d failed, first %s: %v", len(failed), len(r.Outcomes), failed[0].Validator, failed[0].Err)
+}
+
+// BulkVoluntaryExitsFromClientViewContext builds, signs and submits voluntary exits for every selected validator.
+// All validators are resolved with a single validators query and the exits are signed and submitted on a worker pool,
+// the voluntary exit endpoint only takes a single exit per request. A nil selector selects every validator.
+func BulkVoluntaryExitsFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, validators []*validator.Validator, selector ValidatorSelector, epoch phase0.Epoch, opts BulkOpts) (*BulkReport, error) {
+	shardCommitteePeriod, err := consensusClient.GetShardCommitteePeriodContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get shard committee period to check for valid exit epoch")
+	}
+	if uint64(epoch) < shardCommitteePeriod {
+		return nil, errors.New("can not submit an exit before the SHARD_COMMITTEE_PERIOD")
+	}
+	report, err := resolveBulkValidators(ctx, consensusClient, validators, selector, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	runBulkWorkers(ctx, report.Outcomes, opts, func(outcome *BulkOutcome) error {
+		exit, err := SignVoluntaryExitWithValidatorContext(ctx, consensusClient, outcome.Validator, &phase0.VoluntaryExit{
+			Epoch:          epoch,
+			ValidatorIndex: outcome.ClientView.Index,
+		})
+		if err != nil {
+			return err
+		}
+		outcome.Exit = exit
+		if opts.DryRun {
+			return nil
+		}
+		if err := consensusClient.SubmitValidatorExitContext(ctx, exit); err != nil {
+			return errors.Wrapf(err, "client %s rejected the exit", consensusClient.Name)
+		}
+		outcome.Submitted = true
+		return nil
+	})
+	return report, nil
+}
+
+// BulkBLSToExecutionChangesFromClientViewContext builds and signs bls to execution changes to the address for every selected validator
+// and submits them in chunks of ChunkSize. All validators are resolved with a single validators query and signed on a worker pool,
+// validators that already have execution withdrawal credentials fail without a change. A nil selector selects every validator.
+func BulkBLSToExecutionChangesFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, validators []*validator.Validator, selector ValidatorSelector, address bellatrix.ExecutionAddress, opts BulkOpts) (*BulkReport, error) {
+	report, err := resolveBulkValidators(ctx, consensusClient, validators, selector, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	runBulkWorkers(ctx, report.Outcomes, opts, func(outcome *BulkOutcome) error {
+		credentials := outcome.ClientView.Validator.WithdrawalCredentials
+		if len(credentials) == 0 || credentials[0] != BLSWithdrawalPrefix {
+			return errors.New("validator already has execution withdrawal credentials")
+		}
+		if !bytes.Equal(credentials, BLSWithdrawalCredentials(outcome.Validator)) {
+			return errors.New("withdrawal credentials don't match the validators withdrawal key")
+		}
+		change, err := SignBLSToExecutionChangeWithValidatorContext(ctx, consensusClient, outcome.Validator, &capella.BLSToExecutionChange{
+			ValidatorIndex:     outcome.ClientView.Index,
+			FromBLSPubkey:      outcome.Validator.WithdrawalPublicKey,
+			ToExecutionAddress: address,
+		})
+		if err != nil {
+			return err
+		}
+		outcome.Change = change
+		return nil
+	})
+	if opts.DryRun {
+		return report, nil
+	}
+
+	var signed []*BulkOutcome
+	for _, outcome := range report.Outcomes {
+		if outcome.Err == nil {
+			signed = append(signed, outcome)
+		}
+	}
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultBulkChunkSize
+	}
+	for start := 0; start < len(signed); start += chunkSize {
+		chunk := signed[start:min(start+chunkSize, len(signed))]
+		changes := make([]*capella.SignedBLSToExecutionChange, len(chunk))
+		for i, outcome := range chunk {
+			changes[i] = outcome.Change
+		}
+		submitBulkChunk(consensusClient, chunk, consensusClient.SubmitBLSToExecutionChangesContext(ctx, changes))
+	}
+	return report, nil
+}
+
+// resolveBulkValidators looks up all validators in a single query and returns an outcome for every selected one
+func resolveBulkValidators(ctx context.Context, consensusClient *consensus_client.ConsensusClient, validators []*validator.Validator, selector ValidatorSelector, opts BulkOpts) (*BulkReport, error) {
+	stateID := opts.StateID
+	if stateID == "" {
+		stateID = "head"
+	}
+	clientValidators, err := consensusClient.GetAllValidatorsContext(ctx, stateID)
+	if err != nil {
+		return nil, err
+	}
+	byPubKey := make(map[phase0.BLSPubKey]*v1.Validator, len(clientValidators))
+	for _, clientValidator := range clientValidators {
+		byPubKey[clientValidator.Validator.PublicKey] = clientValidator
+	}
+
+	report := &BulkReport{}
+	for _, v := range validators {
+		clientView := byPubKey[v.ValidatorPublicKey]
+		if selector != nil && !selector(v, clientView) {
+			continue
+		}
+		outcome := &BulkOutcome{Validator: v, ClientView: clientView}
+		if clientView == nil {
+			outcome.Err = fmt.Errorf("validator is not in the beacon state of client %s", consensusClient.Name)
+		}
+		report.Outcomes = append(report.Outcomes, outcome)
+	}
+	return report, nil
+}
+
+// runBulkWorkers runs the work for every outcome without an error on opts.Workers goroutines and records the errors
+func runBulkWorkers(ctx context.Context, outcomes []*BulkOutcome, opts BulkOpts, work func(outcome *BulkOutcome) error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	queue := make(chan *BulkOutcome)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for outcome := range queue {
+				if err := ctx.Err(); err != nil {
+					outcome.Err = err
+					continue
+				}
+				outcome.Err = work(outcome)
+			}
+		}()
+	}
+	for _, outcome := range outcomes {
+		if outcome.Err == nil {
+			queue <- outcome
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// submitBulkChunk records the result of submitting a chunk, if the client says which items it rejected the rest are accepted
+func submitBulkChunk(consensusClient *consensus_client.ConsensusClient, chunk []*BulkOutcome, err error) {
+	failures := consensus_client.SubmissionFailures(err)
+	for i, outcome := range chunk {
+		switch {
+		case err == nil:
+			outcome.Submitted = true
+		case failures == nil:
+			outcome.Err = errors.Wrapf(err, "client %s rejected the submission", consensusClient.Name)
+		default:
+			if message, rejected := failures[i]; rejected {
+				outcome.Err = fmt.Errorf("client %s rejected the operation: %s", consensusClient.Name, message)
+				continue
+			}
+			outcome.Submitted = true
+		}
+	}
+}
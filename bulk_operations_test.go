@@ -0,0 +1,86 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"eth-testnet-tool/consensus_client"
+	"eth-testnet-tool/validator"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBulkVoluntaryExitsFromClientView_Mock(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.RejectValidators = map[phase0.ValidatorIndex]bool{7: true}
+	})
+
+	report, err := BulkVoluntaryExitsFromClientViewContext(context.Background(), client, validators, ValidatorIndexRange(5, 15), 0, BulkOpts{Workers: 4})
+	require.NoError(t, err)
+	require.Len(t, report.Outcomes, 10)
+	require.Len(t, report.Succeeded(), 9)
+	require.Len(t, report.Failed(), 1)
+	require.Equal(t, phase0.ValidatorIndex(7), report.Failed()[0].ClientView.Index)
+	require.False(t, report.Failed()[0].Submitted)
+	require.ErrorIs(t, report.Err(), ErrBulkOperationFailed)
+	require.Len(t, node.Submissions().VoluntaryExits, 9)
+
+	outcome := report.Outcomes[0]
+	require.True(t, outcome.Submitted)
+	root, err := outcome.Exit.Message.HashTreeRoot()
+	require.NoError(t, err)
+	requireValidMockSignature(t, common.BLSDomainType{0x04}, mockCapellaForkVersion, root, outcome.Exit.Signature, outcome.Validator.ValidatorKey.PublicKey())
+}
+
+func TestBulkBLSToExecutionChangesFromClientView_Mock(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	address := bellatrix.ExecutionAddress{0x69}
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.RejectValidators = map[phase0.ValidatorIndex]bool{4: true}
+		state.Validators[2].Validator.WithdrawalCredentials = ExecutionWithdrawalCredentials(address)
+	})
+	requests := node.Requests("/eth/v1/beacon/pool/bls_to_execution_changes")
+
+	report, err := BulkBLSToExecutionChangesFromClientViewContext(context.Background(), client, validators, MnemonicAccountRange(0, 10), address, BulkOpts{ChunkSize: 3})
+	require.NoError(t, err)
+	require.Len(t, report.Outcomes, 10)
+	require.Len(t, report.Failed(), 2)
+	require.Nil(t, report.Outcomes[2].Change)
+	require.Error(t, report.Outcomes[2].Err)
+	require.NotNil(t, report.Outcomes[4].Change)
+	require.False(t, report.Outcomes[4].Submitted)
+	require.Error(t, report.Outcomes[4].Err)
+	// the 9 signed changes are submitted in chunks of 3, only the rejected one is missing from the pool
+	require.Equal(t, requests+3, node.Requests("/eth/v1/beacon/pool/bls_to_execution_changes"))
+	require.Len(t, node.Submissions().BLSToExecutionChanges, 8)
+
+	outcome := report.Outcomes[0]
+	require.True(t, outcome.Submitted)
+	root, err := outcome.Change.Message.HashTreeRoot()
+	require.NoError(t, err)
+	requireValidMockSignature(t, common.BLSDomainType{0x0a}, mockGenesisForkVersion, root, outcome.Change.Signature, outcome.Validator.WithdrawalKey.PublicKey())
+}
+
+func TestBulkOperations_MockDryRunAndCancel(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+
+	report, err := BulkBLSToExecutionChangesFromClientViewContext(context.Background(), client, validators, nil, bellatrix.ExecutionAddress{0x01}, BulkOpts{DryRun: true})
+	require.NoError(t, err)
+	require.NoError(t, report.Err())
+	require.Len(t, report.Outcomes, len(validators))
+	require.Empty(t, node.Submissions().BLSToExecutionChanges)
+
+	// the validators are resolved before the context is cancelled, so every exit fails with the cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	report, err = BulkVoluntaryExitsFromClientViewContext(ctx, client, validators, func(v *validator.Validator, _ *v1.Validator) bool {
+		cancel()
+		return true
+	}, 0, BulkOpts{})
+	require.NoError(t, err)
+	require.Len(t, report.Failed(), len(validators))
+	require.ErrorIs(t, report.Outcomes[0].Err, context.Canceled)
+	require.Empty(t, node.Submissions().VoluntaryExits)
+}
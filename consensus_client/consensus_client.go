@@ -1,20 +1,33 @@
 package consensus_client
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/attestantio/go-eth2-client/api"
 	v1 "github.com/attestantio/go-eth2-client/api/v1"
 	eth2client "github.com/attestantio/go-eth2-client/http"
+	"github.com/attestantio/go-eth2-client/spec"
 	"github.com/attestantio/go-eth2-client/spec/capella"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
 )
 
+// ConsensusClient wraps the beacon api of a single client.
+// Every network call has a Context variant passing deadlines and cancellation through to the BeaconService,
+// the variants without a context are kept for compatibility.
 type ConsensusClient struct {
 	Name          string
 	BeaconAPI     string
 	BeaconService *eth2client.Service
+
+	specCacheOnce sync.Once
+	specCache     *SpecCache
 }
 
 func (c *ConsensusClient) String() string {
@@ -23,94 +36,160 @@ func (c *ConsensusClient) String() string {
 
 // Information about spec
 
-// GetDomainTypeFromSpec returns the domain type from the clients spec
-func (c *ConsensusClient) GetDomainTypeFromSpec(domainTypeString string) (phase0.DomainType, error) {
-	spec, err := c.BeaconService.Spec(context.Background())
+// GetDomainTypeFromSpecContext returns the domain type from the clients spec
+func (c *ConsensusClient) GetDomainTypeFromSpecContext(ctx context.Context, domainTypeString string) (phase0.DomainType, error) {
+	return c.SpecCache().DomainType(ctx, domainTypeString)
+}
+
+// GetForkVersionFromSpecContext returns the fork version (e.g. GENESIS_FORK_VERSION) from the clients spec
+func (c *ConsensusClient) GetForkVersionFromSpecContext(ctx context.Context, forkVersionString string) (phase0.Version, error) {
+	return c.SpecCache().Version(ctx, forkVersionString)
+}
+
+// GetDepositContractContext returns the deposit contract the client is following
+func (c *ConsensusClient) GetDepositContractContext(ctx context.Context) (*v1.DepositContract, error) {
+	resp, err := c.BeaconService.DepositContract(ctx)
 	if err != nil {
-		return phase0.DomainType{}, errors.Wrap(err, "failed to get spec for domain calculation")
+		return nil, errors.Wrapf(err, "failed to get deposit contract for client: %s", c.Name)
 	}
+	return resp.Data, nil
+}
+
+// GetDomainFromGenesisContext computes the genesis domain for the domain type
+func (c *ConsensusClient) GetDomainFromGenesisContext(ctx context.Context, domainType phase0.DomainType) (phase0.Domain, error) {
+	return c.SpecCache().GenesisDomain(ctx, domainType)
+}
+
+// GetDomainContext computes the domain for the domain type with the fork version active at the epoch
+func (c *ConsensusClient) GetDomainContext(ctx context.Context, domainType phase0.DomainType, epoch phase0.Epoch) (phase0.Domain, error) {
+	return c.SpecCache().Domain(ctx, domainType, epoch)
+}
 
-	d, ok := spec.Data[domainTypeString]
-	if !ok {
-		return phase0.DomainType{}, errors.New("failed to find domain type from spec")
+func (c *ConsensusClient) GetSlotsPerEpochContext(ctx context.Context) (uint64, error) {
+	return c.SpecCache().Uint64(ctx, "SLOTS_PER_EPOCH")
+}
+
+func (c *ConsensusClient) GetShardCommitteePeriodContext(ctx context.Context) (uint64, error) {
+	return c.SpecCache().Uint64(ctx, "SHARD_COMMITTEE_PERIOD")
+}
+
+func (c *ConsensusClient) GetCurrentEpochContext(ctx context.Context) (phase0.Epoch, error) {
+	currentHeader, err := c.GetBlockHeaderContext(ctx, "head")
+	if err != nil {
+		return phase0.Epoch(0), errors.Wrap(err, "failed to get current header to determine epoch")
 	}
-	domainType := d.(phase0.DomainType)
-	return domainType, nil
+	slotsPerEpoch, err := c.GetSlotsPerEpochContext(ctx)
+	if err != nil {
+		return phase0.Epoch(0), errors.Wrap(err, "failed to fetch slots per epoch from client spec")
+	}
+	return phase0.Epoch(uint64(currentHeader.Header.Message.Slot) / slotsPerEpoch), nil
 }
 
-// GetDomainFromGenesis fetches the genesis domain for the domain type
-func (c *ConsensusClient) GetDomainFromGenesis(domainType phase0.DomainType) (phase0.Domain, error) {
-	domain, err := c.BeaconService.GenesisDomain(context.Background(), domainType)
+// GetBlockHeaderContext returns the clients view of the block header at the provided block_id
+func (c *ConsensusClient) GetBlockHeaderContext(ctx context.Context, block string) (*v1.BeaconBlockHeader, error) {
+	resp, err := c.BeaconService.BeaconBlockHeader(ctx, &api.BeaconBlockHeaderOpts{Block: block})
 	if err != nil {
-		return phase0.Domain{}, errors.Wrap(err, "failed to get domain from consensus client")
+		return nil, errors.Wrapf(err, "failed to get block header for client: %s", c.Name)
+	}
+	return resp.Data, nil
+}
+
+// GetBlockRootAtSlotContext returns the clients view of the root of the latest block at or before the slot, as get_block_root_at_slot does
+func (c *ConsensusClient) GetBlockRootAtSlotContext(ctx context.Context, slot phase0.Slot) (phase0.Root, error) {
+	for {
+		resp, err := c.BeaconService.BeaconBlockHeader(ctx, &api.BeaconBlockHeaderOpts{Block: fmt.Sprintf("%d", slot)})
+		if err == nil {
+			return resp.Data.Root, nil
+		}
+		// an empty slot has the root of the block before it
+		if !isNotFound(err) || slot == 0 {
+			return phase0.Root{}, errors.Wrapf(err, "failed to get block root at slot %d for client: %s", slot, c.Name)
+		}
+		slot--
 	}
-	return domain, nil
 }
 
-func (c *ConsensusClient) GetSlotsPerEpoch() (uint64, error) {
-	slotsPerEpoch, err := c.BeaconService.SlotsPerEpoch(context.Background())
+// GetFinalityContext returns the clients view of the finality checkpoints at the provided state
+func (c *ConsensusClient) GetFinalityContext(ctx context.Context, stateID string) (*v1.Finality, error) {
+	resp, err := c.BeaconService.Finality(ctx, &api.FinalityOpts{State: stateID})
 	if err != nil {
-		return 0, err
+		return nil, errors.Wrapf(err, "failed to get finality for client: %s", c.Name)
 	}
-	return slotsPerEpoch, nil
+	return resp.Data, nil
 }
 
-func (c *ConsensusClient) GetShardCommitteePeriod() (uint64, error) {
-	spec, err := c.BeaconService.Spec(context.Background())
+// GetSignedBeaconBlockContext returns the clients view of the block at the provided block_id, nil is returned for an empty slot
+func (c *ConsensusClient) GetSignedBeaconBlockContext(ctx context.Context, block string) (*spec.VersionedSignedBeaconBlock, error) {
+	resp, err := c.BeaconService.SignedBeaconBlock(ctx, &api.SignedBeaconBlockOpts{Block: block})
 	if err != nil {
-		return 0, errors.Wrap(err, "failed to get spec for shard committee period")
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to get block for client: %s", c.Name)
 	}
-	shardCommitteePeriod, ok := spec.Data["SHARD_COMMITTEE_PERIOD"].(uint64)
-	if !ok {
-		return 0, errors.New("failed to get shard committee period from spec")
+	return resp.Data, nil
+}
+
+// GetBeaconCommitteesContext returns the clients view of the committees for the epoch at the provided state
+func (c *ConsensusClient) GetBeaconCommitteesContext(ctx context.Context, stateID string, epoch phase0.Epoch) ([]*v1.BeaconCommittee, error) {
+	resp, err := c.BeaconService.BeaconCommittees(ctx, &api.BeaconCommitteesOpts{State: stateID, Epoch: &epoch})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get beacon committees for client: %s", c.Name)
 	}
-	return shardCommitteePeriod, nil
+	return resp.Data, nil
 }
 
-func (c *ConsensusClient) GetCurrentEpoch() (phase0.Epoch, error) {
-	currentHeader, err := c.GetBlockHeader("head")
+// GetProposerDutiesContext returns the clients view of the proposer of every slot of the epoch
+func (c *ConsensusClient) GetProposerDutiesContext(ctx context.Context, epoch phase0.Epoch) ([]*v1.ProposerDuty, error) {
+	resp, err := c.BeaconService.ProposerDuties(ctx, &api.ProposerDutiesOpts{Epoch: epoch})
 	if err != nil {
-		return phase0.Epoch(0), errors.Wrap(err, "failed to get current header to determine epoch")
+		return nil, errors.Wrapf(err, "failed to get proposer duties for client: %s", c.Name)
 	}
-	slotsPerEpoch, err := c.GetSlotsPerEpoch()
+	return resp.Data, nil
+}
+
+// GetVoluntaryExitPoolContext returns the contents of the clients voluntary exit pool
+func (c *ConsensusClient) GetVoluntaryExitPoolContext(ctx context.Context) ([]*phase0.SignedVoluntaryExit, error) {
+	exits, err := c.BeaconService.VoluntaryExitPool(ctx)
 	if err != nil {
-		return phase0.Epoch(0), errors.Wrap(err, "failed to fetch slots per epoch from client spec")
+		return nil, errors.Wrapf(err, "failed to get voluntary exit pool for client: %s", c.Name)
 	}
-	return phase0.Epoch(uint64(currentHeader.Header.Message.Slot) / slotsPerEpoch), nil
+	return exits, nil
 }
 
-// GetBlockHeader returns the clients view of the block header at the provided block_id
-func (c *ConsensusClient) GetBlockHeader(block string) (*v1.BeaconBlockHeader, error) {
-	resp, err := c.BeaconService.BeaconBlockHeader(context.Background(), &api.BeaconBlockHeaderOpts{Block: block})
+// GetBLSToExecutionChangePoolContext returns the contents of the clients bls to execution change pool
+func (c *ConsensusClient) GetBLSToExecutionChangePoolContext(ctx context.Context) ([]*capella.SignedBLSToExecutionChange, error) {
+	var changes []*capella.SignedBLSToExecutionChange
+	err := c.getJSON(ctx, "/eth/v1/beacon/pool/bls_to_execution_changes", &changes)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get block header for client: %s", c.Name)
+		return nil, errors.Wrapf(err, "failed to get bls to execution change pool for client: %s", c.Name)
 	}
-	return resp.Data, nil
+	return changes, nil
 }
 
 // getValidators returns the view of the validators from the perspective of this client at a given state
 // this is used by some of the wrappers to remove boilerplate code in testnet experiments
-func (c *ConsensusClient) getValidators(opts *api.ValidatorsOpts) (map[phase0.ValidatorIndex]*v1.Validator, error) {
-	resp, err := c.BeaconService.Validators(context.Background(), opts)
+func (c *ConsensusClient) getValidators(ctx context.Context, opts *api.ValidatorsOpts) (map[phase0.ValidatorIndex]*v1.Validator, error) {
+	resp, err := c.BeaconService.Validators(ctx, opts)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get validators for client: %s", c.Name)
 	}
 	return resp.Data, nil
 }
 
-// GetAllValidators returns all the validators in view of the client at the provided state (head/genesis/finalized/justified/slot/0xstateRoot)
-func (c *ConsensusClient) GetAllValidators(stateID string) (map[phase0.ValidatorIndex]*v1.Validator, error) {
-	resp, err := c.BeaconService.Validators(context.Background(), &api.ValidatorsOpts{State: stateID})
+// GetAllValidatorsContext returns all the validators in view of the client at the provided state (head/genesis/finalized/justified/slot/0xstateRoot)
+func (c *ConsensusClient) GetAllValidatorsContext(ctx context.Context, stateID string) (map[phase0.ValidatorIndex]*v1.Validator, error) {
+	resp, err := c.BeaconService.Validators(ctx, &api.ValidatorsOpts{State: stateID})
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get validators for client: %s", c.Name)
 	}
 	return resp.Data, nil
 }
 
-// GetAllActiveValidators fetches all the validators by a pending state
-func (c *ConsensusClient) GetAllActiveValidators(stateID string) (map[phase0.ValidatorIndex]*v1.Validator, error) {
+// GetAllActiveValidatorsContext fetches all the validators by a pending state
+func (c *ConsensusClient) GetAllActiveValidatorsContext(ctx context.Context, stateID string) (map[phase0.ValidatorIndex]*v1.Validator, error) {
 	activeValidators := make(map[phase0.ValidatorIndex]*v1.Validator)
-	allValidators, err := c.getValidators(&api.ValidatorsOpts{State: stateID})
+	allValidators, err := c.getValidators(ctx, &api.ValidatorsOpts{State: stateID})
 	if err != nil {
 		return nil, err
 	}
@@ -122,9 +201,9 @@ func (c *ConsensusClient) GetAllActiveValidators(stateID string) (map[phase0.Val
 	return activeValidators, nil
 }
 
-// GetValidatorByPublicKey fetches the validator entry with the same public key from the client
-func (c *ConsensusClient) GetValidatorByPublicKey(stateID string, pubKey phase0.BLSPubKey) (*v1.Validator, error) {
-	resp, err := c.getValidators(&api.ValidatorsOpts{
+// GetValidatorByPublicKeyContext fetches the validator entry with the same public key from the client
+func (c *ConsensusClient) GetValidatorByPublicKeyContext(ctx context.Context, stateID string, pubKey phase0.BLSPubKey) (*v1.Validator, error) {
+	resp, err := c.getValidators(ctx, &api.ValidatorsOpts{
 		State:   stateID,
 		PubKeys: []phase0.BLSPubKey{pubKey},
 	})
@@ -143,12 +222,136 @@ func (c *ConsensusClient) GetValidatorByPublicKey(stateID string, pubKey phase0.
 	panic("unreachable")
 }
 
-// SubmitBLSToExecutionChange submits the SignedBLSToExecutionChange to the clients bls to execution change pool
-func (c *ConsensusClient) SubmitBLSToExecutionChange(change *capella.SignedBLSToExecutionChange) error {
-	return c.BeaconService.SubmitBLSToExecutionChanges(context.Background(), []*capella.SignedBLSToExecutionChange{change})
+// SubmitBLSToExecutionChangeContext submits the SignedBLSToExecutionChange to the clients bls to execution change pool
+func (c *ConsensusClient) SubmitBLSToExecutionChangeContext(ctx context.Context, change *capella.SignedBLSToExecutionChange) error {
+	return c.BeaconService.SubmitBLSToExecutionChanges(ctx, []*capella.SignedBLSToExecutionChange{change})
+}
+
+// SubmitBLSToExecutionChangesContext submits the changes to the clients bls to execution change pool in a single request.
+// If only some changes are rejected the rest are still accepted, SubmissionFailures returns which ones were rejected.
+func (c *ConsensusClient) SubmitBLSToExecutionChangesContext(ctx context.Context, changes []*capella.SignedBLSToExecutionChange) error {
+	return c.BeaconService.SubmitBLSToExecutionChanges(ctx, changes)
+}
+
+// SubmitValidatorExitContext submits the voluntary exit to the clients validator exit pool.
+func (c *ConsensusClient) SubmitValidatorExitContext(ctx context.Context, exit *phase0.SignedVoluntaryExit) error {
+	return c.BeaconService.SubmitVoluntaryExit(ctx, exit)
+}
+
+// SubmitAttesterSlashingContext submits the attester slashing to the clients attester slashing pool
+func (c *ConsensusClient) SubmitAttesterSlashingContext(ctx context.Context, slashing *phase0.AttesterSlashing) error {
+	return c.BeaconService.SubmitAttesterSlashing(ctx, slashing)
+}
+
+// SubmitProposerSlashingContext submits the proposer slashing to the clients proposer slashing pool.
+// The BeaconService takes the slashing by value which skips its JSON marshaller, so it is posted directly.
+func (c *ConsensusClient) SubmitProposerSlashingContext(ctx context.Context, slashing *phase0.ProposerSlashing) error {
+	return c.postJSON(ctx, "/eth/v1/beacon/pool/proposer_slashings", slashing)
+}
+
+// GetRawDataContext fetches any beacon api endpoint and returns the data field as generic JSON, numbers are kept as json.Number
+func (c *ConsensusClient) GetRawDataContext(ctx context.Context, endpoint string) (interface{}, error) {
+	var data json.RawMessage
+	if err := c.getJSON(ctx, endpoint, &data); err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s for client: %s", endpoint, c.Name)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s for client: %s", endpoint, c.Name)
+	}
+	return generic, nil
+}
+
+// getJSON fetches an endpoint that isn't covered by the BeaconService and decodes the data field of the response
+func (c *ConsensusClient) getJSON(ctx context.Context, endpoint string, data interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(c.BeaconAPI, "/")+endpoint, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to call %s", endpoint)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read response body")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &api.Error{Method: http.MethodGet, Endpoint: endpoint, StatusCode: resp.StatusCode, Data: body}
+	}
+	return json.Unmarshal(body, &struct {
+		Data interface{} `json:"data"`
+	}{Data: data})
+}
+
+// postJSON posts the data as JSON to an endpoint that isn't (correctly) covered by the BeaconService
+func (c *ConsensusClient) postJSON(ctx context.Context, endpoint string, data interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal request")
+	}
+	statusCode, respBody, err := c.PostRawDataContext(ctx, endpoint, body, nil)
+	if err != nil {
+		return err
+	}
+	if statusCode/100 != 2 {
+		return &api.Error{Method: http.MethodPost, Endpoint: endpoint, StatusCode: statusCode, Data: respBody}
+	}
+	return nil
+}
+
+// PostRawDataContext posts a JSON body as is to any beacon api endpoint with the extra headers, e.g. Eth-Consensus-Version,
+// and returns the status code and body of the response. Error responses aren't turned into errors, only failures to reach the client are.
+func (c *ConsensusClient) PostRawDataContext(ctx context.Context, endpoint string, body []byte, headers map[string]string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.BeaconAPI, "/")+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, errors.Wrapf(err, "failed to call %s", endpoint)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "failed to read response body")
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// SubmissionFailures returns the rejected items by their index in the submission, from the failures of an indexed error response.
+// It returns nil if the error doesn't say which items were rejected.
+func SubmissionFailures(err error) map[int]string {
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) {
+		return nil
+	}
+	var response struct {
+		Failures []struct {
+			Index   int    `json:"index"`
+			Message string `json:"message"`
+		} `json:"failures"`
+	}
+	if json.Unmarshal(apiErr.Data, &response) != nil || len(response.Failures) == 0 {
+		return nil
+	}
+	failures := make(map[int]string, len(response.Failures))
+	for _, failure := range response.Failures {
+		failures[failure.Index] = failure.Message
+	}
+	return failures
 }
 
-// SubmitValidatorExit submits the voluntary exit to the clients validator exit pool.
-func (c *ConsensusClient) SubmitValidatorExit(exit *phase0.SignedVoluntaryExit) error {
-	return c.BeaconService.SubmitVoluntaryExit(context.Background(), exit)
+// isNotFound returns true if the beacon api answered with a 404
+func isNotFound(err error) bool {
+	var apiErr *api.Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
 }
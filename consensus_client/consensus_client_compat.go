@@ -0,0 +1,65 @@
+package consensus_client
+
+import (
+	"context"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+// Compatibility wrappers for callers from before the api took a context, they call the Context methods with a background context
+
+// GetDomainTypeFromSpec calls GetDomainTypeFromSpecContext with a background context
+func (c *ConsensusClient) GetDomainTypeFromSpec(domainTypeString string) (phase0.DomainType, error) {
+	return c.GetDomainTypeFromSpecContext(context.Background(), domainTypeString)
+}
+
+// GetDomainFromGenesis calls GetDomainFromGenesisContext with a background context
+func (c *ConsensusClient) GetDomainFromGenesis(domainType phase0.DomainType) (phase0.Domain, error) {
+	return c.GetDomainFromGenesisContext(context.Background(), domainType)
+}
+
+// GetSlotsPerEpoch calls GetSlotsPerEpochContext with a background context
+func (c *ConsensusClient) GetSlotsPerEpoch() (uint64, error) {
+	return c.GetSlotsPerEpochContext(context.Background())
+}
+
+// GetShardCommitteePeriod calls GetShardCommitteePeriodContext with a background context
+func (c *ConsensusClient) GetShardCommitteePeriod() (uint64, error) {
+	return c.GetShardCommitteePeriodContext(context.Background())
+}
+
+// GetCurrentEpoch calls GetCurrentEpochContext with a background context
+func (c *ConsensusClient) GetCurrentEpoch() (phase0.Epoch, error) {
+	return c.GetCurrentEpochContext(context.Background())
+}
+
+// GetBlockHeader calls GetBlockHeaderContext with a background context
+func (c *ConsensusClient) GetBlockHeader(block string) (*v1.BeaconBlockHeader, error) {
+	return c.GetBlockHeaderContext(context.Background(), block)
+}
+
+// GetAllValidators calls GetAllValidatorsContext with a background context
+func (c *ConsensusClient) GetAllValidators(stateID string) (map[phase0.ValidatorIndex]*v1.Validator, error) {
+	return c.GetAllValidatorsContext(context.Background(), stateID)
+}
+
+// GetAllActiveValidators calls GetAllActiveValidatorsContext with a background context
+func (c *ConsensusClient) GetAllActiveValidators(stateID string) (map[phase0.ValidatorIndex]*v1.Validator, error) {
+	return c.GetAllActiveValidatorsContext(context.Background(), stateID)
+}
+
+// GetValidatorByPublicKey calls GetValidatorByPublicKeyContext with a background context
+func (c *ConsensusClient) GetValidatorByPublicKey(stateID string, pubKey phase0.BLSPubKey) (*v1.Validator, error) {
+	return c.GetValidatorByPublicKeyContext(context.Background(), stateID, pubKey)
+}
+
+// SubmitBLSToExecutionChange calls SubmitBLSToExecutionChangeContext with a background context
+func (c *ConsensusClient) SubmitBLSToExecutionChange(change *capella.SignedBLSToExecutionChange) error {
+	return c.SubmitBLSToExecutionChangeContext(context.Background(), change)
+}
+
+// SubmitValidatorExit calls SubmitValidatorExitContext with a background context
+func (c *ConsensusClient) SubmitValidatorExit(exit *phase0.SignedVoluntaryExit) error {
+	return c.SubmitValidatorExitContext(context.Background(), exit)
+}
@@ -1,11 +1,11 @@
 package consensus_client
 
 import (
-	"context"
 	"encoding/hex"
 	"eth-testnet-tool/validator"
 	"fmt"
-	"github.com/attestantio/go-eth2-client/http"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -14,23 +14,13 @@ import (
 
 const (
 	Timeout               = 3 * time.Second
-	ForceJson             = true // force json for testing with minimal testnets
-	BeaconAPI             = "http://10.0.20.5:5052"
 	ValidatorMnemonic     = "ocean style run case glory clip into nature guess jacket document firm fiscal hello kite disagree symptom tide net coral envelope wink render festival"
 	GenesisValidatorCount = 20
 )
 
-func getTestConsensusClient() (*ConsensusClient, error) {
-	service, err := http.New(context.Background(), http.WithAddress(BeaconAPI), http.WithTimeout(Timeout), http.WithEnforceJSON(ForceJson))
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to get test client for test")
-	}
-
-	return &ConsensusClient{
-		Name:          "testClient",
-		BeaconAPI:     BeaconAPI,
-		BeaconService: service.(*http.Service),
-	}, nil
+// getTestConsensusClient returns a client of a mock beacon node serving the genesis validators
+func getTestConsensusClient(t *testing.T) (*MockBeaconNode, *ConsensusClient) {
+	return newTestMockBeaconNode(t)
 }
 
 func getTestValidators(maxAcc uint64) ([]*validator.Validator, error) {
@@ -42,12 +32,12 @@ func getTestValidators(maxAcc uint64) ([]*validator.Validator, error) {
 }
 
 func TestConsensusClient_GetValidators(t *testing.T) {
-	testConsensusClient, err := getTestConsensusClient()
-	require.NoError(t, err)
+	_, testConsensusClient := getTestConsensusClient(t)
 	genesisValidators, err := getTestValidators(GenesisValidatorCount)
 	require.NoError(t, err)
 	clientValidators, err := testConsensusClient.GetAllValidators("head")
 	require.NoError(t, err)
+	require.Len(t, clientValidators, GenesisValidatorCount)
 	for ndx, v := range clientValidators {
 		require.Equal(t, genesisValidators[ndx].ValidatorIndex, uint64(ndx))
 		require.Equal(t, fmt.Sprintf("0x%s", hex.EncodeToString(genesisValidators[ndx].ValidatorKey.PublicKey().Marshal())), v.Validator.PublicKey.String())
@@ -55,30 +45,38 @@ func TestConsensusClient_GetValidators(t *testing.T) {
 }
 
 func TestConsensusClient_GetAllActiveValidators(t *testing.T) {
-	testConsensusClient, err := getTestConsensusClient()
-	require.NoError(t, err)
+	node, testConsensusClient := getTestConsensusClient(t)
+	node.Update(func(state *MockBeaconState) {
+		state.Validators[2].Status = v1.ValidatorStateExitedUnslashed
+	})
 	clientValidators, err := testConsensusClient.GetAllActiveValidators("head")
 	require.NoError(t, err)
+	require.Len(t, clientValidators, GenesisValidatorCount-1)
+	require.NotContains(t, clientValidators, phase0.ValidatorIndex(2))
 	for _, v := range clientValidators {
-		t.Log(v.String())
 		require.Equal(t, v.Status.IsActive(), true)
 	}
 }
 
 func TestConsensusClient_GetValidatorByPublicKey(t *testing.T) {
-	testConsensusClient, err := getTestConsensusClient()
-	require.NoError(t, err)
+	_, testConsensusClient := getTestConsensusClient(t)
 	genesisValidators, err := getTestValidators(GenesisValidatorCount)
 	require.NoError(t, err)
 	validator, err := testConsensusClient.GetValidatorByPublicKey("head", genesisValidators[4].ValidatorPublicKey)
-	require.Equal(t, validator.Validator.PublicKey, genesisValidators[4].ValidatorPublicKey)
 	require.NoError(t, err)
+	require.Equal(t, validator.Validator.PublicKey, genesisValidators[4].ValidatorPublicKey)
+	require.Equal(t, phase0.ValidatorIndex(4), validator.Index)
+
+	_, err = testConsensusClient.GetValidatorByPublicKey("head", phase0.BLSPubKey{0x01})
+	require.Error(t, err)
 }
 
 func TestConsensusClient_GetShardCommitteePeriod(t *testing.T) {
-	testConsensusClient, err := getTestConsensusClient()
-	require.NoError(t, err)
+	node, testConsensusClient := getTestConsensusClient(t)
+	node.Update(func(state *MockBeaconState) {
+		state.Spec["SHARD_COMMITTEE_PERIOD"] = "64"
+	})
 	shardCommitteePeriod, err := testConsensusClient.GetShardCommitteePeriod()
 	require.NoError(t, err)
-	t.Logf("got shard committee period: %d from %s\n", shardCommitteePeriod, testConsensusClient.String())
+	require.Equal(t, uint64(64), shardCommitteePeriod)
 }
@@ -2,12 +2,11 @@ package consensus_objects
 
 import (
 	"github.com/attestantio/go-eth2-client/spec/phase0"
-	fuzz "github.com/google/gofuzz"
 )
 
-func RandomAggregateAndProof() *phase0.SignedAggregateAndProof {
+func RandomAggregateAndProof(seed int64) *phase0.SignedAggregateAndProof {
 	var signedAggregateAndProof phase0.SignedAggregateAndProof
-	f := fuzz.New().NilChance(0)
+	f := newFuzzer(seed)
 	for {
 		f.Fuzz(&signedAggregateAndProof)
 		_, err := signedAggregateAndProof.MarshalSSZ()
@@ -3,7 +3,6 @@ package consensus_objects
 import (
 	v1 "github.com/attestantio/go-eth2-client/api/v1"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
-	fuzz "github.com/google/gofuzz"
 )
 
 type CheckpointResponseJSON struct {
@@ -12,9 +11,9 @@ type CheckpointResponseJSON struct {
 	Finalized           bool         `json:"finalized"`
 }
 
-func RandomAttestation() *phase0.Attestation {
+func RandomAttestation(seed int64) *phase0.Attestation {
 	var attestation phase0.Attestation
-	f := fuzz.New().NilChance(0)
+	f := newFuzzer(seed)
 	for {
 		f.Fuzz(&attestation)
 		_, err := attestation.MarshalSSZ()
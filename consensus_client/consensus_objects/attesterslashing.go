@@ -2,7 +2,6 @@ package consensus_objects
 
 import (
 	"github.com/attestantio/go-eth2-client/spec/phase0"
-	fuzz "github.com/google/gofuzz"
 )
 
 type AttestorSlashingJSON struct {
@@ -10,9 +9,9 @@ type AttestorSlashingJSON struct {
 	Attestation2 *phase0.IndexedAttestation `json:"attestation_2"`
 }
 
-func RandomAttesterSlashing() *phase0.AttesterSlashing {
+func RandomAttesterSlashing(seed int64) *phase0.AttesterSlashing {
 	var attesterSlashing phase0.AttesterSlashing
-	f := fuzz.New().NilChance(0)
+	f := newFuzzer(seed)
 	for {
 		f.Fuzz(&attesterSlashing)
 		_, err := attesterSlashing.MarshalSSZ()
@@ -2,13 +2,12 @@ package consensus_objects
 
 import (
 	"github.com/attestantio/go-eth2-client/spec/deneb"
-	fuzz "github.com/google/gofuzz"
 )
 
 // RandomBlobSideCar creates a totally random but syntactically valid BlobSideCar
-func RandomBlobSideCar() *deneb.BlobSidecar {
+func RandomBlobSideCar(seed int64) *deneb.BlobSidecar {
 	var blobSideCar deneb.BlobSidecar
-	f := fuzz.New().NilChance(0)
+	f := newFuzzer(seed)
 	for {
 		f.Fuzz(&blobSideCar)
 		_, err := blobSideCar.MarshalSSZ()
@@ -9,7 +9,7 @@ import (
 func TestRoundTripExecutionChange(t *testing.T) {
 	rtChange := capella.BLSToExecutionChange{}
 	for i := 0; i < 10; i++ {
-		randExecutionChange := RandomBLSToExecutionChange()
+		randExecutionChange := RandomBLSToExecutionChange(int64(i))
 		changeSSZ, err := randExecutionChange.MarshalSSZ()
 		require.NoError(t, err)
 		err = rtChange.UnmarshalSSZ(changeSSZ)
@@ -21,7 +21,7 @@ func TestRoundTripExecutionChange(t *testing.T) {
 func TestRoundTripSignedExecutionChange(t *testing.T) {
 	rtSignedChange := capella.SignedBLSToExecutionChange{}
 	for i := 0; i < 10; i++ {
-		randSignedExecutionChange := RandomSignedBLSToExecutionChange()
+		randSignedExecutionChange := RandomSignedBLSToExecutionChange(int64(i))
 		signedChangeSSZ, err := randSignedExecutionChange.MarshalSSZ()
 		require.NoError(t, err)
 		err = rtSignedChange.UnmarshalSSZ(signedChangeSSZ)
@@ -2,7 +2,6 @@ package consensus_objects
 
 import (
 	"github.com/attestantio/go-eth2-client/spec/capella"
-	fuzz "github.com/google/gofuzz"
 )
 
 type SignedBLSToExecutionChangeJSON struct {
@@ -11,9 +10,9 @@ type SignedBLSToExecutionChangeJSON struct {
 }
 
 // RandomBLSToExecutionChange creates a random ssz-able BLSToExecutionChange
-func RandomBLSToExecutionChange() *capella.BLSToExecutionChange {
+func RandomBLSToExecutionChange(seed int64) *capella.BLSToExecutionChange {
 	var blsToExecutionChange capella.BLSToExecutionChange
-	f := fuzz.New().NilChance(0)
+	f := newFuzzer(seed)
 	for {
 		f.Fuzz(&blsToExecutionChange)
 		_, err := blsToExecutionChange.MarshalSSZ()
@@ -24,9 +23,9 @@ func RandomBLSToExecutionChange() *capella.BLSToExecutionChange {
 	return &blsToExecutionChange
 }
 
-func RandomSignedBLSToExecutionChange() *capella.SignedBLSToExecutionChange {
+func RandomSignedBLSToExecutionChange(seed int64) *capella.SignedBLSToExecutionChange {
 	var signedBlsToExecutionChange capella.SignedBLSToExecutionChange
-	f := fuzz.New().NilChance(0)
+	f := newFuzzer(seed)
 	for {
 		f.Fuzz(&signedBlsToExecutionChange)
 		_, err := signedBlsToExecutionChange.MarshalSSZ()
@@ -0,0 +1,248 @@
+package consensus_objects
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"github.com/prysmaticlabs/go-bitfield"
+	"strconv"
+	"strings"
+)
+
+// The electra types aren't in go-eth2-client yet, so they are defined here with their SSZ and beacon api JSON encodings.
+
+// ExecutionLayerWithdrawalRequest an EIP-7002 request of the withdrawal address of a validator to withdraw from it, an amount of 0 exits the validator
+type ExecutionLayerWithdrawalRequest struct {
+	SourceAddress   bellatrix.ExecutionAddress
+	ValidatorPubkey phase0.BLSPubKey
+	Amount          phase0.Gwei
+}
+
+// DepositRequest an EIP-6110 deposit the execution layer passes to the beacon chain, Index is the index of the deposit in the deposit contract
+type DepositRequest struct {
+	Pubkey                phase0.BLSPubKey
+	WithdrawalCredentials []byte
+	Amount                phase0.Gwei
+	Signature             phase0.BLSSignature
+	Index                 uint64
+}
+
+// ConsolidationRequest an EIP-7251 request of the withdrawal address of the source validator to move its balance to the target validator
+type ConsolidationRequest struct {
+	SourceAddress bellatrix.ExecutionAddress
+	SourcePubkey  phase0.BLSPubKey
+	TargetPubkey  phase0.BLSPubKey
+}
+
+// ElectraAttestation an EIP-7549 attestation, the committee moved from Data.Index (always 0) to CommitteeBits
+// and the aggregation bits span the committees of all set committee bits
+type ElectraAttestation struct {
+	AggregationBits bitfield.Bitlist
+	Data            *phase0.AttestationData
+	Signature       phase0.BLSSignature
+	CommitteeBits   bitfield.Bitvector64
+}
+
+type executionLayerWithdrawalRequestJSON struct {
+	SourceAddress   string `json:"source_address"`
+	ValidatorPubkey string `json:"validator_pubkey"`
+	Amount          string `json:"amount"`
+}
+
+type depositRequestJSON struct {
+	Pubkey                string `json:"pubkey"`
+	WithdrawalCredentials string `json:"withdrawal_credentials"`
+	Amount                string `json:"amount"`
+	Signature             string `json:"signature"`
+	Index                 string `json:"index"`
+}
+
+type consolidationRequestJSON struct {
+	SourceAddress string `json:"source_address"`
+	SourcePubkey  string `json:"source_pubkey"`
+	TargetPubkey  string `json:"target_pubkey"`
+}
+
+type electraAttestationJSON struct {
+	AggregationBits string                  `json:"aggregation_bits"`
+	Data            *phase0.AttestationData `json:"data"`
+	Signature       string                  `json:"signature"`
+	CommitteeBits   string                  `json:"committee_bits"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (r *ExecutionLayerWithdrawalRequest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&executionLayerWithdrawalRequestJSON{
+		SourceAddress:   fmt.Sprintf("%#x", r.SourceAddress),
+		ValidatorPubkey: fmt.Sprintf("%#x", r.ValidatorPubkey),
+		Amount:          fmt.Sprintf("%d", r.Amount),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *ExecutionLayerWithdrawalRequest) UnmarshalJSON(input []byte) error {
+	var data executionLayerWithdrawalRequestJSON
+	if err := json.Unmarshal(input, &data); err != nil {
+		return errors.Wrap(err, "invalid JSON")
+	}
+	if err := decodeFixedHex("source address", data.SourceAddress, r.SourceAddress[:]); err != nil {
+		return err
+	}
+	if err := decodeFixedHex("validator pubkey", data.ValidatorPubkey, r.ValidatorPubkey[:]); err != nil {
+		return err
+	}
+	amount, err := parseUint("amount", data.Amount)
+	r.Amount = phase0.Gwei(amount)
+	return err
+}
+
+// MarshalJSON implements json.Marshaler
+func (r *DepositRequest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&depositRequestJSON{
+		Pubkey:                fmt.Sprintf("%#x", r.Pubkey),
+		WithdrawalCredentials: fmt.Sprintf("%#x", r.WithdrawalCredentials),
+		Amount:                fmt.Sprintf("%d", r.Amount),
+		Signature:             fmt.Sprintf("%#x", r.Signature),
+		Index:                 fmt.Sprintf("%d", r.Index),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *DepositRequest) UnmarshalJSON(input []byte) error {
+	var data depositRequestJSON
+	if err := json.Unmarshal(input, &data); err != nil {
+		return errors.Wrap(err, "invalid JSON")
+	}
+	if err := decodeFixedHex("pubkey", data.Pubkey, r.Pubkey[:]); err != nil {
+		return err
+	}
+	r.WithdrawalCredentials = make([]byte, 32)
+	if err := decodeFixedHex("withdrawal credentials", data.WithdrawalCredentials, r.WithdrawalCredentials); err != nil {
+		return err
+	}
+	amount, err := parseUint("amount", data.Amount)
+	if err != nil {
+		return err
+	}
+	r.Amount = phase0.Gwei(amount)
+	if err := decodeFixedHex("signature", data.Signature, r.Signature[:]); err != nil {
+		return err
+	}
+	r.Index, err = parseUint("index", data.Index)
+	return err
+}
+
+// MarshalJSON implements json.Marshaler
+func (r *ConsolidationRequest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&consolidationRequestJSON{
+		SourceAddress: fmt.Sprintf("%#x", r.SourceAddress),
+		SourcePubkey:  fmt.Sprintf("%#x", r.SourcePubkey),
+		TargetPubkey:  fmt.Sprintf("%#x", r.TargetPubkey),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *ConsolidationRequest) UnmarshalJSON(input []byte) error {
+	var data consolidationRequestJSON
+	if err := json.Unmarshal(input, &data); err != nil {
+		return errors.Wrap(err, "invalid JSON")
+	}
+	if err := decodeFixedHex("source address", data.SourceAddress, r.SourceAddress[:]); err != nil {
+		return err
+	}
+	if err := decodeFixedHex("source pubkey", data.SourcePubkey, r.SourcePubkey[:]); err != nil {
+		return err
+	}
+	return decodeFixedHex("target pubkey", data.TargetPubkey, r.TargetPubkey[:])
+}
+
+// MarshalJSON implements json.Marshaler
+func (a *ElectraAttestation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&electraAttestationJSON{
+		AggregationBits: fmt.Sprintf("%#x", []byte(a.AggregationBits)),
+		Data:            a.Data,
+		Signature:       fmt.Sprintf("%#x", a.Signature),
+		CommitteeBits:   fmt.Sprintf("%#x", []byte(a.CommitteeBits)),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (a *ElectraAttestation) UnmarshalJSON(input []byte) error {
+	var data electraAttestationJSON
+	if err := json.Unmarshal(input, &data); err != nil {
+		return errors.Wrap(err, "invalid JSON")
+	}
+	aggregationBits, err := hex.DecodeString(strings.TrimPrefix(data.AggregationBits, "0x"))
+	if err != nil {
+		return errors.Wrap(err, "invalid value for aggregation bits")
+	}
+	if len(aggregationBits) == 0 || aggregationBits[len(aggregationBits)-1] == 0 {
+		return errors.New("invalid bitlist for aggregation bits")
+	}
+	a.AggregationBits = aggregationBits
+	if data.Data == nil {
+		return errors.New("data missing")
+	}
+	a.Data = data.Data
+	if err := decodeFixedHex("signature", data.Signature, a.Signature[:]); err != nil {
+		return err
+	}
+	a.CommitteeBits = make(bitfield.Bitvector64, 8)
+	return decodeFixedHex("committee bits", data.CommitteeBits, a.CommitteeBits)
+}
+
+// decodeFixedHex decodes the 0x prefixed hex value of the field into dst, the value has to have the length of dst
+func decodeFixedHex(field string, value string, dst []byte) error {
+	if value == "" {
+		return fmt.Errorf("%s missing", field)
+	}
+	decoded, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err != nil {
+		return errors.Wrapf(err, "invalid value for %s", field)
+	}
+	if len(decoded) != len(dst) {
+		return fmt.Errorf("incorrect length for %s", field)
+	}
+	copy(dst, decoded)
+	return nil
+}
+
+// parseUint parses the decimal string value of the field
+func parseUint(field string, value string) (uint64, error) {
+	if value == "" {
+		return 0, fmt.Errorf("%s missing", field)
+	}
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	return parsed, errors.Wrapf(err, "invalid value for %s", field)
+}
+
+// RandomExecutionLayerWithdrawalRequest creates a random ssz-able ExecutionLayerWithdrawalRequest
+func RandomExecutionLayerWithdrawalRequest(seed int64) *ExecutionLayerWithdrawalRequest {
+	var request ExecutionLayerWithdrawalRequest
+	newFuzzer(seed).Fuzz(&request)
+	return &request
+}
+
+// RandomDepositRequest creates a random ssz-able DepositRequest
+func RandomDepositRequest(seed int64) *DepositRequest {
+	var request DepositRequest
+	newFuzzer(seed).Fuzz(&request)
+	return &request
+}
+
+// RandomConsolidationRequest creates a random ssz-able ConsolidationRequest
+func RandomConsolidationRequest(seed int64) *ConsolidationRequest {
+	var request ConsolidationRequest
+	newFuzzer(seed).Fuzz(&request)
+	return &request
+}
+
+// RandomElectraAttestation creates a random ssz-able ElectraAttestation
+func RandomElectraAttestation(seed int64) *ElectraAttestation {
+	var attestation ElectraAttestation
+	newFuzzer(seed).Fuzz(&attestation)
+	return &attestation
+}
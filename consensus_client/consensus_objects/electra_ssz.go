@@ -0,0 +1,274 @@
+package consensus_objects
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	ssz "github.com/ferranbt/fastssz"
+)
+
+// SSZ encodings of the electra types, laid out the way fastssz generates them for the go-eth2-client types
+
+const (
+	executionLayerWithdrawalRequestSize = 76
+	depositRequestSize                  = 192
+	consolidationRequestSize            = 116
+	electraAttestationFixedSize         = 236
+	// maxElectraAggregationBits MAX_VALIDATORS_PER_COMMITTEE * MAX_COMMITTEES_PER_SLOT
+	maxElectraAggregationBits = 2048 * 64
+)
+
+// MarshalSSZ ssz marshals the ExecutionLayerWithdrawalRequest object
+func (r *ExecutionLayerWithdrawalRequest) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(r)
+}
+
+// MarshalSSZTo ssz marshals the ExecutionLayerWithdrawalRequest object to a target array
+func (r *ExecutionLayerWithdrawalRequest) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	dst = buf
+	dst = append(dst, r.SourceAddress[:]...)
+	dst = append(dst, r.ValidatorPubkey[:]...)
+	dst = ssz.MarshalUint64(dst, uint64(r.Amount))
+	return
+}
+
+// UnmarshalSSZ ssz unmarshals the ExecutionLayerWithdrawalRequest object
+func (r *ExecutionLayerWithdrawalRequest) UnmarshalSSZ(buf []byte) error {
+	if len(buf) != executionLayerWithdrawalRequestSize {
+		return ssz.ErrSize
+	}
+	copy(r.SourceAddress[:], buf[0:20])
+	copy(r.ValidatorPubkey[:], buf[20:68])
+	r.Amount = phase0.Gwei(ssz.UnmarshallUint64(buf[68:76]))
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the ExecutionLayerWithdrawalRequest object
+func (r *ExecutionLayerWithdrawalRequest) SizeSSZ() int {
+	return executionLayerWithdrawalRequestSize
+}
+
+// HashTreeRoot ssz hashes the ExecutionLayerWithdrawalRequest object
+func (r *ExecutionLayerWithdrawalRequest) HashTreeRoot() ([32]byte, error) {
+	return ssz.HashWithDefaultHasher(r)
+}
+
+// HashTreeRootWith ssz hashes the ExecutionLayerWithdrawalRequest object with a hasher
+func (r *ExecutionLayerWithdrawalRequest) HashTreeRootWith(hh ssz.HashWalker) error {
+	indx := hh.Index()
+	hh.PutBytes(r.SourceAddress[:])
+	hh.PutBytes(r.ValidatorPubkey[:])
+	hh.PutUint64(uint64(r.Amount))
+	hh.Merkleize(indx)
+	return nil
+}
+
+// GetTree ssz hashes the ExecutionLayerWithdrawalRequest object
+func (r *ExecutionLayerWithdrawalRequest) GetTree() (*ssz.Node, error) {
+	return ssz.ProofTree(r)
+}
+
+// MarshalSSZ ssz marshals the DepositRequest object
+func (r *DepositRequest) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(r)
+}
+
+// MarshalSSZTo ssz marshals the DepositRequest object to a target array
+func (r *DepositRequest) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	dst = buf
+	dst = append(dst, r.Pubkey[:]...)
+	if size := len(r.WithdrawalCredentials); size != 32 {
+		err = ssz.ErrBytesLengthFn("DepositRequest.WithdrawalCredentials", size, 32)
+		return
+	}
+	dst = append(dst, r.WithdrawalCredentials...)
+	dst = ssz.MarshalUint64(dst, uint64(r.Amount))
+	dst = append(dst, r.Signature[:]...)
+	dst = ssz.MarshalUint64(dst, r.Index)
+	return
+}
+
+// UnmarshalSSZ ssz unmarshals the DepositRequest object
+func (r *DepositRequest) UnmarshalSSZ(buf []byte) error {
+	if len(buf) != depositRequestSize {
+		return ssz.ErrSize
+	}
+	copy(r.Pubkey[:], buf[0:48])
+	r.WithdrawalCredentials = append([]byte{}, buf[48:80]...)
+	r.Amount = phase0.Gwei(ssz.UnmarshallUint64(buf[80:88]))
+	copy(r.Signature[:], buf[88:184])
+	r.Index = ssz.UnmarshallUint64(buf[184:192])
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the DepositRequest object
+func (r *DepositRequest) SizeSSZ() int {
+	return depositRequestSize
+}
+
+// HashTreeRoot ssz hashes the DepositRequest object
+func (r *DepositRequest) HashTreeRoot() ([32]byte, error) {
+	return ssz.HashWithDefaultHasher(r)
+}
+
+// HashTreeRootWith ssz hashes the DepositRequest object with a hasher
+func (r *DepositRequest) HashTreeRootWith(hh ssz.HashWalker) error {
+	indx := hh.Index()
+	hh.PutBytes(r.Pubkey[:])
+	if size := len(r.WithdrawalCredentials); size != 32 {
+		return ssz.ErrBytesLengthFn("DepositRequest.WithdrawalCredentials", size, 32)
+	}
+	hh.PutBytes(r.WithdrawalCredentials)
+	hh.PutUint64(uint64(r.Amount))
+	hh.PutBytes(r.Signature[:])
+	hh.PutUint64(r.Index)
+	hh.Merkleize(indx)
+	return nil
+}
+
+// GetTree ssz hashes the DepositRequest object
+func (r *DepositRequest) GetTree() (*ssz.Node, error) {
+	return ssz.ProofTree(r)
+}
+
+// MarshalSSZ ssz marshals the ConsolidationRequest object
+func (r *ConsolidationRequest) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(r)
+}
+
+// MarshalSSZTo ssz marshals the ConsolidationRequest object to a target array
+func (r *ConsolidationRequest) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	dst = buf
+	dst = append(dst, r.SourceAddress[:]...)
+	dst = append(dst, r.SourcePubkey[:]...)
+	dst = append(dst, r.TargetPubkey[:]...)
+	return
+}
+
+// UnmarshalSSZ ssz unmarshals the ConsolidationRequest object
+func (r *ConsolidationRequest) UnmarshalSSZ(buf []byte) error {
+	if len(buf) != consolidationRequestSize {
+		return ssz.ErrSize
+	}
+	copy(r.SourceAddress[:], buf[0:20])
+	copy(r.SourcePubkey[:], buf[20:68])
+	copy(r.TargetPubkey[:], buf[68:116])
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the ConsolidationRequest object
+func (r *ConsolidationRequest) SizeSSZ() int {
+	return consolidationRequestSize
+}
+
+// HashTreeRoot ssz hashes the ConsolidationRequest object
+func (r *ConsolidationRequest) HashTreeRoot() ([32]byte, error) {
+	return ssz.HashWithDefaultHasher(r)
+}
+
+// HashTreeRootWith ssz hashes the ConsolidationRequest object with a hasher
+func (r *ConsolidationRequest) HashTreeRootWith(hh ssz.HashWalker) error {
+	indx := hh.Index()
+	hh.PutBytes(r.SourceAddress[:])
+	hh.PutBytes(r.SourcePubkey[:])
+	hh.PutBytes(r.TargetPubkey[:])
+	hh.Merkleize(indx)
+	return nil
+}
+
+// GetTree ssz hashes the ConsolidationRequest object
+func (r *ConsolidationRequest) GetTree() (*ssz.Node, error) {
+	return ssz.ProofTree(r)
+}
+
+// MarshalSSZ ssz marshals the ElectraAttestation object
+func (a *ElectraAttestation) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(a)
+}
+
+// MarshalSSZTo ssz marshals the ElectraAttestation object to a target array
+func (a *ElectraAttestation) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	dst = buf
+	dst = ssz.WriteOffset(dst, electraAttestationFixedSize)
+	if a.Data == nil {
+		a.Data = new(phase0.AttestationData)
+	}
+	if dst, err = a.Data.MarshalSSZTo(dst); err != nil {
+		return
+	}
+	dst = append(dst, a.Signature[:]...)
+	if size := len(a.CommitteeBits); size != 8 {
+		err = ssz.ErrBytesLengthFn("ElectraAttestation.CommitteeBits", size, 8)
+		return
+	}
+	dst = append(dst, a.CommitteeBits...)
+	if size := len(a.AggregationBits); size > maxElectraAggregationBits/8+1 {
+		err = ssz.ErrBytesLengthFn("ElectraAttestation.AggregationBits", size, maxElectraAggregationBits/8+1)
+		return
+	}
+	dst = append(dst, a.AggregationBits...)
+	return
+}
+
+// UnmarshalSSZ ssz unmarshals the ElectraAttestation object
+func (a *ElectraAttestation) UnmarshalSSZ(buf []byte) error {
+	size := uint64(len(buf))
+	if size < electraAttestationFixedSize {
+		return ssz.ErrSize
+	}
+	o0 := ssz.ReadOffset(buf[0:4])
+	if o0 > size {
+		return ssz.ErrOffset
+	}
+	if o0 != electraAttestationFixedSize {
+		return ssz.ErrInvalidVariableOffset
+	}
+	if a.Data == nil {
+		a.Data = new(phase0.AttestationData)
+	}
+	if err := a.Data.UnmarshalSSZ(buf[4:132]); err != nil {
+		return err
+	}
+	copy(a.Signature[:], buf[132:228])
+	a.CommitteeBits = append([]byte{}, buf[228:236]...)
+	if err := ssz.ValidateBitlist(buf[o0:], maxElectraAggregationBits); err != nil {
+		return err
+	}
+	a.AggregationBits = append([]byte{}, buf[o0:]...)
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the ElectraAttestation object
+func (a *ElectraAttestation) SizeSSZ() int {
+	return electraAttestationFixedSize + len(a.AggregationBits)
+}
+
+// HashTreeRoot ssz hashes the ElectraAttestation object
+func (a *ElectraAttestation) HashTreeRoot() ([32]byte, error) {
+	return ssz.HashWithDefaultHasher(a)
+}
+
+// HashTreeRootWith ssz hashes the ElectraAttestation object with a hasher
+func (a *ElectraAttestation) HashTreeRootWith(hh ssz.HashWalker) error {
+	indx := hh.Index()
+	if len(a.AggregationBits) == 0 {
+		return ssz.ErrEmptyBitlist
+	}
+	hh.PutBitlist(a.AggregationBits, maxElectraAggregationBits)
+	if a.Data == nil {
+		a.Data = new(phase0.AttestationData)
+	}
+	if err := a.Data.HashTreeRootWith(hh); err != nil {
+		return err
+	}
+	hh.PutBytes(a.Signature[:])
+	if size := len(a.CommitteeBits); size != 8 {
+		return ssz.ErrBytesLengthFn("ElectraAttestation.CommitteeBits", size, 8)
+	}
+	hh.PutBytes(a.CommitteeBits)
+	hh.Merkleize(indx)
+	return nil
+}
+
+// GetTree ssz hashes the ElectraAttestation object
+func (a *ElectraAttestation) GetTree() (*ssz.Node, error) {
+	return ssz.ProofTree(a)
+}
@@ -0,0 +1,62 @@
+package consensus_objects
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/prysmaticlabs/go-bitfield"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// hashPair hashes two chunks the way merkleization does
+func hashPair(a []byte, b []byte) []byte {
+	h := sha256.Sum256(append(append([]byte{}, a...), b...))
+	return h[:]
+}
+
+// chunk right pads the bytes to 32
+func chunk(data []byte) []byte {
+	return append(append([]byte{}, data...), make([]byte, 32-len(data))...)
+}
+
+func TestExecutionLayerWithdrawalRequest_HashTreeRoot(t *testing.T) {
+	request := RandomExecutionLayerWithdrawalRequest(7)
+	amount := make([]byte, 8)
+	binary.LittleEndian.PutUint64(amount, uint64(request.Amount))
+	pubkeyRoot := hashPair(request.ValidatorPubkey[:32], chunk(request.ValidatorPubkey[32:]))
+	expected := hashPair(hashPair(chunk(request.SourceAddress[:]), pubkeyRoot), hashPair(chunk(amount), make([]byte, 32)))
+
+	root, err := request.HashTreeRoot()
+	require.NoError(t, err)
+	require.Equal(t, expected, root[:])
+}
+
+func TestElectraAttestation_Encoding(t *testing.T) {
+	aggregationBits := bitfield.NewBitlist(4)
+	aggregationBits.SetBitAt(2, true)
+	committeeBits := bitfield.NewBitvector64()
+	committeeBits.SetBitAt(3, true)
+	attestation := &ElectraAttestation{
+		AggregationBits: aggregationBits,
+		Data:            &phase0.AttestationData{Slot: 9, Source: &phase0.Checkpoint{}, Target: &phase0.Checkpoint{Epoch: 1}},
+		CommitteeBits:   committeeBits,
+	}
+
+	attestationSSZ, err := attestation.MarshalSSZ()
+	require.NoError(t, err)
+	require.Len(t, attestationSSZ, attestation.SizeSSZ())
+	require.Equal(t, []byte{0x08}, attestationSSZ[228:229])
+	require.Equal(t, []byte(aggregationBits), attestationSSZ[236:])
+
+	attestationJSON, err := json.Marshal(attestation)
+	require.NoError(t, err)
+	require.Contains(t, string(attestationJSON), `"committee_bits":"0x0800000000000000"`)
+	require.Contains(t, string(attestationJSON), `"aggregation_bits":"0x14"`)
+
+	attestation.CommitteeBits = attestation.CommitteeBits[:4]
+	_, err = attestation.MarshalSSZ()
+	require.Error(t, err)
+	require.Error(t, (&ElectraAttestation{}).UnmarshalJSON([]byte(`{"aggregation_bits":"0x00","data":{},"signature":"0x","committee_bits":"0x"}`)))
+}
@@ -0,0 +1,84 @@
+package consensus_objects
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	fuzz "github.com/google/gofuzz"
+	"github.com/prysmaticlabs/go-bitfield"
+	"time"
+)
+
+const (
+	// maxRandomBitlistLength keeps random bitlists far below the smallest limit of any bitlist in the spec
+	maxRandomBitlistLength = 64
+	// depositProofLength the depth of the deposit contract tree plus the length mix in
+	depositProofLength = 33
+	// maxAttesterSlashings the limit of attester slashings in a block body
+	maxAttesterSlashings = 2
+)
+
+// NewSeed returns a fresh seed for the Random* generators, keep it to regenerate the same object later
+func NewSeed() int64 {
+	return time.Now().UnixNano()
+}
+
+// newFuzzer returns the fuzzer all generators use, the same seed always yields the same objects.
+// Byte slices and bitfields with a fixed size in the spec get that size, so the objects always serialize.
+func newFuzzer(seed int64) *fuzz.Fuzzer {
+	return fuzz.NewWithSeed(seed).NilChance(0).Funcs(
+		func(bits *bitfield.Bitlist, c fuzz.Continue) {
+			*bits = bitfield.NewBitlist(uint64(1 + c.Intn(maxRandomBitlistLength)))
+			for i := uint64(0); i < bits.Len(); i++ {
+				bits.SetBitAt(i, c.RandBool())
+			}
+		},
+		func(bits *bitfield.Bitvector64, c fuzz.Continue) {
+			*bits = randomBytes(c, 8)
+		},
+		func(bits *bitfield.Bitvector128, c fuzz.Continue) {
+			*bits = randomBytes(c, 16)
+		},
+		func(bits *bitfield.Bitvector512, c fuzz.Continue) {
+			*bits = randomBytes(c, 64)
+		},
+		func(eth1Data *phase0.ETH1Data, c fuzz.Continue) {
+			c.Fuzz(&eth1Data.DepositRoot)
+			eth1Data.DepositCount = c.Uint64()
+			eth1Data.BlockHash = randomBytes(c, 32)
+		},
+		func(depositData *phase0.DepositData, c fuzz.Continue) {
+			c.Fuzz(&depositData.PublicKey)
+			depositData.WithdrawalCredentials = randomBytes(c, 32)
+			depositData.Amount = phase0.Gwei(c.Uint64())
+			c.Fuzz(&depositData.Signature)
+		},
+		func(deposit *phase0.Deposit, c fuzz.Continue) {
+			deposit.Proof = make([][]byte, depositProofLength)
+			for i := range deposit.Proof {
+				deposit.Proof[i] = randomBytes(c, 32)
+			}
+			deposit.Data = &phase0.DepositData{}
+			c.Fuzz(deposit.Data)
+		},
+		func(request *DepositRequest, c fuzz.Continue) {
+			c.Fuzz(&request.Pubkey)
+			request.WithdrawalCredentials = randomBytes(c, 32)
+			request.Amount = phase0.Gwei(c.Uint64())
+			c.Fuzz(&request.Signature)
+			request.Index = c.Uint64()
+		},
+		func(slashings *[]*phase0.AttesterSlashing, c fuzz.Continue) {
+			*slashings = make([]*phase0.AttesterSlashing, 1+c.Intn(maxAttesterSlashings))
+			for i := range *slashings {
+				(*slashings)[i] = &phase0.AttesterSlashing{}
+				c.Fuzz((*slashings)[i])
+			}
+		},
+	)
+}
+
+// randomBytes returns n random bytes
+func randomBytes(c fuzz.Continue, n int) []byte {
+	data := make([]byte, n)
+	c.Read(data)
+	return data
+}
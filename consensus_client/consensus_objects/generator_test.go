@@ -0,0 +1,13 @@
+package consensus_objects
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRandomSeed(t *testing.T) {
+	require.Equal(t, RandomSignedVoluntaryExit(42), RandomSignedVoluntaryExit(42))
+	require.Equal(t, RandomAttesterSlashing(42), RandomAttesterSlashing(42))
+	require.Equal(t, RandomCapellaSignedBeaconBlock(42), RandomCapellaSignedBeaconBlock(42))
+	require.NotEqual(t, RandomSignedVoluntaryExit(42), RandomSignedVoluntaryExit(43))
+}
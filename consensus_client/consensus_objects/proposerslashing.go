@@ -2,7 +2,6 @@ package consensus_objects
 
 import (
 	"github.com/attestantio/go-eth2-client/spec/phase0"
-	fuzz "github.com/google/gofuzz"
 )
 
 type ProposerSlashingJSON struct {
@@ -10,9 +9,9 @@ type ProposerSlashingJSON struct {
 	SignedHeader2 *phase0.SignedBeaconBlockHeader `json:"signed_header_2"`
 }
 
-func RandomProposerSlashing() *phase0.ProposerSlashing {
+func RandomProposerSlashing(seed int64) *phase0.ProposerSlashing {
 	var proposerSlashing phase0.ProposerSlashing
-	f := fuzz.New().NilChance(0)
+	f := newFuzzer(seed)
 	for {
 		f.Fuzz(&proposerSlashing)
 		_, err := proposerSlashing.MarshalSSZ()
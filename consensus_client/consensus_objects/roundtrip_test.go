@@ -0,0 +1,192 @@
+package consensus_objects
+
+import (
+	"encoding/json"
+	"fmt"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const roundTripSeeds = 10
+
+type sszObject interface {
+	MarshalSSZ() ([]byte, error)
+	UnmarshalSSZ(buf []byte) error
+}
+
+var roundTripCases = []struct {
+	name   string
+	random func(seed int64) sszObject
+	empty  func() sszObject
+}{
+	{"SignedAggregateAndProof", func(seed int64) sszObject { return RandomAggregateAndProof(seed) }, func() sszObject { return &phase0.SignedAggregateAndProof{} }},
+	{"Attestation", func(seed int64) sszObject { return RandomAttestation(seed) }, func() sszObject { return &phase0.Attestation{} }},
+	{"AttesterSlashing", func(seed int64) sszObject { return RandomAttesterSlashing(seed) }, func() sszObject { return &phase0.AttesterSlashing{} }},
+	{"BlobSidecar", func(seed int64) sszObject { return RandomBlobSideCar(seed) }, func() sszObject { return &deneb.BlobSidecar{} }},
+	{"BLSToExecutionChange", func(seed int64) sszObject { return RandomBLSToExecutionChange(seed) }, func() sszObject { return &capella.BLSToExecutionChange{} }},
+	{"SignedBLSToExecutionChange", func(seed int64) sszObject { return RandomSignedBLSToExecutionChange(seed) }, func() sszObject { return &capella.SignedBLSToExecutionChange{} }},
+	{"ProposerSlashing", func(seed int64) sszObject { return RandomProposerSlashing(seed) }, func() sszObject { return &phase0.ProposerSlashing{} }},
+	{"VoluntaryExit", func(seed int64) sszObject { return RandomVoluntaryExit(seed) }, func() sszObject { return &phase0.VoluntaryExit{} }},
+	{"SignedVoluntaryExit", func(seed int64) sszObject { return RandomSignedVoluntaryExit(seed) }, func() sszObject { return &phase0.SignedVoluntaryExit{} }},
+	{"Phase0SignedBeaconBlock", func(seed int64) sszObject { return RandomPhase0SignedBeaconBlock(seed).Phase0 }, func() sszObject { return &phase0.SignedBeaconBlock{} }},
+	{"AltairSignedBeaconBlock", func(seed int64) sszObject { return RandomAltairSingedBeaconBlock(seed).Altair }, func() sszObject { return &altair.SignedBeaconBlock{} }},
+	{"BellatrixSignedBeaconBlock", func(seed int64) sszObject { return RandomBellatrixSignedBeaconBlock(seed).Bellatrix }, func() sszObject { return &bellatrix.SignedBeaconBlock{} }},
+	{"CapellaSignedBeaconBlock", func(seed int64) sszObject { return RandomCapellaSignedBeaconBlock(seed).Capella }, func() sszObject { return &capella.SignedBeaconBlock{} }},
+	{"DenebSignedBeaconBlock", func(seed int64) sszObject { return RandomDenebSignedBeaconBlock(seed).Deneb }, func() sszObject { return &deneb.SignedBeaconBlock{} }},
+	{"ExecutionLayerWithdrawalRequest", func(seed int64) sszObject { return RandomExecutionLayerWithdrawalRequest(seed) }, func() sszObject { return &ExecutionLayerWithdrawalRequest{} }},
+	{"DepositRequest", func(seed int64) sszObject { return RandomDepositRequest(seed) }, func() sszObject { return &DepositRequest{} }},
+	{"ConsolidationRequest", func(seed int64) sszObject { return RandomConsolidationRequest(seed) }, func() sszObject { return &ConsolidationRequest{} }},
+	{"ElectraAttestation", func(seed int64) sszObject { return RandomElectraAttestation(seed) }, func() sszObject { return &ElectraAttestation{} }},
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range roundTripCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for seed := int64(0); seed < roundTripSeeds; seed++ {
+				object := tc.random(seed)
+
+				objectSSZ, err := object.MarshalSSZ()
+				require.NoError(t, err)
+				fromSSZ := tc.empty()
+				require.NoError(t, fromSSZ.UnmarshalSSZ(objectSSZ), "seed %d", seed)
+				require.Equal(t, object, fromSSZ, "seed %d", seed)
+
+				objectJSON, err := json.Marshal(object)
+				require.NoError(t, err)
+				fromJSON := tc.empty()
+				require.NoError(t, json.Unmarshal(objectJSON, fromJSON), "seed %d", seed)
+				require.Equal(t, object, fromJSON, "seed %d", seed)
+			}
+		})
+	}
+}
+
+var roundTripJSONCases = []struct {
+	name   string
+	random func(seed int64) interface{}
+	empty  func() interface{}
+}{
+	{"Phase0SignedBeaconBlockProposalJSON",
+		func(seed int64) interface{} {
+			return &Phase0SignedBeaconBlockProposalJSON{Data: RandomPhase0SignedBeaconBlock(seed).Phase0}
+		},
+		func() interface{} { return &Phase0SignedBeaconBlockProposalJSON{} }},
+	{"AltairSignedBeaconBlockProposalJSON",
+		func(seed int64) interface{} {
+			return &AltairSignedBeaconBlockProposalJSON{Data: RandomAltairSingedBeaconBlock(seed).Altair}
+		},
+		func() interface{} { return &AltairSignedBeaconBlockProposalJSON{} }},
+	{"BellatrixSignedBeaconBlockProposalJSON",
+		func(seed int64) interface{} {
+			return &BellatrixSignedBeaconBlockProposalJSON{Data: RandomBellatrixSignedBeaconBlock(seed).Bellatrix}
+		},
+		func() interface{} { return &BellatrixSignedBeaconBlockProposalJSON{} }},
+	{"CapellaSignedBeaconBlockProposalJSON",
+		func(seed int64) interface{} {
+			return &CapellaSignedBeaconBlockProposalJSON{Data: RandomCapellaSignedBeaconBlock(seed).Capella}
+		},
+		func() interface{} { return &CapellaSignedBeaconBlockProposalJSON{} }},
+	{"DenebSignedBeaconBlockProposalJSON",
+		func(seed int64) interface{} {
+			return &DenebSignedBeaconBlockProposalJSON{Data: RandomDenebSignedBeaconBlock(seed).Deneb}
+		},
+		func() interface{} { return &DenebSignedBeaconBlockProposalJSON{} }},
+	{"BeaconBlockHeaderResponseJSON",
+		func(seed int64) interface{} {
+			var header v1.BeaconBlockHeader
+			newFuzzer(seed).Fuzz(&header)
+			return &BeaconBlockHeaderResponseJSON{Data: &header, ExecutionOptimistic: seed%2 == 0, Finalized: seed%3 == 0}
+		},
+		func() interface{} { return &BeaconBlockHeaderResponseJSON{} }},
+	{"CheckpointResponseJSON",
+		func(seed int64) interface{} {
+			var finality v1.Finality
+			newFuzzer(seed).Fuzz(&finality)
+			return &CheckpointResponseJSON{Data: &finality, ExecutionOptimistic: seed%2 == 0, Finalized: seed%3 == 0}
+		},
+		func() interface{} { return &CheckpointResponseJSON{} }},
+	{"AttestorSlashingJSON",
+		func(seed int64) interface{} {
+			slashing := RandomAttesterSlashing(seed)
+			return &AttestorSlashingJSON{Attestation1: slashing.Attestation1, Attestation2: slashing.Attestation2}
+		},
+		func() interface{} { return &AttestorSlashingJSON{} }},
+	{"ProposerSlashingJSON",
+		func(seed int64) interface{} {
+			slashing := RandomProposerSlashing(seed)
+			return &ProposerSlashingJSON{SignedHeader1: slashing.SignedHeader1, SignedHeader2: slashing.SignedHeader2}
+		},
+		func() interface{} { return &ProposerSlashingJSON{} }},
+	{"SignedVoluntaryExitJSON",
+		func(seed int64) interface{} {
+			exit := RandomSignedVoluntaryExit(seed)
+			return &SignedVoluntaryExitJSON{Message: exit.Message, Signature: fmt.Sprintf("%#x", exit.Signature)}
+		},
+		func() interface{} { return &SignedVoluntaryExitJSON{} }},
+	{"VoluntaryExitJSON",
+		func(seed int64) interface{} {
+			exit := RandomVoluntaryExit(seed)
+			return &VoluntaryExitJSON{Epoch: fmt.Sprint(exit.Epoch), ValidatorIndex: fmt.Sprint(exit.ValidatorIndex)}
+		},
+		func() interface{} { return &VoluntaryExitJSON{} }},
+	{"SignedBLSToExecutionChangeJSON",
+		func(seed int64) interface{} {
+			change := RandomSignedBLSToExecutionChange(seed)
+			return &SignedBLSToExecutionChangeJSON{Message: change.Message, Signature: fmt.Sprintf("%#x", change.Signature)}
+		},
+		func() interface{} { return &SignedBLSToExecutionChangeJSON{} }},
+}
+
+func TestRoundTripJSON(t *testing.T) {
+	for _, tc := range roundTripJSONCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for seed := int64(0); seed < roundTripSeeds; seed++ {
+				object := tc.random(seed)
+				objectJSON, err := json.Marshal(object)
+				require.NoError(t, err)
+				fromJSON := tc.empty()
+				require.NoError(t, json.Unmarshal(objectJSON, fromJSON), "seed %d", seed)
+				require.Equal(t, object, fromJSON, "seed %d", seed)
+			}
+		})
+	}
+}
+
+// the wrappers have to produce the JSON of the types they stand in for
+func TestRoundTripJSONWrappers(t *testing.T) {
+	for seed := int64(0); seed < roundTripSeeds; seed++ {
+		exit := RandomSignedVoluntaryExit(seed)
+		exitJSON, err := json.Marshal(&SignedVoluntaryExitJSON{Message: exit.Message, Signature: fmt.Sprintf("%#x", exit.Signature)})
+		require.NoError(t, err)
+		var fromExitJSON phase0.SignedVoluntaryExit
+		require.NoError(t, json.Unmarshal(exitJSON, &fromExitJSON))
+		require.Equal(t, exit, &fromExitJSON)
+
+		change := RandomSignedBLSToExecutionChange(seed)
+		changeJSON, err := json.Marshal(&SignedBLSToExecutionChangeJSON{Message: change.Message, Signature: fmt.Sprintf("%#x", change.Signature)})
+		require.NoError(t, err)
+		var fromChangeJSON capella.SignedBLSToExecutionChange
+		require.NoError(t, json.Unmarshal(changeJSON, &fromChangeJSON))
+		require.Equal(t, change, &fromChangeJSON)
+
+		attesterSlashing := RandomAttesterSlashing(seed)
+		attesterSlashingJSON, err := json.Marshal(&AttestorSlashingJSON{Attestation1: attesterSlashing.Attestation1, Attestation2: attesterSlashing.Attestation2})
+		require.NoError(t, err)
+		var fromAttesterSlashingJSON phase0.AttesterSlashing
+		require.NoError(t, json.Unmarshal(attesterSlashingJSON, &fromAttesterSlashingJSON))
+		require.Equal(t, attesterSlashing, &fromAttesterSlashingJSON)
+
+		proposerSlashing := RandomProposerSlashing(seed)
+		proposerSlashingJSON, err := json.Marshal(&ProposerSlashingJSON{SignedHeader1: proposerSlashing.SignedHeader1, SignedHeader2: proposerSlashing.SignedHeader2})
+		require.NoError(t, err)
+		var fromProposerSlashingJSON phase0.ProposerSlashing
+		require.NoError(t, json.Unmarshal(proposerSlashingJSON, &fromProposerSlashingJSON))
+		require.Equal(t, proposerSlashing, &fromProposerSlashingJSON)
+	}
+}
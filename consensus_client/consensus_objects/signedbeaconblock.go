@@ -1,7 +1,6 @@
 package consensus_objects
 
 import (
-	"fmt"
 	v1 "github.com/attestantio/go-eth2-client/api/v1"
 	"github.com/attestantio/go-eth2-client/spec"
 	"github.com/attestantio/go-eth2-client/spec/altair"
@@ -9,7 +8,6 @@ import (
 	"github.com/attestantio/go-eth2-client/spec/capella"
 	"github.com/attestantio/go-eth2-client/spec/deneb"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
-	fuzz "github.com/google/gofuzz"
 )
 
 // BeaconBlockHeaderResponseJSON represents the response in JSON for a /eth/v1/block/header request
@@ -39,10 +37,10 @@ type DenebSignedBeaconBlockProposalJSON struct {
 	Data *deneb.SignedBeaconBlock `json:"data"`
 }
 
-// TODO round trip ssz fails
-func RandomPhase0SignedBeaconBlock() *spec.VersionedSignedBeaconBlock {
+// RandomPhase0SignedBeaconBlock creates a random phase0 signed beacon block that round-trips through SSZ and JSON
+func RandomPhase0SignedBeaconBlock(seed int64) *spec.VersionedSignedBeaconBlock {
 	var signedBeaconBlock phase0.SignedBeaconBlock
-	f := fuzz.New().NilChance(0)
+	f := newFuzzer(seed)
 	for {
 		f.Fuzz(&signedBeaconBlock)
 		_, err := signedBeaconBlock.MarshalSSZ()
@@ -56,10 +54,10 @@ func RandomPhase0SignedBeaconBlock() *spec.VersionedSignedBeaconBlock {
 	}
 }
 
-// TODO round trip ssz fails
-func RandomAltairSingedBeaconBlock() *spec.VersionedSignedBeaconBlock {
+// RandomAltairSingedBeaconBlock creates a random altair signed beacon block that round-trips through SSZ and JSON
+func RandomAltairSingedBeaconBlock(seed int64) *spec.VersionedSignedBeaconBlock {
 	var signedBeaconBlock altair.SignedBeaconBlock
-	f := fuzz.New().NilChance(0)
+	f := newFuzzer(seed)
 	for {
 		f.Fuzz(&signedBeaconBlock)
 		_, err := signedBeaconBlock.MarshalSSZ()
@@ -73,10 +71,10 @@ func RandomAltairSingedBeaconBlock() *spec.VersionedSignedBeaconBlock {
 	}
 }
 
-// TODO round trip ssz fails
-func RandomBellatrixSignedBeaconBlock() *spec.VersionedSignedBeaconBlock {
+// RandomBellatrixSignedBeaconBlock creates a random bellatrix signed beacon block that round-trips through SSZ and JSON
+func RandomBellatrixSignedBeaconBlock(seed int64) *spec.VersionedSignedBeaconBlock {
 	var signedBeaconBlock bellatrix.SignedBeaconBlock
-	f := fuzz.New().NilChance(0)
+	f := newFuzzer(seed)
 	for {
 		f.Fuzz(&signedBeaconBlock)
 		_, err := signedBeaconBlock.MarshalSSZ()
@@ -90,13 +88,13 @@ func RandomBellatrixSignedBeaconBlock() *spec.VersionedSignedBeaconBlock {
 	}
 }
 
-// TODO round trip ssz fails
-func RandomCapellaSignedBeaconBlock() *spec.VersionedSignedBeaconBlock {
+// RandomCapellaSignedBeaconBlock creates a random capella signed beacon block that round-trips through SSZ and JSON
+func RandomCapellaSignedBeaconBlock(seed int64) *spec.VersionedSignedBeaconBlock {
 	var signedBeaconBlock capella.SignedBeaconBlock
-	f := fuzz.New().NilChance(0)
+	f := newFuzzer(seed)
 	for {
 		f.Fuzz(&signedBeaconBlock)
-		_, err := signedBeaconBlock.MarshalJSON()
+		_, err := signedBeaconBlock.MarshalSSZ()
 		if err == nil {
 			break
 		}
@@ -107,17 +105,15 @@ func RandomCapellaSignedBeaconBlock() *spec.VersionedSignedBeaconBlock {
 	}
 }
 
-// TODO round trip ssz fails
-func RandomDenebSignedBeaconBlock() *spec.VersionedSignedBeaconBlock {
+// RandomDenebSignedBeaconBlock creates a random deneb signed beacon block that round-trips through SSZ and JSON
+func RandomDenebSignedBeaconBlock(seed int64) *spec.VersionedSignedBeaconBlock {
 	var signedBeaconBlock deneb.SignedBeaconBlock
-	f := fuzz.New().NilChance(0)
+	f := newFuzzer(seed)
 	for {
 		f.Fuzz(&signedBeaconBlock)
 		_, err := signedBeaconBlock.MarshalSSZ()
 		if err == nil {
 			break
-		} else {
-			fmt.Println("generated block wasn't serializable, retrying.")
 		}
 	}
 	return &spec.VersionedSignedBeaconBlock{
@@ -2,7 +2,6 @@ package consensus_objects
 
 import (
 	"github.com/attestantio/go-eth2-client/spec/phase0"
-	fuzz "github.com/google/gofuzz"
 )
 
 type SignedVoluntaryExitJSON struct {
@@ -16,9 +15,9 @@ type VoluntaryExitJSON struct {
 }
 
 // RandomVoluntaryExit create a random voluntary exit
-func RandomVoluntaryExit() *phase0.VoluntaryExit {
+func RandomVoluntaryExit(seed int64) *phase0.VoluntaryExit {
 	var voluntaryExit phase0.VoluntaryExit
-	f := fuzz.New().NilChance(0)
+	f := newFuzzer(seed)
 	for {
 		f.Fuzz(&voluntaryExit)
 		_, err := voluntaryExit.MarshalSSZ()
@@ -30,9 +29,9 @@ func RandomVoluntaryExit() *phase0.VoluntaryExit {
 }
 
 // RandomSignedVoluntaryExit create a random signed voluntary exit
-func RandomSignedVoluntaryExit() *phase0.SignedVoluntaryExit {
+func RandomSignedVoluntaryExit(seed int64) *phase0.SignedVoluntaryExit {
 	var signedVoluntaryExit phase0.SignedVoluntaryExit
-	f := fuzz.New().NilChance(0)
+	f := newFuzzer(seed)
 	for {
 		f.Fuzz(&signedVoluntaryExit)
 		_, err := signedVoluntaryExit.MarshalSSZ()
@@ -0,0 +1,225 @@
+package consensus_client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Topics served by the /eth/v1/events endpoint that we know how to decode.
+const (
+	HeadEventTopic                 = "head"
+	BlockEventTopic                = "block"
+	FinalizedCheckpointEventTopic  = "finalized_checkpoint"
+	ChainReorgEventTopic           = "chain_reorg"
+	VoluntaryExitEventTopic        = "voluntary_exit"
+	BLSToExecutionChangeEventTopic = "bls_to_execution_change"
+	AttesterSlashingEventTopic     = "attester_slashing"
+	ProposerSlashingEventTopic     = "proposer_slashing"
+	BlobSidecarEventTopic          = "blob_sidecar"
+)
+
+// EventReconnectDelay how long a subscription waits before reconnecting to a dropped event stream
+var EventReconnectDelay = time.Second
+
+// Event is a decoded event from a clients event stream.
+type Event struct {
+	// Client the name of the client that emitted the event
+	Client string
+	Topic  string
+	// Data the typed payload, see eventDecoders for the type used for each topic
+	Data interface{}
+}
+
+// EventSubscription delivers the events of a single client. Both channels are closed once the subscription context is done.
+type EventSubscription struct {
+	Events <-chan *Event
+	// Errors receives stream and decoding errors, errors are dropped if nobody is reading them
+	Errors <-chan error
+}
+
+var eventDecoders = map[string]func([]byte) (interface{}, error){
+	HeadEventTopic: func(data []byte) (interface{}, error) {
+		var event v1.HeadEvent
+		return &event, json.Unmarshal(data, &event)
+	},
+	BlockEventTopic: func(data []byte) (interface{}, error) {
+		var event v1.BlockEvent
+		return &event, json.Unmarshal(data, &event)
+	},
+	FinalizedCheckpointEventTopic: func(data []byte) (interface{}, error) {
+		var event v1.FinalizedCheckpointEvent
+		return &event, json.Unmarshal(data, &event)
+	},
+	ChainReorgEventTopic: func(data []byte) (interface{}, error) {
+		var event v1.ChainReorgEvent
+		return &event, json.Unmarshal(data, &event)
+	},
+	VoluntaryExitEventTopic: func(data []byte) (interface{}, error) {
+		var exit phase0.SignedVoluntaryExit
+		return &exit, json.Unmarshal(data, &exit)
+	},
+	BLSToExecutionChangeEventTopic: func(data []byte) (interface{}, error) {
+		var change capella.SignedBLSToExecutionChange
+		return &change, json.Unmarshal(data, &change)
+	},
+	AttesterSlashingEventTopic: func(data []byte) (interface{}, error) {
+		var slashing phase0.AttesterSlashing
+		return &slashing, json.Unmarshal(data, &slashing)
+	},
+	ProposerSlashingEventTopic: func(data []byte) (interface{}, error) {
+		var slashing phase0.ProposerSlashing
+		return &slashing, json.Unmarshal(data, &slashing)
+	},
+	BlobSidecarEventTopic: func(data []byte) (interface{}, error) {
+		var event v1.BlobSidecarEvent
+		return &event, json.Unmarshal(data, &event)
+	},
+}
+
+// SubscribeEvents subscribes to the clients /eth/v1/events stream for the given topics.
+// The first connection is made before returning so an unreachable client or unsupported topic is reported immediately,
+// afterwards the stream is re-established until ctx is cancelled.
+func (c *ConsensusClient) SubscribeEvents(ctx context.Context, topics ...string) (*EventSubscription, error) {
+	if len(topics) == 0 {
+		return nil, errors.New("no event topics supplied")
+	}
+	for _, topic := range topics {
+		if _, ok := eventDecoders[topic]; !ok {
+			return nil, fmt.Errorf("unsupported event topic: %s", topic)
+		}
+	}
+
+	query := url.Values{"topics": topics}
+	endpoint := fmt.Sprintf("%s/eth/v1/events?%s", strings.TrimSuffix(c.BeaconAPI, "/"), query.Encode())
+
+	resp, err := c.openEventStream(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *Event, 64)
+	errs := make(chan error, 16)
+	go func() {
+		defer close(events)
+		defer close(errs)
+		for {
+			err := c.readEventStream(ctx, resp, events, errs)
+			if ctx.Err() != nil {
+				return
+			}
+			sendEventError(errs, errors.Wrapf(err, "event stream for client %s dropped", c.Name))
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(EventReconnectDelay):
+				}
+				resp, err = c.openEventStream(ctx, endpoint)
+				if err == nil {
+					break
+				}
+				sendEventError(errs, err)
+			}
+		}
+	}()
+
+	return &EventSubscription{Events: events, Errors: errs}, nil
+}
+
+// openEventStream connects to the event stream endpoint
+func (c *ConsensusClient) openEventStream(ctx context.Context, endpoint string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create event stream request")
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to event stream for client: %s", c.Name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("event stream for client: %s returned status %d", c.Name, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// readEventStream parses server sent events from the response until the stream ends or ctx is cancelled
+func (c *ConsensusClient) readEventStream(ctx context.Context, resp *http.Response, events chan<- *Event, errs chan<- error) error {
+	defer resp.Body.Close()
+
+	var topic string
+	var data bytes.Buffer
+	scanner := bufio.NewScanner(resp.Body)
+	// blob sidecar and slashing payloads can be large
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if topic != "" && data.Len() > 0 {
+				event, err := c.decodeEvent(topic, data.Bytes())
+				if err != nil {
+					sendEventError(errs, err)
+				} else {
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+			}
+			topic = ""
+			data.Reset()
+		case strings.HasPrefix(line, ":"):
+			// comment, used as keep alive
+		case strings.HasPrefix(line, "event:"):
+			topic = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("event stream closed by client")
+}
+
+// decodeEvent decodes the data of a single event into its typed payload
+func (c *ConsensusClient) decodeEvent(topic string, data []byte) (*Event, error) {
+	decoder, ok := eventDecoders[topic]
+	if !ok {
+		return nil, fmt.Errorf("received unsupported event topic: %s", topic)
+	}
+	payload, err := decoder(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s event", topic)
+	}
+	return &Event{
+		Client: c.Name,
+		Topic:  topic,
+		Data:   payload,
+	}, nil
+}
+
+func sendEventError(errs chan<- error, err error) {
+	select {
+	case errs <- err:
+	default:
+	}
+}
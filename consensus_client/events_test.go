@@ -0,0 +1,115 @@
+package consensus_client
+
+import (
+	"context"
+	"fmt"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	testHeadEvent          = `{"slot":"10","block":"0x9a2fefd2fdb57f74993c7780ea5b9030d2897b615b89f808011ca5aebed54eaf","state":"0x600e852a08c1200654ddf11025f1ceacb3c2e74bdd5c630cde0838b2591b69f9","epoch_transition":false,"previous_duty_dependent_root":"0x5e0043f107cb57913498fbf2f99ff55e730bf1e151f02f221e977c91a90a0e91","current_duty_dependent_root":"0x5e0043f107cb57913498fbf2f99ff55e730bf1e151f02f221e977c91a90a0e91","execution_optimistic":false}`
+	testFinalizedEvent     = `{"block":"0x9a2fefd2fdb57f74993c7780ea5b9030d2897b615b89f808011ca5aebed54eaf","state":"0x600e852a08c1200654ddf11025f1ceacb3c2e74bdd5c630cde0838b2591b69f9","epoch":"2","execution_optimistic":false}`
+	testVoluntaryExitEvent = `{"message":{"epoch":"1","validator_index":"1"},"signature":"0x1b66ac1fb663c9bc59509846d6ec05345bd908eda73e670af888da41af171505cc411d61252fb6cb3fa0017b679f8bb2305b26a285fa2737f175668d0dff91cc1b66ac1fb663c9bc59509846d6ec05345bd908eda73e670af888da41af171505"}`
+	testBLSChangeEvent     = `{"message":{"validator_index":"1","from_bls_pubkey":"0x93247f2209abcacf57b75a51dafae777f9dd38bc7053d1af526f220a7489a6d3a2753e5f3e8b1cfe39b56f43611df74a","to_execution_address":"0xabcf8e0d4e9587369b2301d0790347320302cc09"},"signature":"0x1b66ac1fb663c9bc59509846d6ec05345bd908eda73e670af888da41af171505cc411d61252fb6cb3fa0017b679f8bb2305b26a285fa2737f175668d0dff91cc1b66ac1fb663c9bc59509846d6ec05345bd908eda73e670af888da41af171505"}`
+)
+
+// newTestEventServer starts a local stand-in for the beacon api event stream that writes the events and then idles
+func newTestEventServer(t *testing.T, events [][2]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/eth/v1/events" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprint(w, ": keep alive\n\n")
+		for _, event := range events {
+			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event[0], event[1])
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func nextEvent(t *testing.T, subscription *EventSubscription) *Event {
+	select {
+	case event := <-subscription.Events:
+		require.NotNil(t, event)
+		return event
+	case <-time.After(Timeout):
+		require.FailNow(t, "timed out waiting for event")
+	}
+	return nil
+}
+
+func TestConsensusClient_SubscribeEvents(t *testing.T) {
+	server := newTestEventServer(t, [][2]string{
+		{HeadEventTopic, testHeadEvent},
+		{FinalizedCheckpointEventTopic, testFinalizedEvent},
+		{VoluntaryExitEventTopic, testVoluntaryExitEvent},
+		{BLSToExecutionChangeEventTopic, testBLSChangeEvent},
+	})
+	client := &ConsensusClient{Name: "testClient", BeaconAPI: server.URL}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	subscription, err := client.SubscribeEvents(ctx, HeadEventTopic, FinalizedCheckpointEventTopic, VoluntaryExitEventTopic, BLSToExecutionChangeEventTopic)
+	require.NoError(t, err)
+
+	event := nextEvent(t, subscription)
+	require.Equal(t, "testClient", event.Client)
+	require.Equal(t, HeadEventTopic, event.Topic)
+	require.Equal(t, phase0.Slot(10), event.Data.(*v1.HeadEvent).Slot)
+
+	event = nextEvent(t, subscription)
+	require.Equal(t, phase0.Epoch(2), event.Data.(*v1.FinalizedCheckpointEvent).Epoch)
+
+	event = nextEvent(t, subscription)
+	require.Equal(t, phase0.ValidatorIndex(1), event.Data.(*phase0.SignedVoluntaryExit).Message.ValidatorIndex)
+
+	event = nextEvent(t, subscription)
+	require.Equal(t, phase0.ValidatorIndex(1), event.Data.(*capella.SignedBLSToExecutionChange).Message.ValidatorIndex)
+
+	cancel()
+	select {
+	case _, ok := <-subscription.Events:
+		require.False(t, ok)
+	case <-time.After(Timeout):
+		require.FailNow(t, "subscription was not closed after cancel")
+	}
+}
+
+func TestConsensusClient_SubscribeEventsBadPayload(t *testing.T) {
+	server := newTestEventServer(t, [][2]string{
+		{HeadEventTopic, `{"slot":"10"}`},
+		{HeadEventTopic, testHeadEvent},
+	})
+	client := &ConsensusClient{Name: "testClient", BeaconAPI: server.URL}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	subscription, err := client.SubscribeEvents(ctx, HeadEventTopic)
+	require.NoError(t, err)
+
+	// the malformed event is reported and the stream carries on
+	event := nextEvent(t, subscription)
+	require.Equal(t, phase0.Slot(10), event.Data.(*v1.HeadEvent).Slot)
+	require.Error(t, <-subscription.Errors)
+}
+
+func TestConsensusClient_SubscribeEventsUnsupportedTopic(t *testing.T) {
+	client := &ConsensusClient{Name: "testClient", BeaconAPI: "http://127.0.0.1:0"}
+	_, err := client.SubscribeEvents(context.Background(), "not_a_topic")
+	require.Error(t, err)
+	_, err = client.SubscribeEvents(context.Background())
+	require.Error(t, err)
+}
@@ -0,0 +1,917 @@
+package consensus_client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"eth-testnet-tool/validator"
+	"fmt"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	eth2client "github.com/attestantio/go-eth2-client/http"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"github.com/prysmaticlabs/go-bitfield"
+	"github.com/rs/zerolog"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockBeaconState is the in memory chain served by a MockBeaconNode.
+// The node only knows a single state, every state id is answered from it.
+type MockBeaconState struct {
+	// Spec the values served by /eth/v1/config/spec, formatted the way clients serve them
+	Spec            map[string]string
+	Genesis         *v1.Genesis
+	ForkSchedule    []*phase0.Fork
+	DepositContract *v1.DepositContract
+	// Blocks the canonical chain by slot, the highest slot is the head
+	Blocks     map[phase0.Slot]*spec.VersionedSignedBeaconBlock
+	Validators []*v1.Validator
+	Finality   *v1.Finality
+	// Committees by epoch, epochs without committees get the active validators spread over the slots of the epoch
+	Committees map[phase0.Epoch][]*v1.BeaconCommittee
+	// Proposers the proposer of each slot, slots without one are proposed by the active validators in turn
+	Proposers map[phase0.Slot]phase0.ValidatorIndex
+
+	VoluntaryExitPool        []*phase0.SignedVoluntaryExit
+	BLSToExecutionChangePool []*capella.SignedBLSToExecutionChange
+	AttesterSlashingPool     []*phase0.AttesterSlashing
+	ProposerSlashingPool     []*phase0.ProposerSlashing
+
+	// RejectSubmissions makes every pool submission fail with a 400
+	RejectSubmissions bool
+	// RejectValidators rejects the exits and bls to execution changes of these validators, the rest of a submission is accepted
+	RejectValidators map[phase0.ValidatorIndex]bool
+	// CheckWithdrawalCredentials rejects bls to execution changes of unknown validators or from a key that doesn't match their withdrawal credentials
+	CheckWithdrawalCredentials bool
+}
+
+// MockSubmissions everything that was submitted to the pools of a MockBeaconNode, in order
+type MockSubmissions struct {
+	VoluntaryExits        []*phase0.SignedVoluntaryExit
+	BLSToExecutionChanges []*capella.SignedBLSToExecutionChange
+	AttesterSlashings     []*phase0.AttesterSlashing
+	ProposerSlashings     []*phase0.ProposerSlashing
+	AggregateAndProofs    []*phase0.SignedAggregateAndProof
+	Blocks                []*spec.VersionedSignedBeaconBlock
+}
+
+// MockBeaconNode is an in process stand-in for a beacon node's API, for testing without a network
+type MockBeaconNode struct {
+	Server *httptest.Server
+
+	lock        sync.Mutex
+	state       *MockBeaconState
+	submissions MockSubmissions
+	requests    map[string]int
+}
+
+// DefaultMockSpec returns a minimal preset spec with every fork up to capella at genesis
+func DefaultMockSpec() map[string]string {
+	return map[string]string{
+		"CONFIG_NAME":                         "mock",
+		"PRESET_BASE":                         "minimal",
+		"SECONDS_PER_SLOT":                    "6",
+		"SLOTS_PER_EPOCH":                     "8",
+		"SHARD_COMMITTEE_PERIOD":              "0",
+		"MAX_SEED_LOOKAHEAD":                  "4",
+		"MIN_VALIDATOR_WITHDRAWABILITY_DELAY": "256",
+		"MIN_PER_EPOCH_CHURN_LIMIT":           "2",
+		"CHURN_LIMIT_QUOTIENT":                "32",
+		"MAX_EFFECTIVE_BALANCE":               "32000000000",
+		"DEPOSIT_CHAIN_ID":                    "1337",
+		"DEPOSIT_NETWORK_ID":                  "1337",
+		"DEPOSIT_CONTRACT_ADDRESS":            "0x4242424242424242424242424242424242424242",
+		"GENESIS_FORK_VERSION":                "0x00000001",
+		"ALTAIR_FORK_VERSION":                 "0x01000001",
+		"ALTAIR_FORK_EPOCH":                   "0",
+		"BELLATRIX_FORK_VERSION":              "0x02000001",
+		"BELLATRIX_FORK_EPOCH":                "0",
+		"CAPELLA_FORK_VERSION":                "0x03000001",
+		"CAPELLA_FORK_EPOCH":                  "0",
+		"DOMAIN_BEACON_PROPOSER":              "0x00000000",
+		"DOMAIN_BEACON_ATTESTER":              "0x01000000",
+		"DOMAIN_RANDAO":                       "0x02000000",
+		"DOMAIN_DEPOSIT":                      "0x03000000",
+		"DOMAIN_VOLUNTARY_EXIT":               "0x04000000",
+		"DOMAIN_SELECTION_PROOF":              "0x05000000",
+		"DOMAIN_AGGREGATE_AND_PROOF":          "0x06000000",
+		"DOMAIN_SYNC_COMMITTEE":               "0x07000000",
+		"DOMAIN_BLS_TO_EXECUTION_CHANGE":      "0x0a000000",
+	}
+}
+
+// NewMockBeaconState returns a capella chain with only a genesis block and the validators active since genesis
+func NewMockBeaconState(validators []*validator.Validator) *MockBeaconState {
+	state := &MockBeaconState{
+		Spec: DefaultMockSpec(),
+		Genesis: &v1.Genesis{
+			GenesisTime:           time.Unix(1690000000, 0),
+			GenesisValidatorsRoot: phase0.Root{0x42},
+			GenesisForkVersion:    phase0.Version{0x00, 0x00, 0x00, 0x01},
+		},
+		ForkSchedule: []*phase0.Fork{
+			{PreviousVersion: phase0.Version{0x00, 0x00, 0x00, 0x01}, CurrentVersion: phase0.Version{0x00, 0x00, 0x00, 0x01}, Epoch: 0},
+			{PreviousVersion: phase0.Version{0x00, 0x00, 0x00, 0x01}, CurrentVersion: phase0.Version{0x01, 0x00, 0x00, 0x01}, Epoch: 0},
+			{PreviousVersion: phase0.Version{0x01, 0x00, 0x00, 0x01}, CurrentVersion: phase0.Version{0x02, 0x00, 0x00, 0x01}, Epoch: 0},
+			{PreviousVersion: phase0.Version{0x02, 0x00, 0x00, 0x01}, CurrentVersion: phase0.Version{0x03, 0x00, 0x00, 0x01}, Epoch: 0},
+		},
+		DepositContract: &v1.DepositContract{
+			ChainID: 1337,
+			Address: []byte{0x42, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42, 0x42},
+		},
+		Blocks: map[phase0.Slot]*spec.VersionedSignedBeaconBlock{
+			0: NewMockCapellaBlock(0, 0, phase0.Root{}),
+		},
+		Finality: &v1.Finality{
+			Finalized:         &phase0.Checkpoint{},
+			Justified:         &phase0.Checkpoint{},
+			PreviousJustified: &phase0.Checkpoint{},
+		},
+		Committees:               make(map[phase0.Epoch][]*v1.BeaconCommittee),
+		Proposers:                make(map[phase0.Slot]phase0.ValidatorIndex),
+		VoluntaryExitPool:        []*phase0.SignedVoluntaryExit{},
+		BLSToExecutionChangePool: []*capella.SignedBLSToExecutionChange{},
+		AttesterSlashingPool:     []*phase0.AttesterSlashing{},
+		ProposerSlashingPool:     []*phase0.ProposerSlashing{},
+	}
+	for _, v := range validators {
+		state.Validators = append(state.Validators, NewMockValidator(v))
+	}
+	return state
+}
+
+// NewMockValidator returns an active validator with 0x00 withdrawal credentials for one of our validators
+func NewMockValidator(v *validator.Validator) *v1.Validator {
+	withdrawalCredentials := sha256.Sum256(v.WithdrawalPublicKey[:])
+	withdrawalCredentials[0] = 0x00
+	return &v1.Validator{
+		Index:   phase0.ValidatorIndex(v.ValidatorIndex),
+		Balance: 32_000_000_000,
+		Status:  v1.ValidatorStateActiveOngoing,
+		Validator: &phase0.Validator{
+			PublicKey:             v.ValidatorPublicKey,
+			WithdrawalCredentials: withdrawalCredentials[:],
+			EffectiveBalance:      32_000_000_000,
+			ExitEpoch:             phase0.Epoch(^uint64(0)),
+			WithdrawableEpoch:     phase0.Epoch(^uint64(0)),
+		},
+	}
+}
+
+// NewMockCapellaBlock returns an empty capella block that survives the JSON round trip
+func NewMockCapellaBlock(slot phase0.Slot, proposer phase0.ValidatorIndex, parentRoot phase0.Root) *spec.VersionedSignedBeaconBlock {
+	return &spec.VersionedSignedBeaconBlock{
+		Version: spec.DataVersionCapella,
+		Capella: &capella.SignedBeaconBlock{
+			Message: &capella.BeaconBlock{
+				Slot:          slot,
+				ProposerIndex: proposer,
+				ParentRoot:    parentRoot,
+				Body: &capella.BeaconBlockBody{
+					ETH1Data: &phase0.ETH1Data{BlockHash: make([]byte, 32)},
+					SyncAggregate: &altair.SyncAggregate{
+						SyncCommitteeBits: bitfield.NewBitvector512(),
+					},
+					ExecutionPayload: &capella.ExecutionPayload{
+						BlockNumber:  uint64(slot),
+						ExtraData:    []byte{},
+						Transactions: []bellatrix.Transaction{},
+						Withdrawals:  []*capella.Withdrawal{},
+					},
+					ProposerSlashings:     []*phase0.ProposerSlashing{},
+					AttesterSlashings:     []*phase0.AttesterSlashing{},
+					Attestations:          []*phase0.Attestation{},
+					Deposits:              []*phase0.Deposit{},
+					VoluntaryExits:        []*phase0.SignedVoluntaryExit{},
+					BLSToExecutionChanges: []*capella.SignedBLSToExecutionChange{},
+				},
+			},
+		},
+	}
+}
+
+// NewMockBeaconNode starts serving the state, close the node when done
+func NewMockBeaconNode(state *MockBeaconState) *MockBeaconNode {
+	node := &MockBeaconNode{state: state, requests: make(map[string]int)}
+	node.Server = httptest.NewServer(node.handler())
+	return node
+}
+
+// Close stops the node
+func (m *MockBeaconNode) Close() {
+	m.Server.Close()
+}
+
+// ConsensusClient returns a ConsensusClient connected to the node
+func (m *MockBeaconNode) ConsensusClient(name string) (*ConsensusClient, error) {
+	service, err := eth2client.New(context.Background(),
+		eth2client.WithAddress(m.Server.URL),
+		eth2client.WithTimeout(5*time.Second),
+		eth2client.WithEnforceJSON(true),
+		eth2client.WithLogLevel(zerolog.Disabled))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to mock beacon node")
+	}
+	return &ConsensusClient{
+		Name:          name,
+		BeaconAPI:     m.Server.URL,
+		BeaconService: service.(*eth2client.Service),
+	}, nil
+}
+
+// Update changes the state the node serves
+func (m *MockBeaconNode) Update(update func(state *MockBeaconState)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	statuses := make(map[*v1.Validator]v1.ValidatorState, len(m.state.Validators))
+	for _, v := range m.state.Validators {
+		statuses[v] = v.Status
+	}
+	update(m.state)
+	m.state.syncValidatorStatuses(statuses)
+}
+
+// AddBlock adds the block to the chain, a block at a higher slot than the rest becomes the head
+func (m *MockBeaconNode) AddBlock(block *spec.VersionedSignedBeaconBlock) error {
+	slot, err := block.Slot()
+	if err != nil {
+		return err
+	}
+	m.Update(func(state *MockBeaconState) {
+		state.Blocks[slot] = block
+	})
+	return nil
+}
+
+// Requests returns how many requests the node got for the path
+func (m *MockBeaconNode) Requests(path string) int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.requests[path]
+}
+
+// Submissions returns everything that was submitted to the node so far
+func (m *MockBeaconNode) Submissions() MockSubmissions {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return MockSubmissions{
+		VoluntaryExits:        append([]*phase0.SignedVoluntaryExit{}, m.submissions.VoluntaryExits...),
+		BLSToExecutionChanges: append([]*capella.SignedBLSToExecutionChange{}, m.submissions.BLSToExecutionChanges...),
+		AttesterSlashings:     append([]*phase0.AttesterSlashing{}, m.submissions.AttesterSlashings...),
+		ProposerSlashings:     append([]*phase0.ProposerSlashing{}, m.submissions.ProposerSlashings...),
+		AggregateAndProofs:    append([]*phase0.SignedAggregateAndProof{}, m.submissions.AggregateAndProofs...),
+		Blocks:                append([]*spec.VersionedSignedBeaconBlock{}, m.submissions.Blocks...),
+	}
+}
+
+func (m *MockBeaconNode) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/eth/v1/node/version", m.get(func(state *MockBeaconState, r *http.Request) (interface{}, error) {
+		return map[string]string{"version": "MockBeaconNode/v0.0.0"}, nil
+	}))
+	mux.HandleFunc("/eth/v1/config/spec", m.get(func(state *MockBeaconState, r *http.Request) (interface{}, error) {
+		return state.Spec, nil
+	}))
+	mux.HandleFunc("/eth/v1/config/fork_schedule", m.get(func(state *MockBeaconState, r *http.Request) (interface{}, error) {
+		return state.ForkSchedule, nil
+	}))
+	mux.HandleFunc("/eth/v1/config/deposit_contract", m.get(func(state *MockBeaconState, r *http.Request) (interface{}, error) {
+		return state.DepositContract, nil
+	}))
+	mux.HandleFunc("/eth/v1/beacon/genesis", m.get(func(state *MockBeaconState, r *http.Request) (interface{}, error) {
+		return state.Genesis, nil
+	}))
+	mux.HandleFunc("/eth/v1/beacon/headers/", m.get(func(state *MockBeaconState, r *http.Request) (interface{}, error) {
+		block, err := state.block(strings.TrimPrefix(r.URL.Path, "/eth/v1/beacon/headers/"))
+		if err != nil {
+			return nil, err
+		}
+		return mockBlockHeader(block)
+	}))
+	mux.HandleFunc("/eth/v1/validator/duties/proposer/", m.get(func(state *MockBeaconState, r *http.Request) (interface{}, error) {
+		epoch, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/eth/v1/validator/duties/proposer/"), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return state.proposerDuties(phase0.Epoch(epoch)), nil
+	}))
+	mux.HandleFunc("/eth/v2/beacon/blocks/", m.serveBlock)
+	mux.HandleFunc("/eth/v1/beacon/states/", m.get(m.serveState))
+	mux.HandleFunc("/eth/v2/debug/beacon/states/", m.serveDebugState)
+	mux.HandleFunc("/eth/v1/beacon/pool/voluntary_exits", m.pool(
+		func(state *MockBeaconState) interface{} { return state.VoluntaryExitPool },
+		func(state *MockBeaconState, body []byte) error {
+			var exit phase0.SignedVoluntaryExit
+			if err := json.Unmarshal(body, &exit); err != nil {
+				return err
+			}
+			if state.RejectValidators[exit.Message.ValidatorIndex] {
+				return fmt.Errorf("exit of validator %d rejected by mock beacon node", exit.Message.ValidatorIndex)
+			}
+			state.VoluntaryExitPool = append(state.VoluntaryExitPool, &exit)
+			m.submissions.VoluntaryExits = append(m.submissions.VoluntaryExits, &exit)
+			return nil
+		}))
+	mux.HandleFunc("/eth/v1/beacon/pool/bls_to_execution_changes", m.pool(
+		func(state *MockBeaconState) interface{} { return state.BLSToExecutionChangePool },
+		func(state *MockBeaconState, body []byte) error {
+			var changes []*capella.SignedBLSToExecutionChange
+			if err := json.Unmarshal(body, &changes); err != nil {
+				return err
+			}
+			var failures []mockIndexedFailure
+			for i, change := range changes {
+				if state.RejectValidators[change.Message.ValidatorIndex] {
+					failures = append(failures, mockIndexedFailure{Index: i, Message: fmt.Sprintf("change of validator %d rejected by mock beacon node", change.Message.ValidatorIndex)})
+					continue
+				}
+				if state.CheckWithdrawalCredentials {
+					if err := state.checkWithdrawalCredentials(change.Message); err != nil {
+						failures = append(failures, mockIndexedFailure{Index: i, Message: err.Error()})
+						continue
+					}
+				}
+				state.BLSToExecutionChangePool = append(state.BLSToExecutionChangePool, change)
+				m.submissions.BLSToExecutionChanges = append(m.submissions.BLSToExecutionChanges, change)
+			}
+			if len(failures) > 0 {
+				return mockIndexedErrors(failures)
+			}
+			return nil
+		}))
+	mux.HandleFunc("/eth/v1/beacon/pool/attester_slashings", m.pool(
+		func(state *MockBeaconState) interface{} { return state.AttesterSlashingPool },
+		func(state *MockBeaconState, body []byte) error {
+			var slashing phase0.AttesterSlashing
+			if err := json.Unmarshal(body, &slashing); err != nil {
+				return err
+			}
+			state.AttesterSlashingPool = append(state.AttesterSlashingPool, &slashing)
+			m.submissions.AttesterSlashings = append(m.submissions.AttesterSlashings, &slashing)
+			return nil
+		}))
+	mux.HandleFunc("/eth/v1/beacon/pool/proposer_slashings", m.pool(
+		func(state *MockBeaconState) interface{} { return state.ProposerSlashingPool },
+		func(state *MockBeaconState, body []byte) error {
+			var slashing phase0.ProposerSlashing
+			if err := json.Unmarshal(body, &slashing); err != nil {
+				return err
+			}
+			state.ProposerSlashingPool = append(state.ProposerSlashingPool, &slashing)
+			m.submissions.ProposerSlashings = append(m.submissions.ProposerSlashings, &slashing)
+			return nil
+		}))
+	mux.HandleFunc("/eth/v1/validator/aggregate_and_proofs", m.pool(nil,
+		func(state *MockBeaconState, body []byte) error {
+			var aggregates []*phase0.SignedAggregateAndProof
+			if err := json.Unmarshal(body, &aggregates); err != nil {
+				return err
+			}
+			m.submissions.AggregateAndProofs = append(m.submissions.AggregateAndProofs, aggregates...)
+			return nil
+		}))
+	mux.HandleFunc("/eth/v2/beacon/blocks", m.publishBlock)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.lock.Lock()
+		m.requests[r.URL.Path]++
+		m.lock.Unlock()
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// mockNotFound is returned by the handlers for anything the state doesn't have
+type mockNotFound string
+
+func (e mockNotFound) Error() string {
+	return string(e)
+}
+
+// get serves the data returned by the handler as {"data": ...}
+func (m *MockBeaconNode) get(handler func(state *MockBeaconState, r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeMockError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		m.lock.Lock()
+		data, err := handler(m.state, r)
+		m.lock.Unlock()
+		if err != nil {
+			writeMockHandlerError(w, err)
+			return
+		}
+		writeMockJSON(w, "", map[string]interface{}{"data": data, "execution_optimistic": false, "finalized": false})
+	}
+}
+
+// pool serves the pool on GET and adds the submission to it on POST, endpoints without a pool have no contents
+func (m *MockBeaconNode) pool(contents func(state *MockBeaconState) interface{}, submit func(state *MockBeaconState, body []byte) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		switch {
+		case r.Method == http.MethodGet && contents != nil:
+			writeMockJSON(w, "", map[string]interface{}{"data": contents(m.state)})
+		case r.Method == http.MethodPost:
+			if m.state.RejectSubmissions {
+				writeMockError(w, http.StatusBadRequest, "submission rejected by mock beacon node")
+				return
+			}
+			var body json.RawMessage
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeMockError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if err := submit(m.state, body); err != nil {
+				var failures mockIndexedErrors
+				if errors.As(err, &failures) {
+					writeMockIndexedErrors(w, failures)
+					return
+				}
+				writeMockError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			writeMockError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	}
+}
+
+// publishBlock records a published block of the fork named by the Eth-Consensus-Version header, it isn't added to the chain
+func (m *MockBeaconNode) publishBlock(w http.ResponseWriter, r *http.Request) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if r.Method != http.MethodPost {
+		writeMockError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if m.state.RejectSubmissions {
+		writeMockError(w, http.StatusBadRequest, "submission rejected by mock beacon node")
+		return
+	}
+	block := &spec.VersionedSignedBeaconBlock{}
+	var target interface{}
+	switch version := r.Header.Get("Eth-Consensus-Version"); version {
+	case "phase0":
+		block.Version, block.Phase0 = spec.DataVersionPhase0, &phase0.SignedBeaconBlock{}
+		target = block.Phase0
+	case "altair":
+		block.Version, block.Altair = spec.DataVersionAltair, &altair.SignedBeaconBlock{}
+		target = block.Altair
+	case "bellatrix":
+		block.Version, block.Bellatrix = spec.DataVersionBellatrix, &bellatrix.SignedBeaconBlock{}
+		target = block.Bellatrix
+	case "capella":
+		block.Version, block.Capella = spec.DataVersionCapella, &capella.SignedBeaconBlock{}
+		target = block.Capella
+	default:
+		writeMockError(w, http.StatusBadRequest, fmt.Sprintf("unsupported Eth-Consensus-Version %q", version))
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(target); err != nil {
+		writeMockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	m.submissions.Blocks = append(m.submissions.Blocks, block)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *MockBeaconNode) serveBlock(w http.ResponseWriter, r *http.Request) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	block, err := m.state.block(strings.TrimPrefix(r.URL.Path, "/eth/v2/beacon/blocks/"))
+	if err != nil {
+		writeMockHandlerError(w, err)
+		return
+	}
+	data, err := mockBlockData(block)
+	if err != nil {
+		writeMockError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeMockJSON(w, block.Version.String(), map[string]interface{}{"version": block.Version.String(), "data": data, "execution_optimistic": false, "finalized": false})
+}
+
+// serveState serves the validators, finality checkpoints and committees of the state
+func (m *MockBeaconNode) serveState(state *MockBeaconState, r *http.Request) (interface{}, error) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/eth/v1/beacon/states/"), "/")
+	if len(parts) != 2 {
+		return nil, mockNotFound("unknown state endpoint")
+	}
+	switch parts[1] {
+	case "validators":
+		return state.validators(r.URL.Query().Get("id")), nil
+	case "validator_balances":
+		var balances []*v1.ValidatorBalance
+		for _, v := range state.validators(r.URL.Query().Get("id")) {
+			balances = append(balances, &v1.ValidatorBalance{Index: v.Index, Balance: v.Balance})
+		}
+		return balances, nil
+	case "fork":
+		return state.fork(), nil
+	case "finality_checkpoints":
+		return state.Finality, nil
+	case "committees":
+		epoch := state.headEpoch()
+		if e := r.URL.Query().Get("epoch"); e != "" {
+			parsed, err := strconv.ParseUint(e, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			epoch = phase0.Epoch(parsed)
+		}
+		return state.committees(epoch), nil
+	}
+	return nil, mockNotFound("unknown state endpoint")
+}
+
+// serveDebugState serves a phase0 state with the validators and their balances, go-eth2-client uses it for fetching all validators
+func (m *MockBeaconNode) serveDebugState(w http.ResponseWriter, r *http.Request) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	state := m.state
+	head, err := state.block("head")
+	if err != nil {
+		writeMockHandlerError(w, err)
+		return
+	}
+	header, err := mockBlockHeader(head)
+	if err != nil {
+		writeMockError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	beaconState := &phase0.BeaconState{
+		GenesisTime:                 uint64(state.Genesis.GenesisTime.Unix()),
+		GenesisValidatorsRoot:       state.Genesis.GenesisValidatorsRoot,
+		Slot:                        header.Header.Message.Slot,
+		Fork:                        state.fork(),
+		LatestBlockHeader:           header.Header.Message,
+		BlockRoots:                  []phase0.Root{header.Root},
+		StateRoots:                  []phase0.Root{header.Header.Message.StateRoot},
+		HistoricalRoots:             []phase0.Root{},
+		ETH1Data:                    &phase0.ETH1Data{BlockHash: make([]byte, 32)},
+		ETH1DataVotes:               []*phase0.ETH1Data{},
+		Validators:                  []*phase0.Validator{},
+		Balances:                    []phase0.Gwei{},
+		RANDAOMixes:                 []phase0.Root{{}},
+		Slashings:                   []phase0.Gwei{},
+		PreviousEpochAttestations:   []*phase0.PendingAttestation{},
+		CurrentEpochAttestations:    []*phase0.PendingAttestation{},
+		JustificationBits:           bitfield.NewBitvector4(),
+		PreviousJustifiedCheckpoint: state.Finality.PreviousJustified,
+		CurrentJustifiedCheckpoint:  state.Finality.Justified,
+		FinalizedCheckpoint:         state.Finality.Finalized,
+	}
+	for _, v := range state.Validators {
+		beaconState.Validators = append(beaconState.Validators, v.Validator)
+		beaconState.Balances = append(beaconState.Balances, v.Balance)
+	}
+	version := spec.DataVersionPhase0.String()
+	writeMockJSON(w, version, map[string]interface{}{"version": version, "data": beaconState, "execution_optimistic": false, "finalized": false})
+}
+
+// block resolves the block id (head, genesis, finalized, justified, a slot or a root) to a block
+func (s *MockBeaconState) block(blockID string) (*spec.VersionedSignedBeaconBlock, error) {
+	switch blockID {
+	case "head":
+		return s.headBlock()
+	case "genesis":
+		return s.blockAtSlot(0)
+	case "finalized":
+		return s.blockAtSlot(phase0.Slot(uint64(s.Finality.Finalized.Epoch) * s.slotsPerEpoch()))
+	case "justified":
+		return s.blockAtSlot(phase0.Slot(uint64(s.Finality.Justified.Epoch) * s.slotsPerEpoch()))
+	}
+	if strings.HasPrefix(blockID, "0x") {
+		for _, block := range s.Blocks {
+			root, err := block.Root()
+			if err == nil && root.String() == strings.ToLower(blockID) {
+				return block, nil
+			}
+		}
+		return nil, mockNotFound(fmt.Sprintf("block %s not found", blockID))
+	}
+	slot, err := strconv.ParseUint(blockID, 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid block id %s", blockID)
+	}
+	block, ok := s.Blocks[phase0.Slot(slot)]
+	if !ok {
+		return nil, mockNotFound(fmt.Sprintf("no block at slot %d", slot))
+	}
+	return block, nil
+}
+
+func (s *MockBeaconState) headBlock() (*spec.VersionedSignedBeaconBlock, error) {
+	var head *spec.VersionedSignedBeaconBlock
+	var headSlot phase0.Slot
+	for slot, block := range s.Blocks {
+		if head == nil || slot > headSlot {
+			head, headSlot = block, slot
+		}
+	}
+	if head == nil {
+		return nil, mockNotFound("no blocks")
+	}
+	return head, nil
+}
+
+// blockAtSlot returns the block at the slot, or the last block before it for an empty slot
+func (s *MockBeaconState) blockAtSlot(slot phase0.Slot) (*spec.VersionedSignedBeaconBlock, error) {
+	for ; ; slot-- {
+		if block, ok := s.Blocks[slot]; ok {
+			return block, nil
+		}
+		if slot == 0 {
+			return nil, mockNotFound("no blocks")
+		}
+	}
+}
+
+func (s *MockBeaconState) slotsPerEpoch() uint64 {
+	slotsPerEpoch, err := strconv.ParseUint(s.Spec["SLOTS_PER_EPOCH"], 10, 64)
+	if err != nil || slotsPerEpoch == 0 {
+		return 32
+	}
+	return slotsPerEpoch
+}
+
+func (s *MockBeaconState) headEpoch() phase0.Epoch {
+	head, err := s.headBlock()
+	if err != nil {
+		return 0
+	}
+	slot, _ := head.Slot()
+	return phase0.Epoch(uint64(slot) / s.slotsPerEpoch())
+}
+
+// syncValidatorStatuses keeps the status and the epochs of the validators consistent at the head after an update.
+// The epochs of a new validator or one whose status was changed follow the status, otherwise the status follows the epochs.
+// go-eth2-client derives the status from the epochs of the debug state and the duties are computed from them too.
+func (s *MockBeaconState) syncValidatorStatuses(previous map[*v1.Validator]v1.ValidatorState) {
+	epoch := s.headEpoch()
+	farFuture := phase0.Epoch(^uint64(0))
+	for _, v := range s.Validators {
+		if v.Validator == nil {
+			continue
+		}
+		state := v1.ValidatorToState(v.Validator, &v.Balance, epoch, farFuture)
+		if state == v.Status {
+			continue
+		}
+		if status, ok := previous[v]; ok && status == v.Status {
+			v.Status = state
+			continue
+		}
+		switch v.Status {
+		case v1.ValidatorStatePendingInitialized:
+			v.Validator.ActivationEligibilityEpoch, v.Validator.ActivationEpoch = farFuture, farFuture
+		case v1.ValidatorStatePendingQueued:
+			v.Validator.ActivationEligibilityEpoch, v.Validator.ActivationEpoch = epoch, farFuture
+		case v1.ValidatorStateActiveOngoing:
+			v.Validator.ActivationEpoch, v.Validator.ExitEpoch, v.Validator.WithdrawableEpoch = 0, farFuture, farFuture
+			v.Validator.Slashed = false
+		case v1.ValidatorStateActiveExiting, v1.ValidatorStateActiveSlashed:
+			v.Validator.ActivationEpoch, v.Validator.ExitEpoch, v.Validator.WithdrawableEpoch = 0, epoch+1, farFuture
+			v.Validator.Slashed = v.Status == v1.ValidatorStateActiveSlashed
+		case v1.ValidatorStateExitedUnslashed, v1.ValidatorStateExitedSlashed:
+			v.Validator.ActivationEpoch, v.Validator.ExitEpoch, v.Validator.WithdrawableEpoch = 0, epoch, farFuture
+			v.Validator.Slashed = v.Status == v1.ValidatorStateExitedSlashed
+		case v1.ValidatorStateWithdrawalPossible, v1.ValidatorStateWithdrawalDone:
+			// the balance tells the two apart
+			v.Validator.ActivationEpoch, v.Validator.ExitEpoch, v.Validator.WithdrawableEpoch = 0, epoch, epoch
+		}
+	}
+}
+
+// fork returns the latest fork of the fork schedule at the head
+func (s *MockBeaconState) fork() *phase0.Fork {
+	headEpoch := s.headEpoch()
+	var fork *phase0.Fork
+	for _, f := range s.ForkSchedule {
+		if f.Epoch <= headEpoch {
+			fork = f
+		}
+	}
+	return fork
+}
+
+// validators returns the validators matching the comma separated indices and public keys, or all of them
+func (s *MockBeaconState) validators(ids string) []*v1.Validator {
+	if ids == "" {
+		return s.Validators
+	}
+	wanted := make(map[string]bool)
+	for _, id := range strings.Split(ids, ",") {
+		wanted[strings.ToLower(id)] = true
+	}
+	var validators []*v1.Validator
+	for _, v := range s.Validators {
+		if wanted[fmt.Sprintf("%d", v.Index)] || wanted[v.Validator.PublicKey.String()] {
+			validators = append(validators, v)
+		}
+	}
+	return validators
+}
+
+// committees returns the configured committees of the epoch, or a committee per slot with the active validators spread over them
+func (s *MockBeaconState) committees(epoch phase0.Epoch) []*v1.BeaconCommittee {
+	if committees, ok := s.Committees[epoch]; ok {
+		return committees
+	}
+	slotsPerEpoch := s.slotsPerEpoch()
+	firstSlot := phase0.Slot(uint64(epoch) * slotsPerEpoch)
+	committees := make([]*v1.BeaconCommittee, slotsPerEpoch)
+	for i := range committees {
+		committees[i] = &v1.BeaconCommittee{Slot: firstSlot + phase0.Slot(i), Validators: []phase0.ValidatorIndex{}}
+	}
+	validators := append([]*v1.Validator{}, s.Validators...)
+	sort.Slice(validators, func(i, j int) bool { return validators[i].Index < validators[j].Index })
+	active := 0
+	for _, v := range validators {
+		if v.Validator.ActivationEpoch > epoch || v.Validator.ExitEpoch <= epoch {
+			continue
+		}
+		committee := committees[uint64(active)%slotsPerEpoch]
+		committee.Validators = append(committee.Validators, v.Index)
+		active++
+	}
+	return committees
+}
+
+// checkWithdrawalCredentials checks the change comes from the bls withdrawal key of a known validator, as in process_bls_to_execution_change
+func (s *MockBeaconState) checkWithdrawalCredentials(change *capella.BLSToExecutionChange) error {
+	for _, v := range s.Validators {
+		if v.Index != change.ValidatorIndex {
+			continue
+		}
+		credentials := v.Validator.WithdrawalCredentials
+		hash := sha256.Sum256(change.FromBLSPubkey[:])
+		if len(credentials) != 32 || credentials[0] != 0x00 || !bytes.Equal(credentials[1:], hash[1:]) {
+			return fmt.Errorf("change of validator %d doesn't match its withdrawal credentials", change.ValidatorIndex)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown validator %d", change.ValidatorIndex)
+}
+
+// proposerDuties returns the proposer of every slot of the epoch, the configured one or the active validators in turn
+func (s *MockBeaconState) proposerDuties(epoch phase0.Epoch) []*v1.ProposerDuty {
+	validators := append([]*v1.Validator{}, s.Validators...)
+	sort.Slice(validators, func(i, j int) bool { return validators[i].Index < validators[j].Index })
+	var active []*v1.Validator
+	for _, v := range validators {
+		if v.Validator.ActivationEpoch <= epoch && v.Validator.ExitEpoch > epoch {
+			active = append(active, v)
+		}
+	}
+	duties := []*v1.ProposerDuty{}
+	if len(active) == 0 {
+		return duties
+	}
+	slotsPerEpoch := s.slotsPerEpoch()
+	for i := uint64(0); i < slotsPerEpoch; i++ {
+		slot := phase0.Slot(uint64(epoch)*slotsPerEpoch + i)
+		proposer := active[uint64(slot)%uint64(len(active))]
+		if index, ok := s.Proposers[slot]; ok {
+			for _, v := range validators {
+				if v.Index == index {
+					proposer = v
+				}
+			}
+		}
+		duties = append(duties, &v1.ProposerDuty{PubKey: proposer.Validator.PublicKey, Slot: slot, ValidatorIndex: proposer.Index})
+	}
+	return duties
+}
+
+// mockBlockHeader returns the signed header of the block
+func mockBlockHeader(block *spec.VersionedSignedBeaconBlock) (*v1.BeaconBlockHeader, error) {
+	root, err := block.Root()
+	if err != nil {
+		return nil, err
+	}
+	slot, err := block.Slot()
+	if err != nil {
+		return nil, err
+	}
+	proposer, err := block.ProposerIndex()
+	if err != nil {
+		return nil, err
+	}
+	parentRoot, err := block.ParentRoot()
+	if err != nil {
+		return nil, err
+	}
+	stateRoot, err := block.StateRoot()
+	if err != nil {
+		return nil, err
+	}
+	bodyRoot, err := block.BodyRoot()
+	if err != nil {
+		return nil, err
+	}
+	var signature phase0.BLSSignature
+	switch block.Version {
+	case spec.DataVersionPhase0:
+		signature = block.Phase0.Signature
+	case spec.DataVersionAltair:
+		signature = block.Altair.Signature
+	case spec.DataVersionBellatrix:
+		signature = block.Bellatrix.Signature
+	case spec.DataVersionCapella:
+		signature = block.Capella.Signature
+	case spec.DataVersionDeneb:
+		signature = block.Deneb.Signature
+	}
+	return &v1.BeaconBlockHeader{
+		Root:      root,
+		Canonical: true,
+		Header: &phase0.SignedBeaconBlockHeader{
+			Message: &phase0.BeaconBlockHeader{
+				Slot:          slot,
+				ProposerIndex: proposer,
+				ParentRoot:    parentRoot,
+				StateRoot:     stateRoot,
+				BodyRoot:      bodyRoot,
+			},
+			Signature: signature,
+		},
+	}, nil
+}
+
+// mockBlockData returns the fork specific block for serialisation
+func mockBlockData(block *spec.VersionedSignedBeaconBlock) (interface{}, error) {
+	switch block.Version {
+	case spec.DataVersionPhase0:
+		return block.Phase0, nil
+	case spec.DataVersionAltair:
+		return block.Altair, nil
+	case spec.DataVersionBellatrix:
+		return block.Bellatrix, nil
+	case spec.DataVersionCapella:
+		return block.Capella, nil
+	case spec.DataVersionDeneb:
+		return block.Deneb, nil
+	}
+	return nil, fmt.Errorf("unsupported block version %s", block.Version)
+}
+
+func writeMockJSON(w http.ResponseWriter, consensusVersion string, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		writeMockError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if consensusVersion != "" {
+		w.Header().Set("Eth-Consensus-Version", consensusVersion)
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+func writeMockHandlerError(w http.ResponseWriter, err error) {
+	var notFound mockNotFound
+	if errors.As(err, &notFound) {
+		writeMockError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeMockError(w, http.StatusBadRequest, err.Error())
+}
+
+// mockIndexedFailure a rejected item of a submission, as in the failures of an indexed error response
+type mockIndexedFailure struct {
+	Index   int    `json:"index"`
+	Message string `json:"message"`
+}
+
+type mockIndexedErrors []mockIndexedFailure
+
+func (e mockIndexedErrors) Error() string {
+	return fmt.Sprintf("%d items of the submission were rejected", len(e))
+}
+
+func writeMockIndexedErrors(w http.ResponseWriter, failures mockIndexedErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": http.StatusBadRequest, "message": failures.Error(), "failures": failures})
+}
+
+func writeMockError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "message": message})
+}
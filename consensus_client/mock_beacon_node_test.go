@@ -0,0 +1,144 @@
+package consensus_client
+
+import (
+	"context"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newTestMockBeaconNode(t *testing.T) (*MockBeaconNode, *ConsensusClient) {
+	validators, err := getTestValidators(GenesisValidatorCount)
+	require.NoError(t, err)
+	node := NewMockBeaconNode(NewMockBeaconState(validators))
+	t.Cleanup(node.Close)
+	client, err := node.ConsensusClient("mock")
+	require.NoError(t, err)
+	return node, client
+}
+
+func TestMockBeaconNode_Spec(t *testing.T) {
+	_, client := newTestMockBeaconNode(t)
+	ctx := context.Background()
+
+	domainType, err := client.GetDomainTypeFromSpec("DOMAIN_VOLUNTARY_EXIT")
+	require.NoError(t, err)
+	require.Equal(t, phase0.DomainType{0x04, 0x00, 0x00, 0x00}, domainType)
+	genesisForkVersion, err := client.GetForkVersionFromSpecContext(ctx, "GENESIS_FORK_VERSION")
+	require.NoError(t, err)
+	require.Equal(t, phase0.Version{0x00, 0x00, 0x00, 0x01}, genesisForkVersion)
+	slotsPerEpoch, err := client.GetSlotsPerEpoch()
+	require.NoError(t, err)
+	require.Equal(t, uint64(8), slotsPerEpoch)
+	_, err = client.GetDomainFromGenesis(domainType)
+	require.NoError(t, err)
+	depositContract, err := client.GetDepositContractContext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1337), depositContract.ChainID)
+}
+
+func TestMockBeaconNode_Blocks(t *testing.T) {
+	node, client := newTestMockBeaconNode(t)
+	ctx := context.Background()
+
+	genesis, err := client.GetBlockHeader("head")
+	require.NoError(t, err)
+	require.Equal(t, phase0.Slot(0), genesis.Header.Message.Slot)
+
+	block := NewMockCapellaBlock(17, 3, genesis.Root)
+	require.NoError(t, node.AddBlock(block))
+	head, err := client.GetBlockHeader("head")
+	require.NoError(t, err)
+	require.Equal(t, phase0.Slot(17), head.Header.Message.Slot)
+	require.Equal(t, genesis.Root, head.Header.Message.ParentRoot)
+	byRoot, err := client.GetBlockHeader(head.Root.String())
+	require.NoError(t, err)
+	require.Equal(t, head.Root, byRoot.Root)
+	epoch, err := client.GetCurrentEpoch()
+	require.NoError(t, err)
+	require.Equal(t, phase0.Epoch(2), epoch)
+
+	signedBlock, err := client.GetSignedBeaconBlockContext(ctx, "17")
+	require.NoError(t, err)
+	root, err := signedBlock.Root()
+	require.NoError(t, err)
+	require.Equal(t, head.Root, root)
+	emptySlot, err := client.GetSignedBeaconBlockContext(ctx, "16")
+	require.NoError(t, err)
+	require.Nil(t, emptySlot)
+}
+
+func TestMockBeaconNode_Validators(t *testing.T) {
+	node, client := newTestMockBeaconNode(t)
+	validators, err := getTestValidators(GenesisValidatorCount)
+	require.NoError(t, err)
+
+	allValidators, err := client.GetAllValidators("head")
+	require.NoError(t, err)
+	require.Len(t, allValidators, GenesisValidatorCount)
+	clientValidator, err := client.GetValidatorByPublicKey("head", validators[5].ValidatorPublicKey)
+	require.NoError(t, err)
+	require.Equal(t, phase0.ValidatorIndex(5), clientValidator.Index)
+
+	node.Update(func(state *MockBeaconState) {
+		state.Validators[5].Validator.ActivationEpoch = 10
+	})
+	clientValidator, err = client.GetValidatorByPublicKey("head", validators[5].ValidatorPublicKey)
+	require.NoError(t, err)
+	require.Equal(t, v1.ValidatorStatePendingQueued, clientValidator.Status)
+	committees, err := client.GetBeaconCommitteesContext(context.Background(), "head", 0)
+	require.NoError(t, err)
+	require.Len(t, committees, 8)
+	members := 0
+	for _, committee := range committees {
+		members += len(committee.Validators)
+		require.NotContains(t, committee.Validators, phase0.ValidatorIndex(5))
+	}
+	require.Equal(t, GenesisValidatorCount-1, members)
+}
+
+func TestMockBeaconNode_Pools(t *testing.T) {
+	node, client := newTestMockBeaconNode(t)
+	ctx := context.Background()
+
+	exit := &phase0.SignedVoluntaryExit{Message: &phase0.VoluntaryExit{Epoch: 1, ValidatorIndex: 2}}
+	require.NoError(t, client.SubmitValidatorExit(exit))
+	change := &capella.SignedBLSToExecutionChange{Message: &capella.BLSToExecutionChange{ValidatorIndex: 3}}
+	require.NoError(t, client.SubmitBLSToExecutionChange(change))
+
+	exitPool, err := client.GetVoluntaryExitPoolContext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []*phase0.SignedVoluntaryExit{exit}, exitPool)
+	changePool, err := client.GetBLSToExecutionChangePoolContext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []*capella.SignedBLSToExecutionChange{change}, changePool)
+
+	submissions := node.Submissions()
+	require.Equal(t, []*phase0.SignedVoluntaryExit{exit}, submissions.VoluntaryExits)
+	require.Equal(t, []*capella.SignedBLSToExecutionChange{change}, submissions.BLSToExecutionChanges)
+
+	node.Update(func(state *MockBeaconState) {
+		state.RejectSubmissions = true
+	})
+	require.Error(t, client.SubmitValidatorExit(exit))
+	require.Len(t, node.Submissions().VoluntaryExits, 1)
+}
+
+func TestMockBeaconNode_ContextCancelled(t *testing.T) {
+	_, client := newTestMockBeaconNode(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.GetBlockHeaderContext(ctx, "head")
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = client.GetBLSToExecutionChangePoolContext(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	err = client.SubmitProposerSlashingContext(ctx, &phase0.ProposerSlashing{})
+	require.ErrorIs(t, err, context.Canceled)
+
+	// the compatibility wrappers aren't affected
+	_, err = client.GetBlockHeader("head")
+	require.NoError(t, err)
+}
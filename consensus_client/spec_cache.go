@@ -0,0 +1,254 @@
+package consensus_client
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SpecCache holds the spec, fork schedule and genesis of a client and the domains computed from them.
+// Everything is fetched once on first use and kept until Invalidate is called.
+type SpecCache struct {
+	client *ConsensusClient
+
+	lock         sync.Mutex
+	spec         map[string]string
+	forkSchedule []*phase0.Fork
+	genesis      *v1.Genesis
+	domains      map[domainKey]phase0.Domain
+}
+
+// ApplicationBuilderDomainType the domain type of builder api messages
+var ApplicationBuilderDomainType = phase0.DomainType{0x00, 0x00, 0x00, 0x01}
+
+type domainKey struct {
+	domainType  phase0.DomainType
+	forkVersion phase0.Version
+}
+
+// SpecCache returns the spec cache of the client
+func (c *ConsensusClient) SpecCache() *SpecCache {
+	c.specCacheOnce.Do(func() {
+		c.specCache = &SpecCache{client: c}
+	})
+	return c.specCache
+}
+
+// Invalidate drops everything cached, the next call fetches it from the client again
+func (s *SpecCache) Invalidate() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.spec = nil
+	s.forkSchedule = nil
+	s.genesis = nil
+	s.domains = nil
+}
+
+// String returns the spec value as served by the client
+func (s *SpecCache) String(ctx context.Context, key string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.loadSpec(ctx); err != nil {
+		return "", err
+	}
+	value, ok := s.spec[key]
+	if !ok {
+		return "", fmt.Errorf("failed to find %s in spec", key)
+	}
+	return value, nil
+}
+
+// Uint64 returns a numeric spec value, e.g. SLOTS_PER_EPOCH
+func (s *SpecCache) Uint64(ctx context.Context, key string) (uint64, error) {
+	value, err := s.String(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "%s in spec is not a number", key)
+	}
+	return n, nil
+}
+
+// DomainType returns a domain type from the spec, e.g. DOMAIN_VOLUNTARY_EXIT
+func (s *SpecCache) DomainType(ctx context.Context, key string) (phase0.DomainType, error) {
+	var domainType phase0.DomainType
+	err := s.fixedBytes(ctx, key, domainType[:])
+	return domainType, err
+}
+
+// Version returns a fork version from the spec, e.g. GENESIS_FORK_VERSION
+func (s *SpecCache) Version(ctx context.Context, key string) (phase0.Version, error) {
+	var version phase0.Version
+	err := s.fixedBytes(ctx, key, version[:])
+	return version, err
+}
+
+func (s *SpecCache) fixedBytes(ctx context.Context, key string, out []byte) error {
+	value, err := s.String(ctx, key)
+	if err != nil {
+		return err
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err != nil {
+		return errors.Wrapf(err, "%s in spec is not hex", key)
+	}
+	if len(data) != len(out) {
+		return fmt.Errorf("%s in spec is %d bytes, expected %d", key, len(data), len(out))
+	}
+	copy(out, data)
+	return nil
+}
+
+// ForkSchedule returns the forks the client knows about, ordered by epoch
+func (s *SpecCache) ForkSchedule(ctx context.Context) ([]*phase0.Fork, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.loadForkSchedule(ctx); err != nil {
+		return nil, err
+	}
+	return s.forkSchedule, nil
+}
+
+// Genesis returns the genesis of the chain the client follows
+func (s *SpecCache) Genesis(ctx context.Context) (*v1.Genesis, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.loadGenesis(ctx); err != nil {
+		return nil, err
+	}
+	return s.genesis, nil
+}
+
+// ForkVersion returns the fork version active at the epoch according to the fork schedule
+func (s *SpecCache) ForkVersion(ctx context.Context, epoch phase0.Epoch) (phase0.Version, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.forkVersion(ctx, epoch)
+}
+
+func (s *SpecCache) forkVersion(ctx context.Context, epoch phase0.Epoch) (phase0.Version, error) {
+	fork, err := s.fork(ctx, epoch)
+	if err != nil {
+		return phase0.Version{}, err
+	}
+	return fork.CurrentVersion, nil
+}
+
+// Fork returns the fork active at the epoch according to the fork schedule, the genesis fork if none is
+func (s *SpecCache) Fork(ctx context.Context, epoch phase0.Epoch) (*phase0.Fork, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.fork(ctx, epoch)
+}
+
+func (s *SpecCache) fork(ctx context.Context, epoch phase0.Epoch) (*phase0.Fork, error) {
+	if err := s.loadForkSchedule(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.loadGenesis(ctx); err != nil {
+		return nil, err
+	}
+	active := &phase0.Fork{PreviousVersion: s.genesis.GenesisForkVersion, CurrentVersion: s.genesis.GenesisForkVersion}
+	for _, fork := range s.forkSchedule {
+		if fork.Epoch <= epoch {
+			active = fork
+		}
+	}
+	return active, nil
+}
+
+// Domain computes the domain for the domain type with the fork version active at the epoch, as get_domain does
+func (s *SpecCache) Domain(ctx context.Context, domainType phase0.DomainType, epoch phase0.Epoch) (phase0.Domain, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	version, err := s.forkVersion(ctx, epoch)
+	if err != nil {
+		return phase0.Domain{}, err
+	}
+	return s.domain(domainType, version), nil
+}
+
+// GenesisDomain computes the domain for the domain type with the genesis fork version
+func (s *SpecCache) GenesisDomain(ctx context.Context, domainType phase0.DomainType) (phase0.Domain, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.loadGenesis(ctx); err != nil {
+		return phase0.Domain{}, err
+	}
+	return s.domain(domainType, s.genesis.GenesisForkVersion), nil
+}
+
+// domain computes the domain or returns it from the cache, the genesis has to be loaded
+func (s *SpecCache) domain(domainType phase0.DomainType, version phase0.Version) phase0.Domain {
+	key := domainKey{domainType: domainType, forkVersion: version}
+	if domain, ok := s.domains[key]; ok {
+		return domain
+	}
+	// the application domain of the builder api is valid across chains, it leaves out the genesis validators root
+	genesisValidatorsRoot := common.Root(s.genesis.GenesisValidatorsRoot)
+	if domainType == ApplicationBuilderDomainType {
+		genesisValidatorsRoot = common.Root{}
+	}
+	domain := phase0.Domain(common.ComputeDomain(common.BLSDomainType(domainType), common.Version(version), genesisValidatorsRoot))
+	if s.domains == nil {
+		s.domains = make(map[domainKey]phase0.Domain)
+	}
+	s.domains[key] = domain
+	return domain
+}
+
+func (s *SpecCache) loadSpec(ctx context.Context) error {
+	if s.spec != nil {
+		return nil
+	}
+	var raw map[string]json.RawMessage
+	if err := s.client.getJSON(ctx, "/eth/v1/config/spec", &raw); err != nil {
+		return errors.Wrapf(err, "failed to get spec for client: %s", s.client.Name)
+	}
+	// newer specs have a few values that are lists or objects, only the plain values are kept
+	spec := make(map[string]string, len(raw))
+	for key, value := range raw {
+		var text string
+		if json.Unmarshal(value, &text) == nil {
+			spec[key] = text
+		}
+	}
+	s.spec = spec
+	return nil
+}
+
+func (s *SpecCache) loadForkSchedule(ctx context.Context) error {
+	if s.forkSchedule != nil {
+		return nil
+	}
+	var forkSchedule []*phase0.Fork
+	if err := s.client.getJSON(ctx, "/eth/v1/config/fork_schedule", &forkSchedule); err != nil {
+		return errors.Wrapf(err, "failed to get fork schedule for client: %s", s.client.Name)
+	}
+	sort.Slice(forkSchedule, func(i, j int) bool { return forkSchedule[i].Epoch < forkSchedule[j].Epoch })
+	s.forkSchedule = forkSchedule
+	return nil
+}
+
+func (s *SpecCache) loadGenesis(ctx context.Context) error {
+	if s.genesis != nil {
+		return nil
+	}
+	var genesis v1.Genesis
+	if err := s.client.getJSON(ctx, "/eth/v1/beacon/genesis", &genesis); err != nil {
+		return errors.Wrapf(err, "failed to get genesis for client: %s", s.client.Name)
+	}
+	s.genesis = &genesis
+	return nil
+}
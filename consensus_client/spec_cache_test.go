@@ -0,0 +1,81 @@
+package consensus_client
+
+import (
+	"context"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSpecCache_Mock(t *testing.T) {
+	node, client := newTestMockBeaconNode(t)
+	ctx := context.Background()
+	// the BeaconService fetches some of these itself when connecting
+	specRequests := node.Requests("/eth/v1/config/spec")
+	forkScheduleRequests := node.Requests("/eth/v1/config/fork_schedule")
+	genesisRequests := node.Requests("/eth/v1/beacon/genesis")
+
+	for i := 0; i < 10; i++ {
+		domainType, err := client.GetDomainTypeFromSpec("DOMAIN_VOLUNTARY_EXIT")
+		require.NoError(t, err)
+		require.Equal(t, phase0.DomainType{0x04, 0x00, 0x00, 0x00}, domainType)
+		_, err = client.GetShardCommitteePeriod()
+		require.NoError(t, err)
+		_, err = client.GetDomainContext(ctx, domainType, phase0.Epoch(i))
+		require.NoError(t, err)
+	}
+	require.Equal(t, specRequests+1, node.Requests("/eth/v1/config/spec"))
+	require.Equal(t, forkScheduleRequests+1, node.Requests("/eth/v1/config/fork_schedule"))
+	require.Equal(t, genesisRequests+1, node.Requests("/eth/v1/beacon/genesis"))
+
+	node.Update(func(state *MockBeaconState) {
+		state.Spec["SHARD_COMMITTEE_PERIOD"] = "256"
+	})
+	period, err := client.SpecCache().Uint64(ctx, "SHARD_COMMITTEE_PERIOD")
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), period)
+	client.SpecCache().Invalidate()
+	period, err = client.SpecCache().Uint64(ctx, "SHARD_COMMITTEE_PERIOD")
+	require.NoError(t, err)
+	require.Equal(t, uint64(256), period)
+	require.Equal(t, specRequests+2, node.Requests("/eth/v1/config/spec"))
+
+	_, err = client.SpecCache().Uint64(ctx, "PRESET_BASE")
+	require.Error(t, err)
+	_, err = client.SpecCache().Version(ctx, "NOT_IN_SPEC")
+	require.Error(t, err)
+}
+
+func TestSpecCache_MockDomain(t *testing.T) {
+	node, _ := newTestMockBeaconNode(t)
+	ctx := context.Background()
+	node.Update(func(state *MockBeaconState) {
+		state.ForkSchedule[3].Epoch = 5
+	})
+	// the BeaconService caches the fork schedule when connecting, so connect after the update
+	client, err := node.ConsensusClient("mock")
+	require.NoError(t, err)
+
+	version, err := client.SpecCache().ForkVersion(ctx, 4)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Version{0x02, 0x00, 0x00, 0x01}, version)
+	version, err = client.SpecCache().ForkVersion(ctx, 5)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Version{0x03, 0x00, 0x00, 0x01}, version)
+
+	// the locally computed domains match the ones from the BeaconService
+	for _, domainType := range []phase0.DomainType{{0x01, 0x00, 0x00, 0x00}, {0x00, 0x00, 0x00, 0x01}} {
+		for _, epoch := range []phase0.Epoch{0, 4, 5, 100} {
+			domain, err := client.GetDomainContext(ctx, domainType, epoch)
+			require.NoError(t, err)
+			expected, err := client.BeaconService.Domain(ctx, domainType, epoch)
+			require.NoError(t, err)
+			require.Equal(t, expected, domain, "epoch %d", epoch)
+		}
+		genesisDomain, err := client.GetDomainFromGenesis(domainType)
+		require.NoError(t, err)
+		expected, err := client.BeaconService.GenesisDomain(ctx, domainType)
+		require.NoError(t, err)
+		require.Equal(t, expected, genesisDomain)
+	}
+}
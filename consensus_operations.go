@@ -2,32 +2,58 @@ package eth_testnet_tool
 
 import (
 	"context"
+	"crypto/sha256"
 	"eth-testnet-tool/consensus_client"
 	"eth-testnet-tool/validator"
 	"fmt"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/altair"
 	"github.com/attestantio/go-eth2-client/spec/bellatrix"
 	"github.com/attestantio/go-eth2-client/spec/capella"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/pkg/errors"
 	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/prysmaticlabs/go-bitfield"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	"sort"
 )
 
 var (
 	BlsToExecutionChangeDomainLookup = "DOMAIN_BLS_TO_EXECUTION_CHANGE"
 	VoluntaryExitDomainLookup        = "DOMAIN_VOLUNTARY_EXIT"
+	DepositDomainLookup              = "DOMAIN_DEPOSIT"
+	GenesisForkVersionLookup         = "GENESIS_FORK_VERSION"
+	BeaconAttesterDomainLookup       = "DOMAIN_BEACON_ATTESTER"
+	BeaconProposerDomainLookup       = "DOMAIN_BEACON_PROPOSER"
+)
+
+const (
+	BLSWithdrawalPrefix         = byte(0x00)
+	ExecutionWithdrawalPrefix   = byte(0x01)
+	CompoundingWithdrawalPrefix = byte(0x02)
+)
+
+// AttesterSlashingKind the kind of conflicting votes an attester slashing is built from
+type AttesterSlashingKind int
+
+const (
+	// DoubleVote two different attestations for the same target epoch
+	DoubleVote AttesterSlashingKind = iota
+	// SurroundVote the first attestation surrounds the second one
+	SurroundVote
 )
 
 // Various useful operations for testnet testing
 // The following should always work under healthy network conditions
 
-// BuildVoluntaryExitFromClientView  uses the specified consensus client to build a SignedVoluntaryExit message
-func BuildVoluntaryExitFromClientView(consensusClient *consensus_client.ConsensusClient, validator *validator.Validator, epoch phase0.Epoch) (*phase0.SignedVoluntaryExit, error) {
-	clientValidatorView, err := consensusClient.GetValidatorByPublicKey("head", validator.ValidatorPublicKey)
+// BuildVoluntaryExitFromClientViewContext  uses the specified consensus client to build a SignedVoluntaryExit message
+func BuildVoluntaryExitFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, validator *validator.Validator, epoch phase0.Epoch) (*phase0.SignedVoluntaryExit, error) {
+	clientValidatorView, err := consensusClient.GetValidatorByPublicKeyContext(ctx, "head", validator.ValidatorPublicKey)
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("couldn't create bls to execution change from client %s", consensusClient.Name))
 	}
 
-	shardCommitteePeriod, err := consensusClient.GetShardCommitteePeriod()
+	shardCommitteePeriod, err := consensusClient.GetShardCommitteePeriodContext(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "couldn't get shard committee period to check for valid exit epoch")
 	}
@@ -40,14 +66,345 @@ func BuildVoluntaryExitFromClientView(consensusClient *consensus_client.Consensu
 		ValidatorIndex: clientValidatorView.Index,
 	}
 
-	return SignVoluntaryExitWithValidator(consensusClient, validator, &voluntaryExit)
+	return SignVoluntaryExitWithValidatorContext(ctx, consensusClient, validator, &voluntaryExit)
+}
+
+// BuildAttesterSlashingFromClientViewContext uses the specified consensus client to look up the committees of the validators in the target epoch
+// and builds two conflicting attestations signed by all of them. Every validator has to be in a committee in the epoch.
+func BuildAttesterSlashingFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, validators []*validator.Validator, epoch phase0.Epoch, kind AttesterSlashingKind) (*phase0.AttesterSlashing, error) {
+	if len(validators) == 0 {
+		return nil, errors.New("an attester slashing needs at least one validator")
+	}
+	if kind == SurroundVote && epoch < 3 {
+		return nil, errors.New("a surround vote needs a target epoch of at least 3")
+	}
+
+	indices := make(map[phase0.ValidatorIndex]bool)
+	for _, v := range validators {
+		clientValidatorView, err := consensusClient.GetValidatorByPublicKeyContext(ctx, "head", v.ValidatorPublicKey)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("couldn't create attester slashing from client %s", consensusClient.Name))
+		}
+		indices[clientValidatorView.Index] = true
+	}
+	committees, err := consensusClient.GetBeaconCommitteesContext(ctx, "head", epoch)
+	if err != nil {
+		return nil, err
+	}
+	var duty *v1.BeaconCommittee
+	var attestingIndices []phase0.ValidatorIndex
+	for _, committee := range committees {
+		for _, index := range committee.Validators {
+			if !indices[index] {
+				continue
+			}
+			if duty == nil {
+				duty = committee
+			}
+			attestingIndices = append(attestingIndices, index)
+		}
+	}
+	if len(attestingIndices) != len(indices) {
+		return nil, fmt.Errorf("only %d of the %d validators are in a committee in epoch %d", len(attestingIndices), len(indices), epoch)
+	}
+
+	head, err := consensusClient.GetBlockHeaderContext(ctx, "head")
+	if err != nil {
+		return nil, err
+	}
+	// the slashing only needs the votes to conflict, the second vote is for a block that doesn't exist
+	conflictingRoot := phase0.Root(sha256.Sum256(head.Root[:]))
+	sourceEpoch := epoch
+	if epoch > 0 {
+		sourceEpoch = epoch - 1
+	}
+	data1 := &phase0.AttestationData{
+		Slot:            duty.Slot,
+		Index:           duty.Index,
+		BeaconBlockRoot: head.Root,
+		Source:          &phase0.Checkpoint{Epoch: sourceEpoch, Root: head.Root},
+		Target:          &phase0.Checkpoint{Epoch: epoch, Root: head.Root},
+	}
+	data2 := &phase0.AttestationData{
+		Slot:            duty.Slot,
+		Index:           duty.Index,
+		BeaconBlockRoot: conflictingRoot,
+		Source:          &phase0.Checkpoint{Epoch: sourceEpoch, Root: head.Root},
+		Target:          &phase0.Checkpoint{Epoch: epoch, Root: conflictingRoot},
+	}
+	if kind == SurroundVote {
+		slotsPerEpoch, err := consensusClient.GetSlotsPerEpochContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// the first vote spans epoch-3 to epoch, the second one epoch-2 to epoch-1
+		data1.Source.Epoch = epoch - 3
+		data2.Slot = duty.Slot - phase0.Slot(slotsPerEpoch)
+		data2.Source.Epoch = epoch - 2
+		data2.Target.Epoch = epoch - 1
+	}
+	if !IsSlashableAttestationData(data1, data2) {
+		return nil, errors.New("built attestations are not slashable")
+	}
+	// the attestations are slashable on purpose, slashing protection has to let them through
+	ctx = validator.AllowSlashable(ctx)
+
+	attestation1, err := SignIndexedAttestationWithValidatorsContext(ctx, consensusClient, validators, attestingIndices, data1)
+	if err != nil {
+		return nil, err
+	}
+	attestation2, err := SignIndexedAttestationWithValidatorsContext(ctx, consensusClient, validators, attestingIndices, data2)
+	if err != nil {
+		return nil, err
+	}
+	return &phase0.AttesterSlashing{
+		Attestation1: attestation1,
+		Attestation2: attestation2,
+	}, nil
+}
+
+// BuildAttestationFromClientViewContext uses the specified consensus client to find the committee of the validator in the epoch
+// and builds the attestation the validator makes at its slot: the head as of the slot, the block at the start of the epoch as target
+// and the current justified checkpoint as source.
+func BuildAttestationFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, v *validator.Validator, epoch phase0.Epoch) (*phase0.Attestation, error) {
+	duty, position, index, err := attestationDutyContext(ctx, consensusClient, v, epoch)
+	if err != nil {
+		return nil, err
+	}
+	data, err := attestationDataContext(ctx, consensusClient, duty, epoch)
+	if err != nil {
+		return nil, err
+	}
+	indexedAttestation, err := SignIndexedAttestationWithValidatorsContext(ctx, consensusClient, []*validator.Validator{v}, []phase0.ValidatorIndex{index}, data)
+	if err != nil {
+		return nil, err
+	}
+	aggregationBits := bitfield.NewBitlist(uint64(len(duty.Validators)))
+	aggregationBits.SetBitAt(uint64(position), true)
+	return &phase0.Attestation{
+		AggregationBits: aggregationBits,
+		Data:            data,
+		Signature:       indexedAttestation.Signature,
+	}, nil
+}
+
+// attestationDutyContext returns the committee of the validator in the epoch, its position in the committee and its validator index
+func attestationDutyContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, v *validator.Validator, epoch phase0.Epoch) (*v1.BeaconCommittee, int, phase0.ValidatorIndex, error) {
+	clientValidatorView, err := consensusClient.GetValidatorByPublicKeyContext(ctx, "head", v.ValidatorPublicKey)
+	if err != nil {
+		return nil, 0, 0, errors.Wrap(err, fmt.Sprintf("couldn't create attestation from client %s", consensusClient.Name))
+	}
+	committees, err := consensusClient.GetBeaconCommitteesContext(ctx, "head", epoch)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	for _, committee := range committees {
+		for i, index := range committee.Validators {
+			if index == clientValidatorView.Index {
+				return committee, i, clientValidatorView.Index, nil
+			}
+		}
+	}
+	return nil, 0, 0, fmt.Errorf("validator %d is not in a committee in epoch %d", clientValidatorView.Index, epoch)
+}
+
+// attestationDataContext returns the data of an attestation of the committee at its slot in the view of the client:
+// the latest block at the slot is the head, the latest block at the first slot of the epoch the target and the justified checkpoint the source
+func attestationDataContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, duty *v1.BeaconCommittee, epoch phase0.Epoch) (*phase0.AttestationData, error) {
+	slotsPerEpoch, err := consensusClient.GetSlotsPerEpochContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	headRoot, err := consensusClient.GetBlockRootAtSlotContext(ctx, duty.Slot)
+	if err != nil {
+		return nil, err
+	}
+	targetRoot, err := consensusClient.GetBlockRootAtSlotContext(ctx, phase0.Slot(uint64(epoch)*slotsPerEpoch))
+	if err != nil {
+		return nil, err
+	}
+	finality, err := consensusClient.GetFinalityContext(ctx, "head")
+	if err != nil {
+		return nil, err
+	}
+	return &phase0.AttestationData{
+		Slot:            duty.Slot,
+		Index:           duty.Index,
+		BeaconBlockRoot: headRoot,
+		Source:          finality.Justified,
+		Target:          &phase0.Checkpoint{Epoch: epoch, Root: targetRoot},
+	}, nil
+}
+
+// BuildAndSubmitAttesterSlashingFromClientViewContext builds an attester slashing for the validators with BuildAttesterSlashingFromClientView
+// and submits it to the attester slashing pool of the same client
+func BuildAndSubmitAttesterSlashingFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, validators []*validator.Validator, epoch phase0.Epoch, kind AttesterSlashingKind) (*phase0.AttesterSlashing, error) {
+	attesterSlashing, err := BuildAttesterSlashingFromClientViewContext(ctx, consensusClient, validators, epoch, kind)
+	if err != nil {
+		return nil, err
+	}
+	if err := consensusClient.SubmitAttesterSlashingContext(ctx, attesterSlashing); err != nil {
+		return attesterSlashing, errors.Wrapf(err, "client %s rejected the attester slashing", consensusClient.Name)
+	}
+	return attesterSlashing, nil
 }
 
-// BuildSignedBLSToExecutionChangeFromClientView uses the specified consensus client to create and sign a BLSToExecutionChange
+// BuildProposerSlashingFromClientViewContext uses the specified consensus client to build two different headers for the slot proposed by the validator.
+// The proposer duties of the client have to name the validator as the proposer of the slot.
+func BuildProposerSlashingFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, v *validator.Validator, slot phase0.Slot) (*phase0.ProposerSlashing, error) {
+	clientValidatorView, err := consensusClient.GetValidatorByPublicKeyContext(ctx, "head", v.ValidatorPublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("couldn't create proposer slashing from client %s", consensusClient.Name))
+	}
+	if err := checkProposerDutyContext(ctx, consensusClient, clientValidatorView.Index, slot); err != nil {
+		return nil, err
+	}
+	head, err := consensusClient.GetBlockHeaderContext(ctx, "head")
+	if err != nil {
+		return nil, err
+	}
+
+	// the blocks only differ in their graffiti
+	block1, err := proposerSlashingBlockContext(ctx, consensusClient, slot, clientValidatorView.Index, head.Root, "proposer slashing header 1")
+	if err != nil {
+		return nil, err
+	}
+	block2, err := proposerSlashingBlockContext(ctx, consensusClient, slot, clientValidatorView.Index, head.Root, "proposer slashing header 2")
+	if err != nil {
+		return nil, err
+	}
+
+	// the blocks are slashable on purpose, slashing protection has to let them through
+	ctx = validator.AllowSlashable(ctx)
+	signedHeader1, err := SignBeaconBlockWithValidatorContext(ctx, consensusClient, v, block1)
+	if err != nil {
+		return nil, err
+	}
+	signedHeader2, err := SignBeaconBlockWithValidatorContext(ctx, consensusClient, v, block2)
+	if err != nil {
+		return nil, err
+	}
+	return &phase0.ProposerSlashing{
+		SignedHeader1: signedHeader1,
+		SignedHeader2: signedHeader2,
+	}, nil
+}
+
+// proposerSlashingBlockContext returns an empty block for the slot in the format signers take for the fork at the slot.
+// Bodies only exist before bellatrix, later forks get a header with the root of the graffiti as body root.
+func proposerSlashingBlockContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, slot phase0.Slot, proposer phase0.ValidatorIndex, parentRoot phase0.Root, graffiti string) (*validator.SigningBeaconBlock, error) {
+	slotsPerEpoch, err := consensusClient.GetSlotsPerEpochContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fork, err := consensusClient.SpecCache().Fork(ctx, phase0.Epoch(uint64(slot)/slotsPerEpoch))
+	if err != nil {
+		return nil, err
+	}
+	forkName, err := forkNameContext(ctx, consensusClient, fork.CurrentVersion)
+	if err != nil {
+		return nil, err
+	}
+	block := &validator.SigningBeaconBlock{Version: forkName}
+	graffitiRoot := sha256.Sum256([]byte(graffiti))
+	switch block.Version {
+	case "PHASE0":
+		block.Phase0Block = &phase0.BeaconBlock{
+			Slot:          slot,
+			ProposerIndex: proposer,
+			ParentRoot:    parentRoot,
+			Body: &phase0.BeaconBlockBody{
+				ETH1Data:          &phase0.ETH1Data{BlockHash: make([]byte, 32)},
+				Graffiti:          graffitiRoot,
+				ProposerSlashings: []*phase0.ProposerSlashing{},
+				AttesterSlashings: []*phase0.AttesterSlashing{},
+				Attestations:      []*phase0.Attestation{},
+				Deposits:          []*phase0.Deposit{},
+				VoluntaryExits:    []*phase0.SignedVoluntaryExit{},
+			},
+		}
+	case "ALTAIR":
+		block.AltairBlock = &altair.BeaconBlock{
+			Slot:          slot,
+			ProposerIndex: proposer,
+			ParentRoot:    parentRoot,
+			Body: &altair.BeaconBlockBody{
+				ETH1Data:          &phase0.ETH1Data{BlockHash: make([]byte, 32)},
+				Graffiti:          graffitiRoot,
+				ProposerSlashings: []*phase0.ProposerSlashing{},
+				AttesterSlashings: []*phase0.AttesterSlashing{},
+				Attestations:      []*phase0.Attestation{},
+				Deposits:          []*phase0.Deposit{},
+				VoluntaryExits:    []*phase0.SignedVoluntaryExit{},
+				SyncAggregate:     &altair.SyncAggregate{SyncCommitteeBits: bitfield.NewBitvector512()},
+			},
+		}
+	default:
+		block.BlockHeader = &phase0.BeaconBlockHeader{
+			Slot:          slot,
+			ProposerIndex: proposer,
+			ParentRoot:    parentRoot,
+			BodyRoot:      graffitiRoot,
+		}
+	}
+	return block, nil
+}
+
+// checkProposerDutyContext returns an error unless the validator is the proposer of the slot according to the client
+func checkProposerDutyContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, index phase0.ValidatorIndex, slot phase0.Slot) error {
+	slotsPerEpoch, err := consensusClient.GetSlotsPerEpochContext(ctx)
+	if err != nil {
+		return err
+	}
+	duties, err := consensusClient.GetProposerDutiesContext(ctx, phase0.Epoch(uint64(slot)/slotsPerEpoch))
+	if err != nil {
+		return err
+	}
+	for _, duty := range duties {
+		if duty.Slot != slot {
+			continue
+		}
+		if duty.ValidatorIndex != index {
+			return fmt.Errorf("validator %d is not the proposer of slot %d, validator %d is", index, slot, duty.ValidatorIndex)
+		}
+		return nil
+	}
+	return fmt.Errorf("client %s has no proposer for slot %d", consensusClient.Name, slot)
+}
+
+// BuildAndSubmitProposerSlashingFromClientViewContext builds a proposer slashing for the validator with BuildProposerSlashingFromClientView
+// and submits it to the proposer slashing pool of the same client
+func BuildAndSubmitProposerSlashingFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, validator *validator.Validator, slot phase0.Slot) (*phase0.ProposerSlashing, error) {
+	proposerSlashing, err := BuildProposerSlashingFromClientViewContext(ctx, consensusClient, validator, slot)
+	if err != nil {
+		return nil, err
+	}
+	if err := consensusClient.SubmitProposerSlashingContext(ctx, proposerSlashing); err != nil {
+		return proposerSlashing, errors.Wrapf(err, "client %s rejected the proposer slashing", consensusClient.Name)
+	}
+	return proposerSlashing, nil
+}
+
+// IsSlashableAttestationData returns true if the two attestations are a double vote or a surround vote, as in is_slashable_attestation_data
+func IsSlashableAttestationData(data1 *phase0.AttestationData, data2 *phase0.AttestationData) bool {
+	root1, err := data1.HashTreeRoot()
+	if err != nil {
+		return false
+	}
+	root2, err := data2.HashTreeRoot()
+	if err != nil {
+		return false
+	}
+	doubleVote := root1 != root2 && data1.Target.Epoch == data2.Target.Epoch
+	surroundVote := data1.Source.Epoch < data2.Source.Epoch && data2.Target.Epoch < data1.Target.Epoch
+	return doubleVote || surroundVote
+}
+
+// BuildSignedBLSToExecutionChangeFromClientViewContext uses the specified consensus client to create and sign a BLSToExecutionChange
 // This will always create a valid BLSToExecutionChange with respect to this client.
-func BuildSignedBLSToExecutionChangeFromClientView(consensusClient *consensus_client.ConsensusClient, validator *validator.Validator, address bellatrix.ExecutionAddress) (*capella.SignedBLSToExecutionChange, error) {
+func BuildSignedBLSToExecutionChangeFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, validator *validator.Validator, address bellatrix.ExecutionAddress) (*capella.SignedBLSToExecutionChange, error) {
 
-	clientValidatorView, err := consensusClient.GetValidatorByPublicKey("head", validator.ValidatorPublicKey)
+	clientValidatorView, err := consensusClient.GetValidatorByPublicKeyContext(ctx, "head", validator.ValidatorPublicKey)
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("couldn't create bls to execution change from client %s", consensusClient.Name))
 	}
@@ -57,27 +414,60 @@ func BuildSignedBLSToExecutionChangeFromClientView(consensusClient *consensus_cl
 	}
 	var blsToExecutionChange = &capella.BLSToExecutionChange{
 		ValidatorIndex:     clientValidatorView.Index,
-		FromBLSPubkey:      phase0.BLSPubKey(validator.WithdrawalKey.PublicKey().Marshal()),
+		FromBLSPubkey:      validator.WithdrawalPublicKey,
 		ToExecutionAddress: address,
 	}
 
-	return SignBLSToExecutionChangeWithValidator(consensusClient, validator, blsToExecutionChange)
+	return SignBLSToExecutionChangeWithValidatorContext(ctx, consensusClient, validator, blsToExecutionChange)
+}
+
+// BuildDepositDataFromClientViewContext uses the specified consensus client's spec to create and sign the DepositData for a validator.
+// The withdrawal credentials can be created with BLSWithdrawalCredentials or ExecutionWithdrawalCredentials.
+func BuildDepositDataFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, validator *validator.Validator, withdrawalCredentials []byte, amount phase0.Gwei) (*phase0.DepositData, error) {
+	if len(withdrawalCredentials) != 32 {
+		return nil, fmt.Errorf("withdrawal credentials must be 32 bytes, got %d", len(withdrawalCredentials))
+	}
+	depositMessage := &phase0.DepositMessage{
+		PublicKey:             validator.ValidatorPublicKey,
+		WithdrawalCredentials: withdrawalCredentials,
+		Amount:                amount,
+	}
+	return SignDepositMessageWithValidatorContext(ctx, consensusClient, validator, depositMessage)
+}
+
+// BLSWithdrawalCredentials returns the 0x00 withdrawal credentials of the validators withdrawal key
+func BLSWithdrawalCredentials(validator *validator.Validator) []byte {
+	credentials := sha256.Sum256(validator.WithdrawalPublicKey[:])
+	credentials[0] = BLSWithdrawalPrefix
+	return credentials[:]
+}
+
+// ExecutionWithdrawalCredentials returns the 0x01 withdrawal credentials for the execution address
+func ExecutionWithdrawalCredentials(address bellatrix.ExecutionAddress) []byte {
+	credentials := make([]byte, 32)
+	credentials[0] = ExecutionWithdrawalPrefix
+	copy(credentials[12:], address[:])
+	return credentials
+}
+
+// CompoundingWithdrawalCredentials returns the EIP-7251 0x02 withdrawal credentials for the execution address
+func CompoundingWithdrawalCredentials(address bellatrix.ExecutionAddress) []byte {
+	credentials := ExecutionWithdrawalCredentials(address)
+	credentials[0] = CompoundingWithdrawalPrefix
+	return credentials
 }
 
 // Signing methods allow you to sign with the wrong key for testing purposes.
+// They sign through the validators signer, which holds the keys in memory unless the validator was set up with a remote signer.
 
-// SignBLSToExecutionChangeWithValidator does an unverified sign with the validator on the supplied execution change
+// SignBLSToExecutionChangeWithValidatorContext does an unverified sign with the validator on the supplied execution change
 // WARN: using the wrong validator can lead to an invalid signature.  If this is not your intentions use BuildSignedBLSToExecutionChangeFromClientView to create a valid signed payload.
-func SignBLSToExecutionChangeWithValidator(consensusClient *consensus_client.ConsensusClient, validator *validator.Validator, blsToExecutionChange *capella.BLSToExecutionChange) (*capella.SignedBLSToExecutionChange, error) {
-	var signedBLSToExecutionChange = capella.SignedBLSToExecutionChange{
-		Message:   blsToExecutionChange,
-		Signature: phase0.BLSSignature{},
-	}
-	domainType, err := consensusClient.GetDomainTypeFromSpec(BlsToExecutionChangeDomainLookup)
+func SignBLSToExecutionChangeWithValidatorContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, v *validator.Validator, blsToExecutionChange *capella.BLSToExecutionChange) (*capella.SignedBLSToExecutionChange, error) {
+	domainType, err := consensusClient.GetDomainTypeFromSpecContext(ctx, BlsToExecutionChangeDomainLookup)
 	if err != nil {
 		return nil, err
 	}
-	domain, err := consensusClient.GetDomainFromGenesis(domainType)
+	domain, err := consensusClient.GetDomainFromGenesisContext(ctx, domainType)
 	if err != nil {
 		return nil, err
 	}
@@ -85,37 +475,247 @@ func SignBLSToExecutionChangeWithValidator(consensusClient *consensus_client.Con
 	if err != nil {
 		return nil, err
 	}
-	signingRoot := common.ComputeSigningRoot(messageRoot, common.BLSDomain(domain))
-	signature := validator.WithdrawalKey.Sign(signingRoot[:])
-	copy(signedBLSToExecutionChange.Signature[:], signature.Marshal()[:])
+	// the change is valid across forks, so the signer gets the genesis fork version for every epoch
+	genesis, err := consensusClient.SpecCache().Genesis(ctx)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := v.Sign(ctx, &validator.SigningRequest{
+		PubKey: v.WithdrawalPublicKey,
+		Type:   validator.SigningTypeBLSToExecutionChange,
+		ForkInfo: &validator.ForkInfo{
+			Fork:                  &phase0.Fork{PreviousVersion: genesis.GenesisForkVersion, CurrentVersion: genesis.GenesisForkVersion},
+			GenesisValidatorsRoot: genesis.GenesisValidatorsRoot,
+		},
+		SigningRoot:          phase0.Root(common.ComputeSigningRoot(messageRoot, common.BLSDomain(domain))),
+		BLSToExecutionChange: blsToExecutionChange,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &capella.SignedBLSToExecutionChange{Message: blsToExecutionChange, Signature: signature}, nil
+}
 
-	return &signedBLSToExecutionChange, nil
+// SignIndexedAttestationWithValidatorsContext signs the attestation data with every validator and aggregates the signatures.
+// The attesting indices are sorted as required by the spec.
+// WARN: the validators have to match the attesting indices for the signature to be valid. If this is not your intentions use BuildAttesterSlashingFromClientView.
+func SignIndexedAttestationWithValidatorsContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, validators []*validator.Validator, attestingIndices []phase0.ValidatorIndex, data *phase0.AttestationData) (*phase0.IndexedAttestation, error) {
+	indices := make([]uint64, len(attestingIndices))
+	for i, index := range attestingIndices {
+		indices[i] = uint64(index)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	var indexedAttestation = phase0.IndexedAttestation{
+		AttestingIndices: indices,
+		Data:             data,
+		Signature:        phase0.BLSSignature{},
+	}
+
+	dataRoot, err := data.HashTreeRoot()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get attestation data hash tree root")
+	}
+	domainType, err := consensusClient.GetDomainTypeFromSpecContext(ctx, BeaconAttesterDomainLookup)
+	if err != nil {
+		return nil, err
+	}
+	domain, err := consensusClient.GetDomainContext(ctx, domainType, data.Target.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	forkInfo, err := signingForkInfoContext(ctx, consensusClient, data.Target.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	signingRoot := common.ComputeSigningRoot(dataRoot, common.BLSDomain(domain))
+	var signatures []e2types.Signature
+	for _, v := range validators {
+		signature, err := v.Sign(ctx, &validator.SigningRequest{
+			PubKey:      v.ValidatorPublicKey,
+			Type:        validator.SigningTypeAttestation,
+			ForkInfo:    forkInfo,
+			SigningRoot: phase0.Root(signingRoot),
+			Attestation: data,
+		})
+		if err != nil {
+			return nil, err
+		}
+		blsSignature, err := e2types.BLSSignatureFromBytes(signature[:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid signature of %s", v)
+		}
+		signatures = append(signatures, blsSignature)
+	}
+	signature := e2types.AggregateSignatures(signatures)
+	copy(indexedAttestation.Signature[:], signature.Marshal()[:])
+	return &indexedAttestation, nil
 }
 
-// SignVoluntaryExitWithValidator sign a volunatry exit with a validator
-// WARN: using the wrong validator can lead to an invalid signature. If this is not your intentions use BuildVoluntaryExitFromClientView to create a valid signed payload.
-func SignVoluntaryExitWithValidator(consensusClient *consensus_client.ConsensusClient, validator *validator.Validator, voluntaryExit *phase0.VoluntaryExit) (*phase0.SignedVoluntaryExit, error) {
+// SignBeaconBlockHeaderWithValidatorContext signs the header with the validator using the proposer domain of the fork at the headers slot.
+// Remote signers need the whole block before bellatrix, so headers of those forks have to be signed with SignBeaconBlockWithValidatorContext.
+// WARN: using the wrong validator can lead to an invalid signature. If this is not your intentions use BuildProposerSlashingFromClientView.
+func SignBeaconBlockHeaderWithValidatorContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, v *validator.Validator, header *phase0.BeaconBlockHeader) (*phase0.SignedBeaconBlockHeader, error) {
+	slotsPerEpoch, err := consensusClient.GetSlotsPerEpochContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fork, err := consensusClient.SpecCache().Fork(ctx, phase0.Epoch(uint64(header.Slot)/slotsPerEpoch))
+	if err != nil {
+		return nil, err
+	}
+	forkName, err := forkNameContext(ctx, consensusClient, fork.CurrentVersion)
+	if err != nil {
+		return nil, err
+	}
+	return SignBeaconBlockWithValidatorContext(ctx, consensusClient, v, &validator.SigningBeaconBlock{
+		Version:     forkName,
+		BlockHeader: header,
+	})
+}
 
-	var signedVoluntaryExit = phase0.SignedVoluntaryExit{
-		Message:   voluntaryExit,
-		Signature: phase0.BLSSignature{},
+// SignBeaconBlockWithValidatorContext signs the block with the validator using the proposer domain of the fork at the blocks slot.
+// The block has to be in the format of the fork at its slot: a phase0 or altair block before bellatrix and a header from bellatrix on.
+// WARN: using the wrong validator can lead to an invalid signature. If this is not your intentions use BuildProposerSlashingFromClientView.
+func SignBeaconBlockWithValidatorContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, v *validator.Validator, block *validator.SigningBeaconBlock) (*phase0.SignedBeaconBlockHeader, error) {
+	header, err := block.Header()
+	if err != nil {
+		return nil, err
 	}
+	headerRoot, err := header.HashTreeRoot()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get header hash tree root")
+	}
+	slotsPerEpoch, err := consensusClient.GetSlotsPerEpochContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	epoch := phase0.Epoch(uint64(header.Slot) / slotsPerEpoch)
+	domainType, err := consensusClient.GetDomainTypeFromSpecContext(ctx, BeaconProposerDomainLookup)
+	if err != nil {
+		return nil, err
+	}
+	domain, err := consensusClient.GetDomainContext(ctx, domainType, epoch)
+	if err != nil {
+		return nil, err
+	}
+	forkInfo, err := signingForkInfoContext(ctx, consensusClient, epoch)
+	if err != nil {
+		return nil, err
+	}
+	forkName, err := forkNameContext(ctx, consensusClient, forkInfo.Fork.CurrentVersion)
+	if err != nil {
+		return nil, err
+	}
+	if block.Version != forkName {
+		return nil, errors.Errorf("block of slot %d is a %s block, the fork at the slot is %s", header.Slot, block.Version, forkName)
+	}
+	signature, err := v.Sign(ctx, &validator.SigningRequest{
+		PubKey:      v.ValidatorPublicKey,
+		Type:        validator.SigningTypeBlockV2,
+		ForkInfo:    forkInfo,
+		SigningRoot: phase0.Root(common.ComputeSigningRoot(headerRoot, common.BLSDomain(domain))),
+		BeaconBlock: block,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &phase0.SignedBeaconBlockHeader{Message: header, Signature: signature}, nil
+}
 
+// SignVoluntaryExitWithValidatorContext sign a volunatry exit with a validator
+// WARN: using the wrong validator can lead to an invalid signature. If this is not your intentions use BuildVoluntaryExitFromClientView to create a valid signed payload.
+func SignVoluntaryExitWithValidatorContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, v *validator.Validator, voluntaryExit *phase0.VoluntaryExit) (*phase0.SignedVoluntaryExit, error) {
 	operationRoot, err := voluntaryExit.HashTreeRoot()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get message hash tree root")
 	}
 
-	domainType, err := consensusClient.GetDomainTypeFromSpec(VoluntaryExitDomainLookup)
+	domainType, err := consensusClient.GetDomainTypeFromSpecContext(ctx, VoluntaryExitDomainLookup)
+	if err != nil {
+		return nil, err
+	}
+	domain, err := consensusClient.GetDomainContext(ctx, domainType, voluntaryExit.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	forkInfo, err := signingForkInfoContext(ctx, consensusClient, voluntaryExit.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := v.Sign(ctx, &validator.SigningRequest{
+		PubKey:        v.ValidatorPublicKey,
+		Type:          validator.SigningTypeVoluntaryExit,
+		ForkInfo:      forkInfo,
+		SigningRoot:   phase0.Root(common.ComputeSigningRoot(operationRoot, common.BLSDomain(domain))),
+		VoluntaryExit: voluntaryExit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &phase0.SignedVoluntaryExit{Message: voluntaryExit, Signature: signature}, nil
+}
+
+// SignDepositMessageWithValidatorContext signs the deposit message with the validator using the fork agnostic deposit domain
+// WARN: using the wrong validator can lead to an invalid signature. If this is not your intentions use BuildDepositDataFromClientView to create a valid deposit.
+func SignDepositMessageWithValidatorContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, v *validator.Validator, depositMessage *phase0.DepositMessage) (*phase0.DepositData, error) {
+	messageRoot, err := depositMessage.HashTreeRoot()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get deposit message hash tree root")
+	}
+
+	domainType, err := consensusClient.GetDomainTypeFromSpecContext(ctx, DepositDomainLookup)
+	if err != nil {
+		return nil, err
+	}
+	genesisForkVersion, err := consensusClient.GetForkVersionFromSpecContext(ctx, GenesisForkVersionLookup)
+	if err != nil {
+		return nil, err
+	}
+	// deposits are valid across forks so the domain uses the genesis fork version and an empty genesis validators root
+	domain := common.ComputeDomain(common.BLSDomainType(domainType), common.Version(genesisForkVersion), common.Root{})
+	signature, err := v.Sign(ctx, &validator.SigningRequest{
+		PubKey:      v.ValidatorPublicKey,
+		Type:        validator.SigningTypeDeposit,
+		SigningRoot: phase0.Root(common.ComputeSigningRoot(messageRoot, domain)),
+		Deposit:     validator.NewSigningDeposit(depositMessage, genesisForkVersion),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &phase0.DepositData{
+		PublicKey:             depositMessage.PublicKey,
+		WithdrawalCredentials: depositMessage.WithdrawalCredentials,
+		Amount:                depositMessage.Amount,
+		Signature:             signature,
+	}, nil
+}
+
+// signingForkInfoContext returns the fork active at the epoch and the genesis validators root, remote signers compute the domain from them
+func signingForkInfoContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, epoch phase0.Epoch) (*validator.ForkInfo, error) {
+	fork, err := consensusClient.SpecCache().Fork(ctx, epoch)
 	if err != nil {
 		return nil, err
 	}
-	domain, err := consensusClient.BeaconService.Domain(context.Background(), domainType, voluntaryExit.Epoch)
+	genesis, err := consensusClient.SpecCache().Genesis(ctx)
 	if err != nil {
 		return nil, err
 	}
-	signingRoot := common.ComputeSigningRoot(operationRoot, common.BLSDomain(domain))
-	signature := validator.ValidatorKey.Sign(signingRoot[:])
-	copy(signedVoluntaryExit.Signature[:], signature.Marshal()[:])
-	return &signedVoluntaryExit, nil
+	return &validator.ForkInfo{Fork: fork, GenesisValidatorsRoot: genesis.GenesisValidatorsRoot}, nil
+}
+
+// forkNameContext returns the upper case name of the fork with the version, an error if the version is none of the forks of the client
+func forkNameContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, version phase0.Version) (string, error) {
+	for _, name := range []string{"ELECTRA", "DENEB", "CAPELLA", "BELLATRIX", "ALTAIR"} {
+		if forkVersion, err := consensusClient.SpecCache().Version(ctx, name+"_FORK_VERSION"); err == nil && forkVersion == version {
+			return name, nil
+		}
+	}
+	genesisForkVersion, err := consensusClient.SpecCache().Version(ctx, GenesisForkVersionLookup)
+	if err != nil {
+		return "", err
+	}
+	if genesisForkVersion == version {
+		return "PHASE0", nil
+	}
+	return "", fmt.Errorf("fork version %#x is none of the forks of client %s", version, consensusClient.Name)
 }
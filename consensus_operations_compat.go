@@ -0,0 +1,32 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"eth-testnet-tool/consensus_client"
+	"eth-testnet-tool/validator"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+// Compatibility wrappers for callers from before the operations took a context, they call the Context functions with a background context
+
+// BuildVoluntaryExitFromClientView calls BuildVoluntaryExitFromClientViewContext with a background context
+func BuildVoluntaryExitFromClientView(consensusClient *consensus_client.ConsensusClient, validator *validator.Validator, epoch phase0.Epoch) (*phase0.SignedVoluntaryExit, error) {
+	return BuildVoluntaryExitFromClientViewContext(context.Background(), consensusClient, validator, epoch)
+}
+
+// BuildSignedBLSToExecutionChangeFromClientView calls BuildSignedBLSToExecutionChangeFromClientViewContext with a background context
+func BuildSignedBLSToExecutionChangeFromClientView(consensusClient *consensus_client.ConsensusClient, validator *validator.Validator, address bellatrix.ExecutionAddress) (*capella.SignedBLSToExecutionChange, error) {
+	return BuildSignedBLSToExecutionChangeFromClientViewContext(context.Background(), consensusClient, validator, address)
+}
+
+// SignBLSToExecutionChangeWithValidator calls SignBLSToExecutionChangeWithValidatorContext with a background context
+func SignBLSToExecutionChangeWithValidator(consensusClient *consensus_client.ConsensusClient, validator *validator.Validator, blsToExecutionChange *capella.BLSToExecutionChange) (*capella.SignedBLSToExecutionChange, error) {
+	return SignBLSToExecutionChangeWithValidatorContext(context.Background(), consensusClient, validator, blsToExecutionChange)
+}
+
+// SignVoluntaryExitWithValidator calls SignVoluntaryExitWithValidatorContext with a background context
+func SignVoluntaryExitWithValidator(consensusClient *consensus_client.ConsensusClient, validator *validator.Validator, voluntaryExit *phase0.VoluntaryExit) (*phase0.SignedVoluntaryExit, error) {
+	return SignVoluntaryExitWithValidatorContext(context.Background(), consensusClient, validator, voluntaryExit)
+}
@@ -5,36 +5,24 @@ import (
 	"eth-testnet-tool/consensus_client"
 	"eth-testnet-tool/consensus_client/consensus_objects"
 	"eth-testnet-tool/validator"
-	"github.com/attestantio/go-eth2-client/http"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
 	"github.com/attestantio/go-eth2-client/spec/bellatrix"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/pkg/errors"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
 	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	"strings"
 	"testing"
 	"time"
 )
 
 const (
 	Timeout               = 3 * time.Second
-	ForceJson             = true // force json for testing with minimal testnets
-	BeaconAPI             = "http://10.0.20.5:5052"
 	ValidatorMnemonic     = "ocean style run case glory clip into nature guess jacket document firm fiscal hello kite disagree symptom tide net coral envelope wink render festival"
 	GenesisValidatorCount = 20
 )
 
-func getTestConsensusClient() (*consensus_client.ConsensusClient, error) {
-	service, err := http.New(context.Background(), http.WithAddress(BeaconAPI), http.WithTimeout(Timeout), http.WithEnforceJSON(ForceJson))
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to get test client for test")
-	}
-
-	return &consensus_client.ConsensusClient{
-		Name:          "testClient",
-		BeaconAPI:     BeaconAPI,
-		BeaconService: service.(*http.Service),
-	}, nil
-}
-
 func getTestValidators(maxAcc uint64) ([]*validator.Validator, error) {
 	validators, err := validator.GetValidatorsFromMnemonic(ValidatorMnemonic, 0, maxAcc)
 	if err != nil {
@@ -44,11 +32,10 @@ func getTestValidators(maxAcc uint64) ([]*validator.Validator, error) {
 }
 
 func TestCreateAndSubmitBLSToExecutionChange(t *testing.T) {
-	// RUN on minimal to see the effect.
-	testConsensusClient, err := getTestConsensusClient()
-	require.NoError(t, err)
-	validators, err := getTestValidators(20)
-	require.NoError(t, err)
+	node, testConsensusClient, validators := getMockConsensusClient(t)
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.CheckWithdrawalCredentials = true
+	})
 	randomAddress := bellatrix.ExecutionAddress{0x69, 0x69, 0x069, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69}
 	testValidator := validators[14]
 
@@ -56,52 +43,384 @@ func TestCreateAndSubmitBLSToExecutionChange(t *testing.T) {
 	require.NoError(t, err)
 
 	err = testConsensusClient.SubmitBLSToExecutionChange(signedBlsToExecutionChange)
-	if err != nil {
-		t.Log(err.Error())
-	}
 	require.NoError(t, err)
+	require.Len(t, node.Submissions().BLSToExecutionChanges, 1)
 }
 
 func TestCreateAndSubmitInvalidBLSToExecutionChange(t *testing.T) {
-	testConsensusClient, err := getTestConsensusClient()
-	require.NoError(t, err)
-	validators, err := getTestValidators(20)
-	require.NoError(t, err)
+	node, testConsensusClient, validators := getMockConsensusClient(t)
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.CheckWithdrawalCredentials = true
+	})
 	testValidator := validators[14]
 
-	blsToExecutionChange := consensus_objects.RandomBLSToExecutionChange()
+	blsToExecutionChange := consensus_objects.RandomBLSToExecutionChange(consensus_objects.NewSeed())
 	signedBLSToExecutionChange, err := SignBLSToExecutionChangeWithValidator(testConsensusClient, testValidator, blsToExecutionChange)
 	require.NoError(t, err)
 
 	err = testConsensusClient.SubmitBLSToExecutionChange(signedBLSToExecutionChange)
-	if err != nil {
-		t.Log(err.Error())
-	}
 	require.Error(t, err)
+	require.Empty(t, node.Submissions().BLSToExecutionChanges)
 }
 
 func TestCreateAndSubmitSignedVoluntaryExit(t *testing.T) {
-	testConsensusClient, err := getTestConsensusClient()
-	require.NoError(t, err)
-	validators, err := getTestValidators(20)
-	require.NoError(t, err)
+	node, testConsensusClient, validators := getMockConsensusClient(t)
 	testValidator := validators[14]
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.Spec["SHARD_COMMITTEE_PERIOD"] = "2"
+	})
+	// the chain has to be past the shard committee period for validators to exit
+	require.NoError(t, node.AddBlock(consensus_client.NewMockCapellaBlock(24, 1, phase0.Root{})))
 
 	shardCommitteePeriod, err := testConsensusClient.GetShardCommitteePeriod()
 	require.NoError(t, err)
-
 	currentEpoch, err := testConsensusClient.GetCurrentEpoch()
 	require.NoError(t, err)
-
-	if uint64(currentEpoch) < shardCommitteePeriod {
-		t.Log("We are going to fail in an expected way. The testnet you are using for testing has not reached epoch > SHARD_COMMITTEE_PERIOD")
-		t.Logf("current epoch: %d, shard_committee_period: %d", currentEpoch, shardCommitteePeriod)
-		require.NoError(t, errors.New("testnet has not progressed long enough to test voluntary exits"))
-	}
+	require.GreaterOrEqual(t, uint64(currentEpoch), shardCommitteePeriod)
 
 	voluntaryExit, err := BuildVoluntaryExitFromClientView(testConsensusClient, testValidator, phase0.Epoch(shardCommitteePeriod))
 	require.NoError(t, err)
 
 	err = testConsensusClient.SubmitValidatorExit(voluntaryExit)
 	require.NoError(t, err)
+	submitted := node.Submissions().VoluntaryExits
+	require.Len(t, submitted, 1)
+	require.Equal(t, phase0.ValidatorIndex(14), submitted[0].Message.ValidatorIndex)
+}
+
+func TestIsSlashableAttestationData(t *testing.T) {
+	attestationData := func(source phase0.Epoch, target phase0.Epoch, root byte) *phase0.AttestationData {
+		return &phase0.AttestationData{
+			BeaconBlockRoot: phase0.Root{root},
+			Source:          &phase0.Checkpoint{Epoch: source},
+			Target:          &phase0.Checkpoint{Epoch: target, Root: phase0.Root{root}},
+		}
+	}
+	require.True(t, IsSlashableAttestationData(attestationData(4, 5, 0x01), attestationData(4, 5, 0x02)), "double vote")
+	require.True(t, IsSlashableAttestationData(attestationData(2, 5, 0x01), attestationData(3, 4, 0x01)), "surround vote")
+	require.False(t, IsSlashableAttestationData(attestationData(3, 4, 0x01), attestationData(2, 5, 0x01)), "surrounded vote")
+	require.False(t, IsSlashableAttestationData(attestationData(4, 5, 0x01), attestationData(4, 5, 0x01)), "same vote")
+	require.False(t, IsSlashableAttestationData(attestationData(4, 5, 0x01), attestationData(5, 6, 0x02)), "consecutive votes")
+}
+
+func getMockConsensusClient(t *testing.T) (*consensus_client.MockBeaconNode, *consensus_client.ConsensusClient, []*validator.Validator) {
+	validators, err := getTestValidators(GenesisValidatorCount)
+	require.NoError(t, err)
+	node := consensus_client.NewMockBeaconNode(consensus_client.NewMockBeaconState(validators))
+	t.Cleanup(node.Close)
+	client, err := node.ConsensusClient("mock")
+	require.NoError(t, err)
+	return node, client, validators
+}
+
+// setMockProposer makes the validator the proposer of the slot on the mock beacon node
+func setMockProposer(node *consensus_client.MockBeaconNode, slot phase0.Slot, index phase0.ValidatorIndex) {
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.Proposers[slot] = index
+	})
+}
+
+// requireValidMockSignature checks the signature over the root with the domain the mock beacon node should have produced
+func requireValidMockSignature(t *testing.T, domainType common.BLSDomainType, forkVersion common.Version, root phase0.Root, signature phase0.BLSSignature, signers ...e2types.PublicKey) {
+	mockGenesisValidatorsRoot := common.Root{0x42}
+	signingRoot := common.ComputeSigningRoot(common.Root(root), common.ComputeDomain(domainType, forkVersion, mockGenesisValidatorsRoot))
+	sig, err := e2types.BLSSignatureFromBytes(signature[:])
+	require.NoError(t, err)
+	require.True(t, sig.(*e2types.BLSSignature).VerifyAggregateCommon(signingRoot[:], signers), "invalid signature")
+}
+
+var (
+	mockGenesisForkVersion = common.Version{0x00, 0x00, 0x00, 0x01}
+	mockCapellaForkVersion = common.Version{0x03, 0x00, 0x00, 0x01}
+)
+
+func TestBuildSignedBLSToExecutionChangeFromClientView_Mock(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	address := bellatrix.ExecutionAddress{0x69}
+
+	change, err := BuildSignedBLSToExecutionChangeFromClientView(client, validators[3], address)
+	require.NoError(t, err)
+	require.NoError(t, client.SubmitBLSToExecutionChange(change))
+
+	submitted := node.Submissions().BLSToExecutionChanges
+	require.Len(t, submitted, 1)
+	require.Equal(t, phase0.ValidatorIndex(3), submitted[0].Message.ValidatorIndex)
+	require.Equal(t, address, submitted[0].Message.ToExecutionAddress)
+	root, err := change.Message.HashTreeRoot()
+	require.NoError(t, err)
+	requireValidMockSignature(t, common.BLSDomainType{0x0a}, mockGenesisForkVersion, root, submitted[0].Signature, validators[3].WithdrawalKey.PublicKey())
+}
+
+func TestBuildVoluntaryExitFromClientView_Mock(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+
+	exit, err := BuildVoluntaryExitFromClientView(client, validators[4], 0)
+	require.NoError(t, err)
+	require.NoError(t, client.SubmitValidatorExit(exit))
+
+	submitted := node.Submissions().VoluntaryExits
+	require.Len(t, submitted, 1)
+	require.Equal(t, phase0.ValidatorIndex(4), submitted[0].Message.ValidatorIndex)
+	root, err := exit.Message.HashTreeRoot()
+	require.NoError(t, err)
+	requireValidMockSignature(t, common.BLSDomainType{0x04}, mockCapellaForkVersion, root, submitted[0].Signature, validators[4].ValidatorKey.PublicKey())
+
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.Spec["SHARD_COMMITTEE_PERIOD"] = "256"
+	})
+	// the spec is cached by the client until it is invalidated
+	_, err = BuildVoluntaryExitFromClientView(client, validators[4], 0)
+	require.NoError(t, err)
+	client.SpecCache().Invalidate()
+	_, err = BuildVoluntaryExitFromClientView(client, validators[4], 0)
+	require.Error(t, err)
+}
+
+func TestBuildAttesterSlashingFromClientView_Mock(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	ctx := context.Background()
+	require.NoError(t, node.AddBlock(consensus_client.NewMockCapellaBlock(40, 1, phase0.Root{})))
+	slashed := []*validator.Validator{validators[9], validators[2]}
+	signers := []e2types.PublicKey{validators[9].ValidatorKey.PublicKey(), validators[2].ValidatorKey.PublicKey()}
+
+	for _, kind := range []AttesterSlashingKind{DoubleVote, SurroundVote} {
+		slashing, err := BuildAndSubmitAttesterSlashingFromClientViewContext(ctx, client, slashed, 5, kind)
+		require.NoError(t, err)
+		require.True(t, IsSlashableAttestationData(slashing.Attestation1.Data, slashing.Attestation2.Data))
+		for _, attestation := range []*phase0.IndexedAttestation{slashing.Attestation1, slashing.Attestation2} {
+			require.Equal(t, []uint64{2, 9}, attestation.AttestingIndices)
+			root, err := attestation.Data.HashTreeRoot()
+			require.NoError(t, err)
+			requireValidMockSignature(t, common.BLSDomainType{0x01}, mockCapellaForkVersion, root, attestation.Signature, signers...)
+		}
+	}
+	require.Len(t, node.Submissions().AttesterSlashings, 2)
+
+	_, err := BuildAttesterSlashingFromClientViewContext(ctx, client, slashed, 2, SurroundVote)
+	require.Error(t, err)
+}
+
+func TestBuildProposerSlashingFromClientView_Mock(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	ctx := context.Background()
+	setMockProposer(node, 12, 7)
+
+	// only the proposer of the slot can be slashed for it
+	_, err := BuildAndSubmitProposerSlashingFromClientViewContext(ctx, client, validators[3], 12)
+	require.Error(t, err)
+	require.Empty(t, node.Submissions().ProposerSlashings)
+
+	slashing, err := BuildAndSubmitProposerSlashingFromClientViewContext(ctx, client, validators[7], 12)
+	require.NoError(t, err)
+	submitted := node.Submissions().ProposerSlashings
+	require.Len(t, submitted, 1)
+	require.Equal(t, slashing, submitted[0])
+
+	header1, header2 := slashing.SignedHeader1, slashing.SignedHeader2
+	require.Equal(t, header1.Message.Slot, header2.Message.Slot)
+	require.Equal(t, phase0.ValidatorIndex(7), header1.Message.ProposerIndex)
+	require.NotEqual(t, header1.Message.BodyRoot, header2.Message.BodyRoot)
+	for _, header := range []*phase0.SignedBeaconBlockHeader{header1, header2} {
+		root, err := header.Message.HashTreeRoot()
+		require.NoError(t, err)
+		requireValidMockSignature(t, common.BLSDomainType{0x00}, mockCapellaForkVersion, root, header.Signature, validators[7].ValidatorKey.PublicKey())
+	}
+}
+
+func TestBuildFromClientViewContext_MockCancelled(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := BuildVoluntaryExitFromClientViewContext(ctx, client, validators[4], 0)
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = BuildAndSubmitProposerSlashingFromClientViewContext(ctx, client, validators[2], 1)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Empty(t, node.Submissions().ProposerSlashings)
+}
+
+func TestBuildFromClientView_MockRemoteSigner(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	ctx := context.Background()
+	signer := validator.NewMockWeb3Signer(validators...)
+	signer.SlotsPerEpoch = 8
+	t.Cleanup(signer.Close)
+	remote := make([]*validator.Validator, len(validators))
+	for i, v := range validators {
+		remote[i] = validator.NewRemoteValidator(v.ValidatorIndex, v.ValidatorPublicKey, v.WithdrawalPublicKey, signer.Signer())
+	}
+
+	exit, err := BuildVoluntaryExitFromClientView(client, remote[4], 0)
+	require.NoError(t, err)
+	root, err := exit.Message.HashTreeRoot()
+	require.NoError(t, err)
+	requireValidMockSignature(t, common.BLSDomainType{0x04}, mockCapellaForkVersion, root, exit.Signature, validators[4].ValidatorKey.PublicKey())
+
+	change, err := BuildSignedBLSToExecutionChangeFromClientView(client, remote[3], bellatrix.ExecutionAddress{0x69})
+	require.NoError(t, err)
+	root, err = change.Message.HashTreeRoot()
+	require.NoError(t, err)
+	requireValidMockSignature(t, common.BLSDomainType{0x0a}, mockGenesisForkVersion, root, change.Signature, validators[3].WithdrawalKey.PublicKey())
+
+	setMockProposer(node, 12, 7)
+	slashing, err := BuildProposerSlashingFromClientViewContext(ctx, client, remote[7], 12)
+	require.NoError(t, err)
+	root, err = slashing.SignedHeader1.Message.HashTreeRoot()
+	require.NoError(t, err)
+	requireValidMockSignature(t, common.BLSDomainType{0x00}, mockCapellaForkVersion, root, slashing.SignedHeader1.Signature, validators[7].ValidatorKey.PublicKey())
+
+	require.NoError(t, node.AddBlock(consensus_client.NewMockCapellaBlock(40, 1, phase0.Root{})))
+	attesterSlashing, err := BuildAttesterSlashingFromClientViewContext(ctx, client, []*validator.Validator{remote[9], remote[2]}, 5, DoubleVote)
+	require.NoError(t, err)
+	root, err = attesterSlashing.Attestation1.Data.HashTreeRoot()
+	require.NoError(t, err)
+	requireValidMockSignature(t, common.BLSDomainType{0x01}, mockCapellaForkVersion, root, attesterSlashing.Attestation1.Signature, validators[9].ValidatorKey.PublicKey(), validators[2].ValidatorKey.PublicKey())
+
+	_, err = BuildDepositDataFromClientViewContext(ctx, client, remote[1], BLSWithdrawalCredentials(remote[1]), 32_000_000_000)
+	require.NoError(t, err)
+
+	// the signer recomputed every signing root from the message, so every request carried the message it signed
+	var types []validator.SigningType
+	for _, request := range signer.Requests() {
+		types = append(types, request.Type)
+	}
+	require.Equal(t, []validator.SigningType{
+		validator.SigningTypeVoluntaryExit,
+		validator.SigningTypeBLSToExecutionChange,
+		validator.SigningTypeBlockV2, validator.SigningTypeBlockV2,
+		validator.SigningTypeAttestation, validator.SigningTypeAttestation, validator.SigningTypeAttestation, validator.SigningTypeAttestation,
+		validator.SigningTypeDeposit,
+	}, types)
+	require.Equal(t, "CAPELLA", signer.Requests()[2].BeaconBlock.Version)
+}
+
+func TestBuildAttestationFromClientView_MockRoots(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	ctx := context.Background()
+	genesis, err := client.GetBlockHeader("genesis")
+	require.NoError(t, err)
+	// the first slot of epoch 5 is empty, the last one holds the head
+	block38 := consensus_client.NewMockCapellaBlock(38, 1, genesis.Root)
+	require.NoError(t, node.AddBlock(block38))
+	root38, err := block38.Root()
+	require.NoError(t, err)
+	block47 := consensus_client.NewMockCapellaBlock(47, 2, root38)
+	require.NoError(t, node.AddBlock(block47))
+	root47, err := block47.Root()
+	require.NoError(t, err)
+
+	// validator 5 attests before the head was proposed, validator 6 at the slot of the head
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.Committees[5] = []*v1.BeaconCommittee{
+			{Slot: 44, Index: 0, Validators: []phase0.ValidatorIndex{5}},
+			{Slot: 47, Index: 0, Validators: []phase0.ValidatorIndex{6}},
+		}
+	})
+
+	attestation, err := BuildAttestationFromClientViewContext(ctx, client, validators[5], 5)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Slot(44), attestation.Data.Slot)
+	require.Equal(t, phase0.Epoch(5), attestation.Data.Target.Epoch)
+	require.Equal(t, root38, attestation.Data.Target.Root)
+	// the head is the head as of the attestation slot, not the current head
+	require.Equal(t, root38, attestation.Data.BeaconBlockRoot)
+
+	attestation, err = BuildAttestationFromClientViewContext(ctx, client, validators[6], 5)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Slot(47), attestation.Data.Slot)
+	require.Equal(t, root38, attestation.Data.Target.Root)
+	require.Equal(t, root47, attestation.Data.BeaconBlockRoot)
+
+	root, err := client.GetBlockRootAtSlotContext(ctx, 40)
+	require.NoError(t, err)
+	require.Equal(t, root38, root)
+	root, err = client.GetBlockRootAtSlotContext(ctx, 30)
+	require.NoError(t, err)
+	require.Equal(t, genesis.Root, root)
+}
+
+func TestBuildProposerSlashingFromClientView_MockRemoteSignerBeforeBellatrix(t *testing.T) {
+	for _, test := range []struct {
+		fork        string
+		forkEpoch   int
+		forkVersion common.Version
+	}{
+		{fork: "PHASE0", forkEpoch: 1, forkVersion: mockGenesisForkVersion},
+		{fork: "ALTAIR", forkEpoch: 2, forkVersion: common.Version{0x01, 0x00, 0x00, 0x01}},
+	} {
+		t.Run(test.fork, func(t *testing.T) {
+			node, client, validators := getMockConsensusClient(t)
+			// slot 12 is in epoch 1, move every fork from the first one on past it
+			node.Update(func(state *consensus_client.MockBeaconState) {
+				for _, fork := range state.ForkSchedule[test.forkEpoch:] {
+					fork.Epoch = 10
+				}
+			})
+			setMockProposer(node, 12, 7)
+			signer := validator.NewMockWeb3Signer(validators[7])
+			signer.SlotsPerEpoch = 8
+			t.Cleanup(signer.Close)
+			remote := validator.NewRemoteValidator(validators[7].ValidatorIndex, validators[7].ValidatorPublicKey, validators[7].WithdrawalPublicKey, signer.Signer())
+
+			slashing, err := BuildProposerSlashingFromClientViewContext(context.Background(), client, remote, 12)
+			require.NoError(t, err)
+			root, err := slashing.SignedHeader1.Message.HashTreeRoot()
+			require.NoError(t, err)
+			requireValidMockSignature(t, common.BLSDomainType{0x00}, test.forkVersion, root, slashing.SignedHeader1.Signature, validators[7].ValidatorKey.PublicKey())
+
+			// the signer got the whole block, not just the header
+			requests := signer.Requests()
+			require.Len(t, requests, 2)
+			require.Equal(t, test.fork, requests[0].BeaconBlock.Version)
+			require.Nil(t, requests[0].BeaconBlock.BlockHeader)
+			header, err := requests[0].BeaconBlock.Header()
+			require.NoError(t, err)
+			require.Equal(t, slashing.SignedHeader1.Message, header)
+
+			// a header alone can't be signed before bellatrix
+			_, err = SignBeaconBlockHeaderWithValidatorContext(validator.AllowSlashable(context.Background()), client, remote, slashing.SignedHeader1.Message)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestForkName_Mock(t *testing.T) {
+	node, client, _ := getMockConsensusClient(t)
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.Spec["ELECTRA_FORK_VERSION"] = "0x05000001"
+	})
+	ctx := context.Background()
+
+	for version, expected := range map[phase0.Version]string{
+		{0x00, 0x00, 0x00, 0x01}: "PHASE0",
+		{0x03, 0x00, 0x00, 0x01}: "CAPELLA",
+		{0x05, 0x00, 0x00, 0x01}: "ELECTRA",
+	} {
+		forkName, err := forkNameContext(ctx, client, version)
+		require.NoError(t, err)
+		require.Equal(t, expected, forkName)
+	}
+	_, err := forkNameContext(ctx, client, phase0.Version{0x09, 0x00, 0x00, 0x01})
+	require.ErrorContains(t, err, "none of the forks")
+}
+
+func TestBuildProposerSlashingFromClientView_MockSlashingProtection(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	ctx := context.Background()
+	setMockProposer(node, 12, 7)
+	protection := validator.NewSlashingProtection(phase0.Root{})
+	protection.Protect(validators[7])
+
+	// building a slashing opts in to slashable signatures, signing another header for the slot doesn't
+	slashing, err := BuildProposerSlashingFromClientViewContext(ctx, client, validators[7], 12)
+	require.NoError(t, err)
+	_, err = SignBeaconBlockHeaderWithValidatorContext(ctx, client, validators[7], &phase0.BeaconBlockHeader{Slot: 12, ProposerIndex: 7})
+	require.ErrorIs(t, err, validator.ErrSlashableBlock)
+	_, err = SignBeaconBlockHeaderWithValidatorContext(ctx, client, validators[7], slashing.SignedHeader1.Message)
+	require.NoError(t, err)
+
+	exported, err := protection.Export()
+	require.NoError(t, err)
+	require.Contains(t, string(exported), "0x4200000000000000000000000000000000000000000000000000000000000000")
+	require.Equal(t, 2, strings.Count(string(exported), `"slot": "12"`))
 }
@@ -0,0 +1,292 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"encoding/json"
+	"eth-testnet-tool/consensus_client"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	corpusEntryFile      = "entry.json"
+	corpusObjectSSZFile  = "object.ssz"
+	corpusObjectJSONFile = "object.json"
+)
+
+// CorpusObject a consensus object the corpus can store and submit
+type CorpusObject interface {
+	MarshalSSZ() ([]byte, error)
+}
+
+// corpusEndpoint the endpoint a kind of object is submitted to, array endpoints take a list of objects.
+// Endpoints taking objects of several forks need the fork of the object in the Eth-Consensus-Version header.
+type corpusEndpoint struct {
+	path    string
+	array   bool
+	version string
+}
+
+var corpusEndpoints = map[string]corpusEndpoint{
+	"Attestation":                {path: "/eth/v1/beacon/pool/attestations", array: true},
+	"AttesterSlashing":           {path: "/eth/v1/beacon/pool/attester_slashings"},
+	"ProposerSlashing":           {path: "/eth/v1/beacon/pool/proposer_slashings"},
+	"SignedBLSToExecutionChange": {path: "/eth/v1/beacon/pool/bls_to_execution_changes", array: true},
+	"SignedVoluntaryExit":        {path: "/eth/v1/beacon/pool/voluntary_exits"},
+	"SignedAggregateAndProof":    {path: "/eth/v1/validator/aggregate_and_proofs", array: true},
+	"Phase0SignedBeaconBlock":    {path: "/eth/v2/beacon/blocks", version: "phase0"},
+	"AltairSignedBeaconBlock":    {path: "/eth/v2/beacon/blocks", version: "altair"},
+	"BellatrixSignedBeaconBlock": {path: "/eth/v2/beacon/blocks", version: "bellatrix"},
+	"CapellaSignedBeaconBlock":   {path: "/eth/v2/beacon/blocks", version: "capella"},
+}
+
+// CorpusEntry a submitted object and how the client responded to it, the object itself is stored next to the entry
+type CorpusEntry struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	// Seed the seed the object was generated with
+	Seed int64 `json:"seed"`
+	// Label anything else worth knowing about the object, e.g. the rule a mutation breaks
+	Label    string    `json:"label,omitempty"`
+	Endpoint string    `json:"endpoint"`
+	Client   string    `json:"client"`
+	Time     time.Time `json:"time"`
+	// StatusCode and Response are the response of the client, Error is set instead if the client couldn't be reached
+	StatusCode int    `json:"status_code"`
+	Response   string `json:"response"`
+	Error      string `json:"error,omitempty"`
+	// ReplayOf the entry a replay was made from, only set on the entries returned by Replay
+	ReplayOf string `json:"replay_of,omitempty"`
+}
+
+// Accepted returns true if the client accepted the object
+func (e *CorpusEntry) Accepted() bool {
+	return e.Error == "" && e.StatusCode/100 == 2
+}
+
+// Corpus stores submitted objects in a directory, one directory per entry holding the object as SSZ and JSON,
+// so a submission that crashed a client can be looked at and replayed later.
+type Corpus struct {
+	Dir string
+
+	lock   sync.Mutex
+	lastID int64
+}
+
+// OpenCorpus opens the corpus in the directory, creating it if needed
+func OpenCorpus(dir string) (*Corpus, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create corpus %s", dir)
+	}
+	return &Corpus{Dir: dir}, nil
+}
+
+// corpusKind returns the kind of the object, which decides the endpoint it is submitted to
+func corpusKind(object CorpusObject) (string, error) {
+	switch object.(type) {
+	case *phase0.Attestation:
+		return "Attestation", nil
+	case *phase0.AttesterSlashing:
+		return "AttesterSlashing", nil
+	case *phase0.ProposerSlashing:
+		return "ProposerSlashing", nil
+	case *capella.SignedBLSToExecutionChange:
+		return "SignedBLSToExecutionChange", nil
+	case *phase0.SignedVoluntaryExit:
+		return "SignedVoluntaryExit", nil
+	case *phase0.SignedAggregateAndProof:
+		return "SignedAggregateAndProof", nil
+	case *phase0.SignedBeaconBlock:
+		return "Phase0SignedBeaconBlock", nil
+	case *altair.SignedBeaconBlock:
+		return "AltairSignedBeaconBlock", nil
+	case *bellatrix.SignedBeaconBlock:
+		return "BellatrixSignedBeaconBlock", nil
+	case *capella.SignedBeaconBlock:
+		return "CapellaSignedBeaconBlock", nil
+	case *deneb.SignedBeaconBlock:
+		return "", errors.New("deneb blocks are published together with their blobs and kzg proofs, which the corpus doesn't store")
+	case *deneb.BlobSidecar:
+		return "", errors.New("blob sidecars can't be submitted on their own, the beacon api only takes them with their block")
+	default:
+		return "", fmt.Errorf("no endpoint for %T", object)
+	}
+}
+
+// SubmitContext submits the object to the client and saves it with the seed it was generated from and the response of the client.
+// Rejections are recorded in the entry, only failing to save the entry or to reach the client returns an error.
+func (c *Corpus) SubmitContext(ctx context.Context, client *consensus_client.ConsensusClient, object CorpusObject, seed int64, label string) (*CorpusEntry, error) {
+	kind, err := corpusKind(object)
+	if err != nil {
+		return nil, err
+	}
+	objectSSZ, err := object.MarshalSSZ()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal %s to ssz", kind)
+	}
+	objectJSON, err := json.Marshal(object)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal %s to json", kind)
+	}
+	entry := &CorpusEntry{
+		ID:       c.nextID(kind),
+		Kind:     kind,
+		Seed:     seed,
+		Label:    label,
+		Endpoint: corpusEndpoints[kind].path,
+	}
+	submitErr := c.post(ctx, client, entry, objectJSON)
+
+	dir := filepath.Join(c.Dir, entry.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create corpus entry %s", entry.ID)
+	}
+	entryJSON, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal corpus entry")
+	}
+	for file, data := range map[string][]byte{corpusEntryFile: entryJSON, corpusObjectSSZFile: objectSSZ, corpusObjectJSONFile: objectJSON} {
+		if err := os.WriteFile(filepath.Join(dir, file), data, 0o644); err != nil {
+			return nil, errors.Wrapf(err, "failed to write %s of corpus entry %s", file, entry.ID)
+		}
+	}
+	return entry, submitErr
+}
+
+// SubmitBlockContext submits the block of the fork it was made for with SubmitContext
+func (c *Corpus) SubmitBlockContext(ctx context.Context, client *consensus_client.ConsensusClient, block *spec.VersionedSignedBeaconBlock, seed int64, label string) (*CorpusEntry, error) {
+	var object CorpusObject
+	switch block.Version {
+	case spec.DataVersionPhase0:
+		object = block.Phase0
+	case spec.DataVersionAltair:
+		object = block.Altair
+	case spec.DataVersionBellatrix:
+		object = block.Bellatrix
+	case spec.DataVersionCapella:
+		object = block.Capella
+	case spec.DataVersionDeneb:
+		object = block.Deneb
+	default:
+		return nil, fmt.Errorf("unsupported block version %s", block.Version)
+	}
+	return c.SubmitContext(ctx, client, object, seed, label)
+}
+
+// ReplayContext resends the object of a saved entry to the client, which doesn't have to be the client it was first sent to.
+// The returned entry holds the new response, it isn't saved.
+func (c *Corpus) ReplayContext(ctx context.Context, client *consensus_client.ConsensusClient, id string) (*CorpusEntry, error) {
+	saved, err := c.Entry(id)
+	if err != nil {
+		return nil, err
+	}
+	objectJSON, err := c.ObjectJSON(id)
+	if err != nil {
+		return nil, err
+	}
+	entry := &CorpusEntry{
+		ID:       saved.ID,
+		Kind:     saved.Kind,
+		Seed:     saved.Seed,
+		Label:    saved.Label,
+		Endpoint: saved.Endpoint,
+		ReplayOf: saved.ID,
+	}
+	return entry, c.post(ctx, client, entry, objectJSON)
+}
+
+// post sends the object to the endpoint of the entry and records the response in it
+func (c *Corpus) post(ctx context.Context, client *consensus_client.ConsensusClient, entry *CorpusEntry, objectJSON []byte) error {
+	endpoint, ok := corpusEndpoints[entry.Kind]
+	if !ok {
+		return fmt.Errorf("no endpoint for %s", entry.Kind)
+	}
+	body := objectJSON
+	if endpoint.array {
+		body = append(append([]byte("["), objectJSON...), ']')
+	}
+	var headers map[string]string
+	if endpoint.version != "" {
+		headers = map[string]string{"Eth-Consensus-Version": endpoint.version}
+	}
+	entry.Client = client.Name
+	entry.Time = time.Now().UTC()
+	statusCode, response, err := client.PostRawDataContext(ctx, entry.Endpoint, body, headers)
+	if err != nil {
+		entry.Error = err.Error()
+		return errors.Wrapf(err, "failed to submit %s to client: %s", entry.Kind, client.Name)
+	}
+	entry.StatusCode, entry.Response = statusCode, string(response)
+	return nil
+}
+
+// nextID returns a new entry id, ids sort in the order the entries were made
+func (c *Corpus) nextID(kind string) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	id := time.Now().UnixNano()
+	if id <= c.lastID {
+		id = c.lastID + 1
+	}
+	c.lastID = id
+	return fmt.Sprintf("%d-%s", id, kind)
+}
+
+// Entry loads the entry with the id
+func (c *Corpus) Entry(id string) (*CorpusEntry, error) {
+	data, err := os.ReadFile(filepath.Join(c.Dir, id, corpusEntryFile))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read corpus entry %s", id)
+	}
+	var entry CorpusEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode corpus entry %s", id)
+	}
+	return &entry, nil
+}
+
+// Entries loads all entries of the corpus, oldest first
+func (c *Corpus) Entries() ([]*CorpusEntry, error) {
+	dirs, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read corpus %s", c.Dir)
+	}
+	var entries []*CorpusEntry
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		entry, err := c.Entry(dir.Name())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+// ObjectSSZ returns the object of the entry as SSZ
+func (c *Corpus) ObjectSSZ(id string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(c.Dir, id, corpusObjectSSZFile))
+	return data, errors.Wrapf(err, "failed to read ssz of corpus entry %s", id)
+}
+
+// ObjectJSON returns the object of the entry as JSON
+func (c *Corpus) ObjectJSON(id string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(c.Dir, id, corpusObjectJSONFile))
+	return data, errors.Wrapf(err, "failed to read json of corpus entry %s", id)
+}
@@ -0,0 +1,135 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"encoding/json"
+	"eth-testnet-tool/consensus_client"
+	"eth-testnet-tool/consensus_client/consensus_objects"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCorpus_SubmitAndReplay(t *testing.T) {
+	ctx := context.Background()
+	node, client, validators := getMockConsensusClient(t)
+	otherNode := consensus_client.NewMockBeaconNode(consensus_client.NewMockBeaconState(validators))
+	t.Cleanup(otherNode.Close)
+	otherClient, err := otherNode.ConsensusClient("other")
+	require.NoError(t, err)
+	corpus, err := OpenCorpus(t.TempDir())
+	require.NoError(t, err)
+
+	seed := consensus_objects.NewSeed()
+	exit := consensus_objects.RandomSignedVoluntaryExit(seed)
+	entry, err := corpus.SubmitContext(ctx, client, exit, seed, "random")
+	require.NoError(t, err)
+	require.True(t, entry.Accepted())
+	require.Equal(t, "SignedVoluntaryExit", entry.Kind)
+	require.Equal(t, "/eth/v1/beacon/pool/voluntary_exits", entry.Endpoint)
+	require.Equal(t, []*phase0.SignedVoluntaryExit{exit}, node.Submissions().VoluntaryExits)
+
+	reopened, err := OpenCorpus(corpus.Dir)
+	require.NoError(t, err)
+	entries, err := reopened.Entries()
+	require.NoError(t, err)
+	require.Equal(t, []*CorpusEntry{entry}, entries)
+
+	// the seed regenerates the object that was stored
+	objectSSZ, err := reopened.ObjectSSZ(entry.ID)
+	require.NoError(t, err)
+	var saved phase0.SignedVoluntaryExit
+	require.NoError(t, saved.UnmarshalSSZ(objectSSZ))
+	require.Equal(t, consensus_objects.RandomSignedVoluntaryExit(entries[0].Seed), &saved)
+	objectJSON, err := reopened.ObjectJSON(entry.ID)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(objectJSON, &saved))
+	require.Equal(t, exit, &saved)
+
+	replay, err := reopened.ReplayContext(ctx, otherClient, entry.ID)
+	require.NoError(t, err)
+	require.True(t, replay.Accepted())
+	require.Equal(t, entry.ID, replay.ReplayOf)
+	require.Equal(t, "other", replay.Client)
+	require.Equal(t, []*phase0.SignedVoluntaryExit{exit}, otherNode.Submissions().VoluntaryExits)
+}
+
+func TestCorpus_Rejected(t *testing.T) {
+	ctx := context.Background()
+	node, client, _ := getMockConsensusClient(t)
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.RejectSubmissions = true
+	})
+	corpus, err := OpenCorpus(filepath.Join(t.TempDir(), "corpus"))
+	require.NoError(t, err)
+
+	seed := consensus_objects.NewSeed()
+	change := consensus_objects.RandomSignedBLSToExecutionChange(seed)
+	entry, err := corpus.SubmitContext(ctx, client, change, seed, "")
+	require.NoError(t, err)
+	require.False(t, entry.Accepted())
+	require.Equal(t, http.StatusBadRequest, entry.StatusCode)
+	require.Contains(t, entry.Response, "submission rejected by mock beacon node")
+
+	saved, err := corpus.Entry(entry.ID)
+	require.NoError(t, err)
+	require.Equal(t, entry, saved)
+
+	// array endpoints get the object wrapped in a list
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.RejectSubmissions = false
+	})
+	replay, err := corpus.ReplayContext(ctx, client, entry.ID)
+	require.NoError(t, err)
+	require.True(t, replay.Accepted(), replay.Response)
+	require.Len(t, node.Submissions().BLSToExecutionChanges, 1)
+
+	_, err = corpus.SubmitContext(ctx, client, consensus_objects.RandomBlobSideCar(seed), seed, "")
+	require.Error(t, err)
+	_, err = corpus.SubmitBlockContext(ctx, client, consensus_objects.RandomDenebSignedBeaconBlock(seed), seed, "")
+	require.Error(t, err)
+	_, err = os.Stat(filepath.Join(corpus.Dir, entry.ID, "object.ssz"))
+	require.NoError(t, err)
+}
+
+func TestCorpus_BlocksAndAggregates(t *testing.T) {
+	ctx := context.Background()
+	node, client, _ := getMockConsensusClient(t)
+	corpus, err := OpenCorpus(t.TempDir())
+	require.NoError(t, err)
+
+	seed := consensus_objects.NewSeed()
+	aggregate := consensus_objects.RandomAggregateAndProof(seed)
+	entry, err := corpus.SubmitContext(ctx, client, aggregate, seed, "")
+	require.NoError(t, err)
+	require.True(t, entry.Accepted(), entry.Response)
+	require.Equal(t, "/eth/v1/validator/aggregate_and_proofs", entry.Endpoint)
+	require.Equal(t, []*phase0.SignedAggregateAndProof{aggregate}, node.Submissions().AggregateAndProofs)
+
+	// blocks are sent with the fork they were made for, also when replayed
+	for _, block := range []*spec.VersionedSignedBeaconBlock{
+		consensus_objects.RandomPhase0SignedBeaconBlock(seed),
+		consensus_objects.RandomAltairSingedBeaconBlock(seed),
+		consensus_objects.RandomBellatrixSignedBeaconBlock(seed),
+		consensus_objects.RandomCapellaSignedBeaconBlock(seed),
+	} {
+		entry, err := corpus.SubmitBlockContext(ctx, client, block, seed, "")
+		require.NoError(t, err)
+		require.True(t, entry.Accepted(), entry.Response)
+		require.Equal(t, "/eth/v2/beacon/blocks", entry.Endpoint)
+		replay, err := corpus.ReplayContext(ctx, client, entry.ID)
+		require.NoError(t, err)
+		require.True(t, replay.Accepted(), replay.Response)
+	}
+	blocks := node.Submissions().Blocks
+	require.Len(t, blocks, 8)
+	for i, version := range []spec.DataVersion{spec.DataVersionPhase0, spec.DataVersionAltair, spec.DataVersionBellatrix, spec.DataVersionCapella} {
+		require.Equal(t, version, blocks[2*i].Version)
+		require.Equal(t, blocks[2*i], blocks[2*i+1])
+	}
+	require.Equal(t, consensus_objects.RandomCapellaSignedBeaconBlock(seed), blocks[6])
+}
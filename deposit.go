@@ -0,0 +1,164 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"eth-testnet-tool/execution_client"
+	"eth-testnet-tool/validator"
+	"fmt"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// DefaultDepositAmount a full 32 ETH deposit
+const DefaultDepositAmount = phase0.Gwei(32_000_000_000)
+
+const depositContractABI = `[{"name":"deposit","type":"function","stateMutability":"payable","outputs":[],"inputs":[{"name":"pubkey","type":"bytes"},{"name":"withdrawal_credentials","type":"bytes"},{"name":"signature","type":"bytes"},{"name":"deposit_data_root","type":"bytes32"}]}]`
+
+// DepositOpts configures how new validators are deposited
+type DepositOpts struct {
+	// Amount the deposit amount per validator, defaults to DefaultDepositAmount
+	Amount phase0.Gwei
+	// WithdrawalAddress if set the validators get 0x01 credentials to this address, otherwise 0x00 credentials of their withdrawal key
+	WithdrawalAddress *bellatrix.ExecutionAddress
+	// PreminePath the premine account paying for the deposits, defaults to the first configured premine
+	PreminePath string
+}
+
+// ValidatorDeposit is a deposit made for a single validator
+type ValidatorDeposit struct {
+	Validator   *validator.Validator
+	DepositData *phase0.DepositData
+	Transaction *types.Transaction
+	Receipt     *types.Receipt
+}
+
+// DepositValidators derives the validators [minAcc, maxAcc) from the validator mnemonic and deposits them through the deposit contract.
+// The deposited validators are added to the managers Validators once their deposit transaction is included.
+func (c *ClientManager) DepositValidators(ctx context.Context, minAcc uint64, maxAcc uint64, opts DepositOpts) ([]*ValidatorDeposit, error) {
+	if minAcc < c.TestnetConfig.GenesisValidatorCount {
+		return nil, fmt.Errorf("validator %d is a genesis validator, new validators start at %d", minAcc, c.TestnetConfig.GenesisValidatorCount)
+	}
+	if opts.Amount == 0 {
+		opts.Amount = DefaultDepositAmount
+	}
+
+	validators, err := validator.GetValidatorsFromMnemonic(c.TestnetConfig.ValidatorMnemonic, minAcc, maxAcc)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to derive validators to deposit")
+	}
+	depositContract, err := c.getDepositContractAddressContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	premineAccount, err := c.GetExecutionAccount(opts.PreminePath)
+	if err != nil {
+		return nil, err
+	}
+	contractABI, err := abi.JSON(strings.NewReader(depositContractABI))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse deposit contract abi")
+	}
+
+	consensusClient := c.GetRandomConsensusClient()
+	executionClient := c.GetRandomExecutionClient()
+
+	var deposits []*ValidatorDeposit
+	for _, v := range validators {
+		withdrawalCredentials := BLSWithdrawalCredentials(v)
+		if opts.WithdrawalAddress != nil {
+			withdrawalCredentials = ExecutionWithdrawalCredentials(*opts.WithdrawalAddress)
+		}
+		depositData, err := BuildDepositDataFromClientViewContext(ctx, consensusClient, v, withdrawalCredentials, opts.Amount)
+		if err != nil {
+			return deposits, errors.Wrapf(err, "failed to build deposit data for validator %d", v.ValidatorIndex)
+		}
+		depositDataRoot, err := depositData.HashTreeRoot()
+		if err != nil {
+			return deposits, errors.Wrap(err, "failed to get deposit data root")
+		}
+		callData, err := contractABI.Pack("deposit", depositData.PublicKey[:], depositData.WithdrawalCredentials, depositData.Signature[:], depositDataRoot)
+		if err != nil {
+			return deposits, errors.Wrap(err, "failed to encode deposit call")
+		}
+		value := new(big.Int).Mul(new(big.Int).SetUint64(uint64(opts.Amount)), big.NewInt(1_000_000_000))
+
+		tx, err := executionClient.SendDynamicFeeTransaction(ctx, premineAccount, execution_client.TransactionOpts{
+			To:    &depositContract,
+			Value: value,
+			Data:  callData,
+		})
+		if err != nil {
+			return deposits, errors.Wrapf(err, "failed to send deposit for validator %d", v.ValidatorIndex)
+		}
+		deposits = append(deposits, &ValidatorDeposit{
+			Validator:   v,
+			DepositData: depositData,
+			Transaction: tx,
+		})
+	}
+
+	for _, deposit := range deposits {
+		receipt, err := executionClient.WaitForReceipt(ctx, deposit.Transaction.Hash())
+		if err != nil {
+			return deposits, err
+		}
+		deposit.Receipt = receipt
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			return deposits, fmt.Errorf("deposit transaction %s for validator %d reverted", deposit.Transaction.Hash().Hex(), deposit.Validator.ValidatorIndex)
+		}
+		c.Validators = append(c.Validators, deposit.Validator)
+	}
+	return deposits, nil
+}
+
+// WaitForValidators polls a consensus client until all the validators show up in its head state or ctx is done.
+// Deposits only get processed after the eth1 follow distance so this can take a while.
+func (c *ClientManager) WaitForValidators(ctx context.Context, validators []*validator.Validator, pollInterval time.Duration) (map[phase0.BLSPubKey]*v1.Validator, error) {
+	pollInterval = c.pollIntervalOrDefault(pollInterval)
+	found := make(map[phase0.BLSPubKey]*v1.Validator)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		consensusClient := c.GetRandomConsensusClient()
+		for _, v := range validators {
+			if _, ok := found[v.ValidatorPublicKey]; ok {
+				continue
+			}
+			clientValidator, err := consensusClient.GetValidatorByPublicKeyContext(ctx, "head", v.ValidatorPublicKey)
+			if err == nil {
+				found[v.ValidatorPublicKey] = clientValidator
+			}
+		}
+		if len(found) == len(validators) {
+			return found, nil
+		}
+		select {
+		case <-ctx.Done():
+			return found, errors.Wrapf(ctx.Err(), "only %d of %d validators showed up in the beacon state", len(found), len(validators))
+		case <-ticker.C:
+		}
+	}
+}
+
+// getDepositContractAddressContext returns the configured deposit contract, or the one a random client follows
+func (c *ClientManager) getDepositContractAddressContext(ctx context.Context) (common.Address, error) {
+	if c.TestnetConfig.DepositContractAddress != "" {
+		if !common.IsHexAddress(c.TestnetConfig.DepositContractAddress) {
+			return common.Address{}, fmt.Errorf("invalid deposit contract address: %s", c.TestnetConfig.DepositContractAddress)
+		}
+		return common.HexToAddress(c.TestnetConfig.DepositContractAddress), nil
+	}
+	depositContract, err := c.GetRandomConsensusClient().GetDepositContractContext(ctx)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return common.BytesToAddress(depositContract.Address), nil
+}
@@ -0,0 +1,55 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	"testing"
+)
+
+func TestDepositValidators_RejectsGenesisValidators(t *testing.T) {
+	manager := &ClientManager{TestnetConfig: &TestnetConfig{GenesisValidatorCount: GenesisValidatorCount}}
+	_, err := manager.DepositValidators(context.Background(), GenesisValidatorCount-1, GenesisValidatorCount+1, DepositOpts{})
+	require.Error(t, err)
+}
+
+func TestWithdrawalCredentials(t *testing.T) {
+	validators, err := getTestValidators(1)
+	require.NoError(t, err)
+	blsCredentials := BLSWithdrawalCredentials(validators[0])
+	require.Len(t, blsCredentials, 32)
+	require.Equal(t, BLSWithdrawalPrefix, blsCredentials[0])
+
+	address := bellatrix.ExecutionAddress{0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69, 0x69}
+	executionCredentials := ExecutionWithdrawalCredentials(address)
+	require.Len(t, executionCredentials, 32)
+	require.Equal(t, ExecutionWithdrawalPrefix, executionCredentials[0])
+	require.Equal(t, make([]byte, 11), executionCredentials[1:12])
+	require.Equal(t, address[:], executionCredentials[12:])
+}
+
+func TestBuildDepositDataFromClientView_Mock(t *testing.T) {
+	_, client, validators := getMockConsensusClient(t)
+	ctx := context.Background()
+	withdrawalCredentials := BLSWithdrawalCredentials(validators[1])
+
+	depositData, err := BuildDepositDataFromClientViewContext(ctx, client, validators[1], withdrawalCredentials, DefaultDepositAmount)
+	require.NoError(t, err)
+	require.Equal(t, validators[1].ValidatorPublicKey, depositData.PublicKey)
+	require.Equal(t, DefaultDepositAmount, depositData.Amount)
+
+	// deposits are signed over the genesis fork version with an empty genesis validators root
+	depositMessage := &phase0.DepositMessage{PublicKey: depositData.PublicKey, WithdrawalCredentials: withdrawalCredentials, Amount: depositData.Amount}
+	root, err := depositMessage.HashTreeRoot()
+	require.NoError(t, err)
+	signingRoot := common.ComputeSigningRoot(common.Root(root), common.ComputeDomain(common.BLSDomainType{0x03}, mockGenesisForkVersion, common.Root{}))
+	signature, err := e2types.BLSSignatureFromBytes(append([]byte{}, depositData.Signature[:]...))
+	require.NoError(t, err)
+	require.True(t, signature.Verify(signingRoot[:], validators[1].ValidatorKey.PublicKey()))
+
+	_, err = BuildDepositDataFromClientViewContext(ctx, client, validators[1], withdrawalCredentials[1:], DefaultDepositAmount)
+	require.Error(t, err)
+}
@@ -0,0 +1,280 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"encoding/json"
+	"eth-testnet-tool/consensus_client"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DivergenceKind is the part of the chain the clients disagree on
+type DivergenceKind string
+
+const (
+	HeadDivergence      DivergenceKind = "head"
+	JustifiedDivergence DivergenceKind = "justified"
+	FinalizedDivergence DivergenceKind = "finalized"
+)
+
+var divergenceKinds = []DivergenceKind{HeadDivergence, JustifiedDivergence, FinalizedDivergence}
+
+// ClientChainView is the view a single client had of the chain when it was polled
+type ClientChainView struct {
+	Client    string
+	HeadSlot  phase0.Slot
+	HeadRoot  phase0.Root
+	Justified *phase0.Checkpoint
+	Finalized *phase0.Checkpoint
+	// Err is set if the client could not be polled, such views are left out of the comparison and the client is marked unknown
+	Err error
+}
+
+// DivergenceRecord describes a period of time in which the clients disagreed on part of the chain
+type DivergenceRecord struct {
+	Kind DivergenceKind `json:"kind"`
+	// StartSlot the slot the divergence was first observed at
+	StartSlot phase0.Slot `json:"start_slot"`
+	// LastSlot the last slot the divergence was observed at
+	LastSlot phase0.Slot `json:"last_slot"`
+	// Forks maps a head root (or epoch/root for checkpoints) to the names of the clients that see it
+	Forks map[string][]string `json:"forks"`
+	// Unknown the clients that could not be polled at LastSlot, they are kept on the fork they were last seen on
+	Unknown  []string `json:"unknown,omitempty"`
+	Resolved bool     `json:"resolved"`
+
+	reported bool
+}
+
+// Slots returns for how many slots the divergence has been observed
+func (r *DivergenceRecord) Slots() uint64 {
+	return uint64(r.LastSlot - r.StartSlot)
+}
+
+// DivergenceMonitorOpts configures a DivergenceMonitor
+type DivergenceMonitorOpts struct {
+	// PollInterval how often all clients are polled, defaults to the slot duration
+	PollInterval time.Duration
+	// ToleranceSlots a divergence is reported once it lasted more than this many slots
+	ToleranceSlots uint64
+	// OnDivergence is called once when a divergence outlasts the tolerance
+	OnDivergence func(record DivergenceRecord)
+	// OnResolved is called when a reported divergence is resolved
+	OnResolved func(record DivergenceRecord)
+	// Output if set, reported and resolved divergences are written to it as json lines
+	Output io.Writer
+}
+
+// DivergenceMonitor compares the head, justified and finalized checkpoints of all the consensus clients
+type DivergenceMonitor struct {
+	manager *ClientManager
+	opts    DivergenceMonitorOpts
+
+	mu      sync.Mutex
+	active  map[DivergenceKind]*DivergenceRecord
+	records []*DivergenceRecord
+}
+
+type divergenceLogJSON struct {
+	Event string `json:"event"`
+	*DivergenceRecord
+}
+
+// NewDivergenceMonitor creates a monitor over all the consensus clients of the manager
+func (c *ClientManager) NewDivergenceMonitor(opts DivergenceMonitorOpts) *DivergenceMonitor {
+	opts.PollInterval = c.pollIntervalOrDefault(opts.PollInterval)
+	return &DivergenceMonitor{
+		manager: c,
+		opts:    opts,
+		active:  make(map[DivergenceKind]*DivergenceRecord),
+	}
+}
+
+// Run polls every client each PollInterval and compares their views until ctx is done
+func (m *DivergenceMonitor) Run(ctx context.Context) error {
+	if m.manager.SlotDuration <= 0 || m.manager.GenesisTime.IsZero() {
+		return errors.New("the slot duration and genesis time of the testnet are required to run the divergence monitor")
+	}
+	ticker := time.NewTicker(m.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		views := m.PollContext(ctx)
+		if err := m.Observe(m.manager.GetCurrentSlot(), views); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll fetches the view of the chain from every consensus client concurrently
+func (m *DivergenceMonitor) Poll() []*ClientChainView {
+	return m.PollContext(context.Background())
+}
+
+// PollContext fetches the view of the chain from every consensus client concurrently, the requests are cancelled with ctx
+func (m *DivergenceMonitor) PollContext(ctx context.Context) []*ClientChainView {
+	views := make([]*ClientChainView, 0, len(m.manager.ConsensusClients))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, client := range m.manager.ConsensusClients {
+		wg.Add(1)
+		go func(client *consensus_client.ConsensusClient) {
+			defer wg.Done()
+			view := pollClientChainView(ctx, client)
+			mu.Lock()
+			views = append(views, view)
+			mu.Unlock()
+		}(client)
+	}
+	wg.Wait()
+	sort.Slice(views, func(i, j int) bool { return views[i].Client < views[j].Client })
+	return views
+}
+
+func pollClientChainView(ctx context.Context, client *consensus_client.ConsensusClient) *ClientChainView {
+	view := &ClientChainView{Client: client.Name}
+	header, err := client.GetBlockHeaderContext(ctx, "head")
+	if err != nil {
+		view.Err = err
+		return view
+	}
+	finality, err := client.GetFinalityContext(ctx, "head")
+	if err != nil {
+		view.Err = err
+		return view
+	}
+	view.HeadSlot = header.Header.Message.Slot
+	view.HeadRoot = header.Root
+	view.Justified = finality.Justified
+	view.Finalized = finality.Finalized
+	return view
+}
+
+// Observe compares the views taken at the given slot and updates the tracked divergences
+func (m *DivergenceMonitor) Observe(slot phase0.Slot, views []*ClientChainView) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, kind := range divergenceKinds {
+		forks, unknown := groupChainViews(kind, views)
+		record, isActive := m.active[kind]
+		if isActive {
+			// a client dropping out doesn't resolve the fork it was on
+			keepUnknownClients(record.Forks, forks, unknown)
+		}
+
+		if len(forks) <= 1 {
+			if isActive {
+				delete(m.active, kind)
+				if record.reported {
+					record.Resolved = true
+					record.LastSlot = slot
+					record.Unknown = unknown
+					if err := m.emit("resolved", record, m.opts.OnResolved); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+
+		if !isActive {
+			record = &DivergenceRecord{Kind: kind, StartSlot: slot}
+			m.active[kind] = record
+		}
+		record.LastSlot = slot
+		record.Forks = forks
+		record.Unknown = unknown
+		if !record.reported && record.Slots() > m.opts.ToleranceSlots {
+			record.reported = true
+			m.records = append(m.records, record)
+			if err := m.emit("divergence", record, m.opts.OnDivergence); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Records returns all reported divergences, resolved or not
+func (m *DivergenceMonitor) Records() []DivergenceRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	records := make([]DivergenceRecord, 0, len(m.records))
+	for _, record := range m.records {
+		records = append(records, *record)
+	}
+	return records
+}
+
+// emit passes a copy of the record to the callback and writes it to the output
+func (m *DivergenceMonitor) emit(event string, record *DivergenceRecord, callback func(DivergenceRecord)) error {
+	if m.opts.Output != nil {
+		data, err := json.Marshal(divergenceLogJSON{Event: event, DivergenceRecord: record})
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal divergence record")
+		}
+		if _, err := m.opts.Output.Write(append(data, '\n')); err != nil {
+			return errors.Wrap(err, "failed to write divergence record")
+		}
+	}
+	if callback != nil {
+		callback(*record)
+	}
+	return nil
+}
+
+// groupChainViews groups the clients by what they see for the given kind, clients that could not be polled are returned as unknown
+func groupChainViews(kind DivergenceKind, views []*ClientChainView) (map[string][]string, []string) {
+	forks := make(map[string][]string)
+	var unknown []string
+	for _, view := range views {
+		if view.Err != nil {
+			unknown = append(unknown, view.Client)
+			continue
+		}
+		var key string
+		switch kind {
+		case HeadDivergence:
+			key = view.HeadRoot.String()
+		case JustifiedDivergence:
+			key = checkpointKey(view.Justified)
+		case FinalizedDivergence:
+			key = checkpointKey(view.Finalized)
+		}
+		forks[key] = append(forks[key], view.Client)
+	}
+	return forks, unknown
+}
+
+// keepUnknownClients adds the unknown clients back to the fork they were on in the previous observation
+func keepUnknownClients(previous map[string][]string, forks map[string][]string, unknown []string) {
+	for key, clients := range previous {
+		for _, client := range clients {
+			for _, name := range unknown {
+				if client == name {
+					forks[key] = append(forks[key], client)
+				}
+			}
+		}
+	}
+	for key := range forks {
+		sort.Strings(forks[key])
+	}
+}
+
+func checkpointKey(checkpoint *phase0.Checkpoint) string {
+	if checkpoint == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%d/%s", checkpoint.Epoch, checkpoint.Root.String())
+}
@@ -0,0 +1,101 @@
+package eth_testnet_tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func testChainView(client string, head byte, finalizedEpoch phase0.Epoch) *ClientChainView {
+	return &ClientChainView{
+		Client:    client,
+		HeadRoot:  phase0.Root{head},
+		Justified: &phase0.Checkpoint{Epoch: finalizedEpoch + 1, Root: phase0.Root{head}},
+		Finalized: &phase0.Checkpoint{Epoch: finalizedEpoch, Root: phase0.Root{0x01}},
+	}
+}
+
+func TestDivergenceMonitor_Observe(t *testing.T) {
+	var diverged, resolved []DivergenceRecord
+	var output bytes.Buffer
+	monitor := (&ClientManager{}).NewDivergenceMonitor(DivergenceMonitorOpts{
+		ToleranceSlots: 2,
+		OnDivergence:   func(record DivergenceRecord) { diverged = append(diverged, record) },
+		OnResolved:     func(record DivergenceRecord) { resolved = append(resolved, record) },
+		Output:         &output,
+	})
+
+	agreeing := []*ClientChainView{
+		testChainView("lighthouse", 0xaa, 1),
+		testChainView("prysm", 0xaa, 1),
+		testChainView("teku", 0xaa, 1),
+	}
+	require.NoError(t, monitor.Observe(10, agreeing))
+	require.Empty(t, monitor.Records())
+
+	// teku goes off on its own fork, the head and justified checkpoint diverge but finality doesn't
+	forked := []*ClientChainView{
+		testChainView("lighthouse", 0xaa, 1),
+		testChainView("prysm", 0xaa, 1),
+		testChainView("teku", 0xbb, 1),
+	}
+	for slot := phase0.Slot(11); slot <= 13; slot++ {
+		require.NoError(t, monitor.Observe(slot, forked))
+	}
+	require.Empty(t, diverged, "divergence within the tolerance should not be reported")
+
+	require.NoError(t, monitor.Observe(14, forked))
+	require.Len(t, diverged, 2)
+	require.Equal(t, HeadDivergence, diverged[0].Kind)
+	require.Equal(t, JustifiedDivergence, diverged[1].Kind)
+	require.Equal(t, phase0.Slot(11), diverged[0].StartSlot)
+	require.Equal(t, uint64(3), diverged[0].Slots())
+	require.Equal(t, []string{"lighthouse", "prysm"}, diverged[0].Forks[phase0.Root{0xaa}.String()])
+	require.Equal(t, []string{"teku"}, diverged[0].Forks[phase0.Root{0xbb}.String()])
+
+	// an unreachable client doesn't resolve the fork it was on
+	unreachable := testChainView("teku", 0, 0)
+	unreachable.Err = errors.New("connection refused")
+	require.NoError(t, monitor.Observe(15, []*ClientChainView{agreeing[0], agreeing[1], unreachable}))
+	require.Empty(t, resolved)
+	records := monitor.Records()
+	require.Len(t, records, 2)
+	require.False(t, records[0].Resolved)
+	require.Equal(t, []string{"teku"}, records[0].Unknown)
+	require.Equal(t, []string{"teku"}, records[0].Forks[phase0.Root{0xbb}.String()])
+
+	require.NoError(t, monitor.Observe(16, agreeing))
+	require.Len(t, resolved, 2)
+	require.True(t, resolved[0].Resolved)
+	require.Empty(t, resolved[0].Unknown)
+	require.Equal(t, phase0.Slot(16), resolved[0].LastSlot)
+
+	records = monitor.Records()
+	require.Len(t, records, 2)
+	require.True(t, records[0].Resolved)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 4)
+	var logged map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &logged))
+	require.Equal(t, "divergence", logged["event"])
+	require.Equal(t, "head", logged["kind"])
+}
+
+func TestDivergenceMonitor_ShortDivergenceNotReported(t *testing.T) {
+	monitor := (&ClientManager{}).NewDivergenceMonitor(DivergenceMonitorOpts{ToleranceSlots: 1})
+	require.NoError(t, monitor.Observe(1, []*ClientChainView{testChainView("lighthouse", 0xaa, 1), testChainView("prysm", 0xbb, 1)}))
+	require.NoError(t, monitor.Observe(2, []*ClientChainView{testChainView("lighthouse", 0xbb, 1), testChainView("prysm", 0xbb, 1)}))
+	require.NoError(t, monitor.Observe(3, []*ClientChainView{testChainView("lighthouse", 0xcc, 1), testChainView("prysm", 0xbb, 1)}))
+	require.Empty(t, monitor.Records())
+}
+
+func TestDivergenceMonitor_RunRequiresSpec(t *testing.T) {
+	monitor := (&ClientManager{}).NewDivergenceMonitor(DivergenceMonitorOpts{})
+	require.Error(t, monitor.Run(context.Background()))
+}
@@ -0,0 +1,224 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"eth-testnet-tool/consensus_client"
+	"eth-testnet-tool/consensus_client/consensus_objects"
+	"eth-testnet-tool/execution_account"
+	"eth-testnet-tool/execution_client"
+	"eth-testnet-tool/validator"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"github.com/prysmaticlabs/go-bitfield"
+	"math/big"
+)
+
+// BuildElectraAttestationFromClientViewContext builds the attestation of BuildAttestationFromClientView in its EIP-7549 form:
+// the committee index is moved from the data into the committee bits, the data is signed with index 0
+func BuildElectraAttestationFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, v *validator.Validator, epoch phase0.Epoch) (*consensus_objects.ElectraAttestation, error) {
+	duty, position, index, err := attestationDutyContext(ctx, consensusClient, v, epoch)
+	if err != nil {
+		return nil, err
+	}
+	data, err := attestationDataContext(ctx, consensusClient, duty, epoch)
+	if err != nil {
+		return nil, err
+	}
+	if duty.Index >= 64 {
+		return nil, fmt.Errorf("committee index %d doesn't fit the committee bits", duty.Index)
+	}
+	data.Index = 0
+	indexedAttestation, err := SignIndexedAttestationWithValidatorsContext(ctx, consensusClient, []*validator.Validator{v}, []phase0.ValidatorIndex{index}, data)
+	if err != nil {
+		return nil, err
+	}
+	committeeBits := bitfield.NewBitvector64()
+	committeeBits.SetBitAt(uint64(duty.Index), true)
+	aggregationBits := bitfield.NewBitlist(uint64(len(duty.Validators)))
+	aggregationBits.SetBitAt(uint64(position), true)
+	return &consensus_objects.ElectraAttestation{
+		AggregationBits: aggregationBits,
+		Data:            data,
+		Signature:       indexedAttestation.Signature,
+		CommitteeBits:   committeeBits,
+	}, nil
+}
+
+// BuildWithdrawalRequestFromClientViewContext builds the EIP-7002 request the withdrawal address of the validator has to send to withdraw the amount,
+// an amount of 0 exits the validator. The validator needs 0x01 or 0x02 withdrawal credentials in the view of the client.
+func BuildWithdrawalRequestFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, v *validator.Validator, amount phase0.Gwei) (*consensus_objects.ExecutionLayerWithdrawalRequest, error) {
+	address, _, err := withdrawalAddressContext(ctx, consensusClient, v)
+	if err != nil {
+		return nil, err
+	}
+	return &consensus_objects.ExecutionLayerWithdrawalRequest{
+		SourceAddress:   address,
+		ValidatorPubkey: v.ValidatorPublicKey,
+		Amount:          amount,
+	}, nil
+}
+
+// BuildConsolidationRequestFromClientViewContext builds the EIP-7251 request the withdrawal address of the source validator has to send to consolidate it into the target.
+// The target needs 0x02 withdrawal credentials, unless source and target are the same validator which switches it to 0x02 credentials.
+func BuildConsolidationRequestFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, source *validator.Validator, target *validator.Validator) (*consensus_objects.ConsolidationRequest, error) {
+	address, _, err := withdrawalAddressContext(ctx, consensusClient, source)
+	if err != nil {
+		return nil, err
+	}
+	if source.ValidatorPublicKey != target.ValidatorPublicKey {
+		_, targetCredentials, err := withdrawalAddressContext(ctx, consensusClient, target)
+		if err != nil {
+			return nil, err
+		}
+		if targetCredentials[0] != CompoundingWithdrawalPrefix {
+			return nil, fmt.Errorf("target validator %d has %#x withdrawal credentials, consolidations need 0x02 credentials", target.ValidatorIndex, targetCredentials[0])
+		}
+	}
+	return &consensus_objects.ConsolidationRequest{
+		SourceAddress: address,
+		SourcePubkey:  source.ValidatorPublicKey,
+		TargetPubkey:  target.ValidatorPublicKey,
+	}, nil
+}
+
+// BuildDepositRequestFromClientViewContext builds the EIP-6110 deposit request the deposit of the validator turns into, index is the index of the deposit in the deposit contract
+func BuildDepositRequestFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, v *validator.Validator, withdrawalCredentials []byte, amount phase0.Gwei, index uint64) (*consensus_objects.DepositRequest, error) {
+	depositData, err := BuildDepositDataFromClientViewContext(ctx, consensusClient, v, withdrawalCredentials, amount)
+	if err != nil {
+		return nil, err
+	}
+	return &consensus_objects.DepositRequest{
+		Pubkey:                depositData.PublicKey,
+		WithdrawalCredentials: depositData.WithdrawalCredentials,
+		Amount:                depositData.Amount,
+		Signature:             depositData.Signature,
+		Index:                 index,
+	}, nil
+}
+
+// withdrawalAddressContext returns the execution address and the withdrawal credentials of the validator in the view of the client,
+// the validator needs 0x01 or 0x02 withdrawal credentials
+func withdrawalAddressContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, v *validator.Validator) (bellatrix.ExecutionAddress, []byte, error) {
+	clientValidatorView, err := consensusClient.GetValidatorByPublicKeyContext(ctx, "head", v.ValidatorPublicKey)
+	if err != nil {
+		return bellatrix.ExecutionAddress{}, nil, errors.Wrap(err, fmt.Sprintf("couldn't get withdrawal credentials from client %s", consensusClient.Name))
+	}
+	credentials := clientValidatorView.Validator.WithdrawalCredentials
+	if len(credentials) != 32 || (credentials[0] != ExecutionWithdrawalPrefix && credentials[0] != CompoundingWithdrawalPrefix) {
+		return bellatrix.ExecutionAddress{}, nil, fmt.Errorf("validator %d has no execution withdrawal credentials: %#x", clientValidatorView.Index, credentials)
+	}
+	var address bellatrix.ExecutionAddress
+	copy(address[:], credentials[12:])
+	return address, credentials, nil
+}
+
+// ExecutionRequestOpts configures how execution layer requests are sent
+type ExecutionRequestOpts struct {
+	// PreminePath the premine account sending the request, defaults to the premine account that is the withdrawal address of the validator
+	PreminePath string
+	// Fee the value sent with the request, defaults to the current fee of the request contract
+	Fee *big.Int
+}
+
+// ExecutionLayerRequest is a request sent to one of the request contracts, only the field of the request type is set
+type ExecutionLayerRequest struct {
+	WithdrawalRequest    *consensus_objects.ExecutionLayerWithdrawalRequest
+	ConsolidationRequest *consensus_objects.ConsolidationRequest
+	Transaction          *types.Transaction
+	Receipt              *types.Receipt
+}
+
+// TriggerExecutionLayerExitContext exits the validator through the EIP-7002 contract, its withdrawal address has to be one of the premine accounts
+func (c *ClientManager) TriggerExecutionLayerExitContext(ctx context.Context, v *validator.Validator, opts ExecutionRequestOpts) (*ExecutionLayerRequest, error) {
+	return c.SendWithdrawalRequestContext(ctx, v, 0, opts)
+}
+
+// SendWithdrawalRequestContext withdraws the amount from the validator through the EIP-7002 contract, an amount of 0 exits the validator.
+// The request is sent from the premine account that is the withdrawal address of the validator.
+func (c *ClientManager) SendWithdrawalRequestContext(ctx context.Context, v *validator.Validator, amount phase0.Gwei, opts ExecutionRequestOpts) (*ExecutionLayerRequest, error) {
+	request, err := BuildWithdrawalRequestFromClientViewContext(ctx, c.GetRandomConsensusClient(), v, amount)
+	if err != nil {
+		return nil, err
+	}
+	account, err := c.requestAccount(request.SourceAddress, opts.PreminePath)
+	if err != nil {
+		return nil, err
+	}
+	callData := execution_client.WithdrawalRequestCallData(request.ValidatorPubkey, uint64(request.Amount))
+	tx, receipt, err := c.sendSystemContractRequest(ctx, account, execution_client.WithdrawalRequestContractAddress, callData, opts.Fee)
+	if err != nil {
+		return nil, errors.Wrapf(err, "withdrawal request for validator %d failed", v.ValidatorIndex)
+	}
+	return &ExecutionLayerRequest{WithdrawalRequest: request, Transaction: tx, Receipt: receipt}, nil
+}
+
+// SendConsolidationRequestContext consolidates the source validator into the target through the EIP-7251 contract.
+// The request is sent from the premine account that is the withdrawal address of the source validator.
+func (c *ClientManager) SendConsolidationRequestContext(ctx context.Context, source *validator.Validator, target *validator.Validator, opts ExecutionRequestOpts) (*ExecutionLayerRequest, error) {
+	request, err := BuildConsolidationRequestFromClientViewContext(ctx, c.GetRandomConsensusClient(), source, target)
+	if err != nil {
+		return nil, err
+	}
+	account, err := c.requestAccount(request.SourceAddress, opts.PreminePath)
+	if err != nil {
+		return nil, err
+	}
+	callData := execution_client.ConsolidationRequestCallData(request.SourcePubkey, request.TargetPubkey)
+	tx, receipt, err := c.sendSystemContractRequest(ctx, account, execution_client.ConsolidationRequestContractAddress, callData, opts.Fee)
+	if err != nil {
+		return nil, errors.Wrapf(err, "consolidation request of validator %d into %d failed", source.ValidatorIndex, target.ValidatorIndex)
+	}
+	return &ExecutionLayerRequest{ConsolidationRequest: request, Transaction: tx, Receipt: receipt}, nil
+}
+
+// requestAccount returns the premine account a request from the source address has to be sent from,
+// the contracts take the sender as source address so any other account makes a request the beacon chain ignores
+func (c *ClientManager) requestAccount(sourceAddress bellatrix.ExecutionAddress, preminePath string) (*execution_account.ExecutionAccount, error) {
+	if preminePath != "" {
+		account, err := c.GetExecutionAccount(preminePath)
+		if err != nil {
+			return nil, err
+		}
+		if account.Address() != common.Address(sourceAddress) {
+			return nil, fmt.Errorf("premine account %s isn't the withdrawal address %#x", account.Address().Hex(), sourceAddress)
+		}
+		return account, nil
+	}
+	for _, account := range c.ExecutionAccounts {
+		if account.Address() == common.Address(sourceAddress) {
+			return account, nil
+		}
+	}
+	return nil, fmt.Errorf("withdrawal address %#x isn't a premine account", sourceAddress)
+}
+
+// sendSystemContractRequest sends the request to the contract with the fee, or the current fee of the contract, and waits for it to be included
+func (c *ClientManager) sendSystemContractRequest(ctx context.Context, account *execution_account.ExecutionAccount, contract common.Address, callData []byte, fee *big.Int) (*types.Transaction, *types.Receipt, error) {
+	executionClient := c.GetRandomExecutionClient()
+	if fee == nil {
+		var err error
+		if fee, err = executionClient.SystemContractFee(ctx, contract); err != nil {
+			return nil, nil, err
+		}
+	}
+	tx, err := executionClient.SendDynamicFeeTransaction(ctx, account, execution_client.TransactionOpts{
+		To:    &contract,
+		Value: fee,
+		Data:  callData,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	receipt, err := executionClient.WaitForReceipt(ctx, tx.Hash())
+	if err != nil {
+		return tx, nil, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return tx, receipt, fmt.Errorf("request transaction %s reverted", tx.Hash().Hex())
+	}
+	return tx, receipt, nil
+}
@@ -0,0 +1,142 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"eth-testnet-tool/consensus_client"
+	"eth-testnet-tool/execution_account"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	"testing"
+)
+
+// setMockWithdrawalCredentials changes the withdrawal credentials of the validators in the state of the node
+func setMockWithdrawalCredentials(node *consensus_client.MockBeaconNode, credentials map[phase0.ValidatorIndex][]byte) {
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		for index, withdrawalCredentials := range credentials {
+			state.Validators[index].Validator.WithdrawalCredentials = withdrawalCredentials
+		}
+	})
+}
+
+func TestBuildElectraAttestationFromClientView_Mock(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	require.NoError(t, node.AddBlock(consensus_client.NewMockCapellaBlock(40, 1, phase0.Root{})))
+	ctx := context.Background()
+
+	attestation, err := BuildAttestationFromClientViewContext(ctx, client, validators[5], 5)
+	require.NoError(t, err)
+	electraAttestation, err := BuildElectraAttestationFromClientViewContext(ctx, client, validators[5], 5)
+	require.NoError(t, err)
+
+	require.Equal(t, phase0.CommitteeIndex(0), electraAttestation.Data.Index)
+	require.Equal(t, []int{int(attestation.Data.Index)}, electraAttestation.CommitteeBits.BitIndices())
+	require.Equal(t, attestation.AggregationBits, electraAttestation.AggregationBits)
+	require.Equal(t, attestation.Data.Slot, electraAttestation.Data.Slot)
+	require.Equal(t, attestation.Data.Target, electraAttestation.Data.Target)
+	root, err := electraAttestation.Data.HashTreeRoot()
+	require.NoError(t, err)
+	requireValidMockSignature(t, common.BLSDomainType{0x01}, mockCapellaForkVersion, root, electraAttestation.Signature, validators[5].ValidatorKey.PublicKey())
+}
+
+func TestBuildWithdrawalRequestFromClientView_Mock(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	address := bellatrix.ExecutionAddress{0x69}
+	setMockWithdrawalCredentials(node, map[phase0.ValidatorIndex][]byte{
+		3: ExecutionWithdrawalCredentials(address),
+		4: CompoundingWithdrawalCredentials(address),
+	})
+	ctx := context.Background()
+
+	request, err := BuildWithdrawalRequestFromClientViewContext(ctx, client, validators[3], 0)
+	require.NoError(t, err)
+	require.Equal(t, address, request.SourceAddress)
+	require.Equal(t, validators[3].ValidatorPublicKey, request.ValidatorPubkey)
+	require.Equal(t, phase0.Gwei(0), request.Amount)
+
+	request, err = BuildWithdrawalRequestFromClientViewContext(ctx, client, validators[4], 1_000_000_000)
+	require.NoError(t, err)
+	require.Equal(t, address, request.SourceAddress)
+	require.Equal(t, phase0.Gwei(1_000_000_000), request.Amount)
+
+	// 0x00 credentials have no withdrawal address to send the request from
+	_, err = BuildWithdrawalRequestFromClientViewContext(ctx, client, validators[5], 0)
+	require.Error(t, err)
+}
+
+func TestBuildConsolidationRequestFromClientView_Mock(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	address := bellatrix.ExecutionAddress{0x69}
+	setMockWithdrawalCredentials(node, map[phase0.ValidatorIndex][]byte{
+		3: ExecutionWithdrawalCredentials(address),
+		4: CompoundingWithdrawalCredentials(bellatrix.ExecutionAddress{0x42}),
+		5: ExecutionWithdrawalCredentials(bellatrix.ExecutionAddress{0x42}),
+	})
+	ctx := context.Background()
+
+	request, err := BuildConsolidationRequestFromClientViewContext(ctx, client, validators[3], validators[4])
+	require.NoError(t, err)
+	require.Equal(t, address, request.SourceAddress)
+	require.Equal(t, validators[3].ValidatorPublicKey, request.SourcePubkey)
+	require.Equal(t, validators[4].ValidatorPublicKey, request.TargetPubkey)
+
+	// a consolidation into itself switches the validator to 0x02 credentials
+	request, err = BuildConsolidationRequestFromClientViewContext(ctx, client, validators[3], validators[3])
+	require.NoError(t, err)
+	require.Equal(t, request.SourcePubkey, request.TargetPubkey)
+
+	_, err = BuildConsolidationRequestFromClientViewContext(ctx, client, validators[3], validators[5])
+	require.Error(t, err)
+	_, err = BuildConsolidationRequestFromClientViewContext(ctx, client, validators[6], validators[4])
+	require.Error(t, err)
+}
+
+func TestBuildDepositRequestFromClientView_Mock(t *testing.T) {
+	_, client, validators := getMockConsensusClient(t)
+	withdrawalCredentials := CompoundingWithdrawalCredentials(bellatrix.ExecutionAddress{0x69})
+	request, err := BuildDepositRequestFromClientViewContext(context.Background(), client, validators[1], withdrawalCredentials, DefaultDepositAmount, 7)
+	require.NoError(t, err)
+	require.Equal(t, validators[1].ValidatorPublicKey, request.Pubkey)
+	require.Equal(t, withdrawalCredentials, request.WithdrawalCredentials)
+	require.Equal(t, uint64(7), request.Index)
+
+	depositMessage := &phase0.DepositMessage{PublicKey: request.Pubkey, WithdrawalCredentials: withdrawalCredentials, Amount: request.Amount}
+	root, err := depositMessage.HashTreeRoot()
+	require.NoError(t, err)
+	signingRoot := common.ComputeSigningRoot(common.Root(root), common.ComputeDomain(common.BLSDomainType{0x03}, mockGenesisForkVersion, common.Root{}))
+	signature, err := e2types.BLSSignatureFromBytes(append([]byte{}, request.Signature[:]...))
+	require.NoError(t, err)
+	require.True(t, signature.Verify(signingRoot[:], validators[1].ValidatorKey.PublicKey()))
+}
+
+func TestSendWithdrawalRequest_PremineAccount(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	premineAccounts, err := execution_account.GetPremineAccounts("test test test test test test test test test test test junk", map[string]uint64{
+		"m/44'/60'/0'/0/0": 1000,
+		"m/44'/60'/0'/0/1": 1000,
+	})
+	require.NoError(t, err)
+	manager := &ClientManager{
+		ConsensusClients:  map[string]*consensus_client.ConsensusClient{"mock": client},
+		ExecutionAccounts: premineAccounts,
+	}
+	premineAddress := bellatrix.ExecutionAddress(premineAccounts[1].Address())
+	setMockWithdrawalCredentials(node, map[phase0.ValidatorIndex][]byte{
+		3: ExecutionWithdrawalCredentials(premineAddress),
+		4: ExecutionWithdrawalCredentials(bellatrix.ExecutionAddress{0x69}),
+	})
+
+	account, err := manager.requestAccount(premineAddress, "")
+	require.NoError(t, err)
+	require.Equal(t, premineAccounts[1], account)
+	_, err = manager.requestAccount(premineAddress, premineAccounts[0].Path)
+	require.Error(t, err)
+
+	// the contracts take the sender as source address, the request is refused before anything is sent
+	_, err = manager.TriggerExecutionLayerExitContext(context.Background(), validators[4], ExecutionRequestOpts{})
+	require.ErrorContains(t, err, "isn't a premine account")
+	_, err = manager.SendConsolidationRequestContext(context.Background(), validators[4], validators[4], ExecutionRequestOpts{})
+	require.ErrorContains(t, err, "isn't a premine account")
+}
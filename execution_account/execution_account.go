@@ -0,0 +1,155 @@
+package execution_account
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"eth-testnet-tool/execution_client"
+	"eth-testnet-tool/validator"
+	"fmt"
+	"github.com/attestantio/go-execution-client/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"github.com/tyler-smith/go-bip32"
+	"math/big"
+	"sort"
+)
+
+var _ execution_client.Signer = (*ExecutionAccount)(nil)
+
+type ExecutionAccount struct {
+	// Path the BIP-44 path the key was derived at, e.g. m/44'/60'/0'/0/0
+	Path       string
+	PrivateKey *ecdsa.PrivateKey
+	// ExecutionAddress the address belonging to the private key
+	ExecutionAddress common.Address
+}
+
+func (a *ExecutionAccount) String() string {
+	return fmt.Sprintf("address: %s (%s)", a.ExecutionAddress.Hex(), a.Path)
+}
+
+// Address returns the address of the account
+func (a *ExecutionAccount) Address() common.Address {
+	return a.ExecutionAddress
+}
+
+// SignTx signs the transaction with the latest signer for the chain
+func (a *ExecutionAccount) SignTx(tx *ethtypes.Transaction, chainID *big.Int) (*ethtypes.Transaction, error) {
+	return ethtypes.SignTx(tx, ethtypes.LatestSignerForChainID(chainID), a.PrivateKey)
+}
+
+// TransactOpts returns transact opts for use with go-ethereum contract bindings
+func (a *ExecutionAccount) TransactOpts(chainID *big.Int) (*bind.TransactOpts, error) {
+	return bind.NewKeyedTransactorWithChainID(a.PrivateKey, chainID)
+}
+
+// GetExecutionAccountFromMnemonic derives the secp256k1 account at the BIP-44 path
+func GetExecutionAccountFromMnemonic(mnemonic string, path string) (*ExecutionAccount, error) {
+	seed, err := validator.MnemonicToSeed(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	return accountFromSeed(seed, path)
+}
+
+// GetExecutionAccountsFromMnemonic derives the secp256k1 accounts at each of the BIP-44 paths
+func GetExecutionAccountsFromMnemonic(mnemonic string, paths []string) ([]*ExecutionAccount, error) {
+	var executionAccounts []*ExecutionAccount
+
+	seed, err := validator.MnemonicToSeed(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		account, err := accountFromSeed(seed, path)
+		if err != nil {
+			return nil, err
+		}
+		executionAccounts = append(executionAccounts, account)
+	}
+	return executionAccounts, nil
+}
+
+// GetPremineAccounts derives the accounts for every premine path, sorted by path
+func GetPremineAccounts(mnemonic string, premines map[string]uint64) ([]*ExecutionAccount, error) {
+	paths := make([]string, 0, len(premines))
+	for path := range premines {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return GetExecutionAccountsFromMnemonic(mnemonic, paths)
+}
+
+func accountFromSeed(seed []byte, path string) (*ExecutionAccount, error) {
+	derivationPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid derivation path: %s", path)
+	}
+	key, err := bip32.NewMasterKey(seed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create master key")
+	}
+	for _, index := range derivationPath {
+		key, err = key.NewChildKey(index)
+		if err != nil {
+			return nil, fmt.Errorf("account %s cannot be derived", path)
+		}
+	}
+	privateKey, err := crypto.ToECDSA(key.Key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "account %s is not a valid secp256k1 key", path)
+	}
+	return &ExecutionAccount{
+		Path:             path,
+		PrivateKey:       privateKey,
+		ExecutionAddress: crypto.PubkeyToAddress(privateKey.PublicKey),
+	}, nil
+}
+
+// PremineBalance compares the configured premine of an account with its balance on chain
+type PremineBalance struct {
+	Account *ExecutionAccount
+	// Expected the configured premine in wei
+	Expected *big.Int
+	// Genesis the balance of the account in the genesis block
+	Genesis *big.Int
+	// Current the balance of the account at the head of the chain
+	Current *big.Int
+}
+
+// Matches returns true if the genesis balance is the configured premine
+func (b *PremineBalance) Matches() bool {
+	return b.Expected.Cmp(b.Genesis) == 0
+}
+
+// CheckPremineBalances fetches the genesis and current balance of every premine account from the execution client.
+// Premines are configured in ETH.
+func CheckPremineBalances(ctx context.Context, executionClient *execution_client.ExecutionClient, premineAccounts []*ExecutionAccount, premines map[string]uint64) ([]*PremineBalance, error) {
+	var balances []*PremineBalance
+	for _, account := range premineAccounts {
+		premine, ok := premines[account.Path]
+		if !ok {
+			return nil, fmt.Errorf("no premine configured for %s", account.Path)
+		}
+		address := types.Address(account.ExecutionAddress)
+		genesisBalance, err := executionClient.RPCService.Balance(ctx, address, "0")
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get genesis balance of %s from %s", account.ExecutionAddress.Hex(), executionClient.Name)
+		}
+		currentBalance, err := executionClient.RPCService.Balance(ctx, address, "latest")
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get balance of %s from %s", account.ExecutionAddress.Hex(), executionClient.Name)
+		}
+		balances = append(balances, &PremineBalance{
+			Account:  account,
+			Expected: new(big.Int).Mul(new(big.Int).SetUint64(premine), big.NewInt(1e18)),
+			Genesis:  genesisBalance,
+			Current:  currentBalance,
+		})
+	}
+	return balances, nil
+}
@@ -0,0 +1,81 @@
+package execution_account
+
+import (
+	"context"
+	"encoding/json"
+	"eth-testnet-tool/execution_client"
+	"fmt"
+	"github.com/attestantio/go-execution-client/jsonrpc"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var (
+	// the well known hardhat/anvil development mnemonic
+	ExecutionAccountMnemonic = "test test test test test test test test test test test junk"
+	Premines                 = map[string]uint64{
+		"m/44'/60'/0'/0/1": 1000,
+		"m/44'/60'/0'/0/0": 1000,
+	}
+)
+
+func TestExecutionAccount_GetPremineAccounts(t *testing.T) {
+	premineAccounts, err := GetPremineAccounts(ExecutionAccountMnemonic, Premines)
+	require.NoError(t, err)
+	require.Len(t, premineAccounts, 2)
+	require.Equal(t, "m/44'/60'/0'/0/0", premineAccounts[0].Path)
+	require.Equal(t, "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", premineAccounts[0].Address().Hex())
+	require.Equal(t, "0x70997970C51812dc3A010C7d01b50e0d17dc79C8", premineAccounts[1].Address().Hex())
+
+	_, err = GetExecutionAccountFromMnemonic(ExecutionAccountMnemonic, "not/a/path")
+	require.Error(t, err)
+}
+
+func TestExecutionAccount_SignTx(t *testing.T) {
+	account, err := GetExecutionAccountFromMnemonic(ExecutionAccountMnemonic, "m/44'/60'/0'/0/0")
+	require.NoError(t, err)
+	var signer execution_client.Signer = account
+	opts, err := account.TransactOpts(big.NewInt(1337))
+	require.NoError(t, err)
+	require.Equal(t, signer.Address(), opts.From)
+}
+
+func TestCheckPremineBalances(t *testing.T) {
+	premineAccounts, err := GetPremineAccounts(ExecutionAccountMnemonic, Premines)
+	require.NoError(t, err)
+	premineWei := new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))
+	// account 0 has spent some of its premine, account 1 was never funded
+	balances := map[string]map[string]*big.Int{
+		strings.ToLower(premineAccounts[0].Address().Hex()): {"0x0": premineWei, "latest": big.NewInt(1)},
+		strings.ToLower(premineAccounts[1].Address().Hex()): {"0x0": big.NewInt(0), "latest": big.NewInt(0)},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     interface{}   `json:"id"`
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Method != "eth_getBalance" {
+			_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%v,"error":{"code":-32601,"message":"not supported"}}`, req.ID)
+			return
+		}
+		balance := balances[req.Params[0].(string)][req.Params[1].(string)]
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%v,"result":"0x%x"}`, req.ID, balance)
+	}))
+	defer server.Close()
+	service, err := jsonrpc.New(context.Background(), jsonrpc.WithAddress(server.URL))
+	require.NoError(t, err)
+	executionClient := &execution_client.ExecutionClient{Name: "testClient", JsonRPC: server.URL, RPCService: service.(*jsonrpc.Service)}
+
+	premineBalances, err := CheckPremineBalances(context.Background(), executionClient, premineAccounts, Premines)
+	require.NoError(t, err)
+	require.Len(t, premineBalances, 2)
+	require.True(t, premineBalances[0].Matches())
+	require.Equal(t, big.NewInt(1), premineBalances[0].Current)
+	require.False(t, premineBalances[1].Matches())
+}
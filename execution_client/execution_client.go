@@ -3,12 +3,21 @@ package execution_client
 import (
 	"fmt"
 	ethclient "github.com/attestantio/go-execution-client/jsonrpc"
+	"github.com/ethereum/go-ethereum/common"
+	gethclient "github.com/ethereum/go-ethereum/ethclient"
+	"sync"
 )
 
 type ExecutionClient struct {
 	Name       string
 	JsonRPC    string
 	RPCService *ethclient.Service
+	// EthClient is used for the calls the RPCService doesn't cover, like sending transactions
+	EthClient *gethclient.Client
+
+	// nonces the next nonce for accounts that sent transactions through this client
+	nonceLock sync.Mutex
+	nonces    map[common.Address]uint64
 }
 
 func (e *ExecutionClient) String() string {
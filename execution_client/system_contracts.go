@@ -0,0 +1,42 @@
+package execution_client
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"math/big"
+)
+
+// The predeployed request contracts of EIP-7002 and EIP-7251, devnets with other deployments can change them
+var (
+	WithdrawalRequestContractAddress    = common.HexToAddress("0x00000961Ef480Eb55e80D19ad83579A64c007002")
+	ConsolidationRequestContractAddress = common.HexToAddress("0x0000BBdDc7CE488642fb579F8B00f3a590007251")
+)
+
+// SystemContractFee returns the fee a request to one of the request contracts currently has to pay, calling them without input returns it
+func (e *ExecutionClient) SystemContractFee(ctx context.Context, contract common.Address) (*big.Int, error) {
+	result, err := e.EthClient.CallContract(ctx, ethereum.CallMsg{To: &contract}, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the fee of contract %s from client: %s", contract.Hex(), e.Name)
+	}
+	if len(result) != 32 {
+		return nil, fmt.Errorf("contract %s returned a %d byte fee", contract.Hex(), len(result))
+	}
+	return new(big.Int).SetBytes(result), nil
+}
+
+// WithdrawalRequestCallData returns the input of an EIP-7002 request: the validator pubkey followed by the big endian amount in gwei
+func WithdrawalRequestCallData(validatorPubkey [48]byte, amount uint64) []byte {
+	data := make([]byte, 56)
+	copy(data, validatorPubkey[:])
+	binary.BigEndian.PutUint64(data[48:], amount)
+	return data
+}
+
+// ConsolidationRequestCallData returns the input of an EIP-7251 request: the source pubkey followed by the target pubkey
+func ConsolidationRequestCallData(sourcePubkey [48]byte, targetPubkey [48]byte) []byte {
+	return append(append(make([]byte, 0, 96), sourcePubkey[:]...), targetPubkey[:]...)
+}
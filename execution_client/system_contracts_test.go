@@ -0,0 +1,36 @@
+package execution_client
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestSystemContractFee(t *testing.T) {
+	node := &testExecutionNode{callResult: common.LeftPadBytes([]byte{0x02}, 32)}
+	executionClient := newTestExecutionClient(t, node)
+	fee, err := executionClient.SystemContractFee(context.Background(), WithdrawalRequestContractAddress)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(2), fee)
+
+	node.callResult = []byte{0x02}
+	_, err = executionClient.SystemContractFee(context.Background(), WithdrawalRequestContractAddress)
+	require.Error(t, err)
+}
+
+func TestRequestCallData(t *testing.T) {
+	pubkey1 := [48]byte{0x01, 47: 0x11}
+	pubkey2 := [48]byte{0x02, 47: 0x22}
+
+	withdrawal := WithdrawalRequestCallData(pubkey1, 0x0102)
+	require.Len(t, withdrawal, 56)
+	require.Equal(t, pubkey1[:], withdrawal[:48])
+	require.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0x01, 0x02}, withdrawal[48:])
+
+	consolidation := ConsolidationRequestCallData(pubkey1, pubkey2)
+	require.Len(t, consolidation, 96)
+	require.Equal(t, pubkey1[:], consolidation[:48])
+	require.Equal(t, pubkey2[:], consolidation[48:])
+}
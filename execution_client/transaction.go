@@ -0,0 +1,352 @@
+package execution_client
+
+import (
+	"context"
+	"crypto/sha256"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/holiman/uint256"
+	"github.com/pkg/errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ReceiptPollInterval how often WaitForReceipt checks for the receipt
+var ReceiptPollInterval = time.Second
+
+// Errors returned when a client rejects a transaction, check for them with errors.Is
+var (
+	ErrReplacementUnderpriced = errors.New("replacement transaction underpriced")
+	ErrNonceTooLow            = errors.New("nonce too low")
+	ErrInsufficientFunds      = errors.New("insufficient funds")
+)
+
+// sendErrorMessages the (normalised) messages the different execution clients use for each of the typed errors
+var sendErrorMessages = map[error][]string{
+	ErrReplacementUnderpriced: {"replacement transaction underpriced", "replacement underpriced", "replacement not allowed", "already known with higher fee"},
+	ErrNonceTooLow:            {"nonce too low", "old nonce", "oldnonce"},
+	ErrInsufficientFunds:      {"insufficient funds", "insufficientfunds", "upfront cost exceeds"},
+}
+
+// Signer signs transactions for an execution layer account, execution_account.ExecutionAccount implements it
+type Signer interface {
+	Address() common.Address
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// TransactionOpts the fields of a transaction, everything left nil or zero is filled in from the client
+type TransactionOpts struct {
+	To    *common.Address
+	Value *big.Int
+	Data  []byte
+	// Nonce if nil the next nonce of the sender is used
+	Nonce *uint64
+	// Gas if 0 the gas is estimated
+	Gas uint64
+	// GasPrice used for legacy transactions
+	GasPrice *big.Int
+	// GasTipCap and GasFeeCap used for dynamic fee and blob transactions
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+	// BlobFeeCap and Blobs used for blob transactions
+	BlobFeeCap *big.Int
+	Blobs      []kzg4844.Blob
+}
+
+// NextNonce returns the next nonce to use for the account, which is the clients pending nonce unless we already used it
+func (e *ExecutionClient) NextNonce(ctx context.Context, from common.Address) (uint64, error) {
+	e.nonceLock.Lock()
+	defer e.nonceLock.Unlock()
+	pending, err := e.EthClient.PendingNonceAt(ctx, from)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get nonce for %s", from.Hex())
+	}
+	nonce := pending
+	if next, ok := e.nonces[from]; ok && next > pending {
+		nonce = next
+	}
+	if e.nonces == nil {
+		e.nonces = make(map[common.Address]uint64)
+	}
+	e.nonces[from] = nonce + 1
+	return nonce, nil
+}
+
+// ResetNonce forgets the nonces handed out for the account so the next transaction uses the clients pending nonce
+func (e *ExecutionClient) ResetNonce(from common.Address) {
+	e.nonceLock.Lock()
+	defer e.nonceLock.Unlock()
+	delete(e.nonces, from)
+}
+
+// BuildLegacyTransaction builds an unsigned legacy transaction from the account
+func (e *ExecutionClient) BuildLegacyTransaction(ctx context.Context, from common.Address, opts TransactionOpts) (*types.Transaction, error) {
+	var err error
+	gasPrice := opts.GasPrice
+	if gasPrice == nil {
+		if gasPrice, err = e.EthClient.SuggestGasPrice(ctx); err != nil {
+			return nil, errors.Wrapf(err, "failed to get gas price from client: %s", e.Name)
+		}
+	}
+	gas, err := e.gasOrEstimate(ctx, from, opts, ethereum.CallMsg{GasPrice: gasPrice})
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := e.nonceOrNext(ctx, from, opts.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: gasPrice,
+		Gas:      gas,
+		To:       opts.To,
+		Value:    opts.Value,
+		Data:     opts.Data,
+	}), nil
+}
+
+// BuildDynamicFeeTransaction builds an unsigned EIP-1559 transaction from the account
+func (e *ExecutionClient) BuildDynamicFeeTransaction(ctx context.Context, from common.Address, opts TransactionOpts) (*types.Transaction, error) {
+	chainID, err := e.EthClient.ChainID(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get chain id from client: %s", e.Name)
+	}
+	tip, feeCap, err := e.dynamicFees(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	gas, err := e.gasOrEstimate(ctx, from, opts, ethereum.CallMsg{GasTipCap: tip, GasFeeCap: feeCap})
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := e.nonceOrNext(ctx, from, opts.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        opts.To,
+		Value:     opts.Value,
+		Data:      opts.Data,
+	}), nil
+}
+
+// BuildBlobTransaction builds an unsigned EIP-4844 transaction carrying the blobs, including their commitments and proofs
+func (e *ExecutionClient) BuildBlobTransaction(ctx context.Context, from common.Address, opts TransactionOpts) (*types.Transaction, error) {
+	if opts.To == nil {
+		return nil, errors.New("blob transactions can not create contracts")
+	}
+	if len(opts.Blobs) == 0 {
+		return nil, errors.New("blob transactions need at least one blob")
+	}
+	chainID, err := e.EthClient.ChainID(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get chain id from client: %s", e.Name)
+	}
+	tip, feeCap, err := e.dynamicFees(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	blobFeeCap := opts.BlobFeeCap
+	if blobFeeCap == nil {
+		head, err := e.EthClient.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get head for blob base fee")
+		}
+		if head.ExcessBlobGas == nil {
+			return nil, errors.New("the chain has not activated cancun, blob transactions are not supported")
+		}
+		blobFeeCap = new(big.Int).Mul(eip4844.CalcBlobFee(*head.ExcessBlobGas), big.NewInt(2))
+	}
+	sidecar, err := BuildBlobSidecar(opts.Blobs)
+	if err != nil {
+		return nil, err
+	}
+	gas, err := e.gasOrEstimate(ctx, from, opts, ethereum.CallMsg{GasTipCap: tip, GasFeeCap: feeCap})
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := e.nonceOrNext(ctx, from, opts.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewTx(&types.BlobTx{
+		ChainID:    uint256.MustFromBig(chainID),
+		Nonce:      nonce,
+		GasTipCap:  uint256.MustFromBig(tip),
+		GasFeeCap:  uint256.MustFromBig(feeCap),
+		Gas:        gas,
+		To:         *opts.To,
+		Value:      uint256.MustFromBig(valueOrZero(opts.Value)),
+		Data:       opts.Data,
+		BlobFeeCap: uint256.MustFromBig(blobFeeCap),
+		BlobHashes: sidecar.BlobHashes(),
+		Sidecar:    sidecar,
+	}), nil
+}
+
+// BuildBlobSidecar computes the kzg commitments and proofs for the blobs
+func BuildBlobSidecar(blobs []kzg4844.Blob) (*types.BlobTxSidecar, error) {
+	sidecar := &types.BlobTxSidecar{}
+	for i, blob := range blobs {
+		commitment, err := kzg4844.BlobToCommitment(blob)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compute commitment for blob %d", i)
+		}
+		proof, err := kzg4844.ComputeBlobProof(blob, commitment)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compute proof for blob %d", i)
+		}
+		sidecar.Blobs = append(sidecar.Blobs, blob)
+		sidecar.Commitments = append(sidecar.Commitments, commitment)
+		sidecar.Proofs = append(sidecar.Proofs, proof)
+	}
+	return sidecar, nil
+}
+
+// BlobVersionedHash returns the versioned hash of a blob commitment as used in the transaction and beacon block
+func BlobVersionedHash(commitment kzg4844.Commitment) common.Hash {
+	return kzg4844.CalcBlobHashV1(sha256.New(), &commitment)
+}
+
+// SignAndSendTransaction signs the transaction with the signer and sends it to the client.
+// Rejections are returned as ErrReplacementUnderpriced, ErrNonceTooLow or ErrInsufficientFunds where possible.
+func (e *ExecutionClient) SignAndSendTransaction(ctx context.Context, signer Signer, tx *types.Transaction) (*types.Transaction, error) {
+	chainID, err := e.EthClient.ChainID(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get chain id from client: %s", e.Name)
+	}
+	signedTx, err := signer.SignTx(tx, chainID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign transaction")
+	}
+	if err := e.EthClient.SendTransaction(ctx, signedTx); err != nil {
+		// the rejected transaction didn't take its nonce, or our view of the nonce is stale, so go back to the clients pending nonce
+		e.ResetNonce(signer.Address())
+		return nil, errors.Wrapf(classifySendError(err), "client %s rejected transaction %s", e.Name, signedTx.Hash().Hex())
+	}
+	return signedTx, nil
+}
+
+// SendLegacyTransaction builds, signs and sends a legacy transaction
+func (e *ExecutionClient) SendLegacyTransaction(ctx context.Context, signer Signer, opts TransactionOpts) (*types.Transaction, error) {
+	tx, err := e.BuildLegacyTransaction(ctx, signer.Address(), opts)
+	if err != nil {
+		return nil, err
+	}
+	return e.SignAndSendTransaction(ctx, signer, tx)
+}
+
+// SendDynamicFeeTransaction builds, signs and sends an EIP-1559 transaction
+func (e *ExecutionClient) SendDynamicFeeTransaction(ctx context.Context, signer Signer, opts TransactionOpts) (*types.Transaction, error) {
+	tx, err := e.BuildDynamicFeeTransaction(ctx, signer.Address(), opts)
+	if err != nil {
+		return nil, err
+	}
+	return e.SignAndSendTransaction(ctx, signer, tx)
+}
+
+// SendBlobTransaction builds, signs and sends an EIP-4844 blob transaction
+func (e *ExecutionClient) SendBlobTransaction(ctx context.Context, signer Signer, opts TransactionOpts) (*types.Transaction, error) {
+	tx, err := e.BuildBlobTransaction(ctx, signer.Address(), opts)
+	if err != nil {
+		return nil, err
+	}
+	return e.SignAndSendTransaction(ctx, signer, tx)
+}
+
+// WaitForReceipt polls the client until the transaction is included or ctx is done
+func (e *ExecutionClient) WaitForReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	ticker := time.NewTicker(ReceiptPollInterval)
+	defer ticker.Stop()
+	for {
+		receipt, err := e.EthClient.TransactionReceipt(ctx, txHash)
+		if err == nil {
+			return receipt, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, errors.Wrapf(err, "failed to get receipt for %s", txHash.Hex())
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrapf(ctx.Err(), "gave up waiting for receipt for %s", txHash.Hex())
+		case <-ticker.C:
+		}
+	}
+}
+
+// nonceOrNext returns the nonce if set, otherwise it takes the next nonce of the account.
+// The builders call it after everything else that can fail so a failed build doesn't leave a gap in the nonces.
+func (e *ExecutionClient) nonceOrNext(ctx context.Context, from common.Address, nonce *uint64) (uint64, error) {
+	if nonce != nil {
+		return *nonce, nil
+	}
+	return e.NextNonce(ctx, from)
+}
+
+// dynamicFees returns the tip and fee cap from the opts, or the suggested tip and a fee cap of twice the base fee on top
+func (e *ExecutionClient) dynamicFees(ctx context.Context, opts TransactionOpts) (*big.Int, *big.Int, error) {
+	tip := opts.GasTipCap
+	if tip == nil {
+		var err error
+		if tip, err = e.EthClient.SuggestGasTipCap(ctx); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to get gas tip cap from client: %s", e.Name)
+		}
+	}
+	feeCap := opts.GasFeeCap
+	if feeCap == nil {
+		head, err := e.EthClient.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to get head for base fee")
+		}
+		if head.BaseFee == nil {
+			return nil, nil, errors.New("the chain has not activated london, use a legacy transaction")
+		}
+		feeCap = new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	}
+	return tip, feeCap, nil
+}
+
+func (e *ExecutionClient) gasOrEstimate(ctx context.Context, from common.Address, opts TransactionOpts, msg ethereum.CallMsg) (uint64, error) {
+	if opts.Gas != 0 {
+		return opts.Gas, nil
+	}
+	msg.From = from
+	msg.To = opts.To
+	msg.Value = opts.Value
+	msg.Data = opts.Data
+	gas, err := e.EthClient.EstimateGas(ctx, msg)
+	if err != nil {
+		return 0, errors.Wrapf(classifySendError(err), "failed to estimate gas with client: %s", e.Name)
+	}
+	return gas, nil
+}
+
+// classifySendError maps the error message of the client to one of the typed errors
+func classifySendError(err error) error {
+	message := strings.ToLower(strings.ReplaceAll(err.Error(), "_", " "))
+	for typedErr, messages := range sendErrorMessages {
+		for _, m := range messages {
+			if strings.Contains(message, m) {
+				return errors.Wrap(typedErr, err.Error())
+			}
+		}
+	}
+	return err
+}
+
+func valueOrZero(value *big.Int) *big.Int {
+	if value == nil {
+		return new(big.Int)
+	}
+	return value
+}
@@ -0,0 +1,205 @@
+package execution_client
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	gethclient "github.com/ethereum/go-ethereum/ethclient"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testSigner signs with a fixed key
+type testSigner struct{}
+
+var testKey, _ = crypto.HexToECDSA("ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80")
+
+func (testSigner) Address() common.Address {
+	return crypto.PubkeyToAddress(testKey.PublicKey)
+}
+
+func (testSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), testKey)
+}
+
+// testExecutionNode answers the json rpc calls the transaction builder makes
+type testExecutionNode struct {
+	pendingNonce uint64
+	sendError    string
+	// estimateError makes eth_estimateGas fail with the message
+	estimateError string
+	sent          []*types.Transaction
+	// callResult what eth_call returns
+	callResult hexutil.Bytes
+}
+
+func (n *testExecutionNode) handle(method string, params []json.RawMessage) (interface{}, string) {
+	switch method {
+	case "eth_chainId":
+		return hexutil.Uint64(1337), ""
+	case "eth_getTransactionCount":
+		return hexutil.Uint64(n.pendingNonce), ""
+	case "eth_gasPrice":
+		return (*hexutil.Big)(big.NewInt(7)), ""
+	case "eth_maxPriorityFeePerGas":
+		return (*hexutil.Big)(big.NewInt(1)), ""
+	case "eth_call":
+		return n.callResult, ""
+	case "eth_estimateGas":
+		if n.estimateError != "" {
+			return nil, n.estimateError
+		}
+		return hexutil.Uint64(21000), ""
+	case "eth_getBlockByNumber":
+		excessBlobGas := uint64(0)
+		return &types.Header{
+			Number:        big.NewInt(1),
+			Difficulty:    big.NewInt(0),
+			BaseFee:       big.NewInt(10),
+			ExcessBlobGas: &excessBlobGas,
+		}, ""
+	case "eth_sendRawTransaction":
+		if n.sendError != "" {
+			return nil, n.sendError
+		}
+		var raw hexutil.Bytes
+		if err := json.Unmarshal(params[0], &raw); err != nil {
+			return nil, err.Error()
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(raw); err != nil {
+			return nil, err.Error()
+		}
+		n.sent = append(n.sent, tx)
+		return tx.Hash(), ""
+	}
+	return nil, "method not supported"
+}
+
+func newTestExecutionClient(t *testing.T, node *testExecutionNode) *ExecutionClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		response := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		result, errMessage := node.handle(req.Method, req.Params)
+		if errMessage != "" {
+			response["error"] = map[string]interface{}{"code": -32000, "message": errMessage}
+		} else {
+			response["result"] = result
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+	t.Cleanup(server.Close)
+	ethClient, err := gethclient.Dial(server.URL)
+	require.NoError(t, err)
+	return &ExecutionClient{Name: "testClient", JsonRPC: server.URL, EthClient: ethClient}
+}
+
+func TestSendTransactions(t *testing.T) {
+	node := &testExecutionNode{pendingNonce: 5}
+	executionClient := newTestExecutionClient(t, node)
+	to := common.HexToAddress("0x6969696969696969696969696969696969696969")
+	ctx := context.Background()
+
+	legacyTx, err := executionClient.SendLegacyTransaction(ctx, testSigner{}, TransactionOpts{To: &to, Value: big.NewInt(1)})
+	require.NoError(t, err)
+	require.Equal(t, uint8(types.LegacyTxType), legacyTx.Type())
+	require.Equal(t, uint64(5), legacyTx.Nonce())
+	require.Equal(t, big.NewInt(7), legacyTx.GasPrice())
+	require.Equal(t, uint64(21000), legacyTx.Gas())
+
+	// the client hasn't seen the first transaction yet, the next nonce still has to follow it
+	dynamicFeeTx, err := executionClient.SendDynamicFeeTransaction(ctx, testSigner{}, TransactionOpts{To: &to, Value: big.NewInt(1)})
+	require.NoError(t, err)
+	require.Equal(t, uint8(types.DynamicFeeTxType), dynamicFeeTx.Type())
+	require.Equal(t, uint64(6), dynamicFeeTx.Nonce())
+	require.Equal(t, big.NewInt(1), dynamicFeeTx.GasTipCap())
+	require.Equal(t, big.NewInt(21), dynamicFeeTx.GasFeeCap())
+
+	require.Len(t, node.sent, 2)
+	sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1337)), node.sent[1])
+	require.NoError(t, err)
+	require.Equal(t, testSigner{}.Address(), sender)
+}
+
+func TestBuildBlobTransaction(t *testing.T) {
+	executionClient := newTestExecutionClient(t, &testExecutionNode{})
+	to := common.HexToAddress("0x6969696969696969696969696969696969696969")
+
+	_, err := executionClient.BuildBlobTransaction(context.Background(), testSigner{}.Address(), TransactionOpts{To: &to})
+	require.Error(t, err)
+
+	blobTx, err := executionClient.BuildBlobTransaction(context.Background(), testSigner{}.Address(), TransactionOpts{To: &to, Blobs: []kzg4844.Blob{{0x01}}})
+	require.NoError(t, err)
+	require.Equal(t, uint8(types.BlobTxType), blobTx.Type())
+	require.Len(t, blobTx.BlobHashes(), 1)
+	require.Equal(t, BlobVersionedHash(blobTx.BlobTxSidecar().Commitments[0]), blobTx.BlobHashes()[0])
+	require.Equal(t, big.NewInt(2), blobTx.BlobGasFeeCap())
+}
+
+func TestSendTransaction_TypedErrors(t *testing.T) {
+	node := &testExecutionNode{pendingNonce: 3}
+	executionClient := newTestExecutionClient(t, node)
+	to := common.HexToAddress("0x6969696969696969696969696969696969696969")
+	ctx := context.Background()
+
+	for message, expected := range map[string]error{
+		"replacement transaction underpriced":                 ErrReplacementUnderpriced,
+		"insufficient funds for gas * price + value":          ErrInsufficientFunds,
+		"TRANSACTION_REPLACEMENT_UNDERPRICED":                 ErrReplacementUnderpriced,
+		"Upfront cost exceeds account balance":                ErrInsufficientFunds,
+		"nonce too low: address 0xf39F, tx: 3 state: 4":       ErrNonceTooLow,
+		"OldNonce, Current nonce: 4, nonce of rejected tx: 3": ErrNonceTooLow,
+	} {
+		node.sendError = message
+		_, err := executionClient.SendLegacyTransaction(ctx, testSigner{}, TransactionOpts{To: &to, Gas: 21000})
+		require.True(t, errors.Is(err, expected), "%s: %v", message, err)
+		node.sendError = ""
+	}
+
+	// after nonce too low the local nonce is dropped and the clients pending nonce is used again
+	node.sendError = "nonce too low"
+	_, err := executionClient.SendLegacyTransaction(ctx, testSigner{}, TransactionOpts{To: &to, Gas: 21000})
+	require.True(t, errors.Is(err, ErrNonceTooLow))
+	node.sendError = ""
+	tx, err := executionClient.SendLegacyTransaction(ctx, testSigner{}, TransactionOpts{To: &to, Gas: 21000})
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), tx.Nonce())
+}
+
+func TestBuildTransaction_FailureDoesNotTakeNonce(t *testing.T) {
+	node := &testExecutionNode{pendingNonce: 5}
+	executionClient := newTestExecutionClient(t, node)
+	to := common.HexToAddress("0x6969696969696969696969696969696969696969")
+	ctx := context.Background()
+
+	tx, err := executionClient.SendDynamicFeeTransaction(ctx, testSigner{}, TransactionOpts{To: &to})
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), tx.Nonce())
+
+	node.estimateError = "execution reverted"
+	_, err = executionClient.BuildLegacyTransaction(ctx, testSigner{}.Address(), TransactionOpts{To: &to})
+	require.Error(t, err)
+	_, err = executionClient.BuildDynamicFeeTransaction(ctx, testSigner{}.Address(), TransactionOpts{To: &to})
+	require.Error(t, err)
+	_, err = executionClient.BuildBlobTransaction(ctx, testSigner{}.Address(), TransactionOpts{To: &to, Blobs: []kzg4844.Blob{{0x01}}})
+	require.Error(t, err)
+	node.estimateError = ""
+
+	// the failed builds didn't take any nonces
+	tx, err = executionClient.SendDynamicFeeTransaction(ctx, testSigner{}, TransactionOpts{To: &to})
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), tx.Nonce())
+}
@@ -0,0 +1,262 @@
+package gossip
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"eth-testnet-tool/consensus_client"
+	"eth-testnet-tool/consensus_client/consensus_objects"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+)
+
+// Gossip topic names, the attestation and blob sidecar topics are suffixed with their subnet
+const (
+	TopicBeaconBlock          = "beacon_block"
+	TopicAggregateAndProof    = "beacon_aggregate_and_proof"
+	TopicAttestation          = "beacon_attestation"
+	TopicVoluntaryExit        = "voluntary_exit"
+	TopicProposerSlashing     = "proposer_slashing"
+	TopicAttesterSlashing     = "attester_slashing"
+	TopicBLSToExecutionChange = "bls_to_execution_change"
+	TopicBlobSidecar          = "blob_sidecar"
+)
+
+const (
+	AttestationSubnetCount = 64
+	BlobSidecarSubnetCount = 6
+	// MaxGossipSize the limit of an uncompressed gossip message
+	MaxGossipSize = 10 * 1 << 20
+
+	messageDomainValidSnappy   = 0x01000000
+	messageDomainInvalidSnappy = 0x00000000
+)
+
+// SSZMarshaler an object that can be published
+type SSZMarshaler interface {
+	MarshalSSZ() ([]byte, error)
+}
+
+// SSZUnmarshaler an object a published message can be decoded into
+type SSZUnmarshaler interface {
+	UnmarshalSSZ(buf []byte) error
+}
+
+// Transport publishes raw messages on gossipsub topics, Host implements it with libp2p and MockGossipPeer records the messages in tests
+type Transport interface {
+	Publish(ctx context.Context, topic string, data []byte) error
+}
+
+// ForkDigest returns the fork digest of the fork version, the first 4 bytes of the root of its fork data
+func ForkDigest(forkVersion phase0.Version, genesisValidatorsRoot phase0.Root) (phase0.ForkDigest, error) {
+	forkData := &phase0.ForkData{CurrentVersion: forkVersion, GenesisValidatorsRoot: genesisValidatorsRoot}
+	root, err := forkData.HashTreeRoot()
+	if err != nil {
+		return phase0.ForkDigest{}, errors.Wrap(err, "failed to hash fork data")
+	}
+	var digest phase0.ForkDigest
+	copy(digest[:], root[:4])
+	return digest, nil
+}
+
+// Topic returns the full gossipsub topic of the topic name for the fork digest
+func Topic(forkDigest phase0.ForkDigest, name string) string {
+	return fmt.Sprintf("/eth2/%x/%s/ssz_snappy", forkDigest, name)
+}
+
+// AttestationSubnet returns the subnet an attestation of the committee is published on
+func AttestationSubnet(committeesPerSlot uint64, slotsPerEpoch uint64, slot phase0.Slot, committeeIndex phase0.CommitteeIndex) uint64 {
+	committeesSinceEpochStart := committeesPerSlot * (uint64(slot) % slotsPerEpoch)
+	return (committeesSinceEpochStart + uint64(committeeIndex)) % AttestationSubnetCount
+}
+
+// Encode returns the SSZ of the object compressed with snappy's block format, the encoding of gossip messages
+func Encode(object SSZMarshaler) ([]byte, error) {
+	data, err := object.MarshalSSZ()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal ssz")
+	}
+	if len(data) > MaxGossipSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds the gossip limit", len(data))
+	}
+	return snappy.Encode(nil, data), nil
+}
+
+// Decode decodes a gossip message into the object
+func Decode(data []byte, object SSZUnmarshaler) error {
+	length, err := snappy.DecodedLen(data)
+	if err != nil {
+		return errors.Wrap(err, "invalid snappy message")
+	}
+	if length > MaxGossipSize {
+		return fmt.Errorf("message of %d bytes exceeds the gossip limit", length)
+	}
+	decoded, err := snappy.Decode(nil, data)
+	if err != nil {
+		return errors.Wrap(err, "invalid snappy message")
+	}
+	return object.UnmarshalSSZ(decoded)
+}
+
+// MessageID returns the altair message id of a message on the topic, gossipsub uses it to drop duplicates
+func MessageID(topic string, data []byte) [20]byte {
+	domain := uint32(messageDomainValidSnappy)
+	message, err := snappy.Decode(nil, data)
+	if err != nil {
+		domain, message = messageDomainInvalidSnappy, data
+	}
+	preimage := make([]byte, 12, 12+len(topic)+len(message))
+	binary.BigEndian.PutUint32(preimage[0:4], domain)
+	binary.LittleEndian.PutUint64(preimage[4:12], uint64(len(topic)))
+	preimage = append(append(preimage, topic...), message...)
+	hash := sha256.Sum256(preimage)
+	var id [20]byte
+	copy(id[:], hash[:20])
+	return id
+}
+
+// Publisher publishes consensus objects on the topics of a fork, straight to the gossip network so no beacon api validation runs first
+type Publisher struct {
+	Transport  Transport
+	ForkDigest phase0.ForkDigest
+	// Electra the fork digest is of electra or later, blocks and attestations are published in their electra form
+	Electra bool
+}
+
+// NewPublisher returns a publisher for the topics of the fork digest
+func NewPublisher(transport Transport, forkDigest phase0.ForkDigest) *Publisher {
+	return &Publisher{Transport: transport, ForkDigest: forkDigest}
+}
+
+// NewPublisherFromClientViewContext returns a publisher for the topics of the fork the client is at
+func NewPublisherFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient, transport Transport) (*Publisher, error) {
+	forkDigest, electra, err := forkFromClientViewContext(ctx, consensusClient)
+	if err != nil {
+		return nil, err
+	}
+	publisher := NewPublisher(transport, forkDigest)
+	publisher.Electra = electra
+	return publisher, nil
+}
+
+// forkFromClientViewContext returns the fork digest of the fork the client is at and whether that fork is electra or later
+func forkFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient) (phase0.ForkDigest, bool, error) {
+	genesis, err := consensusClient.SpecCache().Genesis(ctx)
+	if err != nil {
+		return phase0.ForkDigest{}, false, err
+	}
+	epoch, err := consensusClient.GetCurrentEpochContext(ctx)
+	if err != nil {
+		return phase0.ForkDigest{}, false, err
+	}
+	forkVersion, err := consensusClient.SpecCache().ForkVersion(ctx, epoch)
+	if err != nil {
+		return phase0.ForkDigest{}, false, err
+	}
+	forkDigest, err := ForkDigest(forkVersion, genesis.GenesisValidatorsRoot)
+	if err != nil {
+		return phase0.ForkDigest{}, false, err
+	}
+	// clients without electra in their spec are before electra
+	electraEpoch, err := consensusClient.SpecCache().Uint64(ctx, "ELECTRA_FORK_EPOCH")
+	electra := err == nil && uint64(epoch) >= electraEpoch
+	return forkDigest, electra, nil
+}
+
+// Publish publishes the object on the topic name
+func (p *Publisher) Publish(ctx context.Context, name string, object SSZMarshaler) error {
+	data, err := Encode(object)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode message for %s", name)
+	}
+	topic := Topic(p.ForkDigest, name)
+	return errors.Wrapf(p.Transport.Publish(ctx, topic, data), "failed to publish on %s", topic)
+}
+
+// PublishBeaconBlock publishes the block, the fork of the block has to be the fork of the publisher.
+// go-eth2-client has no electra blocks yet, electra publishers take them through PublishElectraBeaconBlock.
+func (p *Publisher) PublishBeaconBlock(ctx context.Context, block *spec.VersionedSignedBeaconBlock) error {
+	if p.Electra {
+		return fmt.Errorf("%s block on an electra topic, use PublishElectraBeaconBlock", block.Version)
+	}
+	var object SSZMarshaler
+	switch block.Version {
+	case spec.DataVersionPhase0:
+		object = block.Phase0
+	case spec.DataVersionAltair:
+		object = block.Altair
+	case spec.DataVersionBellatrix:
+		object = block.Bellatrix
+	case spec.DataVersionCapella:
+		object = block.Capella
+	case spec.DataVersionDeneb:
+		object = block.Deneb
+	default:
+		return fmt.Errorf("unsupported block version %s", block.Version)
+	}
+	return p.Publish(ctx, TopicBeaconBlock, object)
+}
+
+// PublishElectraBeaconBlock publishes the SSZ encodable electra signed block, the publisher has to be at electra
+func (p *Publisher) PublishElectraBeaconBlock(ctx context.Context, block SSZMarshaler) error {
+	if !p.Electra {
+		return errors.New("electra block on a topic before electra")
+	}
+	return p.Publish(ctx, TopicBeaconBlock, block)
+}
+
+// PublishAggregateAndProof publishes the aggregate
+func (p *Publisher) PublishAggregateAndProof(ctx context.Context, aggregate *phase0.SignedAggregateAndProof) error {
+	return p.Publish(ctx, TopicAggregateAndProof, aggregate)
+}
+
+// PublishAttestation publishes the attestation on the subnet, see AttestationSubnet.
+// The attestation is a *phase0.Attestation before electra and a *consensus_objects.ElectraAttestation from electra on.
+func (p *Publisher) PublishAttestation(ctx context.Context, subnet uint64, attestation SSZMarshaler) error {
+	if subnet >= AttestationSubnetCount {
+		return fmt.Errorf("attestation subnet %d out of range", subnet)
+	}
+	switch attestation.(type) {
+	case *phase0.Attestation:
+		if p.Electra {
+			return errors.New("phase0 attestation on an electra topic")
+		}
+	case *consensus_objects.ElectraAttestation:
+		if !p.Electra {
+			return errors.New("electra attestation on a topic before electra")
+		}
+	default:
+		return fmt.Errorf("unsupported attestation type %T", attestation)
+	}
+	return p.Publish(ctx, fmt.Sprintf("%s_%d", TopicAttestation, subnet), attestation)
+}
+
+// PublishVoluntaryExit publishes the exit
+func (p *Publisher) PublishVoluntaryExit(ctx context.Context, exit *phase0.SignedVoluntaryExit) error {
+	return p.Publish(ctx, TopicVoluntaryExit, exit)
+}
+
+// PublishProposerSlashing publishes the slashing
+func (p *Publisher) PublishProposerSlashing(ctx context.Context, slashing *phase0.ProposerSlashing) error {
+	return p.Publish(ctx, TopicProposerSlashing, slashing)
+}
+
+// PublishAttesterSlashing publishes the slashing
+func (p *Publisher) PublishAttesterSlashing(ctx context.Context, slashing *phase0.AttesterSlashing) error {
+	return p.Publish(ctx, TopicAttesterSlashing, slashing)
+}
+
+// PublishBLSToExecutionChange publishes the change
+func (p *Publisher) PublishBLSToExecutionChange(ctx context.Context, change *capella.SignedBLSToExecutionChange) error {
+	return p.Publish(ctx, TopicBLSToExecutionChange, change)
+}
+
+// PublishBlobSidecar publishes the sidecar on the subnet of its index
+func (p *Publisher) PublishBlobSidecar(ctx context.Context, sidecar *deneb.BlobSidecar) error {
+	return p.Publish(ctx, fmt.Sprintf("%s_%d", TopicBlobSidecar, uint64(sidecar.Index)%BlobSidecarSubnetCount), sidecar)
+}
@@ -0,0 +1,127 @@
+package gossip
+
+import (
+	"context"
+	"crypto/sha256"
+	"eth-testnet-tool/consensus_client"
+	"eth-testnet-tool/consensus_client/consensus_objects"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/golang/snappy"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestForkDigest(t *testing.T) {
+	forkVersion := phase0.Version{0x03, 0x00, 0x00, 0x01}
+	genesisValidatorsRoot := phase0.Root{0x42}
+	forkDigest, err := ForkDigest(forkVersion, genesisValidatorsRoot)
+	require.NoError(t, err)
+	require.Equal(t, common.ComputeForkDigest(common.Version(forkVersion), common.Root(genesisValidatorsRoot)), common.ForkDigest(forkDigest))
+	require.Equal(t, fmt.Sprintf("/eth2/%x/voluntary_exit/ssz_snappy", forkDigest[:]), Topic(forkDigest, TopicVoluntaryExit))
+}
+
+func TestAttestationSubnet(t *testing.T) {
+	require.Equal(t, uint64(0), AttestationSubnet(4, 32, 0, 0))
+	require.Equal(t, uint64(7), AttestationSubnet(4, 32, 33, 3))
+	require.Equal(t, uint64(63), AttestationSubnet(64, 32, 0, 63))
+	require.Equal(t, uint64(2), AttestationSubnet(2, 32, 33, 0))
+}
+
+func TestEncodeDecode(t *testing.T) {
+	exit := consensus_objects.RandomSignedVoluntaryExit(1)
+	data, err := Encode(exit)
+	require.NoError(t, err)
+	var decoded phase0.SignedVoluntaryExit
+	require.NoError(t, Decode(data, &decoded))
+	require.Equal(t, exit, &decoded)
+
+	require.Error(t, Decode([]byte{0xff, 0xff}, &decoded))
+}
+
+func TestMessageID(t *testing.T) {
+	topic := "/eth2/00000000/voluntary_exit/ssz_snappy"
+	message := []byte("message")
+	id := MessageID(topic, snappy.Encode(nil, message))
+	preimage := append(append([]byte{0x01, 0x00, 0x00, 0x00, byte(len(topic)), 0, 0, 0, 0, 0, 0, 0}, topic...), message...)
+	expected := sha256.Sum256(preimage)
+	require.Equal(t, expected[:20], id[:])
+
+	// the same message on another topic is another message
+	require.NotEqual(t, id, MessageID("/eth2/00000000/proposer_slashing/ssz_snappy", snappy.Encode(nil, message)))
+}
+
+func TestPublisher_MockGossipPeer(t *testing.T) {
+	ctx := context.Background()
+	node := consensus_client.NewMockBeaconNode(consensus_client.NewMockBeaconState(nil))
+	t.Cleanup(node.Close)
+	client, err := node.ConsensusClient("mock")
+	require.NoError(t, err)
+
+	// the mock beacon node is at capella from genesis
+	forkDigest, err := ForkDigest(phase0.Version{0x03, 0x00, 0x00, 0x01}, phase0.Root{0x42})
+	require.NoError(t, err)
+	peer := NewMockGossipPeer(forkDigest)
+	publisher, err := NewPublisherFromClientViewContext(ctx, client, peer)
+	require.NoError(t, err)
+	require.Equal(t, forkDigest, publisher.ForkDigest)
+
+	block := consensus_objects.RandomCapellaSignedBeaconBlock(1)
+	require.NoError(t, publisher.PublishBeaconBlock(ctx, block))
+	require.NoError(t, publisher.PublishAggregateAndProof(ctx, consensus_objects.RandomAggregateAndProof(1)))
+	require.NoError(t, publisher.PublishAttestation(ctx, 5, consensus_objects.RandomAttestation(1)))
+	require.Error(t, publisher.PublishAttestation(ctx, AttestationSubnetCount, consensus_objects.RandomAttestation(1)))
+	exit := consensus_objects.RandomSignedVoluntaryExit(1)
+	require.NoError(t, publisher.PublishVoluntaryExit(ctx, exit))
+	require.NoError(t, publisher.PublishProposerSlashing(ctx, consensus_objects.RandomProposerSlashing(1)))
+	require.NoError(t, publisher.PublishAttesterSlashing(ctx, consensus_objects.RandomAttesterSlashing(1)))
+	require.NoError(t, publisher.PublishBLSToExecutionChange(ctx, consensus_objects.RandomSignedBLSToExecutionChange(1)))
+	sidecar := consensus_objects.RandomBlobSideCar(1)
+	sidecar.Index = 8
+	require.NoError(t, publisher.PublishBlobSidecar(ctx, sidecar))
+
+	for _, name := range []string{TopicBeaconBlock, TopicAggregateAndProof, TopicAttestation + "_5", TopicVoluntaryExit, TopicProposerSlashing, TopicAttesterSlashing, TopicBLSToExecutionChange, TopicBlobSidecar + "_2"} {
+		require.Len(t, peer.Messages(name), 1, name)
+	}
+	var decodedBlock capella.SignedBeaconBlock
+	require.NoError(t, Decode(peer.Messages(TopicBeaconBlock)[0].Data, &decodedBlock))
+	require.Equal(t, block.Capella, &decodedBlock)
+	var decodedSidecar deneb.BlobSidecar
+	require.NoError(t, Decode(peer.Messages(TopicBlobSidecar + "_2")[0].Data, &decodedSidecar))
+	require.Equal(t, sidecar, &decodedSidecar)
+
+	// gossipsub drops duplicates
+	require.NoError(t, publisher.PublishVoluntaryExit(ctx, exit))
+	require.Len(t, peer.Messages(TopicVoluntaryExit), 1)
+
+	// a peer on another fork doesn't accept the topics
+	otherFork := NewPublisher(peer, phase0.ForkDigest{0x01, 0x02, 0x03, 0x04})
+	require.Error(t, otherFork.PublishVoluntaryExit(ctx, exit))
+}
+
+func TestPublisher_Electra(t *testing.T) {
+	ctx := context.Background()
+	forkDigest := phase0.ForkDigest{0x05, 0x00, 0x00, 0x01}
+	peer := NewMockGossipPeer(forkDigest)
+	publisher := NewPublisher(peer, forkDigest)
+	publisher.Electra = true
+
+	attestation := consensus_objects.RandomElectraAttestation(1)
+	require.NoError(t, publisher.PublishAttestation(ctx, 5, attestation))
+	var decoded consensus_objects.ElectraAttestation
+	require.NoError(t, Decode(peer.Messages(TopicAttestation + "_5")[0].Data, &decoded))
+	require.Equal(t, attestation, &decoded)
+	// go-eth2-client has no electra block, any SSZ object stands in for one
+	require.NoError(t, publisher.PublishElectraBeaconBlock(ctx, consensus_objects.RandomElectraAttestation(2)))
+	require.Len(t, peer.Messages(TopicBeaconBlock), 1)
+
+	// the attestation and block formats of other forks are refused
+	require.Error(t, publisher.PublishAttestation(ctx, 5, consensus_objects.RandomAttestation(1)))
+	require.Error(t, publisher.PublishBeaconBlock(ctx, consensus_objects.RandomCapellaSignedBeaconBlock(1)))
+	publisher.Electra = false
+	require.Error(t, publisher.PublishAttestation(ctx, 5, attestation))
+	require.Error(t, publisher.PublishElectraBeaconBlock(ctx, attestation))
+}
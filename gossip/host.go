@@ -0,0 +1,355 @@
+package gossip
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"fmt"
+	gcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/golang/snappy"
+	"github.com/libp2p/go-libp2p"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pubsubpb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/security/noise"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultListenAddr listens on a random TCP port of every interface
+	DefaultListenAddr = "/ip4/0.0.0.0/tcp/0"
+	// RequestTimeout the limit of a req/resp exchange
+	RequestTimeout = 10 * time.Second
+)
+
+// HostOpts configures a Host
+type HostOpts struct {
+	// ListenAddrs the multiaddrs the host listens on, defaults to DefaultListenAddr
+	ListenAddrs []string
+	// PrivateKey the secp256k1 identity of the host, a new one is generated if nil
+	PrivateKey crypto.PrivKey
+	// Status the status the host sends in the handshake and serves to peers, its fork digest is the fork of the host
+	Status *Status
+	// MetaData the metadata the host serves to peers, defaults to no subnets
+	MetaData *MetaData
+}
+
+// Host is a libp2p host with a gossipsub router speaking the beacon chain req/resp protocols, it implements Transport.
+// It only serves status, metadata, ping and goodbye, enough for clients to keep it connected and forward its gossip.
+type Host struct {
+	Host   host.Host
+	PubSub *pubsub.PubSub
+
+	lock     sync.Mutex
+	topics   map[string]*pubsub.Topic
+	status   *Status
+	metaData *MetaData
+}
+
+// NewHost starts a host listening on the addresses of the opts, it runs until Close is called
+func NewHost(ctx context.Context, opts HostOpts) (*Host, error) {
+	if opts.Status == nil {
+		return nil, errors.New("host needs a status")
+	}
+	privateKey := opts.PrivateKey
+	if privateKey == nil {
+		var err error
+		if privateKey, _, err = crypto.GenerateSecp256k1Key(rand.Reader); err != nil {
+			return nil, errors.Wrap(err, "failed to generate host key")
+		}
+	}
+	listenAddrs := opts.ListenAddrs
+	if len(listenAddrs) == 0 {
+		listenAddrs = []string{DefaultListenAddr}
+	}
+	metaData := opts.MetaData
+	if metaData == nil {
+		metaData = &MetaData{}
+	}
+	libp2pHost, err := libp2p.New(
+		libp2p.Identity(privateKey),
+		libp2p.ListenAddrStrings(listenAddrs...),
+		libp2p.Transport(tcp.NewTCPTransport),
+		libp2p.Security(noise.ID, noise.New),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to start libp2p host")
+	}
+	gossipSub, err := pubsub.NewGossipSub(ctx, libp2pHost,
+		pubsub.WithMessageSignaturePolicy(pubsub.StrictNoSign),
+		pubsub.WithNoAuthor(),
+		pubsub.WithMessageIdFn(func(message *pubsubpb.Message) string {
+			id := MessageID(message.GetTopic(), message.Data)
+			return string(id[:])
+		}),
+		pubsub.WithMaxMessageSize(snappy.MaxEncodedLen(MaxGossipSize)),
+	)
+	if err != nil {
+		_ = libp2pHost.Close()
+		return nil, errors.Wrap(err, "failed to start gossipsub")
+	}
+	h := &Host{
+		Host:     libp2pHost,
+		PubSub:   gossipSub,
+		topics:   make(map[string]*pubsub.Topic),
+		status:   opts.Status,
+		metaData: metaData,
+	}
+	libp2pHost.SetStreamHandler(StatusProtocol, h.handleStatus)
+	libp2pHost.SetStreamHandler(MetaDataProtocol, h.handleMetaData)
+	libp2pHost.SetStreamHandler(PingProtocol, h.handlePing)
+	libp2pHost.SetStreamHandler(GoodbyeProtocol, h.handleGoodbye)
+	return h, nil
+}
+
+// Close stops the host and drops its connections
+func (h *Host) Close() error {
+	return h.Host.Close()
+}
+
+// ID returns the peer id of the host
+func (h *Host) ID() peer.ID {
+	return h.Host.ID()
+}
+
+// Addrs returns the multiaddrs the host is reachable at, with its peer id
+func (h *Host) Addrs() []ma.Multiaddr {
+	suffix, err := ma.NewMultiaddr("/p2p/" + h.Host.ID().String())
+	if err != nil {
+		return nil
+	}
+	var addrs []ma.Multiaddr
+	for _, addr := range h.Host.Addrs() {
+		addrs = append(addrs, addr.Encapsulate(suffix))
+	}
+	return addrs
+}
+
+// SetStatus changes the status the host sends and serves, e.g. when the head of the chain moves
+func (h *Host) SetStatus(status *Status) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.status = status
+}
+
+// SetMetaData changes the metadata the host serves
+func (h *Host) SetMetaData(metaData *MetaData) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.metaData = metaData
+}
+
+// Connect dials the peer at the address and exchanges status with it, the address is an enr:, an enode:// or a multiaddr with a /p2p/ peer id
+func (h *Host) Connect(ctx context.Context, address string) (peer.ID, *Status, error) {
+	var addrInfo *peer.AddrInfo
+	var err error
+	if strings.HasPrefix(address, "enr:") || strings.HasPrefix(address, "enode://") {
+		addrInfo, err = addrInfoFromENR(address)
+	} else {
+		addrInfo, err = peer.AddrInfoFromString(address)
+	}
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "invalid peer address %s", address)
+	}
+	if err := h.Host.Connect(ctx, *addrInfo); err != nil {
+		return "", nil, errors.Wrapf(err, "failed to dial %s", address)
+	}
+	status, err := h.Handshake(ctx, addrInfo.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	return addrInfo.ID, status, nil
+}
+
+// addrInfoFromENR returns the TCP address and peer id of the record
+func addrInfoFromENR(record string) (*peer.AddrInfo, error) {
+	node, err := enode.Parse(enode.ValidSchemes, record)
+	if err != nil {
+		return nil, err
+	}
+	if node.IP() == nil || node.TCP() == 0 {
+		return nil, errors.New("record has no TCP address")
+	}
+	publicKey, err := crypto.UnmarshalSecp256k1PublicKey(gcrypto.CompressPubkey(node.Pubkey()))
+	if err != nil {
+		return nil, errors.Wrap(err, "record has no secp256k1 key")
+	}
+	id, err := peer.IDFromPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	ipProtocol := "ip4"
+	if node.IP().To4() == nil {
+		ipProtocol = "ip6"
+	}
+	addr, err := ma.NewMultiaddr(fmt.Sprintf("/%s/%s/tcp/%d", ipProtocol, node.IP(), node.TCP()))
+	if err != nil {
+		return nil, err
+	}
+	return &peer.AddrInfo{ID: id, Addrs: []ma.Multiaddr{addr}}, nil
+}
+
+// Handshake sends the status of the host to the connected peer and returns the status of the peer, a peer on another fork digest is an error
+func (h *Host) Handshake(ctx context.Context, id peer.ID) (*Status, error) {
+	h.lock.Lock()
+	status := h.status
+	h.lock.Unlock()
+	var peerStatus Status
+	if err := h.request(ctx, id, StatusProtocol, status, &peerStatus, statusSize); err != nil {
+		return nil, err
+	}
+	if peerStatus.ForkDigest != status.ForkDigest {
+		return &peerStatus, fmt.Errorf("peer %s is on fork digest %#x, expected %#x", id, peerStatus.ForkDigest, status.ForkDigest)
+	}
+	return &peerStatus, nil
+}
+
+// RequestMetaData returns the metadata of the connected peer
+func (h *Host) RequestMetaData(ctx context.Context, id peer.ID) (*MetaData, error) {
+	var metaData MetaData
+	if err := h.request(ctx, id, MetaDataProtocol, nil, &metaData, metaDataSize); err != nil {
+		return nil, err
+	}
+	return &metaData, nil
+}
+
+// Ping sends the metadata sequence number of the host to the connected peer and returns the sequence number of the peer
+func (h *Host) Ping(ctx context.Context, id peer.ID) (uint64, error) {
+	h.lock.Lock()
+	seqNumber := sszUint64(h.metaData.SeqNumber)
+	h.lock.Unlock()
+	var peerSeqNumber sszUint64
+	if err := h.request(ctx, id, PingProtocol, &seqNumber, &peerSeqNumber, uint64Size); err != nil {
+		return 0, err
+	}
+	return uint64(peerSeqNumber), nil
+}
+
+// request sends the request on a new stream of the protocol and reads the response into the object, a nil request sends no payload
+func (h *Host) request(ctx context.Context, id peer.ID, protocolID protocol.ID, request SSZMarshaler, response SSZUnmarshaler, maxSize uint64) error {
+	stream, err := h.Host.NewStream(ctx, id, protocolID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s stream to %s", protocolID, id)
+	}
+	defer stream.Close()
+	_ = stream.SetDeadline(time.Now().Add(RequestTimeout))
+	if request != nil {
+		if err := writeChunk(stream, request); err != nil {
+			_ = stream.Reset()
+			return errors.Wrapf(err, "failed to send %s request to %s", protocolID, id)
+		}
+	}
+	if err := stream.CloseWrite(); err != nil {
+		_ = stream.Reset()
+		return err
+	}
+	if err := readResponse(bufio.NewReader(stream), response, maxSize); err != nil {
+		_ = stream.Reset()
+		return errors.Wrapf(err, "failed to read %s response from %s", protocolID, id)
+	}
+	return nil
+}
+
+// respond reads the request of the stream into the object, a nil request has no payload, and writes the response returned for it
+func (h *Host) respond(stream network.Stream, request SSZUnmarshaler, maxSize uint64, response func() SSZMarshaler) {
+	defer stream.Close()
+	_ = stream.SetDeadline(time.Now().Add(RequestTimeout))
+	if request != nil {
+		if err := readChunk(bufio.NewReader(stream), request, maxSize); err != nil {
+			_ = stream.Reset()
+			return
+		}
+	}
+	if err := writeResponse(stream, response()); err != nil {
+		_ = stream.Reset()
+	}
+}
+
+func (h *Host) handleStatus(stream network.Stream) {
+	var peerStatus Status
+	h.respond(stream, &peerStatus, statusSize, func() SSZMarshaler {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		return h.status
+	})
+}
+
+func (h *Host) handleMetaData(stream network.Stream) {
+	h.respond(stream, nil, 0, func() SSZMarshaler {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		return h.metaData
+	})
+}
+
+func (h *Host) handlePing(stream network.Stream) {
+	var peerSeqNumber sszUint64
+	h.respond(stream, &peerSeqNumber, uint64Size, func() SSZMarshaler {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		seqNumber := sszUint64(h.metaData.SeqNumber)
+		return &seqNumber
+	})
+}
+
+// handleGoodbye reads the reason of the peer and drops the connection, goodbye has no response
+func (h *Host) handleGoodbye(stream network.Stream) {
+	var reason sszUint64
+	_ = stream.SetDeadline(time.Now().Add(RequestTimeout))
+	_ = readChunk(bufio.NewReader(stream), &reason, uint64Size)
+	_ = stream.Close()
+	_ = stream.Conn().Close()
+}
+
+// topic returns the joined topic, topics are joined on first use
+func (h *Host) topic(name string) (*pubsub.Topic, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if topic, ok := h.topics[name]; ok {
+		return topic, nil
+	}
+	topic, err := h.PubSub.Join(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to join %s", name)
+	}
+	h.topics[name] = topic
+	return topic, nil
+}
+
+// Publish publishes the raw message on the topic, it waits until the host has a peer on the topic
+func (h *Host) Publish(ctx context.Context, topic string, data []byte) error {
+	joined, err := h.topic(topic)
+	if err != nil {
+		return err
+	}
+	// the topic size readiness only counts mesh peers, which a host that isn't subscribed itself never has
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for len(joined.ListPeers()) == 0 {
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "no peer on %s", topic)
+		case <-ticker.C:
+		}
+	}
+	return joined.Publish(ctx, data)
+}
+
+// Subscribe subscribes to the topic, messages are read with Next of the subscription and decoded with Decode
+func (h *Host) Subscribe(topic string) (*pubsub.Subscription, error) {
+	joined, err := h.topic(topic)
+	if err != nil {
+		return nil, err
+	}
+	return joined.Subscribe()
+}
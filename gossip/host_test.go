@@ -0,0 +1,115 @@
+package gossip
+
+import (
+	"context"
+	"crypto/rand"
+	"eth-testnet-tool/consensus_client/consensus_objects"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	gcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/prysmaticlabs/go-bitfield"
+	"github.com/stretchr/testify/require"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestHost starts a host on a random local port that is closed with the test
+func newTestHost(t *testing.T, privateKey crypto.PrivKey, status *Status, metaData *MetaData) *Host {
+	h, err := NewHost(context.Background(), HostOpts{
+		ListenAddrs: []string{"/ip4/127.0.0.1/tcp/0"},
+		PrivateKey:  privateKey,
+		Status:      status,
+		MetaData:    metaData,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = h.Close() })
+	return h
+}
+
+func TestStatusMetaData_SSZ(t *testing.T) {
+	status := &Status{ForkDigest: phase0.ForkDigest{0x01}, FinalizedRoot: phase0.Root{0x02}, FinalizedEpoch: 3, HeadRoot: phase0.Root{0x04}, HeadSlot: 5}
+	data, err := status.MarshalSSZ()
+	require.NoError(t, err)
+	require.Len(t, data, statusSize)
+	var decodedStatus Status
+	require.NoError(t, decodedStatus.UnmarshalSSZ(data))
+	require.Equal(t, status, &decodedStatus)
+	require.Error(t, decodedStatus.UnmarshalSSZ(data[1:]))
+
+	metaData := &MetaData{SeqNumber: 7, Attnets: bitfield.NewBitvector64(), Syncnets: bitfield.NewBitvector4()}
+	metaData.Attnets.SetBitAt(9, true)
+	metaData.Syncnets.SetBitAt(1, true)
+	data, err = metaData.MarshalSSZ()
+	require.NoError(t, err)
+	require.Equal(t, []byte{7, 0, 0, 0, 0, 0, 0, 0, 0, 0x02, 0, 0, 0, 0, 0, 0, 0x02}, data)
+	var decodedMetaData MetaData
+	require.NoError(t, decodedMetaData.UnmarshalSSZ(data))
+	require.Equal(t, metaData, &decodedMetaData)
+}
+
+func TestHost_Libp2pPeer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	forkDigest := phase0.ForkDigest{0x05, 0x00, 0x00, 0x01}
+	privateKey, _, err := crypto.GenerateSecp256k1Key(rand.Reader)
+	require.NoError(t, err)
+	remoteStatus := &Status{ForkDigest: forkDigest, FinalizedEpoch: 2, HeadRoot: phase0.Root{0x42}, HeadSlot: 100}
+	remoteMetaData := &MetaData{SeqNumber: 3, Attnets: bitfield.NewBitvector64(), Syncnets: bitfield.NewBitvector4()}
+	remoteMetaData.Attnets.SetBitAt(5, true)
+	remote := newTestHost(t, privateKey, remoteStatus, remoteMetaData)
+	local := newTestHost(t, nil, &Status{ForkDigest: forkDigest}, nil)
+
+	id, status, err := local.Connect(ctx, remote.Addrs()[0].String())
+	require.NoError(t, err)
+	require.Equal(t, remote.ID(), id)
+	require.Equal(t, remoteStatus, status)
+	metaData, err := local.RequestMetaData(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, remoteMetaData, metaData)
+	seqNumber, err := local.Ping(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), seqNumber)
+
+	// the remote subscribes to the attestation subnet, the electra attestation arrives decoded on it
+	subscription, err := remote.Subscribe(Topic(forkDigest, TopicAttestation+"_5"))
+	require.NoError(t, err)
+	publisher := NewPublisher(local, forkDigest)
+	publisher.Electra = true
+	attestation := consensus_objects.RandomElectraAttestation(1)
+	require.NoError(t, publisher.PublishAttestation(ctx, 5, attestation))
+	message, err := subscription.Next(ctx)
+	require.NoError(t, err)
+	var decoded consensus_objects.ElectraAttestation
+	require.NoError(t, Decode(message.Data, &decoded))
+	require.Equal(t, attestation, &decoded)
+
+	// the same peer dialed from its record
+	ecdsaKey, err := privateKey.Raw()
+	require.NoError(t, err)
+	key, err := gcrypto.ToECDSA(ecdsaKey)
+	require.NoError(t, err)
+	tcpPort, err := remote.Addrs()[0].ValueForProtocol(ma.P_TCP)
+	require.NoError(t, err)
+	port, err := strconv.ParseUint(tcpPort, 10, 16)
+	require.NoError(t, err)
+	var record enr.Record
+	record.Set(enr.IP(net.ParseIP("127.0.0.1")))
+	record.Set(enr.TCP(port))
+	require.NoError(t, enode.SignV4(&record, key))
+	node, err := enode.New(enode.ValidSchemes, &record)
+	require.NoError(t, err)
+	other := newTestHost(t, nil, &Status{ForkDigest: forkDigest}, nil)
+	id, _, err = other.Connect(ctx, node.String())
+	require.NoError(t, err)
+	require.Equal(t, remote.ID(), id)
+
+	// a peer on another fork fails the handshake
+	otherFork := newTestHost(t, nil, &Status{ForkDigest: phase0.ForkDigest{0x01, 0x02, 0x03, 0x04}}, nil)
+	_, _, err = otherFork.Connect(ctx, remote.Addrs()[0].String())
+	require.ErrorContains(t, err, "fork digest")
+}
@@ -0,0 +1,72 @@
+package gossip
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/golang/snappy"
+	"strings"
+	"sync"
+)
+
+// MockGossipMessage a message the mock peer accepted
+type MockGossipMessage struct {
+	Topic string
+	// Name the topic name without the fork digest and encoding
+	Name string
+	Data []byte
+	ID   [20]byte
+}
+
+// MockGossipPeer is an in process stand-in for the gossipsub peer of a client, for testing without a network.
+// It only accepts messages on topics of its fork digest and drops duplicates by message id like gossipsub does.
+type MockGossipPeer struct {
+	ForkDigest phase0.ForkDigest
+
+	lock     sync.Mutex
+	seen     map[[20]byte]bool
+	messages []*MockGossipMessage
+}
+
+// NewMockGossipPeer returns a peer on the topics of the fork digest
+func NewMockGossipPeer(forkDigest phase0.ForkDigest) *MockGossipPeer {
+	return &MockGossipPeer{ForkDigest: forkDigest, seen: make(map[[20]byte]bool)}
+}
+
+// Publish implements Transport
+func (m *MockGossipPeer) Publish(ctx context.Context, topic string, data []byte) error {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != "eth2" || parts[4] != "ssz_snappy" {
+		return fmt.Errorf("invalid topic %s", topic)
+	}
+	if parts[2] != hex.EncodeToString(m.ForkDigest[:]) {
+		return fmt.Errorf("topic %s isn't for fork digest %#x", topic, m.ForkDigest)
+	}
+	if _, err := snappy.Decode(nil, data); err != nil {
+		return fmt.Errorf("message on %s isn't snappy encoded: %v", topic, err)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	id := MessageID(topic, data)
+	if m.seen[id] {
+		return nil
+	}
+	m.seen[id] = true
+	m.messages = append(m.messages, &MockGossipMessage{Topic: topic, Name: parts[3], Data: data, ID: id})
+	return nil
+}
+
+// Messages returns the accepted messages on the topic name
+func (m *MockGossipPeer) Messages(name string) []*MockGossipMessage {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var messages []*MockGossipMessage
+	for _, message := range m.messages {
+		if message.Name == name {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
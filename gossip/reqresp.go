@@ -0,0 +1,208 @@
+package gossip
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"eth-testnet-tool/consensus_client"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"github.com/prysmaticlabs/go-bitfield"
+	"io"
+)
+
+// Req/resp protocols of the beacon chain, all of them ssz_snappy encoded
+const (
+	StatusProtocol   = "/eth2/beacon_chain/req/status/1/ssz_snappy"
+	MetaDataProtocol = "/eth2/beacon_chain/req/metadata/2/ssz_snappy"
+	PingProtocol     = "/eth2/beacon_chain/req/ping/1/ssz_snappy"
+	GoodbyeProtocol  = "/eth2/beacon_chain/req/goodbye/1/ssz_snappy"
+)
+
+const (
+	statusSize   = 84
+	metaDataSize = 17
+	uint64Size   = 8
+	// maxErrorMessageSize the limit of the error message of a response
+	maxErrorMessageSize = 256
+
+	responseCodeSuccess = 0x00
+)
+
+// Status the chain a peer is on, exchanged when peers connect
+type Status struct {
+	ForkDigest     phase0.ForkDigest
+	FinalizedRoot  phase0.Root
+	FinalizedEpoch phase0.Epoch
+	HeadRoot       phase0.Root
+	HeadSlot       phase0.Slot
+}
+
+// MarshalSSZ returns the SSZ encoding of the status
+func (s *Status) MarshalSSZ() ([]byte, error) {
+	buf := make([]byte, 0, statusSize)
+	buf = append(buf, s.ForkDigest[:]...)
+	buf = append(buf, s.FinalizedRoot[:]...)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(s.FinalizedEpoch))
+	buf = append(buf, s.HeadRoot[:]...)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(s.HeadSlot))
+	return buf, nil
+}
+
+// UnmarshalSSZ decodes the SSZ encoding of a status
+func (s *Status) UnmarshalSSZ(buf []byte) error {
+	if len(buf) != statusSize {
+		return fmt.Errorf("status is %d bytes, expected %d", len(buf), statusSize)
+	}
+	copy(s.ForkDigest[:], buf[0:4])
+	copy(s.FinalizedRoot[:], buf[4:36])
+	s.FinalizedEpoch = phase0.Epoch(binary.LittleEndian.Uint64(buf[36:44]))
+	copy(s.HeadRoot[:], buf[44:76])
+	s.HeadSlot = phase0.Slot(binary.LittleEndian.Uint64(buf[76:84]))
+	return nil
+}
+
+// StatusFromClientViewContext returns the status of the chain the client is on, with the fork digest of its current fork
+func StatusFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient) (*Status, error) {
+	forkDigest, _, err := forkFromClientViewContext(ctx, consensusClient)
+	if err != nil {
+		return nil, err
+	}
+	head, err := consensusClient.GetBlockHeaderContext(ctx, "head")
+	if err != nil {
+		return nil, err
+	}
+	finality, err := consensusClient.GetFinalityContext(ctx, "head")
+	if err != nil {
+		return nil, err
+	}
+	return &Status{
+		ForkDigest:     forkDigest,
+		FinalizedRoot:  finality.Finalized.Root,
+		FinalizedEpoch: finality.Finalized.Epoch,
+		HeadRoot:       head.Root,
+		HeadSlot:       head.Header.Message.Slot,
+	}, nil
+}
+
+// MetaData the altair metadata of a peer, the subnets it is subscribed to
+type MetaData struct {
+	SeqNumber uint64
+	Attnets   bitfield.Bitvector64
+	Syncnets  bitfield.Bitvector4
+}
+
+// MarshalSSZ returns the SSZ encoding of the metadata
+func (m *MetaData) MarshalSSZ() ([]byte, error) {
+	attnets, syncnets := m.Attnets, m.Syncnets
+	if attnets == nil {
+		attnets = bitfield.NewBitvector64()
+	}
+	if syncnets == nil {
+		syncnets = bitfield.NewBitvector4()
+	}
+	if len(attnets) != 8 || len(syncnets) != 1 {
+		return nil, fmt.Errorf("metadata has %d bytes of attnets and %d of syncnets, expected 8 and 1", len(attnets), len(syncnets))
+	}
+	buf := make([]byte, 0, metaDataSize)
+	buf = binary.LittleEndian.AppendUint64(buf, m.SeqNumber)
+	buf = append(buf, attnets...)
+	buf = append(buf, syncnets...)
+	return buf, nil
+}
+
+// UnmarshalSSZ decodes the SSZ encoding of metadata
+func (m *MetaData) UnmarshalSSZ(buf []byte) error {
+	if len(buf) != metaDataSize {
+		return fmt.Errorf("metadata is %d bytes, expected %d", len(buf), metaDataSize)
+	}
+	m.SeqNumber = binary.LittleEndian.Uint64(buf[0:8])
+	m.Attnets = append(bitfield.Bitvector64{}, buf[8:16]...)
+	m.Syncnets = append(bitfield.Bitvector4{}, buf[16:17]...)
+	return nil
+}
+
+// sszUint64 the ping sequence number and goodbye reason
+type sszUint64 uint64
+
+func (u *sszUint64) MarshalSSZ() ([]byte, error) {
+	return binary.LittleEndian.AppendUint64(nil, uint64(*u)), nil
+}
+
+func (u *sszUint64) UnmarshalSSZ(buf []byte) error {
+	if len(buf) != uint64Size {
+		return fmt.Errorf("uint64 is %d bytes, expected %d", len(buf), uint64Size)
+	}
+	*u = sszUint64(binary.LittleEndian.Uint64(buf))
+	return nil
+}
+
+// writeChunk writes the SSZ of the object as a varint length prefix followed by the SSZ compressed with snappy's frame format
+func writeChunk(w io.Writer, object SSZMarshaler) error {
+	data, err := object.MarshalSSZ()
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal ssz")
+	}
+	if _, err := w.Write(binary.AppendUvarint(nil, uint64(len(data)))); err != nil {
+		return err
+	}
+	snappyWriter := snappy.NewBufferedWriter(w)
+	if _, err := snappyWriter.Write(data); err != nil {
+		return err
+	}
+	return snappyWriter.Close()
+}
+
+// readChunk reads a chunk written by writeChunk into the object, chunks longer than maxSize are refused before they are read
+func readChunk(r *bufio.Reader, object SSZUnmarshaler, maxSize uint64) error {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return errors.Wrap(err, "failed to read length prefix")
+	}
+	if length > maxSize {
+		return fmt.Errorf("chunk of %d bytes exceeds the limit of %d", length, maxSize)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(snappy.NewReader(r), data); err != nil {
+		return errors.Wrap(err, "failed to read snappy frames")
+	}
+	return object.UnmarshalSSZ(data)
+}
+
+// writeResponse writes a successful response chunk
+func writeResponse(w io.Writer, object SSZMarshaler) error {
+	if _, err := w.Write([]byte{responseCodeSuccess}); err != nil {
+		return err
+	}
+	return writeChunk(w, object)
+}
+
+// readResponse reads a response chunk into the object, an error response is returned as an error with its message
+func readResponse(r *bufio.Reader, object SSZUnmarshaler, maxSize uint64) error {
+	code, err := r.ReadByte()
+	if err != nil {
+		return errors.Wrap(err, "failed to read response code")
+	}
+	if code != responseCodeSuccess {
+		var message errorMessage
+		if err := readChunk(r, &message, maxErrorMessageSize); err != nil {
+			return fmt.Errorf("peer responded with error code %d", code)
+		}
+		return fmt.Errorf("peer responded with error code %d: %s", code, message)
+	}
+	return readChunk(r, object, maxSize)
+}
+
+// errorMessage the message of an error response
+type errorMessage []byte
+
+func (e *errorMessage) UnmarshalSSZ(buf []byte) error {
+	*e = append(errorMessage{}, buf...)
+	return nil
+}
+
+func (e errorMessage) String() string {
+	return string(e)
+}
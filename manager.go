@@ -4,12 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"eth-testnet-tool/consensus_client"
+	"eth-testnet-tool/execution_account"
 	"eth-testnet-tool/execution_client"
 	"eth-testnet-tool/validator"
+	"eth-testnet-tool/validator_client"
 	"fmt"
 	"github.com/attestantio/go-eth2-client/http"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/attestantio/go-execution-client/jsonrpc"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"math/rand"
@@ -20,14 +23,24 @@ import (
 type ClientManager struct {
 	ConsensusClients map[string]*consensus_client.ConsensusClient
 	ExecutionClients map[string]*execution_client.ExecutionClient
+	// ValidatorClients the keymanager apis of the validator clients, empty if the config lists none
+	ValidatorClients map[string]*validator_client.ValidatorClient
 	TestnetConfig    *TestnetConfig
 	Validators       []*validator.Validator
-	SlotsPerEpoch    uint64
-	SlotDuration     time.Duration
-	GenesisTime      time.Time
+	// ExecutionAccounts the premined accounts derived from the ExecutionAccountMnemonic
+	ExecutionAccounts []*execution_account.ExecutionAccount
+	SlotsPerEpoch     uint64
+	SlotDuration      time.Duration
+	GenesisTime       time.Time
 }
 
+// NewClientManager calls NewClientManagerContext with a background context
 func NewClientManager(testnetClientsConfigFilePath string, testnetConfigFilePath string) (*ClientManager, error) {
+	return NewClientManagerContext(context.Background(), testnetClientsConfigFilePath, testnetConfigFilePath)
+}
+
+// NewClientManagerContext creates the clients from the config files and reads the testnet parameters from them, the requests are cancelled with ctx
+func NewClientManagerContext(ctx context.Context, testnetClientsConfigFilePath string, testnetConfigFilePath string) (*ClientManager, error) {
 	consensusClients, err := getConsensusClientsFromFile(testnetClientsConfigFilePath, 5*time.Second, zerolog.WarnLevel)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create consensus clients from config.")
@@ -38,6 +51,11 @@ func NewClientManager(testnetClientsConfigFilePath string, testnetConfigFilePath
 		return nil, errors.Wrap(err, "unable to create execution clients from config.")
 	}
 
+	validatorClients, err := getValidatorClientsFromFile(testnetClientsConfigFilePath, 30*time.Second)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create validator clients from config.")
+	}
+
 	testnetConfig, err := testnetConfigFromFile(testnetConfigFilePath)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to get Testnet Config from file")
@@ -48,14 +66,29 @@ func NewClientManager(testnetClientsConfigFilePath string, testnetConfigFilePath
 		return nil, errors.Wrap(err, "failed to create validators")
 	}
 
+	var executionAccounts []*execution_account.ExecutionAccount
+	if testnetConfig.ExecutionAccountMnemonic != "" {
+		executionAccounts, err = execution_account.GetPremineAccounts(testnetConfig.ExecutionAccountMnemonic, testnetConfig.ExecutionPremines)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create execution accounts")
+		}
+	}
+
 	clientManager := ClientManager{
-		ConsensusClients: consensusClients,
-		ExecutionClients: executionClients,
-		TestnetConfig:    testnetConfig,
-		Validators:       validators,
+		ConsensusClients:  consensusClients,
+		ExecutionClients:  executionClients,
+		ValidatorClients:  validatorClients,
+		TestnetConfig:     testnetConfig,
+		Validators:        validators,
+		ExecutionAccounts: executionAccounts,
+	}
+
+	err = clientManager.auditSpecs(ctx, testnetConfig.StrictSpecAudit)
+	if err != nil {
+		return nil, err
 	}
 
-	err = clientManager.setTestnetParameters()
+	err = clientManager.setTestnetParameters(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -64,17 +97,17 @@ func NewClientManager(testnetClientsConfigFilePath string, testnetConfigFilePath
 }
 
 // setTestnetParameters reads the config from a random client and populates the local testnet params.
-func (c *ClientManager) setTestnetParameters() error {
+func (c *ClientManager) setTestnetParameters(ctx context.Context) error {
 	randomClient := c.GetRandomConsensusClient()
-	genesisTime, err := randomClient.BeaconService.GenesisTime(context.Background())
+	genesisTime, err := randomClient.BeaconService.GenesisTime(ctx)
 	if err != nil {
 		return errors.Wrap(err, "failed to get genesis time for testnet")
 	}
-	slotDuration, err := randomClient.BeaconService.SlotDuration(context.Background())
+	slotDuration, err := randomClient.BeaconService.SlotDuration(ctx)
 	if err != nil {
 		return errors.Wrap(err, "failed to get slot duration")
 	}
-	slotsPerEpoch, err := randomClient.BeaconService.SlotsPerEpoch(context.Background())
+	slotsPerEpoch, err := randomClient.BeaconService.SlotsPerEpoch(ctx)
 	if err != nil {
 		return errors.Wrap(err, "failed to get slots per epoch")
 	}
@@ -85,6 +118,17 @@ func (c *ClientManager) setTestnetParameters() error {
 	return nil
 }
 
+func (c *ClientManager) GetRandomExecutionClient() *execution_client.ExecutionClient {
+	r := rand.Intn(len(c.ExecutionClients))
+	for _, client := range c.ExecutionClients {
+		if r == 0 {
+			return client
+		}
+		r--
+	}
+	panic("unreachable")
+}
+
 func (c *ClientManager) GetRandomConsensusClient() *consensus_client.ConsensusClient {
 	r := rand.Intn(len(c.ConsensusClients))
 	for _, client := range c.ConsensusClients {
@@ -104,6 +148,42 @@ func (c *ClientManager) GetCurrentSlot() phase0.Slot {
 	return phase0.Slot(uint64(time.Since(c.GenesisTime).Seconds()) / uint64(c.SlotDuration.Seconds()))
 }
 
+// pollIntervalOrDefault returns the interval if set, otherwise the slot duration of the testnet
+func (c *ClientManager) pollIntervalOrDefault(interval time.Duration) time.Duration {
+	if interval > 0 {
+		return interval
+	}
+	if c.SlotDuration > 0 {
+		return c.SlotDuration
+	}
+	return 12 * time.Second
+}
+
+// GetExecutionAccount returns the premine account derived at the path, or the first premine account if path is empty
+func (c *ClientManager) GetExecutionAccount(path string) (*execution_account.ExecutionAccount, error) {
+	if len(c.ExecutionAccounts) == 0 {
+		return nil, errors.New("no premined execution accounts configured")
+	}
+	if path == "" {
+		return c.ExecutionAccounts[0], nil
+	}
+	for _, account := range c.ExecutionAccounts {
+		if account.Path == path {
+			return account, nil
+		}
+	}
+	return nil, fmt.Errorf("no premined execution account at path: %s", path)
+}
+
+// GetValidatorClient returns the validator client with the name
+func (c *ClientManager) GetValidatorClient(name string) (*validator_client.ValidatorClient, error) {
+	validatorClient, ok := c.ValidatorClients[name]
+	if !ok {
+		return nil, fmt.Errorf("no validator client named: %s", name)
+	}
+	return validatorClient, nil
+}
+
 func getExecutionClientsFromFile(filePath string, timeout time.Duration, logLevel zerolog.Level) (map[string]*execution_client.ExecutionClient, error) {
 	var executionTestnetClients = make(map[string]*execution_client.ExecutionClient)
 	var testnetClientsJSON TestnetClientsJSON
@@ -122,10 +202,15 @@ func getExecutionClientsFromFile(filePath string, timeout time.Duration, logLeve
 		if err != nil {
 			return nil, errors.Wrap(err, fmt.Sprintf("failed to get client: %s at %s", executionClient.Name, executionClient.RPCEndpoint))
 		}
+		ethClient, err := ethclient.DialContext(context.Background(), executionClient.RPCEndpoint)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to get eth client: %s at %s", executionClient.Name, executionClient.RPCEndpoint))
+		}
 		executionTestnetClients[executionClient.Name] = &execution_client.ExecutionClient{
 			Name:       executionClient.Name,
 			JsonRPC:    executionClient.RPCEndpoint,
 			RPCService: service.(*jsonrpc.Service),
+			EthClient:  ethClient,
 		}
 	}
 	return executionTestnetClients, nil
@@ -158,6 +243,28 @@ func getConsensusClientsFromFile(filePath string, timeout time.Duration, logLeve
 	return consensusTestnetClients, nil
 }
 
+func getValidatorClientsFromFile(filePath string, timeout time.Duration) (map[string]*validator_client.ValidatorClient, error) {
+	var validatorClients = make(map[string]*validator_client.ValidatorClient)
+	var testnetClientsJSON TestnetClientsJSON
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the testnet-clients config")
+	}
+
+	err = json.Unmarshal(data, &testnetClientsJSON)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshall the testnet-clients config")
+	}
+
+	for _, validatorClient := range testnetClientsJSON.ValidatorClients {
+		if validatorClient.KeymanagerEndpoint == "" {
+			return nil, fmt.Errorf("validator client %s has no keymanager endpoint", validatorClient.Name)
+		}
+		validatorClients[validatorClient.Name] = validator_client.NewValidatorClient(validatorClient.Name, validatorClient.KeymanagerEndpoint, validatorClient.Token, timeout)
+	}
+	return validatorClients, nil
+}
+
 func testnetConfigFromFile(filePath string) (*TestnetConfig, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
@@ -174,5 +281,6 @@ func testnetConfigFromFile(filePath string) (*TestnetConfig, error) {
 		DepositContractAddress:   testnetConfig.DepositContractAddress,
 		ExecutionAccountMnemonic: testnetConfig.ExecutionAccountMnemonic,
 		ExecutionPremines:        testnetConfig.ExecutionPremines,
+		StrictSpecAudit:          testnetConfig.StrictSpecAudit,
 	}, nil
 }
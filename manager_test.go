@@ -3,43 +3,77 @@ package eth_testnet_tool
 import (
 	"context"
 	"encoding/hex"
+	"encoding/json"
+	"eth-testnet-tool/consensus_client"
 	"fmt"
 	"github.com/attestantio/go-eth2-client/api"
 	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 const ExampleTestnetClientsConfigFilePath = "./example/configs/example-testnet-clients-config.json"
-const ExampleTestnetConfigFilePath = "./example/configs/example-testnet-config.json"
 
-func getTestnetClientManager() (*ClientManager, error) {
-	return NewClientManager(ExampleTestnetClientsConfigFilePath, ExampleTestnetConfigFilePath)
+// getTestnetClientManager creates a client manager from config files that point at two mock beacon nodes serving the genesis validators
+func getTestnetClientManager(t *testing.T) *ClientManager {
+	validators, err := getTestValidators(GenesisValidatorCount)
+	require.NoError(t, err)
+	clientsConfig := TestnetClientsJSON{ExecutionClients: []ExecutionClientJSON{}}
+	for _, name := range []string{"lighthouse-geth-0", "teku-geth-0"} {
+		node := consensus_client.NewMockBeaconNode(consensus_client.NewMockBeaconState(validators))
+		t.Cleanup(node.Close)
+		clientsConfig.ConsensusClients = append(clientsConfig.ConsensusClients, ConsensusClientJSON{Name: name, APIEndpoint: node.Server.URL})
+	}
+	testnetConfig := TestnetConfigJSON{ValidatorMnemonic: ValidatorMnemonic, GenesisValidatorCount: GenesisValidatorCount}
+
+	dir := t.TempDir()
+	clientsConfigPath := filepath.Join(dir, "testnet-clients-config.json")
+	testnetConfigPath := filepath.Join(dir, "testnet-config.json")
+	for path, config := range map[string]interface{}{clientsConfigPath: clientsConfig, testnetConfigPath: testnetConfig} {
+		data, err := json.Marshal(config)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+	}
+	manager, err := NewClientManager(clientsConfigPath, testnetConfigPath)
+	require.NoError(t, err)
+	return manager
 }
 
 func TestClientManager_Validators(t *testing.T) {
-	manager, err := getTestnetClientManager()
-	require.NoError(t, err)
-	t.Log(manager.Validators)
+	manager := getTestnetClientManager(t)
+	require.Len(t, manager.Validators, GenesisValidatorCount)
+	require.Len(t, manager.ConsensusClients, 2)
+	require.Equal(t, uint64(8), manager.SlotsPerEpoch)
+	require.Equal(t, 6*time.Second, manager.SlotDuration)
 }
 
 func TestClient_VerifyValidators(t *testing.T) {
-	//WARN: some clients don't support this endpoint when running minimals
-	manager, err := getTestnetClientManager()
-	require.NoError(t, err)
+	manager := getTestnetClientManager(t)
 	for _, consensusClient := range manager.ConsensusClients {
-		// some consensus clients don't support this endpoint.
 		onChainValidatorsIndexes, err := consensusClient.BeaconService.Validators(context.Background(), &api.ValidatorsOpts{
 			State: "head",
 		})
-		if err != nil {
-			fmt.Printf("Warning %s doesn't support this validators call (%s)\n", consensusClient.String(), err.Error())
-		} else {
-			fmt.Printf("Checking validators from client: %s\n", consensusClient.String())
-			for k, v := range onChainValidatorsIndexes.Data {
-				chainKey := v.Validator.PublicKey.String()
-				localKey := hex.EncodeToString(manager.Validators[k].ValidatorKey.PublicKey().Marshal())
-				require.Equal(t, chainKey, fmt.Sprintf("0x%s", localKey))
-			}
+		require.NoError(t, err, consensusClient.String())
+		require.Len(t, onChainValidatorsIndexes.Data, GenesisValidatorCount)
+		for k, v := range onChainValidatorsIndexes.Data {
+			chainKey := v.Validator.PublicKey.String()
+			localKey := hex.EncodeToString(manager.Validators[k].ValidatorKey.PublicKey().Marshal())
+			require.Equal(t, chainKey, fmt.Sprintf("0x%s", localKey))
 		}
 	}
 }
+
+func TestGetValidatorClientsFromFile(t *testing.T) {
+	validatorClients, err := getValidatorClientsFromFile(ExampleTestnetClientsConfigFilePath, Timeout)
+	require.NoError(t, err)
+	require.Len(t, validatorClients, 5)
+	manager := &ClientManager{ValidatorClients: validatorClients}
+	lighthouse, err := manager.GetValidatorClient("lighthouse-geth-0")
+	require.NoError(t, err)
+	require.Equal(t, "http://10.0.20.6:5062", lighthouse.KeymanagerAPI)
+	require.Equal(t, "example-keymanager-token", lighthouse.Token)
+	_, err = manager.GetValidatorClient("missing")
+	require.Error(t, err)
+}
@@ -0,0 +1,473 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"eth-testnet-tool/consensus_client"
+	"eth-testnet-tool/validator"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/prysmaticlabs/go-bitfield"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+)
+
+// MutationRule the validation rule a mutated message breaks
+type MutationRule string
+
+const (
+	// RuleInvalidSignature the signature doesn't verify against the key of the signer and the domain of the message
+	RuleInvalidSignature MutationRule = "invalid_signature"
+	// RuleUnknownValidator the validator index isn't in the state
+	RuleUnknownValidator MutationRule = "unknown_validator"
+	// RuleFutureEpoch the voluntary exit is for an epoch after the current one
+	RuleFutureEpoch MutationRule = "future_epoch"
+	// RuleWithdrawalCredentials the from_bls_pubkey of a bls to execution change doesn't match the withdrawal credentials of the validator
+	RuleWithdrawalCredentials MutationRule = "withdrawal_credentials_mismatch"
+	// RuleTargetEpoch the target epoch of an attestation isn't the epoch of its slot
+	RuleTargetEpoch MutationRule = "target_epoch_mismatch"
+	// RuleCommitteeIndex the committee index of an attestation isn't lower than the committee count of its slot
+	RuleCommitteeIndex MutationRule = "committee_index_out_of_range"
+	// RuleAggregationBits the aggregation bits of an attestation don't have the length of its committee
+	RuleAggregationBits MutationRule = "aggregation_bits_length"
+	// RuleNotSlashable the two messages of a slashing don't conflict
+	RuleNotSlashable MutationRule = "not_slashable"
+	// RuleAttestingIndices the attesting indices of an indexed attestation aren't sorted and unique
+	RuleAttestingIndices MutationRule = "attesting_indices_not_sorted"
+	// RuleNoSlashableValidator the attestations of an attester slashing have no attester in common
+	RuleNoSlashableValidator MutationRule = "no_slashable_validator"
+	// RuleHeaderSlot the headers of a proposer slashing are for different slots
+	RuleHeaderSlot MutationRule = "header_slot_mismatch"
+	// RuleHeaderProposer the headers of a proposer slashing have different proposers
+	RuleHeaderProposer MutationRule = "header_proposer_mismatch"
+)
+
+// Mutation a correctly built message with a single field changed, so it breaks exactly one rule.
+// Only the field of the message type is set.
+type Mutation struct {
+	// Name what was changed, e.g. "epoch+1" or "wrong key"
+	Name string
+	Rule MutationRule
+
+	VoluntaryExit        *phase0.SignedVoluntaryExit
+	BLSToExecutionChange *capella.SignedBLSToExecutionChange
+	Attestation          *phase0.Attestation
+	AttesterSlashing     *phase0.AttesterSlashing
+	ProposerSlashing     *phase0.ProposerSlashing
+}
+
+func (m *Mutation) String() string {
+	return fmt.Sprintf("%s (%s)", m.Name, m.Rule)
+}
+
+// MutationFuzzer starts from the messages the builders make and mutates one field of them, re-signing where needed.
+// Unlike the Random generators in consensus_objects the mutations get past the first checks of a client, so they reach its deeper validation.
+// Wrong keys and indices are taken from the other validators, signing with a wrong domain needs the keys in memory.
+type MutationFuzzer struct {
+	ConsensusClient *consensus_client.ConsensusClient
+	Validators      []*validator.Validator
+}
+
+// NewMutationFuzzer returns a fuzzer building with the client, validators has to hold at least two validators
+func NewMutationFuzzer(consensusClient *consensus_client.ConsensusClient, validators []*validator.Validator) *MutationFuzzer {
+	return &MutationFuzzer{ConsensusClient: consensusClient, Validators: validators}
+}
+
+// MutateVoluntaryExit builds an exit of the validator for the current epoch and returns its mutations
+func (f *MutationFuzzer) MutateVoluntaryExit(ctx context.Context, v *validator.Validator) ([]*Mutation, error) {
+	epoch, err := f.ConsensusClient.GetCurrentEpochContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	exit, err := BuildVoluntaryExitFromClientViewContext(ctx, f.ConsensusClient, v, epoch)
+	if err != nil {
+		return nil, err
+	}
+	other, otherIndex, err := f.other(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	unknownIndex, err := f.unknownIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var mutations []*Mutation
+	add := func(name string, rule MutationRule, signer *validator.Validator, message phase0.VoluntaryExit) error {
+		signed, err := SignVoluntaryExitWithValidatorContext(ctx, f.ConsensusClient, signer, &message)
+		if err != nil {
+			return errors.Wrapf(err, "failed to sign mutation %s", name)
+		}
+		mutations = append(mutations, &Mutation{Name: name, Rule: rule, VoluntaryExit: signed})
+		return nil
+	}
+	future := *exit.Message
+	future.Epoch++
+	swapped := *exit.Message
+	swapped.ValidatorIndex = otherIndex
+	unknown := *exit.Message
+	unknown.ValidatorIndex = unknownIndex
+	for _, err := range []error{
+		add("epoch+1", RuleFutureEpoch, v, future),
+		add("swapped validator index", RuleInvalidSignature, v, swapped),
+		add("unknown validator index", RuleUnknownValidator, v, unknown),
+		add("wrong key", RuleInvalidSignature, other, *exit.Message),
+	} {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	messageRoot, err := exit.Message.HashTreeRoot()
+	if err != nil {
+		return nil, err
+	}
+	forkVersion, err := f.ConsensusClient.SpecCache().ForkVersion(ctx, exit.Message.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	for _, wrong := range []struct {
+		name         string
+		domainLookup string
+		forkVersion  bool
+	}{
+		{name: "wrong fork domain", domainLookup: VoluntaryExitDomainLookup, forkVersion: true},
+		{name: "wrong domain type", domainLookup: BeaconProposerDomainLookup},
+	} {
+		version := forkVersion
+		if wrong.forkVersion {
+			if version, err = f.otherForkVersion(ctx, forkVersion); err != nil {
+				return nil, err
+			}
+		}
+		signature, err := f.signWithDomain(ctx, v.ValidatorKey, phase0.Root(messageRoot), wrong.domainLookup, version)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to sign mutation %s", wrong.name)
+		}
+		mutations = append(mutations, &Mutation{Name: wrong.name, Rule: RuleInvalidSignature, VoluntaryExit: &phase0.SignedVoluntaryExit{Message: exit.Message, Signature: signature}})
+	}
+	return mutations, nil
+}
+
+// MutateBLSToExecutionChange builds a change of the validator to the address and returns its mutations
+func (f *MutationFuzzer) MutateBLSToExecutionChange(ctx context.Context, v *validator.Validator, address bellatrix.ExecutionAddress) ([]*Mutation, error) {
+	change, err := BuildSignedBLSToExecutionChangeFromClientViewContext(ctx, f.ConsensusClient, v, address)
+	if err != nil {
+		return nil, err
+	}
+	other, otherIndex, err := f.other(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	unknownIndex, err := f.unknownIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var mutations []*Mutation
+	add := func(name string, rule MutationRule, signer *validator.Validator, message capella.BLSToExecutionChange) error {
+		signed, err := SignBLSToExecutionChangeWithValidatorContext(ctx, f.ConsensusClient, signer, &message)
+		if err != nil {
+			return errors.Wrapf(err, "failed to sign mutation %s", name)
+		}
+		mutations = append(mutations, &Mutation{Name: name, Rule: rule, BLSToExecutionChange: signed})
+		return nil
+	}
+	swapped := *change.Message
+	swapped.ValidatorIndex = otherIndex
+	wrongPubKey := *change.Message
+	wrongPubKey.FromBLSPubkey = other.WithdrawalPublicKey
+	unknown := *change.Message
+	unknown.ValidatorIndex = unknownIndex
+	for _, err := range []error{
+		add("swapped validator index", RuleWithdrawalCredentials, v, swapped),
+		add("wrong from_bls_pubkey", RuleWithdrawalCredentials, other, wrongPubKey),
+		add("unknown validator index", RuleUnknownValidator, v, unknown),
+		add("wrong key", RuleInvalidSignature, other, *change.Message),
+	} {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// changes are signed with the genesis fork version, the fork of the head is the usual mistake
+	messageRoot, err := change.Message.HashTreeRoot()
+	if err != nil {
+		return nil, err
+	}
+	genesis, err := f.ConsensusClient.SpecCache().Genesis(ctx)
+	if err != nil {
+		return nil, err
+	}
+	version, err := f.otherForkVersion(ctx, genesis.GenesisForkVersion)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := f.signWithDomain(ctx, v.WithdrawalKey, phase0.Root(messageRoot), BlsToExecutionChangeDomainLookup, version)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign mutation wrong fork domain")
+	}
+	mutations = append(mutations, &Mutation{Name: "wrong fork domain", Rule: RuleInvalidSignature, BLSToExecutionChange: &capella.SignedBLSToExecutionChange{Message: change.Message, Signature: signature}})
+	return mutations, nil
+}
+
+// MutateAttestation builds the attestation of the validator in the epoch and returns its mutations
+func (f *MutationFuzzer) MutateAttestation(ctx context.Context, v *validator.Validator, epoch phase0.Epoch) ([]*Mutation, error) {
+	attestation, err := BuildAttestationFromClientViewContext(ctx, f.ConsensusClient, v, epoch)
+	if err != nil {
+		return nil, err
+	}
+	other, _, err := f.other(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	index, err := f.ConsensusClient.GetValidatorByPublicKeyContext(ctx, "head", v.ValidatorPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	committees, err := f.ConsensusClient.GetBeaconCommitteesContext(ctx, "head", epoch)
+	if err != nil {
+		return nil, err
+	}
+	committeeCount := phase0.CommitteeIndex(0)
+	for _, committee := range committees {
+		if committee.Slot == attestation.Data.Slot {
+			committeeCount++
+		}
+	}
+
+	// the mutations vote again in the same epoch on purpose, slashing protection has to let them through
+	ctx = validator.AllowSlashable(ctx)
+	var mutations []*Mutation
+	add := func(name string, rule MutationRule, signer *validator.Validator, data phase0.AttestationData, aggregationBits bitfield.Bitlist) error {
+		indexedAttestation, err := SignIndexedAttestationWithValidatorsContext(ctx, f.ConsensusClient, []*validator.Validator{signer}, []phase0.ValidatorIndex{index.Index}, &data)
+		if err != nil {
+			return errors.Wrapf(err, "failed to sign mutation %s", name)
+		}
+		mutations = append(mutations, &Mutation{Name: name, Rule: rule, Attestation: &phase0.Attestation{
+			AggregationBits: aggregationBits,
+			Data:            &data,
+			Signature:       indexedAttestation.Signature,
+		}})
+		return nil
+	}
+	targetEpoch := *attestation.Data
+	targetEpoch.Target = &phase0.Checkpoint{Epoch: attestation.Data.Target.Epoch + 1, Root: attestation.Data.Target.Root}
+	committeeIndex := *attestation.Data
+	committeeIndex.Index = committeeCount
+	// the only bit set is the one after the end of the committee
+	committeeSize := attestation.AggregationBits.Len()
+	outOfRange := bitfield.NewBitlist(committeeSize + 1)
+	outOfRange.SetBitAt(committeeSize, true)
+	for _, err := range []error{
+		add("target epoch+1", RuleTargetEpoch, v, targetEpoch, attestation.AggregationBits),
+		add("committee index out of range", RuleCommitteeIndex, v, committeeIndex, attestation.AggregationBits),
+		add("committee bit out of range", RuleAggregationBits, v, *attestation.Data, outOfRange),
+		add("wrong key", RuleInvalidSignature, other, *attestation.Data, attestation.AggregationBits),
+	} {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	messageRoot, err := attestation.Data.HashTreeRoot()
+	if err != nil {
+		return nil, err
+	}
+	forkVersion, err := f.ConsensusClient.SpecCache().ForkVersion(ctx, epoch)
+	if err != nil {
+		return nil, err
+	}
+	version, err := f.otherForkVersion(ctx, forkVersion)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := f.signWithDomain(ctx, v.ValidatorKey, phase0.Root(messageRoot), BeaconAttesterDomainLookup, version)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign mutation wrong fork domain")
+	}
+	mutations = append(mutations, &Mutation{Name: "wrong fork domain", Rule: RuleInvalidSignature, Attestation: &phase0.Attestation{
+		AggregationBits: attestation.AggregationBits,
+		Data:            attestation.Data,
+		Signature:       signature,
+	}})
+	return mutations, nil
+}
+
+// MutateAttesterSlashing builds a double vote slashing of the validators in the epoch and returns its mutations
+func (f *MutationFuzzer) MutateAttesterSlashing(ctx context.Context, validators []*validator.Validator, epoch phase0.Epoch) ([]*Mutation, error) {
+	slashing, err := BuildAttesterSlashingFromClientViewContext(ctx, f.ConsensusClient, validators, epoch, DoubleVote)
+	if err != nil {
+		return nil, err
+	}
+	other, otherIndex, err := f.other(ctx, validators...)
+	if err != nil {
+		return nil, err
+	}
+	ctx = validator.AllowSlashable(ctx)
+
+	attestation1, attestation2 := slashing.Attestation1, slashing.Attestation2
+	indices := make([]phase0.ValidatorIndex, len(attestation2.AttestingIndices))
+	for i, index := range attestation2.AttestingIndices {
+		indices[i] = phase0.ValidatorIndex(index)
+	}
+	wrongKey, err := SignIndexedAttestationWithValidatorsContext(ctx, f.ConsensusClient, []*validator.Validator{other}, indices, attestation2.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign mutation wrong key")
+	}
+	noCommonAttester, err := SignIndexedAttestationWithValidatorsContext(ctx, f.ConsensusClient, []*validator.Validator{other}, []phase0.ValidatorIndex{otherIndex}, attestation2.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign mutation no common attester")
+	}
+	// the signature stays the one of the unique indices, the duplicate is rejected before it is checked
+	duplicateIndex := *attestation1
+	duplicateIndex.AttestingIndices = append(append([]uint64{}, attestation1.AttestingIndices...), attestation1.AttestingIndices[len(attestation1.AttestingIndices)-1])
+
+	return []*Mutation{
+		{Name: "identical attestations", Rule: RuleNotSlashable, AttesterSlashing: &phase0.AttesterSlashing{Attestation1: attestation1, Attestation2: attestation1}},
+		{Name: "duplicate attesting index", Rule: RuleAttestingIndices, AttesterSlashing: &phase0.AttesterSlashing{Attestation1: &duplicateIndex, Attestation2: attestation2}},
+		{Name: "no common attester", Rule: RuleNoSlashableValidator, AttesterSlashing: &phase0.AttesterSlashing{Attestation1: attestation1, Attestation2: noCommonAttester}},
+		{Name: "wrong key", Rule: RuleInvalidSignature, AttesterSlashing: &phase0.AttesterSlashing{Attestation1: attestation1, Attestation2: wrongKey}},
+	}, nil
+}
+
+// MutateProposerSlashing builds a proposer slashing of the validator at the slot and returns its mutations
+func (f *MutationFuzzer) MutateProposerSlashing(ctx context.Context, v *validator.Validator, slot phase0.Slot) ([]*Mutation, error) {
+	slashing, err := BuildProposerSlashingFromClientViewContext(ctx, f.ConsensusClient, v, slot)
+	if err != nil {
+		return nil, err
+	}
+	other, otherIndex, err := f.other(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	ctx = validator.AllowSlashable(ctx)
+
+	var mutations []*Mutation
+	add := func(name string, rule MutationRule, signer *validator.Validator, header phase0.BeaconBlockHeader) error {
+		signed, err := SignBeaconBlockHeaderWithValidatorContext(ctx, f.ConsensusClient, signer, &header)
+		if err != nil {
+			return errors.Wrapf(err, "failed to sign mutation %s", name)
+		}
+		mutations = append(mutations, &Mutation{Name: name, Rule: rule, ProposerSlashing: &phase0.ProposerSlashing{SignedHeader1: slashing.SignedHeader1, SignedHeader2: signed}})
+		return nil
+	}
+	nextSlot := *slashing.SignedHeader2.Message
+	nextSlot.Slot++
+	swapped := *slashing.SignedHeader2.Message
+	swapped.ProposerIndex = otherIndex
+	mutations = append(mutations, &Mutation{Name: "identical headers", Rule: RuleNotSlashable, ProposerSlashing: &phase0.ProposerSlashing{SignedHeader1: slashing.SignedHeader1, SignedHeader2: slashing.SignedHeader1}})
+	for _, err := range []error{
+		add("slot+1", RuleHeaderSlot, v, nextSlot),
+		add("swapped proposer index", RuleHeaderProposer, other, swapped),
+		add("wrong key", RuleInvalidSignature, other, *slashing.SignedHeader2.Message),
+	} {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	messageRoot, err := slashing.SignedHeader1.Message.HashTreeRoot()
+	if err != nil {
+		return nil, err
+	}
+	slotsPerEpoch, err := f.ConsensusClient.GetSlotsPerEpochContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	forkVersion, err := f.ConsensusClient.SpecCache().ForkVersion(ctx, phase0.Epoch(uint64(slot)/slotsPerEpoch))
+	if err != nil {
+		return nil, err
+	}
+	version, err := f.otherForkVersion(ctx, forkVersion)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := f.signWithDomain(ctx, v.ValidatorKey, phase0.Root(messageRoot), BeaconProposerDomainLookup, version)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign mutation wrong fork domain")
+	}
+	mutations = append(mutations, &Mutation{Name: "wrong fork domain", Rule: RuleInvalidSignature, ProposerSlashing: &phase0.ProposerSlashing{
+		SignedHeader1: &phase0.SignedBeaconBlockHeader{Message: slashing.SignedHeader1.Message, Signature: signature},
+		SignedHeader2: slashing.SignedHeader2,
+	}})
+	return mutations, nil
+}
+
+// other returns a validator of the fuzzer that isn't one of the excluded ones, with its index
+func (f *MutationFuzzer) other(ctx context.Context, excluded ...*validator.Validator) (*validator.Validator, phase0.ValidatorIndex, error) {
+	skip := make(map[phase0.BLSPubKey]bool)
+	for _, v := range excluded {
+		skip[v.ValidatorPublicKey] = true
+	}
+	for _, v := range f.Validators {
+		if skip[v.ValidatorPublicKey] {
+			continue
+		}
+		clientValidatorView, err := f.ConsensusClient.GetValidatorByPublicKeyContext(ctx, "head", v.ValidatorPublicKey)
+		if err != nil {
+			return nil, 0, err
+		}
+		return v, clientValidatorView.Index, nil
+	}
+	return nil, 0, errors.New("the fuzzer needs a validator besides the mutated ones for wrong keys and indices")
+}
+
+// unknownIndex returns the index after the last validator of the state
+func (f *MutationFuzzer) unknownIndex(ctx context.Context) (phase0.ValidatorIndex, error) {
+	validators, err := f.ConsensusClient.GetAllValidatorsContext(ctx, "head")
+	if err != nil {
+		return 0, err
+	}
+	unknown := phase0.ValidatorIndex(0)
+	for index := range validators {
+		if index >= unknown {
+			unknown = index + 1
+		}
+	}
+	return unknown, nil
+}
+
+// otherForkVersion returns a version of the chain other than the right one, the genesis or head fork version,
+// or the right one with a flipped byte if the chain only ever had one
+func (f *MutationFuzzer) otherForkVersion(ctx context.Context, right phase0.Version) (phase0.Version, error) {
+	genesis, err := f.ConsensusClient.SpecCache().Genesis(ctx)
+	if err != nil {
+		return phase0.Version{}, err
+	}
+	epoch, err := f.ConsensusClient.GetCurrentEpochContext(ctx)
+	if err != nil {
+		return phase0.Version{}, err
+	}
+	head, err := f.ConsensusClient.SpecCache().ForkVersion(ctx, epoch)
+	if err != nil {
+		return phase0.Version{}, err
+	}
+	for _, version := range []phase0.Version{genesis.GenesisForkVersion, head} {
+		if version != right {
+			return version, nil
+		}
+	}
+	right[3] ^= 0xff
+	return right, nil
+}
+
+// signWithDomain signs the message root with the key for a domain the Sign helpers wouldn't pick, which is why it needs the key in memory
+func (f *MutationFuzzer) signWithDomain(ctx context.Context, key e2types.PrivateKey, messageRoot phase0.Root, domainLookup string, version phase0.Version) (phase0.BLSSignature, error) {
+	if key == nil {
+		return phase0.BLSSignature{}, errors.New("signing with a wrong domain needs the key in memory")
+	}
+	domainType, err := f.ConsensusClient.GetDomainTypeFromSpecContext(ctx, domainLookup)
+	if err != nil {
+		return phase0.BLSSignature{}, err
+	}
+	genesis, err := f.ConsensusClient.SpecCache().Genesis(ctx)
+	if err != nil {
+		return phase0.BLSSignature{}, err
+	}
+	domain := common.ComputeDomain(common.BLSDomainType(domainType), common.Version(version), common.Root(genesis.GenesisValidatorsRoot))
+	signingRoot := common.ComputeSigningRoot(common.Root(messageRoot), domain)
+	return phase0.BLSSignature(key.Sign(signingRoot[:]).Marshal()), nil
+}
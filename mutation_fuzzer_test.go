@@ -0,0 +1,185 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"eth-testnet-tool/consensus_client"
+	"eth-testnet-tool/validator"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// mutationsByName checks every mutation carries a message and is labelled, and returns them by name
+func mutationsByName(t *testing.T, mutations []*Mutation) map[string]*Mutation {
+	byName := make(map[string]*Mutation)
+	for _, mutation := range mutations {
+		require.NotEmpty(t, mutation.Rule, mutation.Name)
+		require.NotContains(t, byName, mutation.Name)
+		byName[mutation.Name] = mutation
+	}
+	return byName
+}
+
+func TestMutationFuzzer_VoluntaryExit(t *testing.T) {
+	_, client, validators := getMockConsensusClient(t)
+	fuzzer := NewMutationFuzzer(client, validators)
+	mutations, err := fuzzer.MutateVoluntaryExit(context.Background(), validators[4])
+	require.NoError(t, err)
+	byName := mutationsByName(t, mutations)
+	require.Len(t, byName, 6)
+
+	// every mutation is signed, the signature only breaks the rule of the signature mutations
+	for name, expected := range map[string]struct {
+		rule        MutationRule
+		message     phase0.VoluntaryExit
+		domainType  common.BLSDomainType
+		forkVersion common.Version
+		signer      *validator.Validator
+	}{
+		"epoch+1":                 {RuleFutureEpoch, phase0.VoluntaryExit{Epoch: 1, ValidatorIndex: 4}, common.BLSDomainType{0x04}, mockCapellaForkVersion, validators[4]},
+		"swapped validator index": {RuleInvalidSignature, phase0.VoluntaryExit{Epoch: 0, ValidatorIndex: 0}, common.BLSDomainType{0x04}, mockCapellaForkVersion, validators[4]},
+		"unknown validator index": {RuleUnknownValidator, phase0.VoluntaryExit{Epoch: 0, ValidatorIndex: GenesisValidatorCount}, common.BLSDomainType{0x04}, mockCapellaForkVersion, validators[4]},
+		"wrong key":               {RuleInvalidSignature, phase0.VoluntaryExit{Epoch: 0, ValidatorIndex: 4}, common.BLSDomainType{0x04}, mockCapellaForkVersion, validators[0]},
+		"wrong fork domain":       {RuleInvalidSignature, phase0.VoluntaryExit{Epoch: 0, ValidatorIndex: 4}, common.BLSDomainType{0x04}, mockGenesisForkVersion, validators[4]},
+		"wrong domain type":       {RuleInvalidSignature, phase0.VoluntaryExit{Epoch: 0, ValidatorIndex: 4}, common.BLSDomainType{0x00}, mockCapellaForkVersion, validators[4]},
+	} {
+		mutation := byName[name]
+		require.NotNil(t, mutation, name)
+		require.Equal(t, expected.rule, mutation.Rule, name)
+		require.Equal(t, expected.message, *mutation.VoluntaryExit.Message, name)
+		root, err := mutation.VoluntaryExit.Message.HashTreeRoot()
+		require.NoError(t, err)
+		requireValidMockSignature(t, expected.domainType, expected.forkVersion, root, mutation.VoluntaryExit.Signature, expected.signer.ValidatorKey.PublicKey())
+	}
+}
+
+func TestMutationFuzzer_BLSToExecutionChange(t *testing.T) {
+	_, client, validators := getMockConsensusClient(t)
+	fuzzer := NewMutationFuzzer(client, validators)
+	mutations, err := fuzzer.MutateBLSToExecutionChange(context.Background(), validators[3], bellatrix.ExecutionAddress{0x69})
+	require.NoError(t, err)
+	byName := mutationsByName(t, mutations)
+	require.Len(t, byName, 5)
+
+	for name, expected := range map[string]struct {
+		rule        MutationRule
+		index       phase0.ValidatorIndex
+		signer      *validator.Validator
+		forkVersion common.Version
+	}{
+		"swapped validator index": {RuleWithdrawalCredentials, 0, validators[3], mockGenesisForkVersion},
+		"wrong from_bls_pubkey":   {RuleWithdrawalCredentials, 3, validators[0], mockGenesisForkVersion},
+		"unknown validator index": {RuleUnknownValidator, GenesisValidatorCount, validators[3], mockGenesisForkVersion},
+		"wrong key":               {RuleInvalidSignature, 3, validators[0], mockGenesisForkVersion},
+		"wrong fork domain":       {RuleInvalidSignature, 3, validators[3], mockCapellaForkVersion},
+	} {
+		mutation := byName[name]
+		require.NotNil(t, mutation, name)
+		require.Equal(t, expected.rule, mutation.Rule, name)
+		require.Equal(t, expected.index, mutation.BLSToExecutionChange.Message.ValidatorIndex, name)
+		root, err := mutation.BLSToExecutionChange.Message.HashTreeRoot()
+		require.NoError(t, err)
+		requireValidMockSignature(t, common.BLSDomainType{0x0a}, expected.forkVersion, root, mutation.BLSToExecutionChange.Signature, expected.signer.WithdrawalKey.PublicKey())
+	}
+	require.Equal(t, validators[0].WithdrawalPublicKey, byName["wrong from_bls_pubkey"].BLSToExecutionChange.Message.FromBLSPubkey)
+}
+
+func TestMutationFuzzer_Attestation(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	require.NoError(t, node.AddBlock(consensus_client.NewMockCapellaBlock(40, 1, phase0.Root{})))
+	// the mutations are slashable, they are signed anyway
+	validator.NewSlashingProtection(phase0.Root{}).Protect(validators[5])
+	ctx := context.Background()
+	attestation, err := BuildAttestationFromClientViewContext(ctx, client, validators[5], 5)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Epoch(5), attestation.Data.Target.Epoch)
+	require.Equal(t, 1, len(attestation.AggregationBits.BitIndices()))
+
+	fuzzer := NewMutationFuzzer(client, validators)
+	mutations, err := fuzzer.MutateAttestation(ctx, validators[5], 5)
+	require.NoError(t, err)
+	byName := mutationsByName(t, mutations)
+	require.Len(t, byName, 5)
+
+	require.Equal(t, RuleTargetEpoch, byName["target epoch+1"].Rule)
+	require.Equal(t, phase0.Epoch(6), byName["target epoch+1"].Attestation.Data.Target.Epoch)
+	require.Equal(t, RuleCommitteeIndex, byName["committee index out of range"].Rule)
+	require.Equal(t, attestation.Data.Index+1, byName["committee index out of range"].Attestation.Data.Index)
+	outOfRange := byName["committee bit out of range"].Attestation.AggregationBits
+	require.Equal(t, RuleAggregationBits, byName["committee bit out of range"].Rule)
+	require.Equal(t, attestation.AggregationBits.Len()+1, outOfRange.Len())
+	require.Equal(t, []int{int(attestation.AggregationBits.Len())}, outOfRange.BitIndices())
+
+	for name, expected := range map[string]struct {
+		signer      *validator.Validator
+		forkVersion common.Version
+	}{
+		"target epoch+1":               {validators[5], mockCapellaForkVersion},
+		"committee index out of range": {validators[5], mockCapellaForkVersion},
+		"committee bit out of range":   {validators[5], mockCapellaForkVersion},
+		"wrong key":                    {validators[0], mockCapellaForkVersion},
+		"wrong fork domain":            {validators[5], mockGenesisForkVersion},
+	} {
+		mutation := byName[name]
+		root, err := mutation.Attestation.Data.HashTreeRoot()
+		require.NoError(t, err)
+		requireValidMockSignature(t, common.BLSDomainType{0x01}, expected.forkVersion, root, mutation.Attestation.Signature, expected.signer.ValidatorKey.PublicKey())
+	}
+}
+
+func TestMutationFuzzer_Slashings(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	require.NoError(t, node.AddBlock(consensus_client.NewMockCapellaBlock(40, 1, phase0.Root{})))
+	setMockProposer(node, 12, 7)
+	ctx := context.Background()
+	fuzzer := NewMutationFuzzer(client, validators)
+
+	mutations, err := fuzzer.MutateAttesterSlashing(ctx, []*validator.Validator{validators[9], validators[2]}, 5)
+	require.NoError(t, err)
+	byName := mutationsByName(t, mutations)
+	require.Len(t, byName, 4)
+	identical := byName["identical attestations"].AttesterSlashing
+	require.False(t, IsSlashableAttestationData(identical.Attestation1.Data, identical.Attestation2.Data))
+	require.Equal(t, []uint64{2, 9, 9}, byName["duplicate attesting index"].AttesterSlashing.Attestation1.AttestingIndices)
+	require.Equal(t, []uint64{0}, byName["no common attester"].AttesterSlashing.Attestation2.AttestingIndices)
+	wrongKey := byName["wrong key"].AttesterSlashing.Attestation2
+	require.Equal(t, []uint64{2, 9}, wrongKey.AttestingIndices)
+	root, err := wrongKey.Data.HashTreeRoot()
+	require.NoError(t, err)
+	requireValidMockSignature(t, common.BLSDomainType{0x01}, mockCapellaForkVersion, root, wrongKey.Signature, validators[0].ValidatorKey.PublicKey())
+
+	mutations, err = fuzzer.MutateProposerSlashing(ctx, validators[7], 12)
+	require.NoError(t, err)
+	byName = mutationsByName(t, mutations)
+	require.Len(t, byName, 5)
+	require.Equal(t, RuleNotSlashable, byName["identical headers"].Rule)
+	require.Equal(t, byName["identical headers"].ProposerSlashing.SignedHeader1, byName["identical headers"].ProposerSlashing.SignedHeader2)
+	for name, expected := range map[string]struct {
+		rule     MutationRule
+		slot     phase0.Slot
+		proposer phase0.ValidatorIndex
+		signer   *validator.Validator
+	}{
+		"slot+1":                 {RuleHeaderSlot, 13, 7, validators[7]},
+		"swapped proposer index": {RuleHeaderProposer, 12, 0, validators[0]},
+		"wrong key":              {RuleInvalidSignature, 12, 7, validators[0]},
+	} {
+		mutation := byName[name]
+		require.Equal(t, expected.rule, mutation.Rule, name)
+		header := mutation.ProposerSlashing.SignedHeader2
+		require.Equal(t, expected.slot, header.Message.Slot, name)
+		require.Equal(t, expected.proposer, header.Message.ProposerIndex, name)
+		root, err := header.Message.HashTreeRoot()
+		require.NoError(t, err)
+		requireValidMockSignature(t, common.BLSDomainType{0x00}, mockCapellaForkVersion, root, header.Signature, expected.signer.ValidatorKey.PublicKey())
+	}
+	header := byName["wrong fork domain"].ProposerSlashing.SignedHeader1
+	root, err = header.Message.HashTreeRoot()
+	require.NoError(t, err)
+	requireValidMockSignature(t, common.BLSDomainType{0x00}, mockGenesisForkVersion, root, header.Signature, validators[7].ValidatorKey.PublicKey())
+
+	_, err = NewMutationFuzzer(client, validators[7:8]).MutateProposerSlashing(ctx, validators[7], 12)
+	require.Error(t, err)
+}
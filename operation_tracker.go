@@ -0,0 +1,295 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"eth-testnet-tool/consensus_client"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// ErrOperationTrackingDeadline is returned when an operation wasn't finalized on every client before the deadline
+var ErrOperationTrackingDeadline = errors.New("operation was not finalized on every client before the deadline")
+
+// OperationTimeline is the propagation history of a submitted operation as seen by a single client
+type OperationTimeline struct {
+	Client string
+	// SeenInPool when the operation was first found in the clients pool, zero if it never was
+	SeenInPool time.Time
+	// IncludedAt when the operation was first found in a block
+	IncludedAt    time.Time
+	IncludedSlot  phase0.Slot
+	IncludedBlock phase0.Root
+	// FinalizedAt when the block containing the operation was first seen as finalized
+	FinalizedAt time.Time
+	// LastErr the last error encountered polling the client
+	LastErr error
+}
+
+// Included returns true if the operation was seen in a block
+func (o *OperationTimeline) Included() bool {
+	return !o.IncludedAt.IsZero()
+}
+
+// Finalized returns true if the block containing the operation was finalized
+func (o *OperationTimeline) Finalized() bool {
+	return !o.FinalizedAt.IsZero()
+}
+
+func (o *OperationTimeline) String() string {
+	return fmt.Sprintf("%s: pool: %s, included: %s (slot %d), finalized: %s", o.Client,
+		formatTimelineTime(o.SeenInPool), formatTimelineTime(o.IncludedAt), o.IncludedSlot, formatTimelineTime(o.FinalizedAt))
+}
+
+// OperationTrackerOpts configures how an operation is tracked
+type OperationTrackerOpts struct {
+	// PollInterval how often every client is polled, defaults to the slot duration
+	PollInterval time.Duration
+	// Deadline how long to track the operation for before giving up
+	Deadline time.Duration
+	// StartSlot the first slot searched for the operation, usually the slot it was submitted at.
+	// Defaults to the current slot when tracking starts, or the clients head at the first poll if the genesis time isn't known.
+	StartSlot phase0.Slot
+}
+
+// operationMatcher knows how to find a single operation in a pool or a block
+type operationMatcher struct {
+	inPool  func(ctx context.Context, client *consensus_client.ConsensusClient) (bool, error)
+	inBlock func(block *spec.VersionedSignedBeaconBlock) bool
+}
+
+// TrackVoluntaryExit follows a submitted voluntary exit through the pool, into a block and to finality on every client
+func (c *ClientManager) TrackVoluntaryExit(ctx context.Context, exit *phase0.SignedVoluntaryExit, opts OperationTrackerOpts) (map[string]*OperationTimeline, error) {
+	root, err := exit.HashTreeRoot()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get voluntary exit hash tree root")
+	}
+	matches := func(exits []*phase0.SignedVoluntaryExit) bool {
+		for _, e := range exits {
+			if r, err := e.HashTreeRoot(); err == nil && r == root {
+				return true
+			}
+		}
+		return false
+	}
+	return c.trackOperation(ctx, opts, operationMatcher{
+		inPool: func(ctx context.Context, client *consensus_client.ConsensusClient) (bool, error) {
+			pool, err := client.GetVoluntaryExitPoolContext(ctx)
+			if err != nil {
+				return false, err
+			}
+			return matches(pool), nil
+		},
+		inBlock: func(block *spec.VersionedSignedBeaconBlock) bool {
+			exits, err := block.VoluntaryExits()
+			return err == nil && matches(exits)
+		},
+	})
+}
+
+// TrackBLSToExecutionChange follows a submitted bls to execution change through the pool, into a block and to finality on every client
+func (c *ClientManager) TrackBLSToExecutionChange(ctx context.Context, change *capella.SignedBLSToExecutionChange, opts OperationTrackerOpts) (map[string]*OperationTimeline, error) {
+	root, err := change.HashTreeRoot()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get bls to execution change hash tree root")
+	}
+	matches := func(changes []*capella.SignedBLSToExecutionChange) bool {
+		for _, c := range changes {
+			if r, err := c.HashTreeRoot(); err == nil && r == root {
+				return true
+			}
+		}
+		return false
+	}
+	return c.trackOperation(ctx, opts, operationMatcher{
+		inPool: func(ctx context.Context, client *consensus_client.ConsensusClient) (bool, error) {
+			pool, err := client.GetBLSToExecutionChangePoolContext(ctx)
+			if err != nil {
+				return false, err
+			}
+			return matches(pool), nil
+		},
+		inBlock: func(block *spec.VersionedSignedBeaconBlock) bool {
+			// pre-capella blocks return an error, they can't contain the change
+			changes, err := block.BLSToExecutionChanges()
+			return err == nil && matches(changes)
+		},
+	})
+}
+
+// trackOperation polls every client until the operation is finalized everywhere or the deadline is reached
+func (c *ClientManager) trackOperation(ctx context.Context, opts OperationTrackerOpts, matcher operationMatcher) (map[string]*OperationTimeline, error) {
+	opts.PollInterval = c.pollIntervalOrDefault(opts.PollInterval)
+	if opts.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Deadline)
+		defer cancel()
+	}
+
+	startSlot := opts.StartSlot
+	if startSlot == 0 && c.SlotDuration > 0 && !c.GenesisTime.IsZero() {
+		startSlot = c.GetCurrentSlot()
+	}
+
+	timelines := make(map[string]*OperationTimeline)
+	var wg sync.WaitGroup
+	for name, client := range c.ConsensusClients {
+		timeline := &OperationTimeline{Client: name}
+		timelines[name] = timeline
+		wg.Add(1)
+		go func(client *consensus_client.ConsensusClient, timeline *OperationTimeline) {
+			defer wg.Done()
+			tracker := &clientOperationTracker{
+				client:   client,
+				matcher:  matcher,
+				timeline: timeline,
+			}
+			if startSlot > 0 {
+				slot := startSlot
+				tracker.nextSlot = &slot
+			}
+			tracker.run(ctx, opts.PollInterval)
+		}(client, timeline)
+	}
+	wg.Wait()
+
+	for _, timeline := range timelines {
+		if !timeline.Finalized() {
+			return timelines, ErrOperationTrackingDeadline
+		}
+	}
+	return timelines, nil
+}
+
+// clientOperationTracker tracks an operation on a single client
+type clientOperationTracker struct {
+	client   *consensus_client.ConsensusClient
+	matcher  operationMatcher
+	timeline *OperationTimeline
+	// nextSlot the next slot to scan for the operation, the head at the first poll if nil
+	nextSlot *phase0.Slot
+}
+
+func (t *clientOperationTracker) run(ctx context.Context, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		if err := t.poll(ctx); err != nil {
+			t.timeline.LastErr = err
+		}
+		if t.timeline.Finalized() {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll does a single round of pool, block and finality checks.
+// A failing pool endpoint doesn't stop the block scan, its error is returned if nothing else failed.
+func (t *clientOperationTracker) poll(ctx context.Context) error {
+	now := time.Now()
+	timeline := t.timeline
+
+	var poolErr error
+	if timeline.SeenInPool.IsZero() && !timeline.Included() {
+		inPool, err := t.matcher.inPool(ctx, t.client)
+		if err != nil {
+			poolErr = errors.Wrap(err, "failed to check pool")
+		} else if inPool {
+			timeline.SeenInPool = now
+		}
+	}
+	if !timeline.Included() {
+		if err := t.scanBlocks(ctx, timeline, now); err != nil {
+			return err
+		}
+	}
+	if timeline.Included() && !timeline.Finalized() {
+		if err := t.checkFinality(ctx, timeline, now); err != nil {
+			return err
+		}
+	}
+	return poolErr
+}
+
+// scanBlocks looks for the operation in every block since the last scan up to the clients head
+func (t *clientOperationTracker) scanBlocks(ctx context.Context, timeline *OperationTimeline, now time.Time) error {
+	head, err := t.client.GetBlockHeaderContext(ctx, "head")
+	if err != nil {
+		return err
+	}
+	headSlot := head.Header.Message.Slot
+	if t.nextSlot == nil {
+		t.nextSlot = &headSlot
+	}
+	for slot := *t.nextSlot; slot <= headSlot; slot++ {
+		block, err := t.client.GetSignedBeaconBlockContext(ctx, fmt.Sprintf("%d", slot))
+		if err != nil {
+			return err
+		}
+		next := slot + 1
+		t.nextSlot = &next
+		if block == nil || !t.matcher.inBlock(block) {
+			continue
+		}
+		root, err := block.Root()
+		if err != nil {
+			return errors.Wrap(err, "failed to get root of block containing the operation")
+		}
+		timeline.IncludedAt = now
+		timeline.IncludedSlot = slot
+		timeline.IncludedBlock = root
+		return nil
+	}
+	return nil
+}
+
+// checkFinality checks the block containing the operation is finalized and is still canonical
+func (t *clientOperationTracker) checkFinality(ctx context.Context, timeline *OperationTimeline, now time.Time) error {
+	block, err := t.client.GetSignedBeaconBlockContext(ctx, fmt.Sprintf("%d", timeline.IncludedSlot))
+	if err != nil {
+		return err
+	}
+	var root phase0.Root
+	if block != nil {
+		if root, err = block.Root(); err != nil {
+			return errors.Wrap(err, "failed to get root of block containing the operation")
+		}
+	}
+	if root != timeline.IncludedBlock {
+		// the block was reorged out, look for the operation again from that slot
+		slot := timeline.IncludedSlot
+		t.nextSlot = &slot
+		timeline.IncludedAt = time.Time{}
+		timeline.IncludedSlot = 0
+		timeline.IncludedBlock = phase0.Root{}
+		return nil
+	}
+	finality, err := t.client.GetFinalityContext(ctx, "head")
+	if err != nil {
+		return err
+	}
+	slotsPerEpoch, err := t.client.GetSlotsPerEpochContext(ctx)
+	if err != nil {
+		return err
+	}
+	if uint64(timeline.IncludedSlot) <= uint64(finality.Finalized.Epoch)*slotsPerEpoch {
+		timeline.FinalizedAt = now
+	}
+	return nil
+}
+
+func formatTimelineTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Format(time.RFC3339)
+}
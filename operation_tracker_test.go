@@ -0,0 +1,104 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"eth-testnet-tool/consensus_client"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTrackVoluntaryExit_Mock(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	// the slots per epoch come from the client, the manager isn't initialised
+	manager := &ClientManager{
+		ConsensusClients: map[string]*consensus_client.ConsensusClient{"mock": client},
+	}
+	exit, err := BuildVoluntaryExitFromClientView(client, validators[4], 0)
+	require.NoError(t, err)
+	require.NoError(t, client.SubmitValidatorExit(exit))
+
+	type trackResult struct {
+		timelines map[string]*OperationTimeline
+		err       error
+	}
+	result := make(chan trackResult)
+	go func() {
+		timelines, err := manager.TrackVoluntaryExit(context.Background(), exit, OperationTrackerOpts{PollInterval: 10 * time.Millisecond, Deadline: 5 * time.Second})
+		result <- trackResult{timelines, err}
+	}()
+
+	// include the exit in a block, then finalize it
+	genesis, err := client.GetBlockHeader("genesis")
+	require.NoError(t, err)
+	block := consensus_client.NewMockCapellaBlock(3, 1, genesis.Root)
+	block.Capella.Message.Body.VoluntaryExits = append(block.Capella.Message.Body.VoluntaryExits, exit)
+	require.NoError(t, node.AddBlock(block))
+	blockRoot, err := block.Root()
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.Finality.Finalized = &phase0.Checkpoint{Epoch: 1, Root: blockRoot}
+	})
+
+	tracked := <-result
+	require.NoError(t, tracked.err)
+	timeline := tracked.timelines["mock"]
+	require.False(t, timeline.SeenInPool.IsZero())
+	require.True(t, timeline.Included())
+	require.Equal(t, phase0.Slot(3), timeline.IncludedSlot)
+	require.Equal(t, blockRoot, timeline.IncludedBlock)
+	require.True(t, timeline.Finalized())
+}
+
+func TestTrackBLSToExecutionChange_MockDeadline(t *testing.T) {
+	_, client, validators := getMockConsensusClient(t)
+	manager := &ClientManager{
+		ConsensusClients: map[string]*consensus_client.ConsensusClient{"mock": client},
+		SlotsPerEpoch:    8,
+	}
+	change, err := BuildSignedBLSToExecutionChangeFromClientView(client, validators[3], [20]byte{0x69})
+	require.NoError(t, err)
+
+	// the change is never submitted so it can't show up anywhere
+	timelines, err := manager.TrackBLSToExecutionChange(context.Background(), change, OperationTrackerOpts{PollInterval: 10 * time.Millisecond, Deadline: 100 * time.Millisecond})
+	require.ErrorIs(t, err, ErrOperationTrackingDeadline)
+	require.True(t, timelines["mock"].SeenInPool.IsZero())
+	require.False(t, timelines["mock"].Included())
+}
+
+func TestTrackBLSToExecutionChange_MockIncludedBeforeTrackingWithFailingPool(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	manager := &ClientManager{
+		ConsensusClients: map[string]*consensus_client.ConsensusClient{"mock": client},
+		SlotsPerEpoch:    8,
+	}
+	change, err := BuildSignedBLSToExecutionChangeFromClientView(client, validators[3], [20]byte{0x69})
+	require.NoError(t, err)
+
+	// the change is included and buried under a newer head before tracking starts
+	genesis, err := client.GetBlockHeader("genesis")
+	require.NoError(t, err)
+	block := consensus_client.NewMockCapellaBlock(3, 1, genesis.Root)
+	block.Capella.Message.Body.BLSToExecutionChanges = append(block.Capella.Message.Body.BLSToExecutionChanges, change)
+	require.NoError(t, node.AddBlock(block))
+	blockRoot, err := block.Root()
+	require.NoError(t, err)
+	require.NoError(t, node.AddBlock(consensus_client.NewMockCapellaBlock(5, 2, blockRoot)))
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.Finality.Finalized = &phase0.Checkpoint{Epoch: 1, Root: blockRoot}
+	})
+
+	// the bls to execution change pool is fetched from the beacon api url, point it somewhere unreachable
+	client.BeaconAPI = "http://127.0.0.1:1"
+
+	timelines, err := manager.TrackBLSToExecutionChange(context.Background(), change, OperationTrackerOpts{PollInterval: 10 * time.Millisecond, Deadline: 5 * time.Second, StartSlot: 1})
+	require.NoError(t, err)
+	timeline := timelines["mock"]
+	require.True(t, timeline.SeenInPool.IsZero())
+	require.True(t, timeline.Included())
+	require.Equal(t, phase0.Slot(3), timeline.IncludedSlot)
+	require.True(t, timeline.Finalized())
+	require.Error(t, timeline.LastErr)
+}
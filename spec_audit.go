@@ -0,0 +1,226 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Sources of the values compared by the spec audit
+const (
+	SpecSource         = "spec"
+	ForkScheduleSource = "fork_schedule"
+	GenesisSource      = "genesis"
+)
+
+// ErrSpecMismatch is returned when the clients disagree on fork versions, fork epochs, domain types or genesis
+var ErrSpecMismatch = errors.New("consensus clients disagree on the chain spec")
+
+// ErrSpecAuditIncomplete is returned when the spec of some clients couldn't be fetched or read
+var ErrSpecAuditIncomplete = errors.New("could not audit the spec of every consensus client")
+
+// SpecMismatch a spec value the clients don't agree on
+type SpecMismatch struct {
+	Source string
+	Key    string
+	// Values the normalised value of every client, missing if the client doesn't have the key
+	Values map[string]string
+	// Critical the clients disagree on a fork version, fork epoch, domain type or genesis, so they can't be on the same chain
+	Critical bool
+}
+
+func (m *SpecMismatch) String() string {
+	var values []string
+	for _, client := range sortedKeys(m.Values) {
+		values = append(values, fmt.Sprintf("%s=%s", client, m.Values[client]))
+	}
+	return fmt.Sprintf("%s %s: %s", m.Source, m.Key, strings.Join(values, ", "))
+}
+
+// SpecAuditReport the result of comparing the spec, fork schedule and genesis of every client
+type SpecAuditReport struct {
+	Mismatches []*SpecMismatch
+	// Errors the clients that couldn't be audited, by client name
+	Errors map[string]error
+}
+
+// Critical returns the mismatches on fork versions, fork epochs, domain types or genesis
+func (r *SpecAuditReport) Critical() []*SpecMismatch {
+	var critical []*SpecMismatch
+	for _, mismatch := range r.Mismatches {
+		if mismatch.Critical {
+			critical = append(critical, mismatch)
+		}
+	}
+	return critical
+}
+
+// Err returns ErrSpecMismatch listing the critical mismatches, ErrSpecAuditIncomplete listing the clients that
+// couldn't be audited if there are none, or nil if every client was audited and agrees
+func (r *SpecAuditReport) Err() error {
+	if critical := r.Critical(); len(critical) > 0 {
+		var mismatches []string
+		for _, mismatch := range critical {
+			mismatches = append(mismatches, mismatch.String())
+		}
+		return errors.Wrap(ErrSpecMismatch, strings.Join(mismatches, "; "))
+	}
+	if len(r.Errors) > 0 {
+		var clientErrors []string
+		for _, client := range sortedKeys(r.Errors) {
+			clientErrors = append(clientErrors, fmt.Sprintf("%s: %s", client, r.Errors[client]))
+		}
+		return errors.Wrap(ErrSpecAuditIncomplete, strings.Join(clientErrors, "; "))
+	}
+	return nil
+}
+
+// AuditSpecs fetches the spec, fork schedule and genesis from every consensus client and compares them key by key.
+// Values are normalised first, so a hex and a decimal value of the same number are equal.
+func (c *ClientManager) AuditSpecs() *SpecAuditReport {
+	return c.AuditSpecsContext(context.Background())
+}
+
+// AuditSpecsContext is AuditSpecs with the requests cancelled with ctx
+func (c *ClientManager) AuditSpecsContext(ctx context.Context) *SpecAuditReport {
+	report := &SpecAuditReport{Errors: make(map[string]error)}
+	values := map[string]map[string]map[string]string{
+		SpecSource:         make(map[string]map[string]string),
+		ForkScheduleSource: make(map[string]map[string]string),
+		GenesisSource:      make(map[string]map[string]string),
+	}
+	endpoints := map[string]string{
+		SpecSource:         "/eth/v1/config/spec",
+		ForkScheduleSource: "/eth/v1/config/fork_schedule",
+		GenesisSource:      "/eth/v1/beacon/genesis",
+	}
+	for _, source := range []string{SpecSource, ForkScheduleSource, GenesisSource} {
+		for client, response := range c.fetchFromAllClients(ctx, endpoints[source]) {
+			if response.err != nil {
+				report.Errors[client] = response.err
+				continue
+			}
+			clientValues, err := specValues(source, response.data)
+			if err != nil {
+				report.Errors[client] = errors.Wrapf(err, "unexpected %s from %s", source, client)
+				continue
+			}
+			values[source][client] = clientValues
+		}
+	}
+	for _, source := range []string{SpecSource, ForkScheduleSource, GenesisSource} {
+		report.Mismatches = append(report.Mismatches, diffSpecValues(source, values[source])...)
+	}
+	return report
+}
+
+// auditSpecs runs the spec audit when building a ClientManager.
+// Critical mismatches and clients that couldn't be audited are an error when strict and a warning otherwise.
+func (c *ClientManager) auditSpecs(ctx context.Context, strict bool) error {
+	report := c.AuditSpecsContext(ctx)
+	for client, err := range report.Errors {
+		log.Warn().Err(err).Str("client", client).Msg("could not audit the spec of consensus client")
+	}
+	for _, mismatch := range report.Mismatches {
+		log.Warn().Bool("critical", mismatch.Critical).Msgf("consensus clients disagree on %s", mismatch)
+	}
+	if strict {
+		return report.Err()
+	}
+	return nil
+}
+
+// specValues flattens the data of a spec, fork schedule or genesis response to normalised values by key
+func specValues(source string, data interface{}) (map[string]string, error) {
+	values := make(map[string]string)
+	switch source {
+	case SpecSource, GenesisSource:
+		object, ok := data.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("expected an object")
+		}
+		for key, value := range object {
+			values[key] = normaliseSpecValue(value)
+		}
+	case ForkScheduleSource:
+		forks, ok := data.([]interface{})
+		if !ok {
+			return nil, errors.New("expected a list of forks")
+		}
+		// forks are keyed by their version, some clients leave out forks that aren't scheduled yet
+		for _, f := range forks {
+			fork, ok := f.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("expected a fork object")
+			}
+			version := strings.ToLower(fmt.Sprintf("%v", fork["current_version"]))
+			values[version+".epoch"] = normaliseSpecValue(fork["epoch"])
+			values[version+".previous_version"] = normaliseSpecValue(fork["previous_version"])
+		}
+	}
+	return values, nil
+}
+
+// normaliseSpecValue returns numbers, decimal or hex, as decimal strings and anything else trimmed and lower cased
+func normaliseSpecValue(value interface{}) string {
+	s := strings.TrimSpace(fmt.Sprintf("%v", value))
+	if hex := strings.TrimPrefix(strings.ToLower(s), "0x"); hex != strings.ToLower(s) {
+		if n, ok := new(big.Int).SetString(hex, 16); ok {
+			return n.String()
+		}
+	}
+	if n, ok := new(big.Int).SetString(s, 10); ok {
+		return n.String()
+	}
+	return strings.ToLower(s)
+}
+
+// isCriticalSpecKey returns true for fork versions, fork epochs, domain types and the genesis
+func isCriticalSpecKey(source string, key string) bool {
+	switch source {
+	case ForkScheduleSource:
+		return true
+	case GenesisSource:
+		return key == "genesis_fork_version" || key == "genesis_validators_root" || key == "genesis_time"
+	}
+	return strings.HasSuffix(key, "_FORK_VERSION") || strings.HasSuffix(key, "_FORK_EPOCH") || strings.HasPrefix(key, "DOMAIN_")
+}
+
+// diffSpecValues compares the values of every client key by key.
+// A key missing from some clients is reported but never critical, not every client serves every key.
+func diffSpecValues(source string, values map[string]map[string]string) []*SpecMismatch {
+	keys := make(map[string]bool)
+	for _, clientValues := range values {
+		for key := range clientValues {
+			keys[key] = true
+		}
+	}
+	var mismatches []*SpecMismatch
+	for _, key := range sortedKeys(keys) {
+		mismatch := &SpecMismatch{Source: source, Key: key, Values: make(map[string]string)}
+		present := make(map[string]bool)
+		missing := false
+		for client, clientValues := range values {
+			value, ok := clientValues[key]
+			if !ok {
+				mismatch.Values[client] = missingField
+				missing = true
+				continue
+			}
+			mismatch.Values[client] = value
+			present[value] = true
+		}
+		if len(present) > 1 {
+			mismatch.Critical = isCriticalSpecKey(source, key)
+		} else if !missing {
+			continue
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	sort.SliceStable(mismatches, func(i, j int) bool { return mismatches[i].Critical && !mismatches[j].Critical })
+	return mismatches
+}
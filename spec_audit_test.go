@@ -0,0 +1,112 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"eth-testnet-tool/consensus_client"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newSpecAuditManager(t *testing.T, modify map[string]func(state *consensus_client.MockBeaconState)) *ClientManager {
+	validators, err := getTestValidators(GenesisValidatorCount)
+	require.NoError(t, err)
+	manager := &ClientManager{ConsensusClients: make(map[string]*consensus_client.ConsensusClient)}
+	for _, name := range []string{"lighthouse", "prysm", "teku"} {
+		state := consensus_client.NewMockBeaconState(validators)
+		if modify[name] != nil {
+			modify[name](state)
+		}
+		node := consensus_client.NewMockBeaconNode(state)
+		t.Cleanup(node.Close)
+		client, err := node.ConsensusClient(name)
+		require.NoError(t, err)
+		manager.ConsensusClients[name] = client
+	}
+	return manager
+}
+
+func TestAuditSpecs_Mock(t *testing.T) {
+	manager := newSpecAuditManager(t, map[string]func(state *consensus_client.MockBeaconState){
+		"prysm": func(state *consensus_client.MockBeaconState) {
+			// the same values formatted differently and a client specific value
+			state.Spec["SECONDS_PER_SLOT"] = "0x6"
+			state.Spec["DOMAIN_VOLUNTARY_EXIT"] = "0x04000000"
+			state.Spec["PRYSM_ONLY_VALUE"] = "1"
+		},
+		"teku": func(state *consensus_client.MockBeaconState) {
+			state.Spec["CAPELLA_FORK_VERSION"] = "0x03000002"
+			state.Spec["DOMAIN_BLS_TO_EXECUTION_CHANGE"] = "0x0b000000"
+			state.ForkSchedule[3].Epoch = 5
+		},
+	})
+
+	report := manager.AuditSpecs()
+	require.Empty(t, report.Errors)
+	mismatches := make(map[string]*SpecMismatch)
+	for _, mismatch := range report.Mismatches {
+		mismatches[mismatch.Source+" "+mismatch.Key] = mismatch
+	}
+	require.Len(t, mismatches, 4, report.Mismatches)
+	require.True(t, mismatches["spec CAPELLA_FORK_VERSION"].Critical)
+	require.True(t, mismatches["spec DOMAIN_BLS_TO_EXECUTION_CHANGE"].Critical)
+	require.True(t, mismatches["fork_schedule 0x03000001.epoch"].Critical)
+	require.Equal(t, "5", mismatches["fork_schedule 0x03000001.epoch"].Values["teku"])
+	require.False(t, mismatches["spec PRYSM_ONLY_VALUE"].Critical)
+	require.Equal(t, missingField, mismatches["spec PRYSM_ONLY_VALUE"].Values["lighthouse"])
+	require.Len(t, report.Critical(), 3)
+	require.ErrorIs(t, report.Err(), ErrSpecMismatch)
+
+	require.ErrorIs(t, manager.auditSpecs(context.Background(), true), ErrSpecMismatch)
+	require.NoError(t, manager.auditSpecs(context.Background(), false))
+}
+
+func TestAuditSpecs_MockGenesis(t *testing.T) {
+	manager := newSpecAuditManager(t, map[string]func(state *consensus_client.MockBeaconState){
+		"lighthouse": func(state *consensus_client.MockBeaconState) {
+			state.Genesis.GenesisForkVersion = phase0.Version{0x00, 0x00, 0x00, 0x02}
+		},
+	})
+	report := manager.AuditSpecs()
+	require.Len(t, report.Mismatches, 1)
+	require.Equal(t, GenesisSource, report.Mismatches[0].Source)
+	require.ErrorIs(t, manager.auditSpecs(context.Background(), true), ErrSpecMismatch)
+
+	// clients that agree pass a strict audit
+	require.NoError(t, newSpecAuditManager(t, nil).auditSpecs(context.Background(), true))
+
+	// a different genesis time or validators root is a different chain
+	manager = newSpecAuditManager(t, map[string]func(state *consensus_client.MockBeaconState){
+		"prysm": func(state *consensus_client.MockBeaconState) {
+			state.Genesis.GenesisTime = time.Unix(1690000012, 0)
+		},
+		"teku": func(state *consensus_client.MockBeaconState) {
+			state.Genesis.GenesisValidatorsRoot = phase0.Root{0x43}
+		},
+	})
+	report = manager.AuditSpecs()
+	require.Len(t, report.Critical(), 2, report.Mismatches)
+	require.ErrorIs(t, manager.auditSpecs(context.Background(), true), ErrSpecMismatch)
+}
+
+func TestAuditSpecs_MockUnreachableClient(t *testing.T) {
+	manager := newSpecAuditManager(t, nil)
+	manager.ConsensusClients["teku"].BeaconAPI = "http://127.0.0.1:1"
+
+	report := manager.AuditSpecs()
+	require.Contains(t, report.Errors, "teku")
+	require.Empty(t, report.Critical())
+	require.ErrorIs(t, report.Err(), ErrSpecAuditIncomplete)
+	require.ErrorIs(t, manager.auditSpecs(context.Background(), true), ErrSpecAuditIncomplete)
+	require.NoError(t, manager.auditSpecs(context.Background(), false))
+}
+
+func TestNormaliseSpecValue(t *testing.T) {
+	require.Equal(t, "6", normaliseSpecValue("0x6"))
+	require.Equal(t, "6", normaliseSpecValue(" 6 "))
+	require.Equal(t, "67108865", normaliseSpecValue("0x04000001"))
+	require.Equal(t, "18446744073709551615", normaliseSpecValue("18446744073709551615"))
+	require.Equal(t, "minimal", normaliseSpecValue("Minimal"))
+	require.Equal(t, "0xzz", normaliseSpecValue("0xZZ"))
+}
@@ -12,10 +12,19 @@ type ConsensusClientJSON struct {
 	APIEndpoint string `json:"api-endpoint"`
 }
 
+// ValidatorClientJSON the json representation of a validator client's keymanager api
+type ValidatorClientJSON struct {
+	Name               string `json:"name"`
+	KeymanagerEndpoint string `json:"keymanager-endpoint"`
+	// Token the bearer token of the keymanager api
+	Token string `json:"token"`
+}
+
 // TestnetClientsJSON the json representation of the TestnetClients
 type TestnetClientsJSON struct {
 	ConsensusClients []ConsensusClientJSON `json:"consensus-clients"`
 	ExecutionClients []ExecutionClientJSON `json:"execution-clients"`
+	ValidatorClients []ValidatorClientJSON `json:"validator-clients,omitempty"`
 }
 
 // TestnetConfigJSON the json structure holding testnet-parameters for various utilities
@@ -25,6 +34,7 @@ type TestnetConfigJSON struct {
 	DepositContractAddress   string            `json:"deposit-contract-address,omitempty"`
 	ExecutionAccountMnemonic string            `json:"execution-account-mnemonic"`
 	ExecutionPremines        map[string]uint64 `json:"premines"`
+	StrictSpecAudit          bool              `json:"strict-spec-audit,omitempty"`
 }
 
 // TestnetConfig contains information about the running testnet.
@@ -40,4 +50,6 @@ type TestnetConfig struct {
 	ExecutionAccountMnemonic string
 	// ExecutionPremines the seeded premine addresses and their values
 	ExecutionPremines map[string]uint64
+	// StrictSpecAudit refuse to create the ClientManager when the consensus clients disagree on the spec instead of warning
+	StrictSpecAudit bool
 }
@@ -0,0 +1,209 @@
+package validator
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+)
+
+const (
+	// KDFScrypt and KDFPBKDF2 are the key derivation functions of EIP-2335 keystores
+	KDFScrypt = "scrypt"
+	KDFPBKDF2 = "pbkdf2"
+
+	keystoreVersion = 4
+)
+
+// ErrInvalidKeystorePassword is returned when the checksum of a keystore doesn't match the password
+var ErrInvalidKeystorePassword = errors.New("invalid keystore password")
+
+// KeystoreOpts configures the encryption of a keystore
+type KeystoreOpts struct {
+	// KDF KDFScrypt or KDFPBKDF2, defaults to scrypt
+	KDF string
+	// LightKDF uses a much cheaper work factor, only for throwaway keys in tests and devnets
+	LightKDF bool
+	// Description is stored unencrypted in the keystore
+	Description string
+}
+
+// Keystore an EIP-2335 keystore
+type Keystore struct {
+	Crypto      KeystoreCrypto `json:"crypto"`
+	Description string         `json:"description"`
+	PubKey      string         `json:"pubkey"`
+	Path        string         `json:"path"`
+	UUID        string         `json:"uuid"`
+	Version     int            `json:"version"`
+}
+
+// KeystoreCrypto the kdf, checksum and cipher modules of a keystore
+type KeystoreCrypto struct {
+	KDF      KeystoreModule `json:"kdf"`
+	Checksum KeystoreModule `json:"checksum"`
+	Cipher   KeystoreModule `json:"cipher"`
+}
+
+// KeystoreModule a function with its params and message
+type KeystoreModule struct {
+	Function string         `json:"function"`
+	Params   KeystoreParams `json:"params"`
+	Message  string         `json:"message"`
+}
+
+// KeystoreParams the union of the params of every module, unused params are omitted
+type KeystoreParams struct {
+	DKLen int    `json:"dklen,omitempty"`
+	N     int    `json:"n,omitempty"`
+	R     int    `json:"r,omitempty"`
+	P     int    `json:"p,omitempty"`
+	C     int    `json:"c,omitempty"`
+	PRF   string `json:"prf,omitempty"`
+	Salt  string `json:"salt,omitempty"`
+	IV    string `json:"iv,omitempty"`
+}
+
+// EncryptKeystore encrypts the secret with the password into a keystore, path is the EIP-2334 path of the key, if known
+func EncryptKeystore(secret []byte, pubKey []byte, path string, password string, opts KeystoreOpts) (*Keystore, error) {
+	salt := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "failed to generate salt")
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, errors.Wrap(err, "failed to generate iv")
+	}
+
+	kdf := KeystoreModule{Function: opts.KDF, Params: KeystoreParams{DKLen: 32, Salt: hex.EncodeToString(salt)}}
+	switch opts.KDF {
+	case KDFScrypt, "":
+		kdf.Function = KDFScrypt
+		kdf.Params.N, kdf.Params.R, kdf.Params.P = 262144, 8, 1
+		if opts.LightKDF {
+			kdf.Params.N = 4096
+		}
+	case KDFPBKDF2:
+		kdf.Params.C, kdf.Params.PRF = 262144, "hmac-sha256"
+		if opts.LightKDF {
+			kdf.Params.C = 4096
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kdf: %s", opts.KDF)
+	}
+	decryptionKey, err := deriveKeystoreKey(kdf, password)
+	if err != nil {
+		return nil, err
+	}
+
+	cipherText, err := aes128CTR(decryptionKey[:16], iv, secret)
+	if err != nil {
+		return nil, err
+	}
+	return &Keystore{
+		Crypto: KeystoreCrypto{
+			KDF:      kdf,
+			Checksum: KeystoreModule{Function: "sha256", Message: hex.EncodeToString(keystoreChecksum(decryptionKey, cipherText))},
+			Cipher:   KeystoreModule{Function: "aes-128-ctr", Params: KeystoreParams{IV: hex.EncodeToString(iv)}, Message: hex.EncodeToString(cipherText)},
+		},
+		Description: opts.Description,
+		PubKey:      hex.EncodeToString(pubKey),
+		Path:        path,
+		UUID:        uuid.NewString(),
+		Version:     keystoreVersion,
+	}, nil
+}
+
+// Decrypt returns the secret of the keystore, or ErrInvalidKeystorePassword if the checksum doesn't match
+func (k *Keystore) Decrypt(password string) ([]byte, error) {
+	if k.Version != keystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version: %d", k.Version)
+	}
+	if k.Crypto.Checksum.Function != "sha256" {
+		return nil, fmt.Errorf("unsupported checksum function: %s", k.Crypto.Checksum.Function)
+	}
+	if k.Crypto.Cipher.Function != "aes-128-ctr" {
+		return nil, fmt.Errorf("unsupported cipher: %s", k.Crypto.Cipher.Function)
+	}
+	decryptionKey, err := deriveKeystoreKey(k.Crypto.KDF, password)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := hex.DecodeString(k.Crypto.Cipher.Message)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid cipher message")
+	}
+	checksum, err := hex.DecodeString(k.Crypto.Checksum.Message)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid checksum message")
+	}
+	if !bytes.Equal(checksum, keystoreChecksum(decryptionKey, cipherText)) {
+		return nil, ErrInvalidKeystorePassword
+	}
+	iv, err := hex.DecodeString(k.Crypto.Cipher.Params.IV)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid cipher iv")
+	}
+	return aes128CTR(decryptionKey[:16], iv, cipherText)
+}
+
+// deriveKeystoreKey runs the kdf module over the processed password
+func deriveKeystoreKey(kdf KeystoreModule, password string) ([]byte, error) {
+	salt, err := hex.DecodeString(kdf.Params.Salt)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid kdf salt")
+	}
+	if kdf.Params.DKLen < 32 {
+		return nil, fmt.Errorf("kdf dklen must be at least 32, got %d", kdf.Params.DKLen)
+	}
+	processed := []byte(processKeystorePassword(password))
+	switch kdf.Function {
+	case KDFScrypt:
+		key, err := scrypt.Key(processed, salt, kdf.Params.N, kdf.Params.R, kdf.Params.P, kdf.Params.DKLen)
+		return key, errors.Wrap(err, "failed to derive scrypt key")
+	case KDFPBKDF2:
+		if kdf.Params.PRF != "hmac-sha256" {
+			return nil, fmt.Errorf("unsupported pbkdf2 prf: %s", kdf.Params.PRF)
+		}
+		return pbkdf2.Key(processed, salt, kdf.Params.C, kdf.Params.DKLen, sha256.New), nil
+	default:
+		return nil, fmt.Errorf("unsupported kdf: %s", kdf.Function)
+	}
+}
+
+// processKeystorePassword normalises the password to NFKD and strips the C0, C1 and Delete control codes as EIP-2335 requires
+func processKeystorePassword(password string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || (r >= 0x7f && r <= 0x9f) {
+			return -1
+		}
+		return r
+	}, norm.NFKD.String(password))
+}
+
+func keystoreChecksum(decryptionKey []byte, cipherText []byte) []byte {
+	checksum := sha256.Sum256(append(append([]byte{}, decryptionKey[16:32]...), cipherText...))
+	return checksum[:]
+}
+
+func aes128CTR(key []byte, iv []byte, in []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create aes cipher")
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid iv length: %d", len(iv))
+	}
+	out := make([]byte, len(in))
+	cipher.NewCTR(block, iv).XORKeyStream(out, in)
+	return out, nil
+}
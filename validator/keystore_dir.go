@@ -0,0 +1,262 @@
+package validator
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// KeystoreLayout the directory layout a validator client expects its keystores and passwords in
+type KeystoreLayout string
+
+const (
+	// LayoutLighthouse validators/0x<pubkey>/voting-keystore.json with the password in secrets/0x<pubkey>
+	LayoutLighthouse KeystoreLayout = "lighthouse"
+	// LayoutTeku keys/<name>.json with the password in passwords/<name>.txt
+	LayoutTeku KeystoreLayout = "teku"
+	// LayoutPrysm keystore-<name>.json files sharing the password in password.txt, for `validator accounts import`
+	LayoutPrysm KeystoreLayout = "prysm"
+	// LayoutLodestar keystores/0x<pubkey>/voting-keystore.json with the password in secrets/0x<pubkey>
+	LayoutLodestar KeystoreLayout = "lodestar"
+	// LayoutNimbus validators/0x<pubkey>/keystore.json with the password in secrets/0x<pubkey>
+	LayoutNimbus KeystoreLayout = "nimbus"
+
+	// withdrawalKeystoreDir holds the withdrawal keystores in every layout, no validator client loads them
+	withdrawalKeystoreDir = "withdrawal_keystores"
+	sharedPasswordFile    = "password.txt"
+)
+
+// ExportOpts configures ExportKeystores
+type ExportOpts struct {
+	Layout   KeystoreLayout
+	Password string
+	Keystore KeystoreOpts
+	// WithdrawalKeys also exports the withdrawal keys into withdrawal_keystores
+	WithdrawalKeys bool
+}
+
+// validatorKeyPath and withdrawalKeyPath are the EIP-2334 paths of the keys of a mnemonic account
+func validatorKeyPath(account uint64) string {
+	return fmt.Sprintf("m/12381/3600/%d/0/0", account)
+}
+
+func withdrawalKeyPath(account uint64) string {
+	return fmt.Sprintf("m/12381/3600/%d/0", account)
+}
+
+// keystoreFileName the name eth2.0-deposit-cli gives keystores, derived from the key path
+func keystoreFileName(path string) string {
+	return "keystore-" + strings.ReplaceAll(path, "/", "_")
+}
+
+// ExportKeystores writes the validator keys, and optionally the withdrawal keys, as EIP-2335 keystores in the layout of a validator client
+func ExportKeystores(validators []*Validator, dir string, opts ExportOpts) error {
+	for _, v := range validators {
+		if err := exportValidatorKeystore(v, dir, opts); err != nil {
+			return errors.Wrapf(err, "failed to export keystore of validator %d", v.ValidatorIndex)
+		}
+		if !opts.WithdrawalKeys || v.WithdrawalKey == nil {
+			continue
+		}
+		path := withdrawalKeyPath(v.ValidatorIndex)
+		keystore, err := encryptKey(v.WithdrawalKey, path, opts)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encrypt withdrawal key of validator %d", v.ValidatorIndex)
+		}
+		if err := writeKeystore(filepath.Join(dir, withdrawalKeystoreDir, keystoreFileName(path)+".json"), keystore); err != nil {
+			return err
+		}
+	}
+	if opts.WithdrawalKeys {
+		if err := writeSecret(filepath.Join(dir, withdrawalKeystoreDir, sharedPasswordFile), opts.Password); err != nil {
+			return err
+		}
+	}
+	if opts.Layout == LayoutPrysm {
+		return writeSecret(filepath.Join(dir, sharedPasswordFile), opts.Password)
+	}
+	return nil
+}
+
+// ValidatorKeystore encrypts the validator key with the password into a keystore carrying its EIP-2334 path
+func (v *Validator) ValidatorKeystore(password string, opts KeystoreOpts) (*Keystore, error) {
+	if v.ValidatorKey == nil {
+		return nil, errors.New("the validator key isn't in memory")
+	}
+	return EncryptKeystore(v.ValidatorKey.Marshal(), v.ValidatorPublicKey[:], validatorKeyPath(v.ValidatorIndex), password, opts)
+}
+
+func exportValidatorKeystore(v *Validator, dir string, opts ExportOpts) error {
+	path := validatorKeyPath(v.ValidatorIndex)
+	keystore, err := v.ValidatorKeystore(opts.Password, opts.Keystore)
+	if err != nil {
+		return err
+	}
+	pubKey := "0x" + keystore.PubKey
+	switch opts.Layout {
+	case LayoutLighthouse:
+		if err := writeKeystore(filepath.Join(dir, "validators", pubKey, "voting-keystore.json"), keystore); err != nil {
+			return err
+		}
+		return writeSecret(filepath.Join(dir, "secrets", pubKey), opts.Password)
+	case LayoutLodestar:
+		if err := writeKeystore(filepath.Join(dir, "keystores", pubKey, "voting-keystore.json"), keystore); err != nil {
+			return err
+		}
+		return writeSecret(filepath.Join(dir, "secrets", pubKey), opts.Password)
+	case LayoutNimbus:
+		if err := writeKeystore(filepath.Join(dir, "validators", pubKey, "keystore.json"), keystore); err != nil {
+			return err
+		}
+		return writeSecret(filepath.Join(dir, "secrets", pubKey), opts.Password)
+	case LayoutTeku:
+		name := keystoreFileName(path)
+		if err := writeKeystore(filepath.Join(dir, "keys", name+".json"), keystore); err != nil {
+			return err
+		}
+		return writeSecret(filepath.Join(dir, "passwords", name+".txt"), opts.Password)
+	case LayoutPrysm:
+		return writeKeystore(filepath.Join(dir, keystoreFileName(path)+".json"), keystore)
+	default:
+		return fmt.Errorf("unknown keystore layout: %s", opts.Layout)
+	}
+}
+
+func encryptKey(key e2types.PrivateKey, path string, opts ExportOpts) (*Keystore, error) {
+	return EncryptKeystore(key.Marshal(), key.PublicKey().Marshal(), path, opts.Password, opts.Keystore)
+}
+
+func writeKeystore(file string, keystore *Keystore) error {
+	data, err := json.MarshalIndent(keystore, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal keystore")
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return errors.Wrap(err, "failed to create keystore directory")
+	}
+	return errors.Wrapf(os.WriteFile(file, data, 0o600), "failed to write keystore %s", file)
+}
+
+func writeSecret(file string, password string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return errors.Wrap(err, "failed to create secrets directory")
+	}
+	return errors.Wrapf(os.WriteFile(file, []byte(password), 0o600), "failed to write secret %s", file)
+}
+
+// ImportValidatorsFromKeystores loads every EIP-2335 keystore under dir, in any of the validator client layouts.
+// The password of a keystore is read from its secrets or passwords file, or a password.txt next to it, falling back to password.
+// Validator keys are paired with the withdrawal keystore of the same account when there is one, otherwise WithdrawalKey is nil.
+// ValidatorIndex is the account of the keystore path, keystores without a path are numbered after the highest account.
+func ImportValidatorsFromKeystores(dir string, password string) ([]*Validator, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(file string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read keystore directory %s", dir)
+	}
+	sort.Strings(files)
+
+	validatorKeys := make(map[uint64]e2types.PrivateKey)
+	withdrawalKeys := make(map[uint64]e2types.PrivateKey)
+	var unindexed []*Validator
+	for _, file := range files {
+		keystore, err := readKeystore(file)
+		if err != nil {
+			return nil, err
+		}
+		if keystore == nil {
+			continue // some other json file
+		}
+		secret, err := keystore.Decrypt(keystorePassword(dir, file, keystore, password))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decrypt keystore %s", file)
+		}
+		key, err := e2types.BLSPrivateKeyFromBytes(secret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid key in keystore %s", file)
+		}
+		if keystore.PubKey != "" && keystore.PubKey != hex.EncodeToString(key.PublicKey().Marshal()) {
+			return nil, fmt.Errorf("keystore %s doesn't match its pubkey", file)
+		}
+
+		account, withdrawal, ok := parseKeyPath(keystore.Path)
+		switch {
+		case !ok:
+			unindexed = append(unindexed, newValidator(0, key, nil))
+		case withdrawal:
+			withdrawalKeys[account] = key
+		default:
+			if _, exists := validatorKeys[account]; exists {
+				return nil, fmt.Errorf("keystore %s duplicates the key of account %d", file, account)
+			}
+			validatorKeys[account] = key
+		}
+	}
+
+	next := uint64(0)
+	result := make([]*Validator, 0, len(validatorKeys)+len(unindexed))
+	for account, key := range validatorKeys {
+		result = append(result, newValidator(account, key, withdrawalKeys[account]))
+		next = max(next, account+1)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ValidatorIndex < result[j].ValidatorIndex })
+	for _, v := range unindexed {
+		v.ValidatorIndex = next
+		next++
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+// readKeystore returns nil without an error for json files that aren't keystores
+func readKeystore(file string) (*Keystore, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", file)
+	}
+	var keystore Keystore
+	if err := json.Unmarshal(data, &keystore); err != nil || keystore.Crypto.Cipher.Message == "" {
+		return nil, nil
+	}
+	return &keystore, nil
+}
+
+// keystorePassword looks for the password of the keystore in the files the client layouts put it in
+func keystorePassword(dir string, file string, keystore *Keystore, password string) string {
+	name := strings.TrimSuffix(filepath.Base(file), ".json")
+	candidates := []string{
+		filepath.Join(dir, "secrets", "0x"+keystore.PubKey),
+		filepath.Join(dir, "secrets", keystore.PubKey),
+		filepath.Join(dir, "passwords", name+".txt"),
+		filepath.Join(filepath.Dir(file), sharedPasswordFile),
+	}
+	for _, candidate := range candidates {
+		if secret, err := os.ReadFile(candidate); err == nil {
+			return strings.TrimRight(string(secret), "\r\n")
+		}
+	}
+	return password
+}
+
+// parseKeyPath returns the account of an EIP-2334 validator or withdrawal key path
+func parseKeyPath(path string) (uint64, bool, bool) {
+	var account uint64
+	if _, err := fmt.Sscanf(path, "m/12381/3600/%d/0", &account); err != nil || withdrawalKeyPath(account) != path && validatorKeyPath(account) != path {
+		return 0, false, false
+	}
+	return account, path == withdrawalKeyPath(account), true
+}
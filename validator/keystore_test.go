@@ -0,0 +1,97 @@
+package validator
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeystore_EIP2335TestVector(t *testing.T) {
+	keystore := &Keystore{
+		Crypto: KeystoreCrypto{
+			KDF: KeystoreModule{Function: KDFPBKDF2, Params: KeystoreParams{
+				DKLen: 32,
+				C:     262144,
+				PRF:   "hmac-sha256",
+				Salt:  "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3",
+			}},
+			Checksum: KeystoreModule{Function: "sha256", Message: "8a9f5d9912ed7e75ea794bc5a89bca5f193721d30868ade6f73043c6ea6febf1"},
+			Cipher: KeystoreModule{
+				Function: "aes-128-ctr",
+				Params:   KeystoreParams{IV: "264daa3f303d7259501c93d997d84fe6"},
+				Message:  "cee03fde2af33149775b7223e7845e4fb2c8ae1792e5f99fe9ecf474cc8c16ad",
+			},
+		},
+		Version: 4,
+	}
+	secret, err := keystore.Decrypt("𝔱𝔢𝔰𝔱𝔭𝔞𝔰𝔰𝔴𝔬𝔯𝔡🔑")
+	require.NoError(t, err)
+	require.Equal(t, "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f", hex.EncodeToString(secret))
+}
+
+func TestKeystore_EncryptDecrypt(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	for _, kdf := range []string{KDFScrypt, KDFPBKDF2} {
+		keystore, err := EncryptKeystore(secret, []byte{0x01}, "m/12381/3600/0/0/0", "pass\u0007word", KeystoreOpts{KDF: kdf, LightKDF: true})
+		require.NoError(t, err)
+		require.Equal(t, kdf, keystore.Crypto.KDF.Function)
+
+		// control codes are stripped from the password
+		decrypted, err := keystore.Decrypt("password")
+		require.NoError(t, err)
+		require.Equal(t, secret, decrypted)
+
+		_, err = keystore.Decrypt("wrong")
+		require.ErrorIs(t, err, ErrInvalidKeystorePassword)
+	}
+	_, err := EncryptKeystore(secret, nil, "", "password", KeystoreOpts{KDF: "argon2"})
+	require.Error(t, err)
+}
+
+func TestKeystore_ExportImportLayouts(t *testing.T) {
+	validators, err := GetValidatorsFromMnemonic(ValidatorMnemonic, 3, 6)
+	require.NoError(t, err)
+	for _, layout := range []KeystoreLayout{LayoutLighthouse, LayoutTeku, LayoutPrysm, LayoutLodestar, LayoutNimbus} {
+		t.Run(string(layout), func(t *testing.T) {
+			dir := t.TempDir()
+			opts := ExportOpts{Layout: layout, Password: "devnet", Keystore: KeystoreOpts{LightKDF: true}, WithdrawalKeys: true}
+			require.NoError(t, ExportKeystores(validators, dir, opts))
+
+			// the passwords are in the layout, so no fallback password is needed
+			imported, err := ImportValidatorsFromKeystores(dir, "")
+			require.NoError(t, err)
+			require.Len(t, imported, len(validators))
+			for i, v := range imported {
+				require.Equal(t, validators[i].ValidatorIndex, v.ValidatorIndex)
+				require.Equal(t, validators[i].ValidatorPublicKey, v.ValidatorPublicKey)
+				require.Equal(t, validators[i].ValidatorKey.Marshal(), v.ValidatorKey.Marshal())
+				require.Equal(t, validators[i].WithdrawalKey.Marshal(), v.WithdrawalKey.Marshal())
+			}
+		})
+	}
+}
+
+func TestKeystore_ImportWithoutPathOrWithdrawalKey(t *testing.T) {
+	validators, err := GetValidatorsFromMnemonic(ValidatorMnemonic, 0, 2)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, ExportKeystores(validators[:1], dir, ExportOpts{Layout: LayoutLighthouse, Password: "devnet", Keystore: KeystoreOpts{LightKDF: true}}))
+
+	// a keystore from someone else's devnet without a derivation path or a secrets file
+	keystore, err := EncryptKeystore(validators[1].ValidatorKey.Marshal(), validators[1].ValidatorKey.PublicKey().Marshal(), "", "other", KeystoreOpts{KDF: KDFPBKDF2, LightKDF: true})
+	require.NoError(t, err)
+	require.NoError(t, writeKeystore(filepath.Join(dir, "external", "keystore.json"), keystore))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "validator_definitions.json"), []byte(`{"enabled": true}`), 0o600))
+
+	_, err = ImportValidatorsFromKeystores(dir, "wrong")
+	require.ErrorIs(t, err, ErrInvalidKeystorePassword)
+
+	imported, err := ImportValidatorsFromKeystores(dir, "other")
+	require.NoError(t, err)
+	require.Len(t, imported, 2)
+	require.Nil(t, imported[0].WithdrawalKey)
+	require.Equal(t, validators[1].ValidatorPublicKey, imported[1].ValidatorPublicKey)
+	require.Equal(t, uint64(1), imported[1].ValidatorIndex)
+}
@@ -0,0 +1,170 @@
+package validator
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MockWeb3Signer is an in process stand-in for a Web3Signer holding the keys of validators.
+// Like Web3Signer it recomputes the signing root from the message and fork info and refuses requests where they don't match.
+type MockWeb3Signer struct {
+	Server *httptest.Server
+	// SlotsPerEpoch is used to find the fork of a block, defaults to 32
+	SlotsPerEpoch uint64
+
+	lock     sync.Mutex
+	keys     *KeySigner
+	pubKeys  []phase0.BLSPubKey
+	requests []*SigningRequest
+}
+
+// NewMockWeb3Signer starts a signer with the validator keys of the validators, their withdrawal keys can sign too
+func NewMockWeb3Signer(validators ...*Validator) *MockWeb3Signer {
+	m := &MockWeb3Signer{SlotsPerEpoch: 32, keys: NewKeySigner(validators...)}
+	for _, v := range validators {
+		m.pubKeys = append(m.pubKeys, v.ValidatorPublicKey)
+	}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	return m
+}
+
+// Signer returns a Web3Signer client for the mock
+func (m *MockWeb3Signer) Signer() *Web3Signer {
+	return NewWeb3Signer(m.Server.URL)
+}
+
+// Requests returns every signing request the mock signed, in order
+func (m *MockWeb3Signer) Requests() []*SigningRequest {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]*SigningRequest{}, m.requests...)
+}
+
+func (m *MockWeb3Signer) Close() {
+	m.Server.Close()
+}
+
+func (m *MockWeb3Signer) serve(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/upcheck":
+		_, _ = w.Write([]byte("OK"))
+	case r.URL.Path == "/api/v1/eth2/publicKeys":
+		_ = json.NewEncoder(w).Encode(m.pubKeys)
+	case strings.HasPrefix(r.URL.Path, "/api/v1/eth2/sign/") && r.Method == http.MethodPost:
+		m.sign(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *MockWeb3Signer) sign(w http.ResponseWriter, r *http.Request) {
+	pubKeyBytes, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/v1/eth2/sign/"), "0x"))
+	if err != nil || len(pubKeyBytes) != phase0.PublicKeyLength {
+		http.Error(w, "invalid identifier", http.StatusBadRequest)
+		return
+	}
+	var request SigningRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	request.PubKey = phase0.BLSPubKey(pubKeyBytes)
+	signingRoot, err := m.signingRoot(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if signingRoot != request.SigningRoot {
+		http.Error(w, fmt.Sprintf("signing root %s doesn't match the message, expected %s", request.SigningRoot, signingRoot), http.StatusBadRequest)
+		return
+	}
+	signature, err := m.keys.Sign(r.Context(), &request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	m.lock.Lock()
+	m.requests = append(m.requests, &request)
+	m.lock.Unlock()
+	_ = json.NewEncoder(w).Encode(map[string]phase0.BLSSignature{"signature": signature})
+}
+
+// signingRoot computes the signing root of the message in the request the way the signer would
+func (m *MockWeb3Signer) signingRoot(request *SigningRequest) (phase0.Root, error) {
+	var messageRoot [32]byte
+	var domainType common.BLSDomainType
+	var epoch phase0.Epoch
+	var err error
+	switch {
+	case request.Type == SigningTypeDeposit && request.Deposit != nil:
+		return depositSigningRoot(request.Deposit)
+	case request.Type == SigningTypeVoluntaryExit && request.VoluntaryExit != nil:
+		messageRoot, err = request.VoluntaryExit.HashTreeRoot()
+		domainType, epoch = common.BLSDomainType{0x04}, request.VoluntaryExit.Epoch
+	case request.Type == SigningTypeAttestation && request.Attestation != nil:
+		messageRoot, err = request.Attestation.HashTreeRoot()
+		domainType, epoch = common.BLSDomainType{0x01}, request.Attestation.Target.Epoch
+	case request.Type == SigningTypeBlockV2 && request.BeaconBlock != nil:
+		var header *phase0.BeaconBlockHeader
+		if header, err = request.BeaconBlock.Header(); err != nil {
+			return phase0.Root{}, err
+		}
+		messageRoot, err = header.HashTreeRoot()
+		domainType, epoch = common.BLSDomainType{0x00}, phase0.Epoch(uint64(header.Slot)/m.SlotsPerEpoch)
+	case request.Type == SigningTypeBLSToExecutionChange && request.BLSToExecutionChange != nil:
+		// bls to execution changes are signed with the genesis fork version, which the fork info has to carry
+		messageRoot, err = request.BLSToExecutionChange.HashTreeRoot()
+		domainType = common.BLSDomainType{0x0a}
+	default:
+		return phase0.Root{}, fmt.Errorf("unsupported signing request type %s", request.Type)
+	}
+	if err != nil {
+		return phase0.Root{}, err
+	}
+	if request.ForkInfo == nil || request.ForkInfo.Fork == nil {
+		return phase0.Root{}, fmt.Errorf("%s requests need fork info", request.Type)
+	}
+	version := request.ForkInfo.Fork.CurrentVersion
+	if epoch < request.ForkInfo.Fork.Epoch {
+		version = request.ForkInfo.Fork.PreviousVersion
+	}
+	domain := common.ComputeDomain(domainType, common.Version(version), common.Root(request.ForkInfo.GenesisValidatorsRoot))
+	return phase0.Root(common.ComputeSigningRoot(messageRoot, domain)), nil
+}
+
+func depositSigningRoot(deposit *SigningDeposit) (phase0.Root, error) {
+	var message phase0.DepositMessage
+	var genesisForkVersion phase0.Version
+	pubKey, err := hex.DecodeString(strings.TrimPrefix(deposit.PubKey, "0x"))
+	if err != nil || len(pubKey) != phase0.PublicKeyLength {
+		return phase0.Root{}, fmt.Errorf("invalid deposit pubkey %s", deposit.PubKey)
+	}
+	message.PublicKey = phase0.BLSPubKey(pubKey)
+	if message.WithdrawalCredentials, err = hex.DecodeString(strings.TrimPrefix(deposit.WithdrawalCredentials, "0x")); err != nil {
+		return phase0.Root{}, fmt.Errorf("invalid deposit withdrawal credentials %s", deposit.WithdrawalCredentials)
+	}
+	amount, err := strconv.ParseUint(deposit.Amount, 10, 64)
+	if err != nil {
+		return phase0.Root{}, fmt.Errorf("invalid deposit amount %s", deposit.Amount)
+	}
+	message.Amount = phase0.Gwei(amount)
+	version, err := hex.DecodeString(strings.TrimPrefix(deposit.GenesisForkVersion, "0x"))
+	if err != nil || len(version) != len(genesisForkVersion) {
+		return phase0.Root{}, fmt.Errorf("invalid genesis fork version %s", deposit.GenesisForkVersion)
+	}
+	copy(genesisForkVersion[:], version)
+	messageRoot, err := message.HashTreeRoot()
+	if err != nil {
+		return phase0.Root{}, err
+	}
+	domain := common.ComputeDomain(common.BLSDomainType{0x03}, common.Version(genesisForkVersion), common.Root{})
+	return phase0.Root(common.ComputeSigningRoot(messageRoot, domain)), nil
+}
@@ -0,0 +1,202 @@
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+)
+
+// SigningType the type of a signing request, as named by the Web3Signer eth2 api
+type SigningType string
+
+const (
+	SigningTypeAttestation   SigningType = "ATTESTATION"
+	SigningTypeBlockV2       SigningType = "BLOCK_V2"
+	SigningTypeDeposit       SigningType = "DEPOSIT"
+	SigningTypeVoluntaryExit SigningType = "VOLUNTARY_EXIT"
+	// SigningTypeBLSToExecutionChange isn't part of the Web3Signer api, signers that don't support it reject the request
+	SigningTypeBLSToExecutionChange SigningType = "BLS_TO_EXECUTION_CHANGE"
+)
+
+// ForkInfo the fork and chain a message is signed for, remote signers compute the domain from it
+type ForkInfo struct {
+	Fork                  *phase0.Fork `json:"fork"`
+	GenesisValidatorsRoot phase0.Root  `json:"genesis_validators_root"`
+}
+
+// SigningBeaconBlock the block of a BLOCK_V2 request.
+// Web3Signer takes the whole block for PHASE0 and ALTAIR and only the header from BELLATRIX on, so it doesn't need the payload.
+type SigningBeaconBlock struct {
+	// Version the upper case name of the fork of the block, e.g. CAPELLA
+	Version string
+	// Phase0Block the block of a PHASE0 request
+	Phase0Block *phase0.BeaconBlock
+	// AltairBlock the block of an ALTAIR request
+	AltairBlock *altair.BeaconBlock
+	// BlockHeader the header of a BELLATRIX or later request
+	BlockHeader *phase0.BeaconBlockHeader
+}
+
+// Validate returns an error unless the block or header the fork of the request needs is set
+func (b *SigningBeaconBlock) Validate() error {
+	switch {
+	case b.Version == "PHASE0" && b.Phase0Block == nil:
+		return fmt.Errorf("PHASE0 block requests need the phase0 block")
+	case b.Version == "ALTAIR" && b.AltairBlock == nil:
+		return fmt.Errorf("ALTAIR block requests need the altair block")
+	case b.Version != "PHASE0" && b.Version != "ALTAIR" && b.BlockHeader == nil:
+		return fmt.Errorf("%s block requests need the block header", b.Version)
+	}
+	return nil
+}
+
+// Header returns the header of the block, computed from the block for PHASE0 and ALTAIR.
+// The header has the same hash tree root as the block, so it is signed in its place.
+func (b *SigningBeaconBlock) Header() (*phase0.BeaconBlockHeader, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	var header *phase0.BeaconBlockHeader
+	var bodyRoot [32]byte
+	var err error
+	switch b.Version {
+	case "PHASE0":
+		block := b.Phase0Block
+		header = &phase0.BeaconBlockHeader{Slot: block.Slot, ProposerIndex: block.ProposerIndex, ParentRoot: block.ParentRoot, StateRoot: block.StateRoot}
+		bodyRoot, err = block.Body.HashTreeRoot()
+	case "ALTAIR":
+		block := b.AltairBlock
+		header = &phase0.BeaconBlockHeader{Slot: block.Slot, ProposerIndex: block.ProposerIndex, ParentRoot: block.ParentRoot, StateRoot: block.StateRoot}
+		bodyRoot, err = block.Body.HashTreeRoot()
+	default:
+		return b.BlockHeader, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s block body hash tree root: %w", b.Version, err)
+	}
+	header.BodyRoot = bodyRoot
+	return header, nil
+}
+
+// signingBeaconBlockJSON the Web3Signer format of a block, only one of Block and BlockHeader is set
+type signingBeaconBlockJSON struct {
+	Version     string                    `json:"version"`
+	Block       json.RawMessage           `json:"block,omitempty"`
+	BlockHeader *phase0.BeaconBlockHeader `json:"block_header,omitempty"`
+}
+
+// MarshalJSON sends the block for PHASE0 and ALTAIR and the header otherwise
+func (b *SigningBeaconBlock) MarshalJSON() ([]byte, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	data := signingBeaconBlockJSON{Version: b.Version}
+	var err error
+	switch b.Version {
+	case "PHASE0":
+		data.Block, err = json.Marshal(b.Phase0Block)
+	case "ALTAIR":
+		data.Block, err = json.Marshal(b.AltairBlock)
+	default:
+		data.BlockHeader = b.BlockHeader
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON reads the block or header the version of the request needs
+func (b *SigningBeaconBlock) UnmarshalJSON(input []byte) error {
+	var data signingBeaconBlockJSON
+	if err := json.Unmarshal(input, &data); err != nil {
+		return err
+	}
+	*b = SigningBeaconBlock{Version: data.Version, BlockHeader: data.BlockHeader}
+	switch {
+	case data.Version == "PHASE0" && data.Block != nil:
+		b.Phase0Block = new(phase0.BeaconBlock)
+		if err := json.Unmarshal(data.Block, b.Phase0Block); err != nil {
+			return err
+		}
+	case data.Version == "ALTAIR" && data.Block != nil:
+		b.AltairBlock = new(altair.BeaconBlock)
+		if err := json.Unmarshal(data.Block, b.AltairBlock); err != nil {
+			return err
+		}
+	}
+	return b.Validate()
+}
+
+// SigningDeposit the deposit of a DEPOSIT request
+type SigningDeposit struct {
+	PubKey                string `json:"pubkey"`
+	WithdrawalCredentials string `json:"withdrawal_credentials"`
+	Amount                string `json:"amount"`
+	GenesisForkVersion    string `json:"genesis_fork_version"`
+}
+
+// NewSigningDeposit returns the deposit of a request for the deposit message
+func NewSigningDeposit(message *phase0.DepositMessage, genesisForkVersion phase0.Version) *SigningDeposit {
+	return &SigningDeposit{
+		PubKey:                fmt.Sprintf("%#x", message.PublicKey),
+		WithdrawalCredentials: fmt.Sprintf("%#x", message.WithdrawalCredentials),
+		Amount:                fmt.Sprintf("%d", message.Amount),
+		GenesisForkVersion:    fmt.Sprintf("%#x", genesisForkVersion),
+	}
+}
+
+// SigningRequest a signing root with the message it was computed from, in the Web3Signer request format.
+// Local signers only sign the root, remote signers can check the message against their slashing protection.
+type SigningRequest struct {
+	// PubKey the key to sign with
+	PubKey phase0.BLSPubKey `json:"-"`
+
+	Type        SigningType `json:"type"`
+	ForkInfo    *ForkInfo   `json:"fork_info,omitempty"`
+	SigningRoot phase0.Root `json:"signingRoot"`
+
+	Attestation          *phase0.AttestationData       `json:"attestation,omitempty"`
+	BeaconBlock          *SigningBeaconBlock           `json:"beacon_block,omitempty"`
+	Deposit              *SigningDeposit               `json:"deposit,omitempty"`
+	VoluntaryExit        *phase0.VoluntaryExit         `json:"voluntary_exit,omitempty"`
+	BLSToExecutionChange *capella.BLSToExecutionChange `json:"bls_to_execution_change,omitempty"`
+}
+
+// Signer signs requests with the keys of validators
+type Signer interface {
+	Sign(ctx context.Context, request *SigningRequest) (phase0.BLSSignature, error)
+}
+
+// KeySigner signs with private keys held in memory
+type KeySigner struct {
+	keys map[phase0.BLSPubKey]e2types.PrivateKey
+}
+
+// NewKeySigner returns a signer for the validator and withdrawal keys of the validators
+func NewKeySigner(validators ...*Validator) *KeySigner {
+	signer := &KeySigner{keys: make(map[phase0.BLSPubKey]e2types.PrivateKey)}
+	for _, v := range validators {
+		for _, key := range []e2types.PrivateKey{v.ValidatorKey, v.WithdrawalKey} {
+			if key != nil {
+				signer.keys[phase0.BLSPubKey(key.PublicKey().Marshal())] = key
+			}
+		}
+	}
+	return signer
+}
+
+// Sign signs the signing root with the key of the request
+func (s *KeySigner) Sign(_ context.Context, request *SigningRequest) (phase0.BLSSignature, error) {
+	key, ok := s.keys[request.PubKey]
+	if !ok {
+		return phase0.BLSSignature{}, fmt.Errorf("no key for %s", request.PubKey)
+	}
+	// the root is copied out of the request, cgo refuses memory that holds go pointers
+	root := request.SigningRoot
+	return phase0.BLSSignature(key.Sign(root[:]).Marshal()), nil
+}
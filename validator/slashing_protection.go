@@ -0,0 +1,284 @@
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"sort"
+	"sync"
+)
+
+// InterchangeFormatVersion the EIP-3076 interchange format version that is imported and exported
+const InterchangeFormatVersion = "5"
+
+var (
+	// ErrSlashableBlock is returned when signing the block could get the proposer slashed
+	ErrSlashableBlock = errors.New("slashable block")
+	// ErrSlashableAttestation is returned when signing the attestation could get the attester slashed
+	ErrSlashableAttestation = errors.New("slashable attestation")
+)
+
+// SlashingInterchange the EIP-3076 slashing protection interchange format
+type SlashingInterchange struct {
+	Metadata SlashingInterchangeMetadata `json:"metadata"`
+	Data     []*SlashingInterchangeData  `json:"data"`
+}
+
+// SlashingInterchangeMetadata the format version and the chain of an interchange
+type SlashingInterchangeMetadata struct {
+	InterchangeFormatVersion string      `json:"interchange_format_version"`
+	GenesisValidatorsRoot    phase0.Root `json:"genesis_validators_root"`
+}
+
+// SlashingInterchangeData the signing history of a single key
+type SlashingInterchangeData struct {
+	PubKey             phase0.BLSPubKey           `json:"pubkey"`
+	SignedBlocks       []*SignedBlockRecord       `json:"signed_blocks"`
+	SignedAttestations []*SignedAttestationRecord `json:"signed_attestations"`
+}
+
+// SignedBlockRecord a signed block, the signing root is nil if the exporting client didn't keep it
+type SignedBlockRecord struct {
+	Slot        phase0.Slot  `json:"slot,string"`
+	SigningRoot *phase0.Root `json:"signing_root,omitempty"`
+}
+
+// SignedAttestationRecord a signed attestation, the signing root is nil if the exporting client didn't keep it
+type SignedAttestationRecord struct {
+	SourceEpoch phase0.Epoch `json:"source_epoch,string"`
+	TargetEpoch phase0.Epoch `json:"target_epoch,string"`
+	SigningRoot *phase0.Root `json:"signing_root,omitempty"`
+}
+
+// SlashingProtection an in memory slashing protection database of the blocks and attestations signed per key.
+// Besides double proposals, double votes and surround votes it refuses, as EIP-3076 asks of imported histories,
+// blocks at or below the lowest recorded slot and attestations below the lowest source or at or below the lowest target epoch.
+type SlashingProtection struct {
+	// GenesisValidatorsRoot the chain the history belongs to, set by the first import or signing request if zero
+	GenesisValidatorsRoot phase0.Root
+
+	lock    sync.Mutex
+	history map[phase0.BLSPubKey]*SlashingInterchangeData
+}
+
+// NewSlashingProtection returns an empty database for the chain
+func NewSlashingProtection(genesisValidatorsRoot phase0.Root) *SlashingProtection {
+	return &SlashingProtection{GenesisValidatorsRoot: genesisValidatorsRoot, history: make(map[phase0.BLSPubKey]*SlashingInterchangeData)}
+}
+
+type allowSlashableKey struct{}
+
+// AllowSlashable returns a context under which protected signers sign slashable messages, they are still recorded
+func AllowSlashable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allowSlashableKey{}, true)
+}
+
+func slashableAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(allowSlashableKey{}).(bool)
+	return allowed
+}
+
+// CheckAndRecordBlock records the block if signing it is safe, otherwise it returns ErrSlashableBlock unless allowSlashable is set
+func (p *SlashingProtection) CheckAndRecordBlock(pubKey phase0.BLSPubKey, slot phase0.Slot, signingRoot phase0.Root, allowSlashable bool) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	history := p.historyOf(pubKey)
+	for _, block := range history.SignedBlocks {
+		if block.Slot == slot && block.SigningRoot != nil && *block.SigningRoot == signingRoot {
+			return nil // signing the same block again is safe
+		}
+	}
+	if err := checkBlock(history, slot); err != nil && !allowSlashable {
+		return errors.Wrapf(err, "refusing to sign for %s", pubKey)
+	}
+	history.SignedBlocks = append(history.SignedBlocks, &SignedBlockRecord{Slot: slot, SigningRoot: &signingRoot})
+	return nil
+}
+
+// CheckAndRecordAttestation records the attestation if signing it is safe, otherwise it returns ErrSlashableAttestation unless allowSlashable is set
+func (p *SlashingProtection) CheckAndRecordAttestation(pubKey phase0.BLSPubKey, source phase0.Epoch, target phase0.Epoch, signingRoot phase0.Root, allowSlashable bool) error {
+	if source > target {
+		return fmt.Errorf("attestation source epoch %d is after its target epoch %d", source, target)
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	history := p.historyOf(pubKey)
+	for _, attestation := range history.SignedAttestations {
+		if attestation.TargetEpoch == target && attestation.SourceEpoch == source && attestation.SigningRoot != nil && *attestation.SigningRoot == signingRoot {
+			return nil
+		}
+	}
+	if err := checkAttestation(history, source, target); err != nil && !allowSlashable {
+		return errors.Wrapf(err, "refusing to sign for %s", pubKey)
+	}
+	history.SignedAttestations = append(history.SignedAttestations, &SignedAttestationRecord{SourceEpoch: source, TargetEpoch: target, SigningRoot: &signingRoot})
+	return nil
+}
+
+func checkBlock(history *SlashingInterchangeData, slot phase0.Slot) error {
+	for _, block := range history.SignedBlocks {
+		if block.Slot == slot {
+			return errors.Wrapf(ErrSlashableBlock, "another block was signed for slot %d", slot)
+		}
+	}
+	if len(history.SignedBlocks) > 0 {
+		lowest := history.SignedBlocks[0].Slot
+		for _, block := range history.SignedBlocks {
+			lowest = min(lowest, block.Slot)
+		}
+		if slot <= lowest {
+			return errors.Wrapf(ErrSlashableBlock, "slot %d is at or below the lowest signed slot %d", slot, lowest)
+		}
+	}
+	return nil
+}
+
+func checkAttestation(history *SlashingInterchangeData, source phase0.Epoch, target phase0.Epoch) error {
+	if len(history.SignedAttestations) == 0 {
+		return nil
+	}
+	lowestSource, lowestTarget := history.SignedAttestations[0].SourceEpoch, history.SignedAttestations[0].TargetEpoch
+	for _, attestation := range history.SignedAttestations {
+		switch {
+		case attestation.TargetEpoch == target:
+			return errors.Wrapf(ErrSlashableAttestation, "double vote for target epoch %d", target)
+		case source < attestation.SourceEpoch && target > attestation.TargetEpoch:
+			return errors.Wrapf(ErrSlashableAttestation, "%d-%d surrounds the signed %d-%d", source, target, attestation.SourceEpoch, attestation.TargetEpoch)
+		case source > attestation.SourceEpoch && target < attestation.TargetEpoch:
+			return errors.Wrapf(ErrSlashableAttestation, "%d-%d is surrounded by the signed %d-%d", source, target, attestation.SourceEpoch, attestation.TargetEpoch)
+		}
+		lowestSource, lowestTarget = min(lowestSource, attestation.SourceEpoch), min(lowestTarget, attestation.TargetEpoch)
+	}
+	if source < lowestSource {
+		return errors.Wrapf(ErrSlashableAttestation, "source epoch %d is below the lowest signed source epoch %d", source, lowestSource)
+	}
+	if target <= lowestTarget {
+		return errors.Wrapf(ErrSlashableAttestation, "target epoch %d is at or below the lowest signed target epoch %d", target, lowestTarget)
+	}
+	return nil
+}
+
+func (p *SlashingProtection) historyOf(pubKey phase0.BLSPubKey) *SlashingInterchangeData {
+	if p.history == nil {
+		p.history = make(map[phase0.BLSPubKey]*SlashingInterchangeData)
+	}
+	history, ok := p.history[pubKey]
+	if !ok {
+		history = &SlashingInterchangeData{PubKey: pubKey}
+		p.history[pubKey] = history
+	}
+	return history
+}
+
+// Import merges an EIP-3076 interchange into the database, it has to be for the same chain
+func (p *SlashingProtection) Import(data []byte) error {
+	var interchange SlashingInterchange
+	if err := json.Unmarshal(data, &interchange); err != nil {
+		return errors.Wrap(err, "invalid slashing protection interchange")
+	}
+	if interchange.Metadata.InterchangeFormatVersion != InterchangeFormatVersion {
+		return fmt.Errorf("unsupported interchange format version %s", interchange.Metadata.InterchangeFormatVersion)
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.GenesisValidatorsRoot == (phase0.Root{}) {
+		p.GenesisValidatorsRoot = interchange.Metadata.GenesisValidatorsRoot
+	}
+	if interchange.Metadata.GenesisValidatorsRoot != p.GenesisValidatorsRoot {
+		return fmt.Errorf("interchange is for genesis validators root %s, not %s", interchange.Metadata.GenesisValidatorsRoot, p.GenesisValidatorsRoot)
+	}
+	for _, imported := range interchange.Data {
+		history := p.historyOf(imported.PubKey)
+		history.SignedBlocks = append(history.SignedBlocks, imported.SignedBlocks...)
+		history.SignedAttestations = append(history.SignedAttestations, imported.SignedAttestations...)
+	}
+	return nil
+}
+
+// Export returns the history of the keys, or of every key if none are given, as an EIP-3076 interchange ordered by pubkey, slot and target epoch
+func (p *SlashingProtection) Export(pubKeys ...phase0.BLSPubKey) ([]byte, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	interchange := SlashingInterchange{
+		Metadata: SlashingInterchangeMetadata{InterchangeFormatVersion: InterchangeFormatVersion, GenesisValidatorsRoot: p.GenesisValidatorsRoot},
+		Data:     make([]*SlashingInterchangeData, 0, len(p.history)),
+	}
+	selected := make(map[phase0.BLSPubKey]bool)
+	for _, pubKey := range pubKeys {
+		selected[pubKey] = true
+	}
+	for _, history := range p.history {
+		if len(selected) > 0 && !selected[history.PubKey] {
+			continue
+		}
+		data := &SlashingInterchangeData{
+			PubKey:             history.PubKey,
+			SignedBlocks:       append([]*SignedBlockRecord{}, history.SignedBlocks...),
+			SignedAttestations: append([]*SignedAttestationRecord{}, history.SignedAttestations...),
+		}
+		sort.SliceStable(data.SignedBlocks, func(i, j int) bool { return data.SignedBlocks[i].Slot < data.SignedBlocks[j].Slot })
+		sort.SliceStable(data.SignedAttestations, func(i, j int) bool {
+			return data.SignedAttestations[i].TargetEpoch < data.SignedAttestations[j].TargetEpoch
+		})
+		interchange.Data = append(interchange.Data, data)
+	}
+	sort.Slice(interchange.Data, func(i, j int) bool {
+		return interchange.Data[i].PubKey.String() < interchange.Data[j].PubKey.String()
+	})
+	return json.MarshalIndent(interchange, "", "  ")
+}
+
+// Protect puts the protection in front of the signer of every validator, validators without a signer get one for their in memory keys
+func (p *SlashingProtection) Protect(validators ...*Validator) {
+	for _, v := range validators {
+		signer := v.Signer
+		if signer == nil {
+			signer = NewKeySigner(v)
+		}
+		v.Signer = &ProtectedSigner{Signer: signer, Protection: p}
+	}
+}
+
+// ProtectedSigner checks and records blocks and attestations in the slashing protection before the signer signs them.
+// Slashable requests are refused unless the context was made with AllowSlashable.
+type ProtectedSigner struct {
+	Signer     Signer
+	Protection *SlashingProtection
+}
+
+// Sign checks and records the request before signing it, other message types are signed without a check
+func (s *ProtectedSigner) Sign(ctx context.Context, request *SigningRequest) (phase0.BLSSignature, error) {
+	if request.ForkInfo != nil {
+		if err := s.Protection.checkGenesisValidatorsRoot(request.ForkInfo.GenesisValidatorsRoot); err != nil {
+			return phase0.BLSSignature{}, err
+		}
+	}
+	var err error
+	switch {
+	case request.Type == SigningTypeBlockV2 && request.BeaconBlock != nil:
+		var header *phase0.BeaconBlockHeader
+		if header, err = request.BeaconBlock.Header(); err == nil {
+			err = s.Protection.CheckAndRecordBlock(request.PubKey, header.Slot, request.SigningRoot, slashableAllowed(ctx))
+		}
+	case request.Type == SigningTypeAttestation && request.Attestation != nil:
+		err = s.Protection.CheckAndRecordAttestation(request.PubKey, request.Attestation.Source.Epoch, request.Attestation.Target.Epoch, request.SigningRoot, slashableAllowed(ctx))
+	}
+	if err != nil {
+		return phase0.BLSSignature{}, err
+	}
+	return s.Signer.Sign(ctx, request)
+}
+
+func (p *SlashingProtection) checkGenesisValidatorsRoot(root phase0.Root) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.GenesisValidatorsRoot == (phase0.Root{}) {
+		p.GenesisValidatorsRoot = root
+	}
+	if root != p.GenesisValidatorsRoot {
+		return fmt.Errorf("slashing protection is for genesis validators root %s, not %s", p.GenesisValidatorsRoot, root)
+	}
+	return nil
+}
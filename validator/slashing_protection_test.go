@@ -0,0 +1,162 @@
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSlashingProtection_Blocks(t *testing.T) {
+	protection := NewSlashingProtection(phase0.Root{0x42})
+	pubKey := phase0.BLSPubKey{0x01}
+
+	require.NoError(t, protection.CheckAndRecordBlock(pubKey, 10, phase0.Root{0xaa}, false))
+	require.NoError(t, protection.CheckAndRecordBlock(pubKey, 10, phase0.Root{0xaa}, false))
+	require.ErrorIs(t, protection.CheckAndRecordBlock(pubKey, 10, phase0.Root{0xbb}, false), ErrSlashableBlock)
+	require.ErrorIs(t, protection.CheckAndRecordBlock(pubKey, 9, phase0.Root{0xcc}, false), ErrSlashableBlock)
+	require.NoError(t, protection.CheckAndRecordBlock(pubKey, 11, phase0.Root{0xdd}, false))
+	// other keys have their own history
+	require.NoError(t, protection.CheckAndRecordBlock(phase0.BLSPubKey{0x02}, 10, phase0.Root{0xbb}, false))
+
+	require.NoError(t, protection.CheckAndRecordBlock(pubKey, 10, phase0.Root{0xbb}, true))
+	// the slashable block is recorded, so signing it again is a repeat
+	require.NoError(t, protection.CheckAndRecordBlock(pubKey, 10, phase0.Root{0xbb}, false))
+}
+
+func TestSlashingProtection_Attestations(t *testing.T) {
+	protection := NewSlashingProtection(phase0.Root{0x42})
+	pubKey := phase0.BLSPubKey{0x01}
+
+	require.NoError(t, protection.CheckAndRecordAttestation(pubKey, 2, 4, phase0.Root{0xaa}, false))
+	require.NoError(t, protection.CheckAndRecordAttestation(pubKey, 2, 4, phase0.Root{0xaa}, false))
+	require.ErrorIs(t, protection.CheckAndRecordAttestation(pubKey, 2, 4, phase0.Root{0xbb}, false), ErrSlashableAttestation)
+	require.NoError(t, protection.CheckAndRecordAttestation(pubKey, 4, 6, phase0.Root{0xcc}, false))
+	// surrounding 4-6 and surrounded by 4-6
+	require.ErrorIs(t, protection.CheckAndRecordAttestation(pubKey, 3, 7, phase0.Root{0xdd}, false), ErrSlashableAttestation)
+	require.NoError(t, protection.CheckAndRecordAttestation(pubKey, 6, 8, phase0.Root{0xdd}, false))
+	require.ErrorIs(t, protection.CheckAndRecordAttestation(pubKey, 7, 7, phase0.Root{0xee}, false), ErrSlashableAttestation)
+	// below the lowest source and target
+	require.ErrorIs(t, protection.CheckAndRecordAttestation(pubKey, 1, 9, phase0.Root{0xee}, false), ErrSlashableAttestation)
+	require.ErrorIs(t, protection.CheckAndRecordAttestation(pubKey, 2, 3, phase0.Root{0xee}, false), ErrSlashableAttestation)
+	require.Error(t, protection.CheckAndRecordAttestation(pubKey, 9, 8, phase0.Root{0xee}, true))
+
+	require.NoError(t, protection.CheckAndRecordAttestation(pubKey, 3, 7, phase0.Root{0xdd}, true))
+}
+
+func TestSlashingProtection_Interchange(t *testing.T) {
+	protection := NewSlashingProtection(phase0.Root{0x42})
+	pubKey := phase0.BLSPubKey{0x01}
+	require.NoError(t, protection.CheckAndRecordBlock(pubKey, 12, phase0.Root{0xaa}, false))
+	require.NoError(t, protection.CheckAndRecordAttestation(pubKey, 2, 3, phase0.Root{0xbb}, false))
+	exported, err := protection.Export()
+	require.NoError(t, err)
+
+	var interchange map[string]interface{}
+	require.NoError(t, json.Unmarshal(exported, &interchange))
+	require.Equal(t, "5", interchange["metadata"].(map[string]interface{})["interchange_format_version"])
+	data := interchange["data"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, "12", data["signed_blocks"].([]interface{})[0].(map[string]interface{})["slot"])
+	require.Equal(t, "3", data["signed_attestations"].([]interface{})[0].(map[string]interface{})["target_epoch"])
+
+	imported := NewSlashingProtection(phase0.Root{})
+	require.NoError(t, imported.Import(exported))
+	require.Equal(t, phase0.Root{0x42}, imported.GenesisValidatorsRoot)
+	require.ErrorIs(t, imported.CheckAndRecordBlock(pubKey, 12, phase0.Root{0xcc}, false), ErrSlashableBlock)
+	require.NoError(t, imported.CheckAndRecordBlock(pubKey, 12, phase0.Root{0xaa}, false))
+	require.ErrorIs(t, imported.CheckAndRecordAttestation(pubKey, 2, 3, phase0.Root{0xcc}, false), ErrSlashableAttestation)
+
+	// histories from other clients may leave out the signing roots, so even a repeat is refused
+	require.NoError(t, imported.Import([]byte(`{
+		"metadata": {"interchange_format_version": "5", "genesis_validators_root": "0x4200000000000000000000000000000000000000000000000000000000000000"},
+		"data": [{"pubkey": "0x020000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000", "signed_blocks": [{"slot": "100"}], "signed_attestations": []}]
+	}`)))
+	require.ErrorIs(t, imported.CheckAndRecordBlock(phase0.BLSPubKey{0x02}, 100, phase0.Root{0xaa}, false), ErrSlashableBlock)
+	require.ErrorIs(t, imported.CheckAndRecordBlock(phase0.BLSPubKey{0x02}, 50, phase0.Root{0xaa}, false), ErrSlashableBlock)
+	require.NoError(t, imported.CheckAndRecordBlock(phase0.BLSPubKey{0x02}, 101, phase0.Root{0xaa}, false))
+
+	require.Error(t, imported.Import([]byte(`{"metadata": {"interchange_format_version": "5", "genesis_validators_root": "0x0100000000000000000000000000000000000000000000000000000000000000"}, "data": []}`)))
+	require.Error(t, imported.Import([]byte(`{"metadata": {"interchange_format_version": "4", "genesis_validators_root": "0x4200000000000000000000000000000000000000000000000000000000000000"}, "data": []}`)))
+}
+
+func TestSlashingProtection_InterchangeEIP3076Example(t *testing.T) {
+	// the example interchange of EIP-3076
+	example := `{
+		"metadata": {
+			"interchange_format_version": "5",
+			"genesis_validators_root": "0x04700007fabc8282644aed6d1c7c9e21d38a03a0c4ba193f3afe428824b3a673"
+		},
+		"data": [
+			{
+				"pubkey": "0xb845089a1457f811bfc000588fbb4e713669be8ce060ea6be3c6ece09afc3794106c91ca73acda5e5457122d58723bed",
+				"signed_blocks": [
+					{"slot": "81952", "signing_root": "0x4ff6f743a43f3b4f95350831aeaf0a122a1a392922c45d804280284a69eb850b"},
+					{"slot": "81951"}
+				],
+				"signed_attestations": [
+					{"source_epoch": "2290", "target_epoch": "3007", "signing_root": "0x587d6a4f59a58fe24f406e0502413e77fe1babddee641fda30034ed37ecc884d"},
+					{"source_epoch": "2290", "target_epoch": "3008"}
+				]
+			}
+		]
+	}`
+	protection := NewSlashingProtection(phase0.Root{})
+	require.NoError(t, protection.Import([]byte(example)))
+	var pubKey phase0.BLSPubKey
+	require.NoError(t, json.Unmarshal([]byte(`"0xb845089a1457f811bfc000588fbb4e713669be8ce060ea6be3c6ece09afc3794106c91ca73acda5e5457122d58723bed"`), &pubKey))
+	require.ErrorIs(t, protection.CheckAndRecordBlock(pubKey, 81952, phase0.Root{0xaa}, false), ErrSlashableBlock)
+	require.ErrorIs(t, protection.CheckAndRecordAttestation(pubKey, 2290, 3008, phase0.Root{0xaa}, false), ErrSlashableAttestation)
+
+	// the export is the example ordered by slot and target epoch
+	exported, err := protection.Export()
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"metadata": {
+			"interchange_format_version": "5",
+			"genesis_validators_root": "0x04700007fabc8282644aed6d1c7c9e21d38a03a0c4ba193f3afe428824b3a673"
+		},
+		"data": [
+			{
+				"pubkey": "0xb845089a1457f811bfc000588fbb4e713669be8ce060ea6be3c6ece09afc3794106c91ca73acda5e5457122d58723bed",
+				"signed_blocks": [
+					{"slot": "81951"},
+					{"slot": "81952", "signing_root": "0x4ff6f743a43f3b4f95350831aeaf0a122a1a392922c45d804280284a69eb850b"}
+				],
+				"signed_attestations": [
+					{"source_epoch": "2290", "target_epoch": "3007", "signing_root": "0x587d6a4f59a58fe24f406e0502413e77fe1babddee641fda30034ed37ecc884d"},
+					{"source_epoch": "2290", "target_epoch": "3008"}
+				]
+			}
+		]
+	}`, string(exported))
+}
+
+func TestSlashingProtection_ProtectedSigner(t *testing.T) {
+	validators, err := GetValidatorsFromMnemonic(ValidatorMnemonic, 0, 1)
+	require.NoError(t, err)
+	protection := NewSlashingProtection(phase0.Root{})
+	protection.Protect(validators...)
+	request := func(root phase0.Root) *SigningRequest {
+		return &SigningRequest{
+			PubKey:      validators[0].ValidatorPublicKey,
+			Type:        SigningTypeBlockV2,
+			ForkInfo:    &ForkInfo{Fork: &phase0.Fork{}, GenesisValidatorsRoot: phase0.Root{0x42}},
+			SigningRoot: root,
+			BeaconBlock: &SigningBeaconBlock{Version: "CAPELLA", BlockHeader: &phase0.BeaconBlockHeader{Slot: 5}},
+		}
+	}
+
+	_, err = validators[0].Sign(context.Background(), request(phase0.Root{0xaa}))
+	require.NoError(t, err)
+	require.Equal(t, phase0.Root{0x42}, protection.GenesisValidatorsRoot)
+	_, err = validators[0].Sign(context.Background(), request(phase0.Root{0xbb}))
+	require.ErrorIs(t, err, ErrSlashableBlock)
+	_, err = validators[0].Sign(AllowSlashable(context.Background()), request(phase0.Root{0xbb}))
+	require.NoError(t, err)
+
+	other := request(phase0.Root{0xcc})
+	other.ForkInfo.GenesisValidatorsRoot = phase0.Root{0x01}
+	_, err = validators[0].Sign(AllowSlashable(context.Background()), other)
+	require.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package validator
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
@@ -19,14 +20,52 @@ func init() {
 
 type Validator struct {
 	ValidatorIndex uint64
-	ValidatorKey   e2types.PrivateKey
-	WithdrawalKey  e2types.PrivateKey
+	// ValidatorKey and WithdrawalKey are nil when the keys only live in a remote signer,
+	// WithdrawalKey is also nil for validators imported without a withdrawal keystore
+	ValidatorKey  e2types.PrivateKey
+	WithdrawalKey e2types.PrivateKey
 	// ValidatorPublicKey contains a type friendly version of the public key for go-eth2-client
 	ValidatorPublicKey phase0.BLSPubKey
+	// WithdrawalPublicKey is zero if the withdrawal key is unknown
+	WithdrawalPublicKey phase0.BLSPubKey
+	// Signer signs for the validator, nil signs with the in memory keys
+	Signer Signer
 }
 
 func (v *Validator) String() string {
-	return fmt.Sprintf("pubKey: 0x%s", hex.EncodeToString(v.ValidatorKey.PublicKey().Marshal()))
+	return fmt.Sprintf("pubKey: 0x%s", hex.EncodeToString(v.ValidatorPublicKey[:]))
+}
+
+// Sign signs the request with the validators signer, or its in memory keys if it has no signer
+func (v *Validator) Sign(ctx context.Context, request *SigningRequest) (phase0.BLSSignature, error) {
+	if v.Signer != nil {
+		return v.Signer.Sign(ctx, request)
+	}
+	return NewKeySigner(v).Sign(ctx, request)
+}
+
+// NewRemoteValidator returns a validator whose keys live in the signer, the withdrawal key may be zero if it is unknown
+func NewRemoteValidator(account uint64, pubKey phase0.BLSPubKey, withdrawalPubKey phase0.BLSPubKey, signer Signer) *Validator {
+	return &Validator{
+		ValidatorIndex:      account,
+		ValidatorPublicKey:  pubKey,
+		WithdrawalPublicKey: withdrawalPubKey,
+		Signer:              signer,
+	}
+}
+
+// newValidator builds a validator from its keys
+func newValidator(account uint64, validatorKey e2types.PrivateKey, withdrawalKey e2types.PrivateKey) *Validator {
+	v := &Validator{
+		ValidatorIndex:     account,
+		ValidatorKey:       validatorKey,
+		WithdrawalKey:      withdrawalKey,
+		ValidatorPublicKey: phase0.BLSPubKey(validatorKey.PublicKey().Marshal()),
+	}
+	if withdrawalKey != nil {
+		v.WithdrawalPublicKey = phase0.BLSPubKey(withdrawalKey.PublicKey().Marshal())
+	}
+	return v
 }
 
 func MnemonicToSeed(mnemonic string) ([]byte, error) {
@@ -47,8 +86,8 @@ func GetValidatorsFromMnemonic(mnemonic string, minAcc uint64, maxAcc uint64) ([
 		return nil, err
 	}
 	for idx := minAcc; idx < maxAcc; idx++ {
-		valAccPath := fmt.Sprintf("m/12381/3600/%d/0/0", idx)
-		withdrawalAccPath := fmt.Sprintf("m/12381/3600/%d/0", idx)
+		valAccPath := validatorKeyPath(idx)
+		withdrawalAccPath := withdrawalKeyPath(idx)
 		validatorKey, err := util.PrivateKeyFromSeedAndPath(seed, valAccPath)
 		if err != nil {
 			return nil, fmt.Errorf("account %s cannot be derived, continuing to next account", valAccPath)
@@ -57,15 +96,7 @@ func GetValidatorsFromMnemonic(mnemonic string, minAcc uint64, maxAcc uint64) ([
 		if err != nil {
 			return nil, fmt.Errorf("withdrawal %s cannot be derived, continuing to next account", valAccPath)
 		}
-		var pubKey phase0.BLSPubKey
-		copy(pubKey[:], validatorKey.PublicKey().Marshal()[:])
-
-		validators = append(validators, &Validator{
-			ValidatorIndex:     idx,
-			WithdrawalKey:      withdrawalKey,
-			ValidatorKey:       validatorKey,
-			ValidatorPublicKey: pubKey,
-		})
+		validators = append(validators, newValidator(idx, validatorKey, withdrawalKey))
 	}
 
 	return validators, err
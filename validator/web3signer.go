@@ -0,0 +1,86 @@
+package validator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Web3Signer signs with a remote signer that implements the Web3Signer eth2 api
+type Web3Signer struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+// NewWeb3Signer returns a signer for the Web3Signer at the url
+func NewWeb3Signer(url string) *Web3Signer {
+	return &Web3Signer{URL: strings.TrimSuffix(url, "/"), HTTPClient: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Sign posts the request to /api/v1/eth2/sign/{pubkey}, the signer refuses messages its slashing protection doesn't allow
+func (s *Web3Signer) Sign(ctx context.Context, request *SigningRequest) (phase0.BLSSignature, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return phase0.BLSSignature{}, errors.Wrap(err, "failed to marshal signing request")
+	}
+	var response struct {
+		Signature phase0.BLSSignature `json:"signature"`
+	}
+	if err := s.do(ctx, http.MethodPost, fmt.Sprintf("/api/v1/eth2/sign/%#x", request.PubKey), body, &response); err != nil {
+		return phase0.BLSSignature{}, errors.Wrapf(err, "remote signer failed to sign %s for %s", request.Type, request.PubKey)
+	}
+	return response.Signature, nil
+}
+
+// PublicKeys returns the validator keys the signer holds
+func (s *Web3Signer) PublicKeys(ctx context.Context) ([]phase0.BLSPubKey, error) {
+	var pubKeys []phase0.BLSPubKey
+	if err := s.do(ctx, http.MethodGet, "/api/v1/eth2/publicKeys", nil, &pubKeys); err != nil {
+		return nil, errors.Wrap(err, "failed to list remote signer keys")
+	}
+	return pubKeys, nil
+}
+
+// Validators returns a validator for every key the signer holds, without withdrawal keys, numbered in the order the signer lists them
+func (s *Web3Signer) Validators(ctx context.Context) ([]*Validator, error) {
+	pubKeys, err := s.PublicKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	validators := make([]*Validator, len(pubKeys))
+	for i, pubKey := range pubKeys {
+		validators[i] = NewRemoteValidator(uint64(i), pubKey, phase0.BLSPubKey{}, s)
+	}
+	return validators, nil
+}
+
+func (s *Web3Signer) do(ctx context.Context, method string, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, s.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return errors.Wrap(json.Unmarshal(data, out), "failed to decode remote signer response")
+}
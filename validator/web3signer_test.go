@@ -0,0 +1,95 @@
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWeb3Signer_Mock(t *testing.T) {
+	validators, err := GetValidatorsFromMnemonic(ValidatorMnemonic, 0, 3)
+	require.NoError(t, err)
+	mock := NewMockWeb3Signer(validators...)
+	t.Cleanup(mock.Close)
+	signer := mock.Signer()
+
+	remote, err := signer.Validators(context.Background())
+	require.NoError(t, err)
+	require.Len(t, remote, 3)
+	require.Equal(t, validators[1].ValidatorPublicKey, remote[1].ValidatorPublicKey)
+	require.Nil(t, remote[1].ValidatorKey)
+
+	exit := &phase0.VoluntaryExit{Epoch: 3, ValidatorIndex: 1}
+	fork := &phase0.Fork{PreviousVersion: phase0.Version{0x01}, CurrentVersion: phase0.Version{0x02}, Epoch: 2}
+	exitRoot, err := exit.HashTreeRoot()
+	require.NoError(t, err)
+	domain := common.ComputeDomain(common.BLSDomainType{0x04}, common.Version{0x02}, common.Root{0x42})
+	request := &SigningRequest{
+		PubKey:        validators[1].ValidatorPublicKey,
+		Type:          SigningTypeVoluntaryExit,
+		ForkInfo:      &ForkInfo{Fork: fork, GenesisValidatorsRoot: phase0.Root{0x42}},
+		SigningRoot:   phase0.Root(common.ComputeSigningRoot(exitRoot, domain)),
+		VoluntaryExit: exit,
+	}
+
+	// the remote signature is the one the in memory key makes
+	signature, err := remote[1].Sign(context.Background(), request)
+	require.NoError(t, err)
+	local, err := validators[1].Sign(context.Background(), request)
+	require.NoError(t, err)
+	require.Equal(t, local, signature)
+	require.Len(t, mock.Requests(), 1)
+
+	// a signing root that doesn't match the message is refused, as is a key the signer doesn't hold
+	request.VoluntaryExit = &phase0.VoluntaryExit{Epoch: 1, ValidatorIndex: 1}
+	_, err = signer.Sign(context.Background(), request)
+	require.ErrorContains(t, err, "400")
+	request.VoluntaryExit = exit
+	request.PubKey = phase0.BLSPubKey{0x01}
+	_, err = signer.Sign(context.Background(), request)
+	require.Error(t, err)
+	require.Len(t, mock.Requests(), 1)
+}
+
+func TestSigningBeaconBlock_JSON(t *testing.T) {
+	// before bellatrix the whole block is sent, from bellatrix on only the header
+	phase0Block := &SigningBeaconBlock{Version: "PHASE0", Phase0Block: &phase0.BeaconBlock{
+		Slot: 5,
+		Body: &phase0.BeaconBlockBody{
+			ETH1Data:          &phase0.ETH1Data{BlockHash: make([]byte, 32)},
+			ProposerSlashings: []*phase0.ProposerSlashing{},
+			AttesterSlashings: []*phase0.AttesterSlashing{},
+			Attestations:      []*phase0.Attestation{},
+			Deposits:          []*phase0.Deposit{},
+			VoluntaryExits:    []*phase0.SignedVoluntaryExit{},
+		},
+	}}
+	data, err := json.Marshal(phase0Block)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"block":{"slot":"5"`)
+	require.NotContains(t, string(data), "block_header")
+	var decoded SigningBeaconBlock
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, phase0Block.Phase0Block.Slot, decoded.Phase0Block.Slot)
+	header, err := decoded.Header()
+	require.NoError(t, err)
+	blockRoot, err := phase0Block.Phase0Block.HashTreeRoot()
+	require.NoError(t, err)
+	headerRoot, err := header.HashTreeRoot()
+	require.NoError(t, err)
+	require.Equal(t, blockRoot, headerRoot)
+
+	capellaBlock := &SigningBeaconBlock{Version: "CAPELLA", BlockHeader: &phase0.BeaconBlockHeader{Slot: 7}}
+	data, err = json.Marshal(capellaBlock)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"block_header":{"slot":"7"`)
+	require.NotContains(t, string(data), `"block":`)
+
+	// a header alone isn't enough for an altair block
+	_, err = json.Marshal(&SigningBeaconBlock{Version: "ALTAIR", BlockHeader: &phase0.BeaconBlockHeader{Slot: 7}})
+	require.Error(t, err)
+	require.Error(t, json.Unmarshal([]byte(`{"version":"ALTAIR","block_header":{"slot":"7"}}`), &decoded))
+}
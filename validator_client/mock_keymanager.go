@@ -0,0 +1,429 @@
+package validator_client
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"eth-testnet-tool/validator"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockKeymanager is an in process stand-in for the Keymanager API of a validator client, for testing without a network.
+// Like a real client it decrypts imported keystores, keeps the slashing protection of its keys and hands it out when they are deleted.
+type MockKeymanager struct {
+	Server *httptest.Server
+	// Token the bearer token requests need, requests without it get a 401
+	Token string
+	// ForkVersion and GenesisValidatorsRoot are the domain voluntary exits are signed with,
+	// CurrentEpoch is the epoch of exits requested without one
+	ForkVersion           phase0.Version
+	GenesisValidatorsRoot phase0.Root
+	CurrentEpoch          phase0.Epoch
+	// Indices the validator index of every key that can exit, a real client looks them up on its beacon node
+	Indices map[phase0.BLSPubKey]phase0.ValidatorIndex
+	// DefaultFeeRecipient, DefaultGasLimit and DefaultGraffiti are served for keys without their own
+	DefaultFeeRecipient bellatrix.ExecutionAddress
+	DefaultGasLimit     uint64
+	DefaultGraffiti     string
+
+	lock          sync.Mutex
+	keystores     map[phase0.BLSPubKey]*mockKeystore
+	remoteKeys    map[phase0.BLSPubKey]string
+	feeRecipients map[phase0.BLSPubKey]bellatrix.ExecutionAddress
+	gasLimits     map[phase0.BLSPubKey]uint64
+	graffiti      map[phase0.BLSPubKey]string
+	protection    *validator.SlashingProtection
+	// protected the keys the slashing protection has history for
+	protected map[phase0.BLSPubKey]bool
+}
+
+type mockKeystore struct {
+	path      string
+	validator *validator.Validator
+}
+
+// NewMockKeymanager starts a keymanager without keys that accepts requests with the token
+func NewMockKeymanager(token string) *MockKeymanager {
+	m := &MockKeymanager{
+		Token:           token,
+		Indices:         make(map[phase0.BLSPubKey]phase0.ValidatorIndex),
+		DefaultGasLimit: 30000000,
+		keystores:       make(map[phase0.BLSPubKey]*mockKeystore),
+		remoteKeys:      make(map[phase0.BLSPubKey]string),
+		feeRecipients:   make(map[phase0.BLSPubKey]bellatrix.ExecutionAddress),
+		gasLimits:       make(map[phase0.BLSPubKey]uint64),
+		graffiti:        make(map[phase0.BLSPubKey]string),
+		protection:      validator.NewSlashingProtection(phase0.Root{}),
+		protected:       make(map[phase0.BLSPubKey]bool),
+	}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	return m
+}
+
+// ValidatorClient returns a ValidatorClient connected to the keymanager
+func (m *MockKeymanager) ValidatorClient(name string) *ValidatorClient {
+	return NewValidatorClient(name, m.Server.URL, m.Token, 5*time.Second)
+}
+
+// Protect puts the slashing protection of the keymanager in front of the validators, as if the client signed their messages
+func (m *MockKeymanager) Protect(validators ...*validator.Validator) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, v := range validators {
+		m.protected[v.ValidatorPublicKey] = true
+	}
+	m.protection.Protect(validators...)
+}
+
+// Close stops the keymanager
+func (m *MockKeymanager) Close() {
+	m.Server.Close()
+}
+
+func (m *MockKeymanager) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+m.Token {
+		mockError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	switch {
+	case r.URL.Path == "/eth/v1/keystores" && r.Method == http.MethodGet:
+		m.listKeystores(w)
+	case r.URL.Path == "/eth/v1/keystores" && r.Method == http.MethodPost:
+		m.importKeystores(w, r)
+	case r.URL.Path == "/eth/v1/keystores" && r.Method == http.MethodDelete:
+		m.deleteKeystores(w, r)
+	case r.URL.Path == "/eth/v1/remotekeys" && r.Method == http.MethodGet:
+		m.listRemoteKeys(w)
+	case r.URL.Path == "/eth/v1/remotekeys" && r.Method == http.MethodPost:
+		m.importRemoteKeys(w, r)
+	case r.URL.Path == "/eth/v1/remotekeys" && r.Method == http.MethodDelete:
+		m.deleteRemoteKeys(w, r)
+	case strings.HasPrefix(r.URL.Path, "/eth/v1/validator/"):
+		m.serveKey(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *MockKeymanager) listKeystores(w http.ResponseWriter) {
+	keystores := make([]*KeystoreInfo, 0, len(m.keystores))
+	for pubKey, keystore := range m.keystores {
+		keystores = append(keystores, &KeystoreInfo{ValidatingPubKey: pubKey, DerivationPath: keystore.path})
+	}
+	sort.Slice(keystores, func(i, j int) bool {
+		return keystores[i].ValidatingPubKey.String() < keystores[j].ValidatingPubKey.String()
+	})
+	mockData(w, keystores)
+}
+
+func (m *MockKeymanager) importKeystores(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Keystores          []string `json:"keystores"`
+		Passwords          []string `json:"passwords"`
+		SlashingProtection string   `json:"slashing_protection"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		mockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(request.Keystores) != len(request.Passwords) {
+		mockError(w, http.StatusBadRequest, "keystores and passwords differ in length")
+		return
+	}
+	// an invalid interchange fails the whole request, before any key is imported
+	if request.SlashingProtection != "" {
+		var interchange validator.SlashingInterchange
+		if err := json.Unmarshal([]byte(request.SlashingProtection), &interchange); err != nil {
+			mockError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := m.protection.Import([]byte(request.SlashingProtection)); err != nil {
+			mockError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for _, data := range interchange.Data {
+			m.protected[data.PubKey] = true
+		}
+	}
+
+	results := make([]*KeymanagerResult, len(request.Keystores))
+	for i, data := range request.Keystores {
+		keystore, err := decryptMockKeystore(data, request.Passwords[i])
+		switch {
+		case err != nil:
+			results[i] = &KeymanagerResult{Status: StatusError, Message: err.Error()}
+		case m.keystores[keystore.validator.ValidatorPublicKey] != nil || m.remoteKeys[keystore.validator.ValidatorPublicKey] != "":
+			results[i] = &KeymanagerResult{Status: StatusDuplicate}
+		default:
+			m.keystores[keystore.validator.ValidatorPublicKey] = keystore
+			results[i] = &KeymanagerResult{Status: StatusImported}
+		}
+	}
+	mockData(w, results)
+}
+
+func decryptMockKeystore(data string, password string) (*mockKeystore, error) {
+	var keystore validator.Keystore
+	if err := json.Unmarshal([]byte(data), &keystore); err != nil {
+		return nil, err
+	}
+	secret, err := keystore.Decrypt(password)
+	if err != nil {
+		return nil, err
+	}
+	key, err := e2types.BLSPrivateKeyFromBytes(secret)
+	if err != nil {
+		return nil, err
+	}
+	pubKey := phase0.BLSPubKey(key.PublicKey().Marshal())
+	if keystore.PubKey != "" && strings.TrimPrefix(keystore.PubKey, "0x") != hex.EncodeToString(pubKey[:]) {
+		return nil, fmt.Errorf("keystore pubkey %s doesn't match its secret", keystore.PubKey)
+	}
+	return &mockKeystore{
+		path:      keystore.Path,
+		validator: &validator.Validator{ValidatorKey: key, ValidatorPublicKey: pubKey},
+	}, nil
+}
+
+func (m *MockKeymanager) deleteKeystores(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		PubKeys []phase0.BLSPubKey `json:"pubkeys"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		mockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	results := make([]*KeymanagerResult, len(request.PubKeys))
+	var exported []phase0.BLSPubKey
+	for i, pubKey := range request.PubKeys {
+		switch {
+		case m.keystores[pubKey] != nil:
+			delete(m.keystores, pubKey)
+			results[i] = &KeymanagerResult{Status: StatusDeleted}
+		case m.protected[pubKey]:
+			results[i] = &KeymanagerResult{Status: StatusNotActive}
+		default:
+			results[i] = &KeymanagerResult{Status: StatusNotFound}
+			continue
+		}
+		if m.protected[pubKey] {
+			exported = append(exported, pubKey)
+		}
+	}
+
+	// Export without keys would export every key
+	interchange, err := json.Marshal(&validator.SlashingInterchange{
+		Metadata: validator.SlashingInterchangeMetadata{InterchangeFormatVersion: validator.InterchangeFormatVersion, GenesisValidatorsRoot: m.protection.GenesisValidatorsRoot},
+		Data:     []*validator.SlashingInterchangeData{},
+	})
+	if len(exported) > 0 {
+		interchange, err = m.protection.Export(exported...)
+	}
+	if err != nil {
+		mockError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	mockJSON(w, http.StatusOK, map[string]interface{}{"data": results, "slashing_protection": string(interchange)})
+}
+
+func (m *MockKeymanager) listRemoteKeys(w http.ResponseWriter) {
+	remoteKeys := make([]*RemoteKey, 0, len(m.remoteKeys))
+	for pubKey, url := range m.remoteKeys {
+		remoteKeys = append(remoteKeys, &RemoteKey{PubKey: pubKey, URL: url})
+	}
+	sort.Slice(remoteKeys, func(i, j int) bool { return remoteKeys[i].PubKey.String() < remoteKeys[j].PubKey.String() })
+	mockData(w, remoteKeys)
+}
+
+func (m *MockKeymanager) importRemoteKeys(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		RemoteKeys []*RemoteKey `json:"remote_keys"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		mockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	results := make([]*KeymanagerResult, len(request.RemoteKeys))
+	for i, remoteKey := range request.RemoteKeys {
+		switch {
+		case remoteKey.URL == "":
+			results[i] = &KeymanagerResult{Status: StatusError, Message: "missing url"}
+		case m.keystores[remoteKey.PubKey] != nil || m.remoteKeys[remoteKey.PubKey] != "":
+			results[i] = &KeymanagerResult{Status: StatusDuplicate}
+		default:
+			m.remoteKeys[remoteKey.PubKey] = remoteKey.URL
+			results[i] = &KeymanagerResult{Status: StatusImported}
+		}
+	}
+	mockData(w, results)
+}
+
+func (m *MockKeymanager) deleteRemoteKeys(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		PubKeys []phase0.BLSPubKey `json:"pubkeys"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		mockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	results := make([]*KeymanagerResult, len(request.PubKeys))
+	for i, pubKey := range request.PubKeys {
+		results[i] = &KeymanagerResult{Status: StatusNotFound}
+		if m.remoteKeys[pubKey] != "" {
+			delete(m.remoteKeys, pubKey)
+			results[i].Status = StatusDeleted
+		}
+	}
+	mockData(w, results)
+}
+
+// serveKey serves /eth/v1/validator/{pubkey}/{endpoint}
+func (m *MockKeymanager) serveKey(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/eth/v1/validator/"), "/")
+	var pubKey phase0.BLSPubKey
+	if len(parts) != 2 || json.Unmarshal([]byte(strconv.Quote(parts[0])), &pubKey) != nil {
+		mockError(w, http.StatusBadRequest, "invalid pubkey")
+		return
+	}
+	if m.keystores[pubKey] == nil && m.remoteKeys[pubKey] == "" {
+		mockError(w, http.StatusNotFound, fmt.Sprintf("validator %s not found", pubKey))
+		return
+	}
+
+	switch endpoint := parts[1]; {
+	case endpoint == "voluntary_exit" && r.Method == http.MethodPost:
+		m.signVoluntaryExit(w, r, pubKey)
+	case endpoint == "feerecipient" && r.Method == http.MethodDelete:
+		delete(m.feeRecipients, pubKey)
+		w.WriteHeader(http.StatusNoContent)
+	case endpoint == "gas_limit" && r.Method == http.MethodDelete:
+		delete(m.gasLimits, pubKey)
+		w.WriteHeader(http.StatusNoContent)
+	case endpoint == "graffiti" && r.Method == http.MethodDelete:
+		delete(m.graffiti, pubKey)
+		w.WriteHeader(http.StatusNoContent)
+	case endpoint == "feerecipient" && r.Method == http.MethodGet:
+		feeRecipient, ok := m.feeRecipients[pubKey]
+		if !ok {
+			feeRecipient = m.DefaultFeeRecipient
+		}
+		mockData(w, map[string]interface{}{"pubkey": pubKey, "ethaddress": feeRecipient})
+	case endpoint == "feerecipient" && r.Method == http.MethodPost:
+		var request struct {
+			EthAddress bellatrix.ExecutionAddress `json:"ethaddress"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			mockError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		m.feeRecipients[pubKey] = request.EthAddress
+		w.WriteHeader(http.StatusAccepted)
+	case endpoint == "gas_limit" && r.Method == http.MethodGet:
+		gasLimit, ok := m.gasLimits[pubKey]
+		if !ok {
+			gasLimit = m.DefaultGasLimit
+		}
+		mockData(w, map[string]interface{}{"pubkey": pubKey, "gas_limit": strconv.FormatUint(gasLimit, 10)})
+	case endpoint == "gas_limit" && r.Method == http.MethodPost:
+		var request struct {
+			GasLimit string `json:"gas_limit"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			mockError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		gasLimit, err := strconv.ParseUint(request.GasLimit, 10, 64)
+		if err != nil {
+			mockError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		m.gasLimits[pubKey] = gasLimit
+		w.WriteHeader(http.StatusAccepted)
+	case endpoint == "graffiti" && r.Method == http.MethodGet:
+		graffiti, ok := m.graffiti[pubKey]
+		if !ok {
+			graffiti = m.DefaultGraffiti
+		}
+		mockData(w, map[string]interface{}{"pubkey": pubKey, "graffiti": graffiti})
+	case endpoint == "graffiti" && r.Method == http.MethodPost:
+		var request struct {
+			Graffiti string `json:"graffiti"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			mockError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		m.graffiti[pubKey] = request.Graffiti
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// signVoluntaryExit signs with the keystore, or the remote signer of a remote key
+func (m *MockKeymanager) signVoluntaryExit(w http.ResponseWriter, r *http.Request, pubKey phase0.BLSPubKey) {
+	index, ok := m.Indices[pubKey]
+	if !ok {
+		mockError(w, http.StatusBadRequest, fmt.Sprintf("validator %s has no index", pubKey))
+		return
+	}
+	exit := &phase0.VoluntaryExit{Epoch: m.CurrentEpoch, ValidatorIndex: index}
+	if epoch := r.URL.Query().Get("epoch"); epoch != "" {
+		parsed, err := strconv.ParseUint(epoch, 10, 64)
+		if err != nil {
+			mockError(w, http.StatusBadRequest, "invalid epoch")
+			return
+		}
+		exit.Epoch = phase0.Epoch(parsed)
+	}
+	exitRoot, err := exit.HashTreeRoot()
+	if err != nil {
+		mockError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	domain := common.ComputeDomain(common.BLSDomainType{0x04}, common.Version(m.ForkVersion), common.Root(m.GenesisValidatorsRoot))
+	request := &validator.SigningRequest{
+		PubKey:        pubKey,
+		Type:          validator.SigningTypeVoluntaryExit,
+		ForkInfo:      &validator.ForkInfo{Fork: &phase0.Fork{PreviousVersion: m.ForkVersion, CurrentVersion: m.ForkVersion}, GenesisValidatorsRoot: m.GenesisValidatorsRoot},
+		SigningRoot:   phase0.Root(common.ComputeSigningRoot(exitRoot, domain)),
+		VoluntaryExit: exit,
+	}
+
+	v := validator.NewRemoteValidator(0, pubKey, phase0.BLSPubKey{}, validator.NewWeb3Signer(m.remoteKeys[pubKey]))
+	if keystore := m.keystores[pubKey]; keystore != nil {
+		v = keystore.validator
+	}
+	signature, err := v.Sign(r.Context(), request)
+	if err != nil {
+		mockError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	mockData(w, &phase0.SignedVoluntaryExit{Message: exit, Signature: signature})
+}
+
+func mockData(w http.ResponseWriter, data interface{}) {
+	mockJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+func mockError(w http.ResponseWriter, status int, message string) {
+	mockJSON(w, status, map[string]interface{}{"message": message})
+}
+
+func mockJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,89 @@
+package validator_client
+
+import (
+	"context"
+	"eth-testnet-tool/validator"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+)
+
+// MoveKeystores moves the keys of the validators from one client to another, with the slashing protection the first client kept for them.
+// The keys are deleted before they are imported so the clients never sign with them at the same time,
+// the keystores the second client gets are encrypted with the password.
+func MoveKeystores(ctx context.Context, from *ValidatorClient, to *ValidatorClient, validators []*validator.Validator, password string, opts validator.KeystoreOpts) error {
+	keystores := make([]*validator.Keystore, len(validators))
+	passwords := make([]string, len(validators))
+	pubKeys := make([]phase0.BLSPubKey, len(validators))
+	for i, v := range validators {
+		keystore, err := v.ValidatorKeystore(password, opts)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encrypt keystore of validator %d", v.ValidatorIndex)
+		}
+		keystores[i], passwords[i], pubKeys[i] = keystore, password, v.ValidatorPublicKey
+	}
+
+	deleted, slashingProtection, err := from.DeleteKeystores(ctx, pubKeys)
+	if err != nil {
+		return err
+	}
+	if err := checkResults(deleted, pubKeys, StatusDeleted, StatusNotActive, StatusNotFound); err != nil {
+		return errors.Wrapf(err, "failed to delete keystores from client: %s", from.Name)
+	}
+	imported, err := to.ImportKeystores(ctx, keystores, passwords, slashingProtection)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(checkResults(imported, pubKeys, StatusImported, StatusDuplicate), "failed to import keystores into client: %s", to.Name)
+}
+
+// MoveRemoteKeys moves the remote keys from one client to another, both clients have to reach the remote signers.
+// Remote signers keep their own slashing protection, so there is none to move.
+func MoveRemoteKeys(ctx context.Context, from *ValidatorClient, to *ValidatorClient, pubKeys []phase0.BLSPubKey) error {
+	remoteKeys, err := from.ListRemoteKeys(ctx)
+	if err != nil {
+		return err
+	}
+	urls := make(map[phase0.BLSPubKey]string)
+	for _, remoteKey := range remoteKeys {
+		urls[remoteKey.PubKey] = remoteKey.URL
+	}
+	moved := make([]*RemoteKey, len(pubKeys))
+	for i, pubKey := range pubKeys {
+		url, ok := urls[pubKey]
+		if !ok {
+			return fmt.Errorf("client %s has no remote key %s", from.Name, pubKey)
+		}
+		moved[i] = &RemoteKey{PubKey: pubKey, URL: url}
+	}
+
+	deleted, err := from.DeleteRemoteKeys(ctx, pubKeys)
+	if err != nil {
+		return err
+	}
+	if err := checkResults(deleted, pubKeys, StatusDeleted, StatusNotFound); err != nil {
+		return errors.Wrapf(err, "failed to delete remote keys from client: %s", from.Name)
+	}
+	imported, err := to.ImportRemoteKeys(ctx, moved)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(checkResults(imported, pubKeys, StatusImported, StatusDuplicate), "failed to import remote keys into client: %s", to.Name)
+}
+
+// checkResults returns an error for the first key whose status isn't one of the accepted ones
+func checkResults(results []*KeymanagerResult, pubKeys []phase0.BLSPubKey, accepted ...KeymanagerStatus) error {
+	if len(results) != len(pubKeys) {
+		return fmt.Errorf("got %d results for %d keys", len(results), len(pubKeys))
+	}
+	for i, result := range results {
+		ok := false
+		for _, status := range accepted {
+			ok = ok || result.Status == status
+		}
+		if !ok {
+			return fmt.Errorf("%s: %s %s", pubKeys[i], result.Status, result.Message)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package validator_client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"eth-testnet-tool/validator"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrKeyNotFound is returned when the validator client doesn't hold the key a per key endpoint was called for
+var ErrKeyNotFound = errors.New("validator client doesn't hold the key")
+
+// KeymanagerStatus the result of importing or deleting a single key
+type KeymanagerStatus string
+
+const (
+	StatusImported  KeymanagerStatus = "imported"
+	StatusDuplicate KeymanagerStatus = "duplicate"
+	StatusDeleted   KeymanagerStatus = "deleted"
+	// StatusNotActive the key isn't held, but the client has slashing protection data for it
+	StatusNotActive KeymanagerStatus = "not_active"
+	StatusNotFound  KeymanagerStatus = "not_found"
+	StatusError     KeymanagerStatus = "error"
+)
+
+// KeymanagerResult the status of a single key of an import or delete, in the order of the request
+type KeymanagerResult struct {
+	Status  KeymanagerStatus `json:"status"`
+	Message string           `json:"message,omitempty"`
+}
+
+// KeystoreInfo a key the validator client holds a keystore for
+type KeystoreInfo struct {
+	ValidatingPubKey phase0.BLSPubKey `json:"validating_pubkey"`
+	DerivationPath   string           `json:"derivation_path,omitempty"`
+	ReadOnly         bool             `json:"readonly"`
+}
+
+// RemoteKey a key the validator client signs with through a remote signer
+type RemoteKey struct {
+	PubKey   phase0.BLSPubKey `json:"pubkey"`
+	URL      string           `json:"url"`
+	ReadOnly bool             `json:"readonly,omitempty"`
+}
+
+// ValidatorClient wraps the Keymanager API of a single validator client
+type ValidatorClient struct {
+	Name          string
+	KeymanagerAPI string
+	// Token the bearer token the keymanager api requires
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewValidatorClient returns a client for the keymanager api at the endpoint
+func NewValidatorClient(name string, endpoint string, token string, timeout time.Duration) *ValidatorClient {
+	return &ValidatorClient{
+		Name:          name,
+		KeymanagerAPI: strings.TrimSuffix(endpoint, "/"),
+		Token:         token,
+		HTTPClient:    &http.Client{Timeout: timeout},
+	}
+}
+
+func (v *ValidatorClient) String() string {
+	return fmt.Sprintf("%s @ %s", v.Name, v.KeymanagerAPI)
+}
+
+// Keystores
+
+// ListKeystores returns the keys the client holds keystores for
+func (v *ValidatorClient) ListKeystores(ctx context.Context) ([]*KeystoreInfo, error) {
+	var response struct {
+		Data []*KeystoreInfo `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, "/eth/v1/keystores", nil, &response); err != nil {
+		return nil, errors.Wrapf(err, "failed to list keystores of client: %s", v.Name)
+	}
+	return response.Data, nil
+}
+
+// ImportKeystores imports the keystores, each with the password at the same position.
+// slashingProtection is an EIP-3076 interchange for the keys and may be nil.
+func (v *ValidatorClient) ImportKeystores(ctx context.Context, keystores []*validator.Keystore, passwords []string, slashingProtection []byte) ([]*KeymanagerResult, error) {
+	if len(keystores) != len(passwords) {
+		return nil, fmt.Errorf("got %d keystores but %d passwords", len(keystores), len(passwords))
+	}
+	request := struct {
+		Keystores          []string `json:"keystores"`
+		Passwords          []string `json:"passwords"`
+		SlashingProtection string   `json:"slashing_protection,omitempty"`
+	}{Passwords: passwords, SlashingProtection: string(slashingProtection)}
+	for _, keystore := range keystores {
+		// the api wants every keystore as a json string
+		data, err := json.Marshal(keystore)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal keystore")
+		}
+		request.Keystores = append(request.Keystores, string(data))
+	}
+	var response struct {
+		Data []*KeymanagerResult `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodPost, "/eth/v1/keystores", request, &response); err != nil {
+		return nil, errors.Wrapf(err, "failed to import keystores into client: %s", v.Name)
+	}
+	return response.Data, nil
+}
+
+// DeleteKeystores deletes the keystores of the keys and returns the slashing protection of the keys as an EIP-3076 interchange
+func (v *ValidatorClient) DeleteKeystores(ctx context.Context, pubKeys []phase0.BLSPubKey) ([]*KeymanagerResult, []byte, error) {
+	request := struct {
+		PubKeys []phase0.BLSPubKey `json:"pubkeys"`
+	}{PubKeys: pubKeys}
+	var response struct {
+		Data               []*KeymanagerResult `json:"data"`
+		SlashingProtection string              `json:"slashing_protection"`
+	}
+	if err := v.do(ctx, http.MethodDelete, "/eth/v1/keystores", request, &response); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to delete keystores from client: %s", v.Name)
+	}
+	return response.Data, []byte(response.SlashingProtection), nil
+}
+
+// Remote keys
+
+// ListRemoteKeys returns the keys the client signs with through remote signers
+func (v *ValidatorClient) ListRemoteKeys(ctx context.Context) ([]*RemoteKey, error) {
+	var response struct {
+		Data []*RemoteKey `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, "/eth/v1/remotekeys", nil, &response); err != nil {
+		return nil, errors.Wrapf(err, "failed to list remote keys of client: %s", v.Name)
+	}
+	return response.Data, nil
+}
+
+// ImportRemoteKeys makes the client sign for the keys with their remote signers
+func (v *ValidatorClient) ImportRemoteKeys(ctx context.Context, remoteKeys []*RemoteKey) ([]*KeymanagerResult, error) {
+	request := struct {
+		RemoteKeys []*RemoteKey `json:"remote_keys"`
+	}{RemoteKeys: remoteKeys}
+	var response struct {
+		Data []*KeymanagerResult `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodPost, "/eth/v1/remotekeys", request, &response); err != nil {
+		return nil, errors.Wrapf(err, "failed to import remote keys into client: %s", v.Name)
+	}
+	return response.Data, nil
+}
+
+// DeleteRemoteKeys stops the client from signing for the remote keys
+func (v *ValidatorClient) DeleteRemoteKeys(ctx context.Context, pubKeys []phase0.BLSPubKey) ([]*KeymanagerResult, error) {
+	request := struct {
+		PubKeys []phase0.BLSPubKey `json:"pubkeys"`
+	}{PubKeys: pubKeys}
+	var response struct {
+		Data []*KeymanagerResult `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodDelete, "/eth/v1/remotekeys", request, &response); err != nil {
+		return nil, errors.Wrapf(err, "failed to delete remote keys from client: %s", v.Name)
+	}
+	return response.Data, nil
+}
+
+// Per key configuration
+
+// GetFeeRecipient returns the fee recipient the client proposes with for the key
+func (v *ValidatorClient) GetFeeRecipient(ctx context.Context, pubKey phase0.BLSPubKey) (bellatrix.ExecutionAddress, error) {
+	var response struct {
+		Data struct {
+			EthAddress bellatrix.ExecutionAddress `json:"ethaddress"`
+		} `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, keyPath(pubKey, "feerecipient"), nil, &response); err != nil {
+		return bellatrix.ExecutionAddress{}, errors.Wrapf(err, "failed to get fee recipient of %s from client: %s", pubKey, v.Name)
+	}
+	return response.Data.EthAddress, nil
+}
+
+// SetFeeRecipient sets the fee recipient the client proposes with for the key
+func (v *ValidatorClient) SetFeeRecipient(ctx context.Context, pubKey phase0.BLSPubKey, feeRecipient bellatrix.ExecutionAddress) error {
+	request := struct {
+		EthAddress bellatrix.ExecutionAddress `json:"ethaddress"`
+	}{EthAddress: feeRecipient}
+	return errors.Wrapf(v.do(ctx, http.MethodPost, keyPath(pubKey, "feerecipient"), request, nil), "failed to set fee recipient of %s on client: %s", pubKey, v.Name)
+}
+
+// DeleteFeeRecipient makes the client fall back to its default fee recipient for the key
+func (v *ValidatorClient) DeleteFeeRecipient(ctx context.Context, pubKey phase0.BLSPubKey) error {
+	return errors.Wrapf(v.do(ctx, http.MethodDelete, keyPath(pubKey, "feerecipient"), nil, nil), "failed to delete fee recipient of %s on client: %s", pubKey, v.Name)
+}
+
+// GetGasLimit returns the gas limit the client registers with the builder for the key
+func (v *ValidatorClient) GetGasLimit(ctx context.Context, pubKey phase0.BLSPubKey) (uint64, error) {
+	var response struct {
+		Data struct {
+			GasLimit string `json:"gas_limit"`
+		} `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, keyPath(pubKey, "gas_limit"), nil, &response); err != nil {
+		return 0, errors.Wrapf(err, "failed to get gas limit of %s from client: %s", pubKey, v.Name)
+	}
+	gasLimit, err := strconv.ParseUint(response.Data.GasLimit, 10, 64)
+	return gasLimit, errors.Wrapf(err, "invalid gas limit from client: %s", v.Name)
+}
+
+// SetGasLimit sets the gas limit the client registers with the builder for the key
+func (v *ValidatorClient) SetGasLimit(ctx context.Context, pubKey phase0.BLSPubKey, gasLimit uint64) error {
+	request := struct {
+		GasLimit string `json:"gas_limit"`
+	}{GasLimit: strconv.FormatUint(gasLimit, 10)}
+	return errors.Wrapf(v.do(ctx, http.MethodPost, keyPath(pubKey, "gas_limit"), request, nil), "failed to set gas limit of %s on client: %s", pubKey, v.Name)
+}
+
+// DeleteGasLimit makes the client fall back to its default gas limit for the key
+func (v *ValidatorClient) DeleteGasLimit(ctx context.Context, pubKey phase0.BLSPubKey) error {
+	return errors.Wrapf(v.do(ctx, http.MethodDelete, keyPath(pubKey, "gas_limit"), nil, nil), "failed to delete gas limit of %s on client: %s", pubKey, v.Name)
+}
+
+// GetGraffiti returns the graffiti the client proposes with for the key
+func (v *ValidatorClient) GetGraffiti(ctx context.Context, pubKey phase0.BLSPubKey) (string, error) {
+	var response struct {
+		Data struct {
+			Graffiti string `json:"graffiti"`
+		} `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, keyPath(pubKey, "graffiti"), nil, &response); err != nil {
+		return "", errors.Wrapf(err, "failed to get graffiti of %s from client: %s", pubKey, v.Name)
+	}
+	return response.Data.Graffiti, nil
+}
+
+// SetGraffiti sets the graffiti the client proposes with for the key, at most 32 bytes
+func (v *ValidatorClient) SetGraffiti(ctx context.Context, pubKey phase0.BLSPubKey, graffiti string) error {
+	if len(graffiti) > 32 {
+		return fmt.Errorf("graffiti is %d bytes, at most 32 fit in a block", len(graffiti))
+	}
+	request := struct {
+		Graffiti string `json:"graffiti"`
+	}{Graffiti: graffiti}
+	return errors.Wrapf(v.do(ctx, http.MethodPost, keyPath(pubKey, "graffiti"), request, nil), "failed to set graffiti of %s on client: %s", pubKey, v.Name)
+}
+
+// DeleteGraffiti makes the client fall back to its default graffiti for the key
+func (v *ValidatorClient) DeleteGraffiti(ctx context.Context, pubKey phase0.BLSPubKey) error {
+	return errors.Wrapf(v.do(ctx, http.MethodDelete, keyPath(pubKey, "graffiti"), nil, nil), "failed to delete graffiti of %s on client: %s", pubKey, v.Name)
+}
+
+// SignVoluntaryExit has the client sign an exit for the key, for the epoch or the current epoch if epoch is nil.
+// The exit isn't submitted, the client only signs it.
+func (v *ValidatorClient) SignVoluntaryExit(ctx context.Context, pubKey phase0.BLSPubKey, epoch *phase0.Epoch) (*phase0.SignedVoluntaryExit, error) {
+	path := keyPath(pubKey, "voluntary_exit")
+	if epoch != nil {
+		path += "?" + url.Values{"epoch": {strconv.FormatUint(uint64(*epoch), 10)}}.Encode()
+	}
+	var response struct {
+		Data *phase0.SignedVoluntaryExit `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodPost, path, nil, &response); err != nil {
+		return nil, errors.Wrapf(err, "failed to sign voluntary exit of %s with client: %s", pubKey, v.Name)
+	}
+	return response.Data, nil
+}
+
+func keyPath(pubKey phase0.BLSPubKey, endpoint string) string {
+	return fmt.Sprintf("/eth/v1/validator/%#x/%s", pubKey, endpoint)
+}
+
+// do sends the request with the bearer token, out may be nil for endpoints that answer without a body
+func (v *ValidatorClient) do(ctx context.Context, method string, path string, request interface{}, out interface{}) error {
+	var body io.Reader
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal request")
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.KeymanagerAPI+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+v.Token)
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := v.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound && strings.HasPrefix(path, "/eth/v1/validator/") {
+		return errors.Wrap(ErrKeyNotFound, strings.TrimSpace(string(data)))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiError struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &apiError) == nil && apiError.Message != "" {
+			return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, apiError.Message)
+		}
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal(data, out), "failed to decode keymanager response")
+}
@@ -0,0 +1,252 @@
+package validator_client
+
+import (
+	"context"
+	"encoding/json"
+	"eth-testnet-tool/validator"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/stretchr/testify/require"
+	e2types "github.com/wealdtech/go-eth2-types/v2"
+	"testing"
+	"time"
+)
+
+const (
+	ValidatorMnemonic = "ocean style run case glory clip into nature guess jacket document firm fiscal hello kite disagree symptom tide net coral envelope wink render festival"
+	KeymanagerToken   = "mock-token"
+	Timeout           = 3 * time.Second
+)
+
+func getTestValidators(t *testing.T, count uint64) []*validator.Validator {
+	validators, err := validator.GetValidatorsFromMnemonic(ValidatorMnemonic, 0, count)
+	require.NoError(t, err)
+	return validators
+}
+
+func getMockKeymanager(t *testing.T) (*MockKeymanager, *ValidatorClient) {
+	mock := NewMockKeymanager(KeymanagerToken)
+	t.Cleanup(mock.Close)
+	return mock, mock.ValidatorClient("mock")
+}
+
+func importTestKeystores(t *testing.T, client *ValidatorClient, validators ...*validator.Validator) {
+	var keystores []*validator.Keystore
+	var passwords []string
+	for _, v := range validators {
+		keystore, err := v.ValidatorKeystore("password", validator.KeystoreOpts{LightKDF: true})
+		require.NoError(t, err)
+		keystores = append(keystores, keystore)
+		passwords = append(passwords, "password")
+	}
+	results, err := client.ImportKeystores(context.Background(), keystores, passwords, nil)
+	require.NoError(t, err)
+	for _, result := range results {
+		require.Equal(t, StatusImported, result.Status, result.Message)
+	}
+}
+
+func TestValidatorClient_Keystores(t *testing.T) {
+	_, client := getMockKeymanager(t)
+	validators := getTestValidators(t, 2)
+	ctx := context.Background()
+
+	importTestKeystores(t, client, validators...)
+	keystores, err := client.ListKeystores(ctx)
+	require.NoError(t, err)
+	require.Len(t, keystores, 2)
+	require.ElementsMatch(t, []phase0.BLSPubKey{validators[0].ValidatorPublicKey, validators[1].ValidatorPublicKey},
+		[]phase0.BLSPubKey{keystores[0].ValidatingPubKey, keystores[1].ValidatingPubKey})
+
+	// a key that is already held is a duplicate, a wrong password an error for that key only
+	keystore, err := validators[0].ValidatorKeystore("password", validator.KeystoreOpts{LightKDF: true})
+	require.NoError(t, err)
+	results, err := client.ImportKeystores(ctx, []*validator.Keystore{keystore, keystore}, []string{"password", "wrong"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, StatusDuplicate, results[0].Status)
+	require.Equal(t, StatusError, results[1].Status)
+	_, err = client.ImportKeystores(ctx, []*validator.Keystore{keystore}, nil, nil)
+	require.Error(t, err)
+
+	results, slashingProtection, err := client.DeleteKeystores(ctx, []phase0.BLSPubKey{validators[0].ValidatorPublicKey, {0x01}})
+	require.NoError(t, err)
+	require.Equal(t, StatusDeleted, results[0].Status)
+	require.Equal(t, StatusNotFound, results[1].Status)
+	var interchange validator.SlashingInterchange
+	require.NoError(t, json.Unmarshal(slashingProtection, &interchange))
+	require.Equal(t, validator.InterchangeFormatVersion, interchange.Metadata.InterchangeFormatVersion)
+	keystores, err = client.ListKeystores(ctx)
+	require.NoError(t, err)
+	require.Len(t, keystores, 1)
+}
+
+func TestValidatorClient_Token(t *testing.T) {
+	mock, _ := getMockKeymanager(t)
+	_, err := NewValidatorClient("mock", mock.Server.URL, "wrong", Timeout).ListKeystores(context.Background())
+	require.ErrorContains(t, err, "401")
+}
+
+func TestValidatorClient_RemoteKeys(t *testing.T) {
+	_, client := getMockKeymanager(t)
+	validators := getTestValidators(t, 1)
+	ctx := context.Background()
+	remoteKey := &RemoteKey{PubKey: validators[0].ValidatorPublicKey, URL: "http://web3signer:9000"}
+
+	results, err := client.ImportRemoteKeys(ctx, []*RemoteKey{remoteKey, remoteKey})
+	require.NoError(t, err)
+	require.Equal(t, StatusImported, results[0].Status)
+	require.Equal(t, StatusDuplicate, results[1].Status)
+	remoteKeys, err := client.ListRemoteKeys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []*RemoteKey{remoteKey}, remoteKeys)
+
+	results, err = client.DeleteRemoteKeys(ctx, []phase0.BLSPubKey{remoteKey.PubKey, remoteKey.PubKey})
+	require.NoError(t, err)
+	require.Equal(t, StatusDeleted, results[0].Status)
+	require.Equal(t, StatusNotFound, results[1].Status)
+}
+
+func TestValidatorClient_KeyConfig(t *testing.T) {
+	mock, client := getMockKeymanager(t)
+	mock.DefaultFeeRecipient = bellatrix.ExecutionAddress{0xfe}
+	validators := getTestValidators(t, 1)
+	pubKey := validators[0].ValidatorPublicKey
+	ctx := context.Background()
+
+	_, err := client.GetFeeRecipient(ctx, pubKey)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	importTestKeystores(t, client, validators...)
+
+	feeRecipient, err := client.GetFeeRecipient(ctx, pubKey)
+	require.NoError(t, err)
+	require.Equal(t, bellatrix.ExecutionAddress{0xfe}, feeRecipient)
+	require.NoError(t, client.SetFeeRecipient(ctx, pubKey, bellatrix.ExecutionAddress{0x01}))
+	feeRecipient, err = client.GetFeeRecipient(ctx, pubKey)
+	require.NoError(t, err)
+	require.Equal(t, bellatrix.ExecutionAddress{0x01}, feeRecipient)
+
+	require.NoError(t, client.SetGasLimit(ctx, pubKey, 36000000))
+	gasLimit, err := client.GetGasLimit(ctx, pubKey)
+	require.NoError(t, err)
+	require.Equal(t, uint64(36000000), gasLimit)
+	require.NoError(t, client.SetGraffiti(ctx, pubKey, "eth-testnet-tool"))
+	graffiti, err := client.GetGraffiti(ctx, pubKey)
+	require.NoError(t, err)
+	require.Equal(t, "eth-testnet-tool", graffiti)
+	require.Error(t, client.SetGraffiti(ctx, pubKey, "a graffiti that is longer than 32 bytes"))
+
+	// deleting one setting leaves the others
+	require.NoError(t, client.DeleteGasLimit(ctx, pubKey))
+	gasLimit, err = client.GetGasLimit(ctx, pubKey)
+	require.NoError(t, err)
+	require.Equal(t, uint64(30000000), gasLimit)
+	feeRecipient, err = client.GetFeeRecipient(ctx, pubKey)
+	require.NoError(t, err)
+	require.Equal(t, bellatrix.ExecutionAddress{0x01}, feeRecipient)
+	require.NoError(t, client.DeleteFeeRecipient(ctx, pubKey))
+	require.NoError(t, client.DeleteGraffiti(ctx, pubKey))
+	graffiti, err = client.GetGraffiti(ctx, pubKey)
+	require.NoError(t, err)
+	require.Empty(t, graffiti)
+}
+
+func TestValidatorClient_SignVoluntaryExit(t *testing.T) {
+	mock, client := getMockKeymanager(t)
+	mock.ForkVersion, mock.GenesisValidatorsRoot, mock.CurrentEpoch = phase0.Version{0x03}, phase0.Root{0x42}, 9
+	validators := getTestValidators(t, 2)
+	mock.Indices[validators[0].ValidatorPublicKey] = 5
+	mock.Indices[validators[1].ValidatorPublicKey] = 6
+	ctx := context.Background()
+
+	// one key is held in a keystore, the other in a remote signer
+	importTestKeystores(t, client, validators[0])
+	signer := validator.NewMockWeb3Signer(validators[1])
+	t.Cleanup(signer.Close)
+	_, err := client.ImportRemoteKeys(ctx, []*RemoteKey{{PubKey: validators[1].ValidatorPublicKey, URL: signer.Server.URL}})
+	require.NoError(t, err)
+
+	epoch := phase0.Epoch(4)
+	for i, v := range validators {
+		exit, err := client.SignVoluntaryExit(ctx, v.ValidatorPublicKey, &epoch)
+		require.NoError(t, err)
+		require.Equal(t, phase0.VoluntaryExit{Epoch: 4, ValidatorIndex: phase0.ValidatorIndex(5 + i)}, *exit.Message)
+		root, err := exit.Message.HashTreeRoot()
+		require.NoError(t, err)
+		signingRoot := common.ComputeSigningRoot(root, common.ComputeDomain(common.BLSDomainType{0x04}, common.Version{0x03}, common.Root{0x42}))
+		// cgo refuses memory that holds go pointers, so the signature and key are copied out first
+		signatureBytes, pubKeyBytes := exit.Signature, v.ValidatorPublicKey
+		signature, err := e2types.BLSSignatureFromBytes(signatureBytes[:])
+		require.NoError(t, err)
+		pubKey, err := e2types.BLSPublicKeyFromBytes(pubKeyBytes[:])
+		require.NoError(t, err)
+		require.True(t, signature.Verify(signingRoot[:], pubKey))
+	}
+	require.Len(t, signer.Requests(), 1)
+
+	exit, err := client.SignVoluntaryExit(ctx, validators[0].ValidatorPublicKey, nil)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Epoch(9), exit.Message.Epoch)
+}
+
+func TestMoveKeystores(t *testing.T) {
+	source, from := getMockKeymanager(t)
+	_, to := getMockKeymanager(t)
+	validators := getTestValidators(t, 3)
+	ctx := context.Background()
+	importTestKeystores(t, from, validators...)
+
+	// the history the first client signed with has to follow the keys
+	source.Protect(validators[0])
+	_, err := validators[0].Sign(ctx, &validator.SigningRequest{
+		PubKey:      validators[0].ValidatorPublicKey,
+		Type:        validator.SigningTypeBlockV2,
+		ForkInfo:    &validator.ForkInfo{Fork: &phase0.Fork{}, GenesisValidatorsRoot: phase0.Root{0x42}},
+		SigningRoot: phase0.Root{0xaa},
+		BeaconBlock: &validator.SigningBeaconBlock{Version: "CAPELLA", BlockHeader: &phase0.BeaconBlockHeader{Slot: 7}},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, MoveKeystores(ctx, from, to, validators[:2], "moved", validator.KeystoreOpts{LightKDF: true}))
+	left, err := from.ListKeystores(ctx)
+	require.NoError(t, err)
+	require.Len(t, left, 1)
+	require.Equal(t, validators[2].ValidatorPublicKey, left[0].ValidatingPubKey)
+	moved, err := to.ListKeystores(ctx)
+	require.NoError(t, err)
+	require.Len(t, moved, 2)
+
+	// the second client imported the history, deleting the key there hands it out again
+	results, slashingProtection, err := to.DeleteKeystores(ctx, []phase0.BLSPubKey{validators[0].ValidatorPublicKey})
+	require.NoError(t, err)
+	require.Equal(t, StatusDeleted, results[0].Status)
+	var interchange validator.SlashingInterchange
+	require.NoError(t, json.Unmarshal(slashingProtection, &interchange))
+	require.Equal(t, phase0.Root{0x42}, interchange.Metadata.GenesisValidatorsRoot)
+	require.Len(t, interchange.Data, 1)
+	require.Equal(t, phase0.Slot(7), interchange.Data[0].SignedBlocks[0].Slot)
+
+	// a deleted key with history is not active on the first client
+	results, _, err = from.DeleteKeystores(ctx, []phase0.BLSPubKey{validators[0].ValidatorPublicKey})
+	require.NoError(t, err)
+	require.Equal(t, StatusNotActive, results[0].Status)
+}
+
+func TestMoveRemoteKeys(t *testing.T) {
+	_, from := getMockKeymanager(t)
+	_, to := getMockKeymanager(t)
+	validators := getTestValidators(t, 1)
+	ctx := context.Background()
+	remoteKey := &RemoteKey{PubKey: validators[0].ValidatorPublicKey, URL: "http://web3signer:9000"}
+	_, err := from.ImportRemoteKeys(ctx, []*RemoteKey{remoteKey})
+	require.NoError(t, err)
+
+	require.NoError(t, MoveRemoteKeys(ctx, from, to, []phase0.BLSPubKey{remoteKey.PubKey}))
+	remoteKeys, err := to.ListRemoteKeys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []*RemoteKey{remoteKey}, remoteKeys)
+	remoteKeys, err = from.ListRemoteKeys(ctx)
+	require.NoError(t, err)
+	require.Empty(t, remoteKeys)
+	require.Error(t, MoveRemoteKeys(ctx, from, to, []phase0.BLSPubKey{remoteKey.PubKey}))
+}
@@ -0,0 +1,363 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"eth-testnet-tool/consensus_client"
+	"eth-testnet-tool/validator"
+	"fmt"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// farFutureEpoch is FAR_FUTURE_EPOCH, the epoch of transitions that aren't scheduled yet
+const farFutureEpoch = phase0.Epoch(^uint64(0))
+
+// LifecycleSpec the spec values that predict when validators move through their lifecycle
+type LifecycleSpec struct {
+	MaxSeedLookahead                 uint64
+	ShardCommitteePeriod             uint64
+	MinValidatorWithdrawabilityDelay uint64
+	MinPerEpochChurnLimit            uint64
+	ChurnLimitQuotient               uint64
+	// MaxPerEpochActivationChurnLimit caps the activation churn since deneb, 0 if the client doesn't have it
+	MaxPerEpochActivationChurnLimit uint64
+}
+
+// LifecycleSpecFromClientViewContext reads the lifecycle spec values from the clients spec
+func LifecycleSpecFromClientViewContext(ctx context.Context, consensusClient *consensus_client.ConsensusClient) (*LifecycleSpec, error) {
+	spec := &LifecycleSpec{}
+	for key, value := range map[string]*uint64{
+		"MAX_SEED_LOOKAHEAD":                  &spec.MaxSeedLookahead,
+		"SHARD_COMMITTEE_PERIOD":              &spec.ShardCommitteePeriod,
+		"MIN_VALIDATOR_WITHDRAWABILITY_DELAY": &spec.MinValidatorWithdrawabilityDelay,
+		"MIN_PER_EPOCH_CHURN_LIMIT":           &spec.MinPerEpochChurnLimit,
+		"CHURN_LIMIT_QUOTIENT":                &spec.ChurnLimitQuotient,
+	} {
+		n, err := consensusClient.SpecCache().Uint64(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		*value = n
+	}
+	// pre-deneb clients don't have the activation churn limit
+	if n, err := consensusClient.SpecCache().Uint64(ctx, "MAX_PER_EPOCH_ACTIVATION_CHURN_LIMIT"); err == nil {
+		spec.MaxPerEpochActivationChurnLimit = n
+	}
+	return spec, nil
+}
+
+// LifecycleTransition a change of the status of a validator
+type LifecycleTransition struct {
+	From v1.ValidatorState
+	To   v1.ValidatorState
+	// Epoch the epoch the new status was first observed at
+	Epoch phase0.Epoch
+	At    time.Time
+}
+
+// LifecycleAnomaly a transition that happened earlier or later than the spec rules allow
+type LifecycleAnomaly struct {
+	Index phase0.ValidatorIndex
+	// Transition the observed transition, nil for anomalies in the epochs the client scheduled
+	Transition *LifecycleTransition
+	// Epoch the epoch that was observed or scheduled
+	Epoch phase0.Epoch
+	// Earliest and Latest the window the spec rules predict for Epoch
+	Earliest phase0.Epoch
+	Latest   phase0.Epoch
+	Reason   string
+}
+
+// Early returns true if the epoch is before the predicted window, otherwise it is after it
+func (a *LifecycleAnomaly) Early() bool {
+	return a.Epoch < a.Earliest
+}
+
+func (a *LifecycleAnomaly) String() string {
+	when := "late"
+	if a.Early() {
+		when = "early"
+	}
+	return fmt.Sprintf("validator %d: %s %s at epoch %d, expected %d-%d", a.Index, a.Reason, when, a.Epoch, a.Earliest, a.Latest)
+}
+
+// ValidatorLifecycle the observed lifecycle of one of our validators
+type ValidatorLifecycle struct {
+	Validator *validator.Validator
+	// Index the on chain index, only valid once InState is true
+	Index   phase0.ValidatorIndex
+	InState bool
+	Status  v1.ValidatorState
+	// Record the last validator record seen
+	Record      *phase0.Validator
+	Transitions []*LifecycleTransition
+	Anomalies   []*LifecycleAnomaly
+
+	// activationEarliest and activationLatest the activation epochs predicted from the queue when the validator was first seen queued
+	activationEarliest phase0.Epoch
+	activationLatest   phase0.Epoch
+	// exitLatest the latest exit epoch predicted from the exit queue when the validator was first seen exiting
+	exitLatest phase0.Epoch
+}
+
+// LifecycleTrackerOpts configures a LifecycleTracker
+type LifecycleTrackerOpts struct {
+	// PollInterval how often the beacon state is polled, defaults to the slot duration
+	PollInterval time.Duration
+	// ToleranceEpochs how many epochs after the predicted window a transition may be observed, covering the poll interval and late finality
+	ToleranceEpochs uint64
+	// Spec the lifecycle spec values, read from the client on the first poll if nil
+	Spec *LifecycleSpec
+	// OnAnomaly is called for every anomaly when it is found
+	OnAnomaly func(anomaly LifecycleAnomaly)
+}
+
+// LifecycleTracker follows our validators through pending, active, exiting and withdrawal statuses
+type LifecycleTracker struct {
+	client *consensus_client.ConsensusClient
+	opts   LifecycleTrackerOpts
+
+	mu         sync.Mutex
+	lifecycles map[phase0.BLSPubKey]*ValidatorLifecycle
+}
+
+// NewLifecycleTracker creates a tracker of the managers validators reading the beacon state of a random client,
+// validators deposited later can be added with Track
+func (c *ClientManager) NewLifecycleTracker(opts LifecycleTrackerOpts) *LifecycleTracker {
+	opts.PollInterval = c.pollIntervalOrDefault(opts.PollInterval)
+	tracker := &LifecycleTracker{
+		client:     c.GetRandomConsensusClient(),
+		opts:       opts,
+		lifecycles: make(map[phase0.BLSPubKey]*ValidatorLifecycle),
+	}
+	tracker.Track(c.Validators...)
+	return tracker
+}
+
+// Track adds the validators to the tracker
+func (t *LifecycleTracker) Track(validators ...*validator.Validator) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, v := range validators {
+		if _, ok := t.lifecycles[v.ValidatorPublicKey]; !ok {
+			t.lifecycles[v.ValidatorPublicKey] = &ValidatorLifecycle{Validator: v, Status: v1.ValidatorStateUnknown}
+		}
+	}
+}
+
+// Run polls the beacon state each PollInterval until ctx is done
+func (t *LifecycleTracker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(t.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := t.PollContext(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll reads the validators from the clients head state and records their transitions
+func (t *LifecycleTracker) Poll() error {
+	return t.PollContext(context.Background())
+}
+
+// PollContext reads the validators from the clients head state and records their transitions
+func (t *LifecycleTracker) PollContext(ctx context.Context) error {
+	if t.opts.Spec == nil {
+		spec, err := LifecycleSpecFromClientViewContext(ctx, t.client)
+		if err != nil {
+			return errors.Wrap(err, "failed to get lifecycle spec")
+		}
+		t.opts.Spec = spec
+	}
+	epoch, err := t.client.GetCurrentEpochContext(ctx)
+	if err != nil {
+		return err
+	}
+	validators, err := t.client.GetAllValidatorsContext(ctx, "head")
+	if err != nil {
+		return err
+	}
+	t.Observe(epoch, validators)
+	return nil
+}
+
+// Observe records the status of our validators in the state of the epoch and checks new transitions against the spec rules
+func (t *LifecycleTracker) Observe(epoch phase0.Epoch, validators map[phase0.ValidatorIndex]*v1.Validator) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	queues := newLifecycleQueues(t.opts.Spec, validators)
+	for _, clientValidator := range validators {
+		lifecycle, ok := t.lifecycles[clientValidator.Validator.PublicKey]
+		if !ok {
+			continue
+		}
+		lifecycle.Index = clientValidator.Index
+		lifecycle.InState = true
+		lifecycle.Record = clientValidator.Validator
+		if clientValidator.Status == lifecycle.Status {
+			continue
+		}
+		transition := &LifecycleTransition{From: lifecycle.Status, To: clientValidator.Status, Epoch: epoch, At: now}
+		lifecycle.Status = clientValidator.Status
+		lifecycle.Transitions = append(lifecycle.Transitions, transition)
+		for _, anomaly := range t.checkTransition(lifecycle, transition, queues) {
+			lifecycle.Anomalies = append(lifecycle.Anomalies, anomaly)
+			if t.opts.OnAnomaly != nil {
+				t.opts.OnAnomaly(*anomaly)
+			}
+		}
+	}
+}
+
+// checkTransition predicts when the validator should have entered its new status and returns the anomalies
+func (t *LifecycleTracker) checkTransition(lifecycle *ValidatorLifecycle, transition *LifecycleTransition, queues *lifecycleQueues) []*LifecycleAnomaly {
+	spec := t.opts.Spec
+	record := lifecycle.Record
+	tolerance := phase0.Epoch(t.opts.ToleranceEpochs)
+	lookahead := phase0.Epoch(spec.MaxSeedLookahead)
+	var anomalies []*LifecycleAnomaly
+	check := func(observed phase0.Epoch, earliest phase0.Epoch, latest phase0.Epoch, reason string, withTransition bool) {
+		// the first sighting of a validator says nothing about when it changed status
+		if withTransition && transition.From == v1.ValidatorStateUnknown {
+			return
+		}
+		if observed >= earliest && observed <= latest {
+			return
+		}
+		anomaly := &LifecycleAnomaly{Index: lifecycle.Index, Epoch: observed, Earliest: earliest, Latest: latest, Reason: reason}
+		if withTransition {
+			anomaly.Transition = transition
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+
+	switch transition.To {
+	case v1.ValidatorStatePendingQueued:
+		// eligibility has to be finalized before the validator is dequeued, then everyone ahead in the queue,
+		// ordered by eligibility, is activated first at the activation churn per epoch
+		if record.ActivationEpoch != farFutureEpoch {
+			lifecycle.activationEarliest, lifecycle.activationLatest = record.ActivationEpoch, record.ActivationEpoch
+			break
+		}
+		dequeue := max(record.ActivationEligibilityEpoch+2, transition.Epoch+1) + lookahead
+		wait := phase0.Epoch(queues.activationQueueAhead(record, lifecycle.Index) / queues.activationChurn)
+		lifecycle.activationEarliest, lifecycle.activationLatest = dequeue+wait, dequeue+wait+tolerance
+	case v1.ValidatorStateActiveOngoing:
+		earliest, latest := lifecycle.activationEarliest, lifecycle.activationLatest
+		if latest == 0 {
+			// not seen queued, only the finality of the eligibility bounds the activation
+			earliest, latest = record.ActivationEligibilityEpoch+2+lookahead, record.ActivationEpoch
+			if record.ActivationEligibilityEpoch == 0 {
+				earliest = 0 // genesis validators
+			}
+		}
+		check(record.ActivationEpoch, earliest, latest, "scheduled activation", false)
+		check(transition.Epoch, record.ActivationEpoch, record.ActivationEpoch+tolerance, "activation", true)
+	case v1.ValidatorStateActiveExiting:
+		// voluntary exits are only valid SHARD_COMMITTEE_PERIOD epochs after activation, and take effect after the lookahead
+		earliest := record.ActivationEpoch + phase0.Epoch(spec.ShardCommitteePeriod) + 1 + lookahead
+		ahead := queues.exitQueueAhead(transition.Epoch+1+lookahead, lifecycle.Index)
+		lifecycle.exitLatest = transition.Epoch + 1 + lookahead + phase0.Epoch(ahead/queues.exitChurn) + tolerance
+		check(record.ExitEpoch, earliest, lifecycle.exitLatest, "scheduled exit", false)
+	case v1.ValidatorStateExitedUnslashed, v1.ValidatorStateExitedSlashed:
+		check(transition.Epoch, record.ExitEpoch, record.ExitEpoch+tolerance, "exit", true)
+	case v1.ValidatorStateWithdrawalPossible:
+		if !record.Slashed {
+			withdrawable := record.ExitEpoch + phase0.Epoch(spec.MinValidatorWithdrawabilityDelay)
+			check(record.WithdrawableEpoch, withdrawable, withdrawable, "scheduled withdrawability", false)
+		}
+		check(transition.Epoch, record.WithdrawableEpoch, record.WithdrawableEpoch+tolerance, "withdrawability", true)
+	}
+	// withdrawal_done depends on the withdrawal sweep reaching the validator, which the spec doesn't bound
+	return anomalies
+}
+
+// Lifecycles returns the lifecycle of every tracked validator ordered by derivation index
+func (t *LifecycleTracker) Lifecycles() []*ValidatorLifecycle {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lifecycles := make([]*ValidatorLifecycle, 0, len(t.lifecycles))
+	for _, lifecycle := range t.lifecycles {
+		lifecycles = append(lifecycles, lifecycle)
+	}
+	sort.Slice(lifecycles, func(i, j int) bool {
+		return lifecycles[i].Validator.ValidatorIndex < lifecycles[j].Validator.ValidatorIndex
+	})
+	return lifecycles
+}
+
+// Lifecycle returns the lifecycle of the validator, nil if it isn't tracked
+func (t *LifecycleTracker) Lifecycle(pubKey phase0.BLSPubKey) *ValidatorLifecycle {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lifecycles[pubKey]
+}
+
+// Anomalies returns the anomalies of every tracked validator
+func (t *LifecycleTracker) Anomalies() []*LifecycleAnomaly {
+	var anomalies []*LifecycleAnomaly
+	for _, lifecycle := range t.Lifecycles() {
+		anomalies = append(anomalies, lifecycle.Anomalies...)
+	}
+	return anomalies
+}
+
+// lifecycleQueues the churn and queues of the state a transition was observed in
+type lifecycleQueues struct {
+	validators      map[phase0.ValidatorIndex]*v1.Validator
+	activationChurn uint64
+	exitChurn       uint64
+}
+
+func newLifecycleQueues(spec *LifecycleSpec, validators map[phase0.ValidatorIndex]*v1.Validator) *lifecycleQueues {
+	active := uint64(0)
+	for _, v := range validators {
+		if v.Status.IsActive() {
+			active++
+		}
+	}
+	churn := max(spec.MinPerEpochChurnLimit, active/max(spec.ChurnLimitQuotient, 1))
+	churn = max(churn, 1)
+	activationChurn := churn
+	if spec.MaxPerEpochActivationChurnLimit > 0 {
+		activationChurn = min(churn, spec.MaxPerEpochActivationChurnLimit)
+	}
+	return &lifecycleQueues{validators: validators, activationChurn: activationChurn, exitChurn: churn}
+}
+
+// activationQueueAhead counts the queued validators that are activated before the validator
+func (q *lifecycleQueues) activationQueueAhead(record *phase0.Validator, index phase0.ValidatorIndex) uint64 {
+	ahead := uint64(0)
+	for _, v := range q.validators {
+		if v.Status != v1.ValidatorStatePendingQueued || v.Index == index {
+			continue
+		}
+		eligibility := v.Validator.ActivationEligibilityEpoch
+		if eligibility < record.ActivationEligibilityEpoch || (eligibility == record.ActivationEligibilityEpoch && v.Index < index) {
+			ahead++
+		}
+	}
+	return ahead
+}
+
+// exitQueueAhead counts the other validators exiting at or after the earliest exit epoch, they can push the exit back
+func (q *lifecycleQueues) exitQueueAhead(earliestExit phase0.Epoch, index phase0.ValidatorIndex) uint64 {
+	ahead := uint64(0)
+	for _, v := range q.validators {
+		if v.Index != index && v.Validator.ExitEpoch != farFutureEpoch && v.Validator.ExitEpoch >= earliestExit {
+			ahead++
+		}
+	}
+	return ahead
+}
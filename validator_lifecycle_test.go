@@ -0,0 +1,110 @@
+package eth_testnet_tool
+
+import (
+	"eth-testnet-tool/consensus_client"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestLifecycleTracker_Mock(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	manager := &ClientManager{
+		ConsensusClients: map[string]*consensus_client.ConsensusClient{"mock": client},
+		Validators:       validators,
+		SlotsPerEpoch:    8,
+	}
+	advanceToEpoch := func(epoch phase0.Epoch) {
+		require.NoError(t, node.AddBlock(consensus_client.NewMockCapellaBlock(phase0.Slot(epoch)*8, 0, phase0.Root{})))
+	}
+
+	// validator 3 is deposited after genesis and waits in the activation queue, the statuses follow from the record epochs
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.Spec["SHARD_COMMITTEE_PERIOD"] = "4"
+		state.Validators[3].Validator.ActivationEligibilityEpoch = 1
+		state.Validators[3].Validator.ActivationEpoch = farFutureEpoch
+	})
+	advanceToEpoch(2)
+	var anomalies []LifecycleAnomaly
+	tracker := manager.NewLifecycleTracker(LifecycleTrackerOpts{
+		ToleranceEpochs: 1,
+		OnAnomaly:       func(anomaly LifecycleAnomaly) { anomalies = append(anomalies, anomaly) },
+	})
+	require.NoError(t, tracker.Poll())
+	require.Len(t, tracker.Lifecycles(), len(validators))
+	require.Equal(t, v1.ValidatorStatePendingQueued, tracker.Lifecycles()[3].Status)
+	require.Empty(t, anomalies)
+
+	// 3 is activated at the first epoch finality allows, 5 exits after the shard committee period and 6 before it
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.Validators[3].Validator.ActivationEpoch = 7
+		state.Validators[5].Validator.ExitEpoch = 12
+		state.Validators[5].Validator.WithdrawableEpoch = 12 + 256
+		state.Validators[6].Validator.ExitEpoch = 8
+		state.Validators[6].Validator.WithdrawableEpoch = 8 + 256
+	})
+	advanceToEpoch(7)
+	require.NoError(t, tracker.Poll())
+	lifecycle := tracker.Lifecycle(validators[3].ValidatorPublicKey)
+	require.Equal(t, phase0.ValidatorIndex(3), lifecycle.Index)
+	require.Len(t, lifecycle.Transitions, 2)
+	require.Equal(t, phase0.Epoch(7), lifecycle.Transitions[1].Epoch)
+	require.Empty(t, lifecycle.Anomalies)
+	require.Len(t, anomalies, 1)
+	require.Equal(t, phase0.ValidatorIndex(6), anomalies[0].Index)
+	require.True(t, anomalies[0].Early())
+	require.Nil(t, anomalies[0].Transition)
+	require.Equal(t, phase0.Epoch(9), anomalies[0].Earliest)
+
+	// 6 is seen exited on time, 5 only well after its exit epoch
+	advanceToEpoch(9)
+	require.NoError(t, tracker.Poll())
+	require.Equal(t, v1.ValidatorStateExitedUnslashed, tracker.Lifecycles()[6].Status)
+	require.Len(t, anomalies, 1)
+	advanceToEpoch(20)
+	require.NoError(t, tracker.Poll())
+	require.Len(t, anomalies, 2)
+	require.Equal(t, phase0.ValidatorIndex(5), anomalies[1].Index)
+	require.False(t, anomalies[1].Early())
+	require.Equal(t, v1.ValidatorStateExitedUnslashed, anomalies[1].Transition.To)
+	require.Equal(t, phase0.Epoch(20), anomalies[1].Epoch)
+	require.Len(t, tracker.Anomalies(), 2)
+}
+
+func TestLifecycleTracker_ActivationQueue(t *testing.T) {
+	_, client, validators := getMockConsensusClient(t)
+	validators = validators[:4]
+	manager := &ClientManager{ConsensusClients: map[string]*consensus_client.ConsensusClient{"mock": client}}
+	tracker := manager.NewLifecycleTracker(LifecycleTrackerOpts{Spec: &LifecycleSpec{
+		MaxSeedLookahead:      4,
+		MinPerEpochChurnLimit: 1,
+		ChurnLimitQuotient:    65536,
+	}})
+	tracker.Track(validators...)
+
+	state := make(map[phase0.ValidatorIndex]*v1.Validator)
+	for _, v := range validators {
+		clientView := consensus_client.NewMockValidator(v)
+		clientView.Status = v1.ValidatorStatePendingQueued
+		clientView.Validator.ActivationEligibilityEpoch = 1
+		clientView.Validator.ActivationEpoch = farFutureEpoch
+		state[clientView.Index] = clientView
+	}
+	tracker.Observe(2, state)
+
+	// with a churn of 1 the queue activates one validator per epoch, so the last one can't be activated with the first
+	for _, clientView := range state {
+		clientView.Validator.ActivationEpoch = 7 + phase0.Epoch(clientView.Index)
+	}
+	state[3].Validator.ActivationEpoch = 7
+	state[0].Status = v1.ValidatorStateActiveOngoing
+	state[3].Status = v1.ValidatorStateActiveOngoing
+	tracker.Observe(7, state)
+	anomalies := tracker.Anomalies()
+	require.Len(t, anomalies, 1)
+	require.Equal(t, phase0.ValidatorIndex(3), anomalies[0].Index)
+	require.True(t, anomalies[0].Early())
+	require.Equal(t, phase0.Epoch(10), anomalies[0].Earliest)
+	require.Equal(t, v1.ValidatorStatePendingQueued, tracker.Lifecycles()[2].Status)
+}
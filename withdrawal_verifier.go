@@ -0,0 +1,330 @@
+package eth_testnet_tool
+
+import (
+	"bytes"
+	"context"
+	"eth-testnet-tool/consensus_client"
+	"fmt"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"math/big"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrWithdrawalVerificationDeadline is returned when not every validator was seen withdrawing before the deadline
+	ErrWithdrawalVerificationDeadline = errors.New("withdrawals were not seen for every validator before the deadline")
+	// ErrWithdrawalVerificationFailed is returned when a withdrawal went to the wrong address or a balance didn't grow as expected
+	ErrWithdrawalVerificationFailed = errors.New("withdrawal verification failed")
+)
+
+// gweiToWei the factor between withdrawal amounts and execution balances
+var gweiToWei = big.NewInt(1_000_000_000)
+
+// WithdrawalVerifierOpts configures how withdrawals are verified
+type WithdrawalVerifierOpts struct {
+	// PollInterval how often the beacon state and new blocks are checked, defaults to the slot duration
+	PollInterval time.Duration
+	// Deadline how long to wait for the withdrawals before giving up
+	Deadline time.Duration
+	// Withdrawals the number of withdrawals to wait for per validator, defaults to 1
+	Withdrawals int
+}
+
+// ValidatorWithdrawals is what was seen of a validator after its bls to execution change
+type ValidatorWithdrawals struct {
+	Index   phase0.ValidatorIndex
+	Address bellatrix.ExecutionAddress
+	// CredentialsChangedAt when the validator was first seen with execution credentials to the address
+	CredentialsChangedAt time.Time
+	// Withdrawals the withdrawals of the validator in the execution payloads since the verification started
+	Withdrawals     []*capella.Withdrawal
+	WithdrawalSlots []phase0.Slot
+	// Err the validator got credentials to or withdrew to another address
+	Err error
+}
+
+// CredentialsChanged returns true if the validator was seen with execution credentials to the address
+func (w *ValidatorWithdrawals) CredentialsChanged() bool {
+	return !w.CredentialsChangedAt.IsZero()
+}
+
+// Withdrawn returns the sum of the withdrawals seen
+func (w *ValidatorWithdrawals) Withdrawn() phase0.Gwei {
+	var withdrawn phase0.Gwei
+	for _, withdrawal := range w.Withdrawals {
+		withdrawn += withdrawal.Amount
+	}
+	return withdrawn
+}
+
+func (w *ValidatorWithdrawals) String() string {
+	return fmt.Sprintf("validator %d: credentials changed: %s, withdrawals: %d (%d gwei)", w.Index, formatTimelineTime(w.CredentialsChangedAt), len(w.Withdrawals), w.Withdrawn())
+}
+
+// AddressBalanceCheck compares the balance growth of a withdrawal address on an execution client with the withdrawals seen
+type AddressBalanceCheck struct {
+	Client    string
+	Address   common.Address
+	FromBlock uint64
+	ToBlock   uint64
+	// ExpectedWei the sum of the withdrawals to the address between the blocks
+	ExpectedWei *big.Int
+	// ActualWei the balance at ToBlock minus the balance at FromBlock
+	ActualWei *big.Int
+	Err       error
+}
+
+// OK returns true if the balance grew by at least the withdrawn amount, other transfers to the address can make it grow more
+func (b *AddressBalanceCheck) OK() bool {
+	return b.Err == nil && b.ActualWei.Cmp(b.ExpectedWei) >= 0
+}
+
+func (b *AddressBalanceCheck) String() string {
+	return fmt.Sprintf("%s %s blocks %d-%d: expected %s wei, got %s wei", b.Client, b.Address, b.FromBlock, b.ToBlock, b.ExpectedWei, b.ActualWei)
+}
+
+// WithdrawalReport the result of verifying the withdrawals of rotated validators
+type WithdrawalReport struct {
+	// Client the consensus client the beacon state and blocks were read from
+	Client     string
+	Validators map[phase0.ValidatorIndex]*ValidatorWithdrawals
+	// BalanceChecks a check for every withdrawal address on every execution client
+	BalanceChecks []*AddressBalanceCheck
+}
+
+// Err returns ErrWithdrawalVerificationFailed with the first problem, or nil if everything checked out
+func (r *WithdrawalReport) Err() error {
+	indices := make([]phase0.ValidatorIndex, 0, len(r.Validators))
+	for index := range r.Validators {
+		indices = append(indices, index)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	for _, index := range indices {
+		if err := r.Validators[index].Err; err != nil {
+			return errors.Wrapf(ErrWithdrawalVerificationFailed, "validator %d: %v", index, err)
+		}
+	}
+	for _, check := range r.BalanceChecks {
+		if check.Err != nil {
+			return errors.Wrapf(ErrWithdrawalVerificationFailed, "%s %s: %v", check.Client, check.Address, check.Err)
+		}
+		if !check.OK() {
+			return errors.Wrap(ErrWithdrawalVerificationFailed, check.String())
+		}
+	}
+	return nil
+}
+
+// VerifyWithdrawals follows the validators of the bls to execution changes until their credentials switch to the execution address
+// and the withdrawal sweep pays out to it, then checks the balance of every address grew by the withdrawn amount on every execution client.
+// Only withdrawals in blocks after the current head are counted.
+func (c *ClientManager) VerifyWithdrawals(ctx context.Context, changes []*capella.SignedBLSToExecutionChange, opts WithdrawalVerifierOpts) (*WithdrawalReport, error) {
+	opts.PollInterval = c.pollIntervalOrDefault(opts.PollInterval)
+	if opts.Withdrawals <= 0 {
+		opts.Withdrawals = 1
+	}
+	client := c.GetRandomConsensusClient()
+	verifier := &withdrawalVerifier{
+		client: client,
+		opts:   opts,
+		report: &WithdrawalReport{Client: client.Name, Validators: make(map[phase0.ValidatorIndex]*ValidatorWithdrawals)},
+	}
+	for _, change := range changes {
+		verifier.report.Validators[change.Message.ValidatorIndex] = &ValidatorWithdrawals{
+			Index:   change.Message.ValidatorIndex,
+			Address: change.Message.ToExecutionAddress,
+		}
+	}
+	if err := verifier.start(ctx); err != nil {
+		return nil, err
+	}
+
+	pollCtx := ctx
+	if opts.Deadline > 0 {
+		var cancel context.CancelFunc
+		pollCtx, cancel = context.WithTimeout(ctx, opts.Deadline)
+		defer cancel()
+	}
+	complete := verifier.run(pollCtx)
+	verifier.checkBalances(ctx, c)
+	if !complete {
+		return verifier.report, ErrWithdrawalVerificationDeadline
+	}
+	return verifier.report, verifier.report.Err()
+}
+
+// withdrawalVerifier scans the beacon state and blocks of a single client
+type withdrawalVerifier struct {
+	client *consensus_client.ConsensusClient
+	opts   WithdrawalVerifierOpts
+	report *WithdrawalReport
+	// nextSlot the next slot to scan for withdrawals
+	nextSlot phase0.Slot
+	// fromBlock the execution block number of the head when the verification started, toBlock of the last block scanned
+	fromBlock uint64
+	toBlock   uint64
+}
+
+func (v *withdrawalVerifier) start(ctx context.Context) error {
+	head, err := v.client.GetSignedBeaconBlockContext(ctx, "head")
+	if err != nil {
+		return err
+	}
+	if head == nil {
+		return errors.New("client has no head block")
+	}
+	slot, err := head.Slot()
+	if err != nil {
+		return err
+	}
+	blockNumber, err := head.ExecutionBlockNumber()
+	if err != nil {
+		return errors.Wrap(err, "head block has no execution payload")
+	}
+	v.nextSlot = slot + 1
+	v.fromBlock = blockNumber
+	v.toBlock = blockNumber
+	return nil
+}
+
+// run polls until every validator is done or ctx is done, it returns true if every validator is done
+func (v *withdrawalVerifier) run(ctx context.Context) bool {
+	ticker := time.NewTicker(v.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		// errors polling are retried on the next tick until the deadline
+		_ = v.poll(ctx)
+		if v.complete() {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+func (v *withdrawalVerifier) complete() bool {
+	for _, validator := range v.report.Validators {
+		if validator.Err == nil && (!validator.CredentialsChanged() || len(validator.Withdrawals) < v.opts.Withdrawals) {
+			return false
+		}
+	}
+	return true
+}
+
+// poll checks the credentials of the validators that haven't changed yet and scans the new blocks for withdrawals
+func (v *withdrawalVerifier) poll(ctx context.Context) error {
+	now := time.Now()
+	pending := false
+	for _, validator := range v.report.Validators {
+		pending = pending || !validator.CredentialsChanged()
+	}
+	if pending {
+		clientValidators, err := v.client.GetAllValidatorsContext(ctx, "head")
+		if err != nil {
+			return err
+		}
+		for index, validator := range v.report.Validators {
+			clientValidator, ok := clientValidators[index]
+			if !ok || validator.CredentialsChanged() {
+				continue
+			}
+			credentials := clientValidator.Validator.WithdrawalCredentials
+			switch {
+			case bytes.Equal(credentials, ExecutionWithdrawalCredentials(validator.Address)):
+				validator.CredentialsChangedAt = now
+			case len(credentials) > 0 && credentials[0] != BLSWithdrawalPrefix:
+				validator.Err = fmt.Errorf("validator has execution withdrawal credentials %#x", credentials)
+			}
+		}
+	}
+
+	head, err := v.client.GetBlockHeaderContext(ctx, "head")
+	if err != nil {
+		return err
+	}
+	for ; v.nextSlot <= head.Header.Message.Slot; v.nextSlot++ {
+		block, err := v.client.GetSignedBeaconBlockContext(ctx, fmt.Sprintf("%d", v.nextSlot))
+		if err != nil {
+			return err
+		}
+		if block == nil {
+			continue
+		}
+		withdrawals, err := block.Withdrawals()
+		if err != nil {
+			return errors.Wrapf(err, "block at slot %d has no withdrawals", v.nextSlot)
+		}
+		blockNumber, err := block.ExecutionBlockNumber()
+		if err != nil {
+			return errors.Wrapf(err, "block at slot %d has no execution payload", v.nextSlot)
+		}
+		for _, withdrawal := range withdrawals {
+			validator, ok := v.report.Validators[withdrawal.ValidatorIndex]
+			if !ok {
+				continue
+			}
+			if withdrawal.Address != validator.Address {
+				validator.Err = fmt.Errorf("withdrawal %d at slot %d went to %s", withdrawal.Index, v.nextSlot, withdrawal.Address)
+			}
+			validator.Withdrawals = append(validator.Withdrawals, withdrawal)
+			validator.WithdrawalSlots = append(validator.WithdrawalSlots, v.nextSlot)
+		}
+		v.toBlock = blockNumber
+	}
+	return nil
+}
+
+// checkBalances compares the balance growth of every withdrawal address over the scanned blocks on every execution client
+func (v *withdrawalVerifier) checkBalances(ctx context.Context, c *ClientManager) {
+	expected := make(map[common.Address]*big.Int)
+	for _, validator := range v.report.Validators {
+		address := common.Address(validator.Address)
+		if expected[address] == nil {
+			expected[address] = new(big.Int)
+		}
+		for _, withdrawal := range validator.Withdrawals {
+			if withdrawal.Address == validator.Address {
+				expected[address].Add(expected[address], new(big.Int).Mul(new(big.Int).SetUint64(uint64(withdrawal.Amount)), gweiToWei))
+			}
+		}
+	}
+	addresses := make([]common.Address, 0, len(expected))
+	for address := range expected {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return bytes.Compare(addresses[i][:], addresses[j][:]) < 0 })
+
+	for _, name := range sortedKeys(c.ExecutionClients) {
+		executionClient := c.ExecutionClients[name]
+		for _, address := range addresses {
+			check := &AddressBalanceCheck{
+				Client:      name,
+				Address:     address,
+				FromBlock:   v.fromBlock,
+				ToBlock:     v.toBlock,
+				ExpectedWei: expected[address],
+				ActualWei:   new(big.Int),
+			}
+			v.report.BalanceChecks = append(v.report.BalanceChecks, check)
+			from, err := executionClient.EthClient.BalanceAt(ctx, address, new(big.Int).SetUint64(v.fromBlock))
+			if err != nil {
+				check.Err = errors.Wrapf(err, "failed to get balance at block %d", v.fromBlock)
+				continue
+			}
+			to, err := executionClient.EthClient.BalanceAt(ctx, address, new(big.Int).SetUint64(v.toBlock))
+			if err != nil {
+				check.Err = errors.Wrapf(err, "failed to get balance at block %d", v.toBlock)
+				continue
+			}
+			check.ActualWei.Sub(to, from)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package eth_testnet_tool
+
+import (
+	"context"
+	"encoding/json"
+	"eth-testnet-tool/consensus_client"
+	"eth-testnet-tool/execution_client"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newBalanceExecutionClient answers eth_getBalance from the balances by block number, missing balances are 0
+func newBalanceExecutionClient(t *testing.T, name string, balances map[uint64]map[common.Address]*big.Int) *execution_client.ExecutionClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params []string        `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "eth_getBalance", req.Method)
+		blockNumber, err := hexutil.DecodeUint64(req.Params[1])
+		require.NoError(t, err)
+		balance := new(big.Int)
+		if b := balances[blockNumber][common.HexToAddress(req.Params[0])]; b != nil {
+			balance = b
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": (*hexutil.Big)(balance)}))
+	}))
+	t.Cleanup(server.Close)
+	ethClient, err := ethclient.Dial(server.URL)
+	require.NoError(t, err)
+	return &execution_client.ExecutionClient{Name: name, JsonRPC: server.URL, EthClient: ethClient}
+}
+
+func TestVerifyWithdrawals_Mock(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	addressA, addressB := bellatrix.ExecutionAddress{0xaa}, bellatrix.ExecutionAddress{0xbb}
+	changeA, err := BuildSignedBLSToExecutionChangeFromClientView(client, validators[3], addressA)
+	require.NoError(t, err)
+	changeB, err := BuildSignedBLSToExecutionChangeFromClientView(client, validators[5], addressB)
+	require.NoError(t, err)
+
+	gwei := func(amount int64) *big.Int { return new(big.Int).Mul(big.NewInt(amount), gweiToWei) }
+	manager := &ClientManager{
+		ConsensusClients: map[string]*consensus_client.ConsensusClient{"mock": client},
+		ExecutionClients: map[string]*execution_client.ExecutionClient{
+			"geth": newBalanceExecutionClient(t, "geth", map[uint64]map[common.Address]*big.Int{
+				0: {common.Address(addressA): big.NewInt(10)},
+				1: {common.Address(addressA): new(big.Int).Add(gwei(1000), big.NewInt(10)), common.Address(addressB): gwei(2000)},
+			}),
+			"besu": newBalanceExecutionClient(t, "besu", map[uint64]map[common.Address]*big.Int{
+				1: {common.Address(addressA): gwei(1000), common.Address(addressB): gwei(1999)},
+			}),
+		},
+		SlotsPerEpoch: 8,
+	}
+
+	type verifyResult struct {
+		report *WithdrawalReport
+		err    error
+	}
+	result := make(chan verifyResult)
+	go func() {
+		report, err := manager.VerifyWithdrawals(context.Background(), []*capella.SignedBLSToExecutionChange{changeA, changeB}, WithdrawalVerifierOpts{PollInterval: 10 * time.Millisecond, Deadline: 5 * time.Second})
+		result <- verifyResult{report, err}
+	}()
+
+	// the credentials change, then the sweep pays out to both addresses and to a validator that isn't verified
+	time.Sleep(50 * time.Millisecond)
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.Validators[3].Validator.WithdrawalCredentials = ExecutionWithdrawalCredentials(addressA)
+		state.Validators[5].Validator.WithdrawalCredentials = ExecutionWithdrawalCredentials(addressB)
+	})
+	block := consensus_client.NewMockCapellaBlock(1, 2, phase0.Root{})
+	block.Capella.Message.Body.ExecutionPayload.Withdrawals = []*capella.Withdrawal{
+		{Index: 0, ValidatorIndex: 3, Address: addressA, Amount: 1000},
+		{Index: 1, ValidatorIndex: 5, Address: addressB, Amount: 2000},
+		{Index: 2, ValidatorIndex: 9, Address: bellatrix.ExecutionAddress{0x99}, Amount: 3000},
+	}
+	require.NoError(t, node.AddBlock(block))
+
+	verified := <-result
+	require.ErrorIs(t, verified.err, ErrWithdrawalVerificationFailed)
+	report := verified.report
+	require.Len(t, report.Validators, 2)
+	require.True(t, report.Validators[3].CredentialsChanged())
+	require.Equal(t, phase0.Gwei(1000), report.Validators[3].Withdrawn())
+	require.Equal(t, []phase0.Slot{1}, report.Validators[5].WithdrawalSlots)
+	require.NoError(t, report.Validators[5].Err)
+
+	require.Len(t, report.BalanceChecks, 4)
+	checks := make(map[string]*AddressBalanceCheck)
+	for _, check := range report.BalanceChecks {
+		require.Equal(t, uint64(0), check.FromBlock)
+		require.Equal(t, uint64(1), check.ToBlock)
+		checks[check.Client+" "+check.Address.Hex()] = check
+	}
+	require.True(t, checks["geth "+common.Address(addressA).Hex()].OK())
+	require.True(t, checks["geth "+common.Address(addressB).Hex()].OK())
+	require.True(t, checks["besu "+common.Address(addressA).Hex()].OK())
+	require.False(t, checks["besu "+common.Address(addressB).Hex()].OK())
+	require.Equal(t, gwei(1999), checks["besu "+common.Address(addressB).Hex()].ActualWei)
+}
+
+func TestVerifyWithdrawals_MockDeadline(t *testing.T) {
+	node, client, validators := getMockConsensusClient(t)
+	change, err := BuildSignedBLSToExecutionChangeFromClientView(client, validators[3], bellatrix.ExecutionAddress{0xaa})
+	require.NoError(t, err)
+	manager := &ClientManager{
+		ConsensusClients: map[string]*consensus_client.ConsensusClient{"mock": client},
+		ExecutionClients: map[string]*execution_client.ExecutionClient{},
+		SlotsPerEpoch:    8,
+	}
+
+	// the credentials change to another address and nothing is withdrawn
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.Validators[3].Validator.WithdrawalCredentials = ExecutionWithdrawalCredentials(bellatrix.ExecutionAddress{0xcc})
+	})
+	report, err := manager.VerifyWithdrawals(context.Background(), []*capella.SignedBLSToExecutionChange{change}, WithdrawalVerifierOpts{PollInterval: 10 * time.Millisecond, Deadline: 100 * time.Millisecond})
+	require.ErrorIs(t, err, ErrWithdrawalVerificationFailed)
+	require.False(t, report.Validators[3].CredentialsChanged())
+	require.Error(t, report.Validators[3].Err)
+
+	node.Update(func(state *consensus_client.MockBeaconState) {
+		state.Validators[3].Validator.WithdrawalCredentials = BLSWithdrawalCredentials(validators[3])
+	})
+	_, err = manager.VerifyWithdrawals(context.Background(), []*capella.SignedBLSToExecutionChange{change}, WithdrawalVerifierOpts{PollInterval: 10 * time.Millisecond, Deadline: 100 * time.Millisecond})
+	require.ErrorIs(t, err, ErrWithdrawalVerificationDeadline)
+}